@@ -322,12 +322,14 @@ class DashboardManager {
             const statusClass = {
                 'published': 'status-success',
                 'draft': 'status-warning',
+                'pending_review': 'status-warning',
                 'rejected': 'status-error'
             }[portfolio.status] || 'status-warning';
 
             const statusText = {
                 'published': '已发布',
                 'draft': '草稿',
+                'pending_review': '待审核',
                 'rejected': '已拒绝'
             }[portfolio.status] || '草稿';
 
@@ -757,12 +759,14 @@ class DashboardManager {
                     const statusClass = {
                         'published': 'status-success',
                         'draft': 'status-warning',
+                        'pending_review': 'status-warning',
                         'rejected': 'status-error'
                     }[portfolio.status] || 'status-warning';
 
                     const statusText = {
                         'published': '已发布',
-                        'draft': '待审核',
+                        'draft': '草稿',
+                        'pending_review': '待审核',
                         'rejected': '已拒绝'
                     }[portfolio.status] || '待审核';
 
@@ -786,7 +790,7 @@ class DashboardManager {
                                     <span class="status-badge ${statusClass}">${statusText}</span>
                                 </div>
                                 <div class="portfolio-actions">
-                                    ${portfolio.status === 'draft' ? `
+                                    ${portfolio.status === 'pending_review' ? `
                                         <button class="btn btn-small btn-success" onclick="dashboardManager.updatePortfolioStatus('${portfolio.id}', 'published')">
                                             <span>✅</span>
                                             <span>通过审核</span>
@@ -821,10 +825,19 @@ class DashboardManager {
 
     // 用户管理操作方法
     async updateUserStatus(userId, status) {
+        // 拒绝时必须填写原因
+        let reason = '';
+        if (status === 'rejected') {
+            reason = (prompt('请输入拒绝原因') || '').trim();
+            if (!reason) {
+                return;
+            }
+        }
+
         try {
             await apiClient.request(`/admin/users/${userId}`, {
                 method: 'PUT',
-                body: JSON.stringify({ status })
+                body: JSON.stringify({ status, reason })
             });
 
             NotificationManager.success(`用户状态已更新为: ${status === 'approved' ? '已通过' : '已拒绝'}`);
@@ -856,15 +869,25 @@ class DashboardManager {
 
     // 作品管理操作方法
     async updatePortfolioStatus(portfolioId, status) {
+        // 拒绝时必须填写原因
+        let reason = '';
+        if (status === 'rejected') {
+            reason = (prompt('请输入拒绝原因') || '').trim();
+            if (!reason) {
+                return;
+            }
+        }
+
         try {
             await apiClient.request(`/admin/portfolios/${portfolioId}`, {
                 method: 'PUT',
-                body: JSON.stringify({ status })
+                body: JSON.stringify({ status, reason })
             });
 
             const statusText = {
                 'published': '已发布',
                 'draft': '草稿',
+                'pending_review': '待审核',
                 'rejected': '已拒绝'
             }[status] || status;
 
@@ -947,6 +970,17 @@ class DashboardManager {
                 portfolioApprovalCheckbox.checked = settings.portfolioApprovalRequired;
             }
 
+            const stripUnsafeCheckbox = document.getElementById('stripUnsafeHTML');
+            const blockPublishCheckbox = document.getElementById('blockPublishOnFindings');
+
+            if (stripUnsafeCheckbox) {
+                stripUnsafeCheckbox.checked = settings.stripUnsafeHTML;
+            }
+
+            if (blockPublishCheckbox) {
+                blockPublishCheckbox.checked = settings.blockPublishOnFindings;
+            }
+
         } catch (error) {
             console.error('Failed to load admin settings:', error);
             NotificationManager.error('加载管理员设置失败');
@@ -957,10 +991,14 @@ class DashboardManager {
         try {
             const userApprovalRequired = document.getElementById('userApprovalRequired').checked;
             const portfolioApprovalRequired = document.getElementById('portfolioApprovalRequired').checked;
+            const stripUnsafeHTML = document.getElementById('stripUnsafeHTML').checked;
+            const blockPublishOnFindings = document.getElementById('blockPublishOnFindings').checked;
 
             const data = {
                 userApprovalRequired,
-                portfolioApprovalRequired
+                portfolioApprovalRequired,
+                stripUnsafeHTML,
+                blockPublishOnFindings
             };
 
             await apiClient.request('/admin/settings', {
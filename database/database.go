@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/utils"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -29,11 +30,26 @@ func InitDatabase() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	err = DB.AutoMigrate(&models.User{}, &models.Portfolio{}, &models.PortfolioVersion{}, &models.MinIOConfig{}, &models.FileObject{}, &models.AdminSettings{})
+	// 为已有版本补充语义化版本号，保证唯一索引可以创建
+	if err := migrateVersionNumbers(); err != nil {
+		log.Fatal("Failed to migrate version numbers:", err)
+	}
+
+	err = DB.AutoMigrate(&models.User{}, &models.Portfolio{}, &models.PortfolioVersion{}, &models.MinIOConfig{}, &models.FileObject{}, &models.AdminSettings{}, &models.ModerationDecision{}, &models.PortfolioCollaborator{}, &models.PortfolioTransfer{}, &models.Organization{}, &models.OrganizationMember{}, &models.ShareLink{}, &models.ContentBlob{}, &models.VersionAsset{}, &models.ThumbnailJob{}, &models.UploadSession{}, &models.UploadPart{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// 将旧版本表中的HTML内容迁移到内容寻址存储
+	if err := migrateVersionContent(); err != nil {
+		log.Fatal("Failed to migrate version content:", err)
+	}
+
+	// 将版本上的 is_active 标记迁移到 portfolios.active_version_id
+	if err := migrateActiveVersions(); err != nil {
+		log.Fatal("Failed to migrate active versions:", err)
+	}
+
 	// 确保存在默认管理员设置
 	if err := ensureDefaultAdminSettings(); err != nil {
 		log.Fatal("Failed to create default admin settings:", err)
@@ -86,3 +102,146 @@ func ensureDefaultAdminSettings() error {
 
 	return nil
 }
+
+// migrateVersionContent 把 portfolio_versions.html_content 中的内容迁移到 content_blobs，
+// 迁移完成后删除旧列
+func migrateVersionContent() error {
+	if !DB.Migrator().HasColumn(&models.PortfolioVersion{}, "html_content") {
+		return nil
+	}
+
+	type legacyVersion struct {
+		ID          string
+		HTMLContent string
+	}
+	var rows []legacyVersion
+	if err := DB.Table("portfolio_versions").Select("id, html_content").
+		Where("content_hash IS NULL OR content_hash = ''").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			hash, err := models.StoreContentBlob(tx, row.HTMLContent)
+			if err != nil {
+				return err
+			}
+			if err := tx.Table("portfolio_versions").Where("id = ?", row.ID).
+				Update("content_hash", hash).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Migrated %d portfolio versions to content-addressed storage", len(rows))
+	return DB.Migrator().DropColumn(&models.PortfolioVersion{}, "html_content")
+}
+
+// migrateVersionNumbers 将旧的 vX.Y 版本号转换为语义化版本号并去重，
+// 在创建 (portfolio_id, version) 唯一索引之前执行
+func migrateVersionNumbers() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.PortfolioVersion{}) || migrator.HasColumn(&models.PortfolioVersion{}, "branch") {
+		return nil
+	}
+	for _, field := range []string{"Branch", "Major", "Minor", "Patch"} {
+		if err := migrator.AddColumn(&models.PortfolioVersion{}, field); err != nil {
+			return err
+		}
+	}
+
+	type legacyVersion struct {
+		ID          string
+		PortfolioID string
+		Version     string
+	}
+	var rows []legacyVersion
+	if err := DB.Table("portfolio_versions").Select("id, portfolio_id, version").
+		Order("portfolio_id, created_at").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	type semver struct{ major, minor, patch int }
+	newer := func(a, b semver) bool {
+		if a.major != b.major {
+			return a.major > b.major
+		}
+		if a.minor != b.minor {
+			return a.minor > b.minor
+		}
+		return a.patch > b.patch
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		used := make(map[string]map[semver]bool)
+		highest := make(map[string]semver)
+		for _, row := range rows {
+			if used[row.PortfolioID] == nil {
+				used[row.PortfolioID] = make(map[semver]bool)
+			}
+
+			// 无法解析或重复的版本号在该作品最大版本号的基础上递增修订号
+			major, minor, patch, ok := utils.ParseSemver(row.Version)
+			number := semver{major, minor, patch}
+			if !ok || used[row.PortfolioID][number] {
+				top := highest[row.PortfolioID]
+				if top == (semver{}) {
+					top = semver{1, 0, 0}
+				} else {
+					top.patch++
+				}
+				for used[row.PortfolioID][top] {
+					top.patch++
+				}
+				number = top
+			}
+			used[row.PortfolioID][number] = true
+
+			if newer(number, highest[row.PortfolioID]) {
+				highest[row.PortfolioID] = number
+			}
+
+			if err := tx.Table("portfolio_versions").Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"branch":  utils.DefaultBranch,
+				"major":   number.major,
+				"minor":   number.minor,
+				"patch":   number.patch,
+				"version": utils.FormatVersion(number.major, number.minor, number.patch, utils.DefaultBranch),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("Migrated %d portfolio version numbers to semantic versions", len(rows))
+		return nil
+	})
+}
+
+// migrateActiveVersions 根据旧的 portfolio_versions.is_active 标记填充 portfolios.active_version_id，
+// 同一作品有多个活跃版本时取最近更新的一个，迁移完成后删除旧列
+func migrateActiveVersions() error {
+	if !DB.Migrator().HasColumn(&models.PortfolioVersion{}, "is_active") {
+		return nil
+	}
+
+	err := DB.Exec(`UPDATE portfolios SET active_version_id = (
+		SELECT v.id FROM portfolio_versions v
+		WHERE v.portfolio_id = portfolios.id AND v.is_active = true AND v.deleted_at IS NULL
+		ORDER BY v.updated_at DESC LIMIT 1
+	) WHERE (active_version_id IS NULL OR active_version_id = '') AND EXISTS (
+		SELECT 1 FROM portfolio_versions v
+		WHERE v.portfolio_id = portfolios.id AND v.is_active = true AND v.deleted_at IS NULL
+	)`).Error
+	if err != nil {
+		return err
+	}
+
+	if DB.Migrator().HasIndex(&models.PortfolioVersion{}, "idx_portfolio_versions_is_active") {
+		if err := DB.Migrator().DropIndex(&models.PortfolioVersion{}, "idx_portfolio_versions_is_active"); err != nil {
+			return err
+		}
+	}
+	return DB.Migrator().DropColumn(&models.PortfolioVersion{}, "is_active")
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oldweipro/design-ai/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMigrateVersionNumbers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+
+	// 旧版本表没有分支和语义化版本号列
+	if err := db.Exec(`CREATE TABLE portfolio_versions (
+		id TEXT PRIMARY KEY, portfolio_id TEXT, version TEXT, created_at DATETIME
+	)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	legacy := []struct{ id, portfolioID, version, createdAt string }{
+		{"a1", "p1", "v1.0", "2024-01-01"},
+		{"a2", "p1", "v1.1", "2024-01-02"},
+		{"a3", "p1", "v1.1", "2024-01-03"},
+		{"a4", "p1", "draft", "2024-01-04"},
+		{"a5", "p1", "v1.3", "2024-01-05"},
+		{"b1", "p2", "draft", "2024-01-01"},
+		{"b2", "p2", "v2", "2024-01-02"},
+		{"b3", "p2", "v1.0", "2024-01-03"},
+		{"c1", "p3", "v1.0", "2024-01-01"},
+	}
+	for _, row := range legacy {
+		if err := db.Exec("INSERT INTO portfolio_versions (id, portfolio_id, version, created_at) VALUES (?, ?, ?, ?)",
+			row.id, row.portfolioID, row.version, row.createdAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateVersionNumbers(); err != nil {
+		t.Fatalf("migrateVersionNumbers() error = %v", err)
+	}
+
+	want := map[string]string{
+		"a1": "v1.0.0",
+		"a2": "v1.1.0",
+		"a3": "v1.1.1", // 重复的版本号在最大版本号上递增修订号
+		"a4": "v1.1.2", // 无法解析的版本号同样递增
+		"a5": "v1.3.0",
+		"b1": "v1.0.0", // 作品的第一个版本无法解析时从 v1.0.0 开始
+		"b2": "v2.0.0",
+		"b3": "v2.0.1", // v1.0 已被占用
+		"c1": "v1.0.0",
+	}
+	type migrated struct {
+		ID      string
+		Version string
+		Branch  string
+		Major   int
+		Minor   int
+		Patch   int
+	}
+	var rows []migrated
+	if err := db.Table("portfolio_versions").Select("id, version, branch, major, minor, patch").Scan(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for _, row := range rows {
+		if row.Version != want[row.ID] {
+			t.Errorf("version of %s = %q, want %q", row.ID, row.Version, want[row.ID])
+		}
+		if row.Branch != "main" {
+			t.Errorf("branch of %s = %q, want main", row.ID, row.Branch)
+		}
+		if got := utils.FormatVersion(row.Major, row.Minor, row.Patch, row.Branch); got != row.Version {
+			t.Errorf("numbers of %s = %s, want %s", row.ID, got, row.Version)
+		}
+	}
+
+	// 已有分支列时不再重复迁移，已迁移的版本号保持不变
+	if err := migrateVersionNumbers(); err != nil {
+		t.Fatalf("second migrateVersionNumbers() error = %v", err)
+	}
+	var again []migrated
+	if err := db.Table("portfolio_versions").Select("id, version, branch, major, minor, patch").Scan(&again).Error; err != nil {
+		t.Fatal(err)
+	}
+	before := make(map[string]migrated, len(rows))
+	for _, row := range rows {
+		before[row.ID] = row
+	}
+	if len(again) != len(rows) {
+		t.Fatalf("got %d rows after the second run, want %d", len(again), len(rows))
+	}
+	for _, row := range again {
+		if row != before[row.ID] {
+			t.Errorf("second run changed %s from %+v to %+v", row.ID, before[row.ID], row)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -46,6 +47,51 @@ func UpdateAdminSettings(c *gin.Context) {
 		settings.PortfolioApprovalRequired = *req.PortfolioApprovalRequired
 	}
 
+	if req.TrashRetentionDays != nil {
+		settings.TrashRetentionDays = *req.TrashRetentionDays
+	}
+
+	if req.StripUnsafeHTML != nil {
+		settings.StripUnsafeHTML = *req.StripUnsafeHTML
+	}
+
+	if req.BlockPublishOnFindings != nil {
+		settings.BlockPublishOnFindings = *req.BlockPublishOnFindings
+	}
+
+	if req.TrustedScriptHosts != nil {
+		hostsJSON, _ := json.Marshal(req.TrustedScriptHosts)
+		settings.TrustedScriptHosts = string(hostsJSON)
+	}
+
+	if req.UploadSizeLimits != nil {
+		for key := range req.UploadSizeLimits {
+			if !models.ValidUploadLimitKey(key) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid content type in uploadSizeLimits: " + key})
+				return
+			}
+		}
+		limitsJSON, _ := json.Marshal(req.UploadSizeLimits)
+		settings.UploadSizeLimits = string(limitsJSON)
+	}
+
+	if req.UploadAllowedTypes != nil {
+		for purpose, types := range req.UploadAllowedTypes {
+			if purpose == "" || len(purpose) > 50 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purpose in uploadAllowedTypes: " + purpose})
+				return
+			}
+			for _, contentType := range types {
+				if !models.ValidUploadLimitKey(contentType) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid content type in uploadAllowedTypes: " + contentType})
+					return
+				}
+			}
+		}
+		allowedJSON, _ := json.Marshal(req.UploadAllowedTypes)
+		settings.UploadAllowedTypes = string(allowedJSON)
+	}
+
 	if err := db.Save(&settings).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update admin settings"})
 		return
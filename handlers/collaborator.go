@@ -0,0 +1,540 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"gorm.io/gorm"
+)
+
+// portfolioRole 获取用户在作品中的角色，无权限时返回空字符串
+func portfolioRole(db *gorm.DB, portfolio *models.Portfolio, userID string) string {
+	if portfolio == nil || userID == "" {
+		return ""
+	}
+	if portfolio.UserID == userID {
+		return models.PortfolioRoleOwner
+	}
+
+	var collaborator models.PortfolioCollaborator
+	if err := db.Where("portfolio_id = ? AND user_id = ?", portfolio.ID, userID).First(&collaborator).Error; err == nil {
+		return collaborator.Role
+	}
+
+	// 组织作品：组织所有者和管理员可以编辑，普通成员可以查看
+	if role := orgRole(db, portfolio.OrganizationID, userID); role != "" {
+		if isOrgManager(role) {
+			return models.PortfolioRoleEditor
+		}
+		return models.PortfolioRoleViewer
+	}
+	return ""
+}
+
+// canEditPortfolio 管理员、所有者和编辑者可以修改作品及其版本
+func canEditPortfolio(c *gin.Context, db *gorm.DB, portfolio *models.Portfolio) bool {
+	if middleware.IsAdmin(c) {
+		return true
+	}
+	userID, _ := middleware.GetCurrentUserID(c)
+	role := portfolioRole(db, portfolio, userID)
+	return role == models.PortfolioRoleOwner || role == models.PortfolioRoleEditor
+}
+
+// canViewPortfolio 已发布的公开或不公开列出的作品所有人可见，其余需要是管理员、作者、协作者或组织成员
+func canViewPortfolio(c *gin.Context, db *gorm.DB, portfolio *models.Portfolio) bool {
+	if portfolio == nil {
+		return false
+	}
+	if portfolio.Status == "published" && !portfolio.MembersOnly && portfolio.Visibility != models.VisibilityPrivate {
+		return true
+	}
+	if middleware.IsAdmin(c) {
+		return true
+	}
+	userID, _ := middleware.GetCurrentUserID(c)
+	return portfolioRole(db, portfolio, userID) != ""
+}
+
+// canManagePortfolio 只有管理员和所有者可以管理协作者、转移和删除作品
+func canManagePortfolio(c *gin.Context, portfolio *models.Portfolio) bool {
+	if middleware.IsAdmin(c) {
+		return true
+	}
+	userID, _ := middleware.GetCurrentUserID(c)
+	return portfolio != nil && portfolio.UserID == userID
+}
+
+// collaboratorPortfolioIDs 用户参与协作的作品ID子查询
+func collaboratorPortfolioIDs(db *gorm.DB, userID string) *gorm.DB {
+	return db.Model(&models.PortfolioCollaborator{}).Select("portfolio_id").Where("user_id = ?", userID)
+}
+
+// buildPortfolioAuthors 根据所有者和编辑者生成作者列表
+func buildPortfolioAuthors(portfolio models.Portfolio) []models.PortfolioAuthor {
+	authors := make([]models.PortfolioAuthor, 0, len(portfolio.Collaborators)+1)
+	if portfolio.User != nil {
+		authors = append(authors, models.PortfolioAuthor{
+			UserID: portfolio.User.ID,
+			Name:   portfolio.User.DisplayName(),
+			Avatar: portfolio.User.Avatar,
+			Role:   models.PortfolioRoleOwner,
+		})
+	} else if portfolio.Author != "" {
+		authors = append(authors, models.PortfolioAuthor{
+			UserID: portfolio.UserID,
+			Name:   portfolio.Author,
+			Role:   models.PortfolioRoleOwner,
+		})
+	}
+
+	for _, collaborator := range portfolio.Collaborators {
+		if collaborator.Role != models.PortfolioRoleEditor || collaborator.User == nil {
+			continue
+		}
+		authors = append(authors, models.PortfolioAuthor{
+			UserID: collaborator.UserID,
+			Name:   collaborator.User.DisplayName(),
+			Avatar: collaborator.User.Avatar,
+			Role:   collaborator.Role,
+		})
+	}
+	return authors
+}
+
+// refreshPortfolioAuthor 重新计算作品的作者字段（用于列表展示和搜索）
+func refreshPortfolioAuthor(tx *gorm.DB, portfolioID string) error {
+	var portfolio models.Portfolio
+	if err := tx.Preload("User").
+		Preload("Collaborators", "role = ?", models.PortfolioRoleEditor).
+		Preload("Collaborators.User").
+		Where("id = ?", portfolioID).
+		First(&portfolio).Error; err != nil {
+		return err
+	}
+
+	authors := buildPortfolioAuthors(portfolio)
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		names = append(names, author.Name)
+	}
+
+	return tx.Model(&models.Portfolio{}).Where("id = ?", portfolioID).
+		Update("author", strings.Join(names, ", ")).Error
+}
+
+// refreshUserPortfolioAuthors 用户昵称变更后刷新其参与的所有作品作者字段
+func refreshUserPortfolioAuthors(db *gorm.DB, userID string) error {
+	var portfolioIDs []string
+	if err := db.Model(&models.Portfolio{}).
+		Where("user_id = ? OR id IN (?)", userID, collaboratorPortfolioIDs(db, userID)).
+		Pluck("id", &portfolioIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range portfolioIDs {
+		if err := refreshPortfolioAuthor(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCollaborators 获取作品协作者列表
+func GetCollaborators(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	var portfolio models.Portfolio
+	if err := db.Preload("User").Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !middleware.IsAdmin(c) && portfolioRole(db, &portfolio, userID) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var collaborators []models.PortfolioCollaborator
+	if err := db.Preload("User").Where("portfolio_id = ?", portfolio.ID).
+		Order("created_at ASC").Find(&collaborators).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get collaborators"})
+		return
+	}
+
+	responses := make([]models.CollaboratorResponse, 0, len(collaborators)+1)
+	if portfolio.User != nil {
+		owner := portfolio.User.ToResponse()
+		responses = append(responses, models.CollaboratorResponse{
+			UserID:    portfolio.UserID,
+			Role:      models.PortfolioRoleOwner,
+			CreatedAt: portfolio.CreatedAt,
+			User:      &owner,
+		})
+	}
+	for _, collaborator := range collaborators {
+		responses = append(responses, collaborator.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// AddCollaborator 添加协作者
+func AddCollaborator(c *gin.Context) {
+	var req models.AddCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !canManagePortfolio(c, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	// 查找目标用户
+	var user models.User
+	userQuery := db.Where("status = ?", "approved")
+	switch {
+	case req.UserID != "":
+		userQuery = userQuery.Where("id = ?", req.UserID)
+	case req.Username != "":
+		userQuery = userQuery.Where("username = ?", req.Username)
+	case req.Email != "":
+		userQuery = userQuery.Where("email = ?", req.Email)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId, username or email is required"})
+		return
+	}
+	if err := userQuery.First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.ID == portfolio.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is already the owner"})
+		return
+	}
+
+	collaborator := models.PortfolioCollaborator{
+		PortfolioID: portfolio.ID,
+		UserID:      user.ID,
+		Role:        req.Role,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		tx.Model(&models.PortfolioCollaborator{}).
+			Where("portfolio_id = ? AND user_id = ?", portfolio.ID, user.ID).
+			Count(&existing)
+		if existing > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+		if err := tx.Create(&collaborator).Error; err != nil {
+			return err
+		}
+		return refreshPortfolioAuthor(tx, portfolio.ID)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already a collaborator"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add collaborator"})
+		return
+	}
+
+	collaborator.User = &user
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Collaborator added successfully",
+		"data":    collaborator.ToResponse(),
+	})
+}
+
+// UpdateCollaborator 修改协作者角色
+func UpdateCollaborator(c *gin.Context) {
+	var req models.UpdateCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !canManagePortfolio(c, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var collaborator models.PortfolioCollaborator
+	if err := db.Preload("User").
+		Where("portfolio_id = ? AND user_id = ?", portfolio.ID, c.Param("userId")).
+		First(&collaborator).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&collaborator).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return refreshPortfolioAuthor(tx, portfolio.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collaborator"})
+		return
+	}
+
+	collaborator.Role = req.Role
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Collaborator updated successfully",
+		"data":    collaborator.ToResponse(),
+	})
+}
+
+// RemoveCollaborator 移除协作者，协作者也可以主动退出
+func RemoveCollaborator(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	targetUserID := c.Param("userId")
+
+	db := database.GetDB()
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !canManagePortfolio(c, &portfolio) && targetUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var deleted int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("portfolio_id = ? AND user_id = ?", portfolio.ID, targetUserID).
+			Delete(&models.PortfolioCollaborator{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return refreshPortfolioAuthor(tx, portfolio.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove collaborator"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator removed successfully"})
+}
+
+// TransferPortfolio 发起作品所有权转移，接收人确认后生效
+func TransferPortfolio(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.TransferPortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !canManagePortfolio(c, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	if req.ToUserID == portfolio.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is already the owner"})
+		return
+	}
+
+	var recipient models.User
+	if err := db.Where("id = ? AND status = ?", req.ToUserID, "approved").First(&recipient).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipient not found"})
+		return
+	}
+
+	// 同一作品同时只能有一个待处理的转移请求
+	var pending int64
+	db.Model(&models.PortfolioTransfer{}).
+		Where("portfolio_id = ? AND status = ?", portfolio.ID, models.TransferStatusPending).
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A transfer is already pending for this portfolio"})
+		return
+	}
+
+	transfer := models.PortfolioTransfer{
+		PortfolioID: portfolio.ID,
+		FromUserID:  userID,
+		ToUserID:    recipient.ID,
+		Status:      models.TransferStatusPending,
+		Message:     req.Message,
+	}
+	if err := db.Create(&transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Transfer request created successfully",
+		"data":    transfer,
+	})
+}
+
+// GetMyTransfers 获取与当前用户相关的所有权转移请求
+func GetMyTransfers(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+	query := db.Preload("Portfolio").Preload("FromUser").Preload("ToUser")
+	switch c.Query("direction") {
+	case "incoming":
+		query = query.Where("to_user_id = ?", userID)
+	case "outgoing":
+		query = query.Where("from_user_id = ?", userID)
+	default:
+		query = query.Where("to_user_id = ? OR from_user_id = ?", userID, userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var transfers []models.PortfolioTransfer
+	if err := query.Order("created_at DESC").Find(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfers})
+}
+
+// AcceptTransfer 接收人确认所有权转移
+func AcceptTransfer(c *gin.Context) {
+	respondTransfer(c, models.TransferStatusAccepted)
+}
+
+// DeclineTransfer 接收人拒绝所有权转移
+func DeclineTransfer(c *gin.Context) {
+	respondTransfer(c, models.TransferStatusDeclined)
+}
+
+// CancelTransfer 发起人取消所有权转移
+func CancelTransfer(c *gin.Context) {
+	respondTransfer(c, models.TransferStatusCancelled)
+}
+
+// respondTransfer 处理转移请求的接受、拒绝和取消
+func respondTransfer(c *gin.Context, status string) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+	var transfer models.PortfolioTransfer
+	if err := db.Where("id = ? AND status = ?", c.Param("id"), models.TransferStatusPending).
+		First(&transfer).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+
+	// 接受和拒绝只能由接收人操作，取消只能由发起人或管理员操作
+	allowed := transfer.ToUserID == userID
+	if status == models.TransferStatusCancelled {
+		allowed = transfer.FromUserID == userID || middleware.IsAdmin(c)
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PortfolioTransfer{}).
+			Where("id = ? AND status = ?", transfer.ID, models.TransferStatusPending).
+			Updates(map[string]interface{}{"status": status, "responded_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if status != models.TransferStatusAccepted {
+			return nil
+		}
+
+		var portfolio models.Portfolio
+		if err := tx.Where("id = ?", transfer.PortfolioID).First(&portfolio).Error; err != nil {
+			return err
+		}
+		previousOwner := portfolio.UserID
+
+		if err := tx.Model(&portfolio).Update("user_id", transfer.ToUserID).Error; err != nil {
+			return err
+		}
+
+		// 新所有者不再是协作者，原所有者保留编辑权限
+		if err := tx.Where("portfolio_id = ? AND user_id IN ?", portfolio.ID, []string{transfer.ToUserID, previousOwner}).
+			Delete(&models.PortfolioCollaborator{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PortfolioCollaborator{
+			PortfolioID: portfolio.ID,
+			UserID:      previousOwner,
+			Role:        models.PortfolioRoleEditor,
+		}).Error; err != nil {
+			return err
+		}
+
+		return refreshPortfolioAuthor(tx, portfolio.ID)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transfer is no longer pending"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer " + status + " successfully"})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+)
+
+// CreateDirectUpload 签发直传存储桶的上传表单，文件内容不经过服务端。
+// 客户端上传完成后调用确认接口，未确认的文件在保留时长后被清理
+func CreateDirectUpload(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DirectUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if services.NewMinIOService().GetActiveConfig() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage is not configured"})
+		return
+	}
+
+	if err := services.CheckDeclaredUploadType(req.ContentType, req.Purpose); err != nil {
+		respondUploadTypeError(c, err, req.Purpose)
+		return
+	}
+
+	db := database.GetDB()
+	if limit := models.UploadSizeLimit(services.UploadSizeLimits(), req.ContentType); limit > 0 && req.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":       "File exceeds the maximum size for its type",
+			"contentType": req.ContentType,
+			"limit":       limit,
+		})
+		return
+	}
+	if !checkOrganizationUpload(c, db, req.OrganizationID, userID, req.Size) {
+		return
+	}
+
+	upload, err := services.DirectUploadSvc.Create(req, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create direct upload", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Direct upload created", "data": upload})
+}
+
+// CompleteDirectUpload 确认直传完成，核对存储桶中的对象后将文件标记为可用
+func CompleteDirectUpload(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileObject, err := services.DirectUploadSvc.Complete(c.Param("id"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDirectUploadNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pending upload not found"})
+		case errors.Is(err, services.ErrDirectUploadMissing):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUploadTypeMismatch), errors.Is(err, services.ErrUploadTypeNotAllowed):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUploadTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDirectUploadMismatch):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUploadStorageChanged):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Failed to confirm direct upload %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm upload", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "file": fileObject})
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+	"gorm.io/gorm"
+)
+
+// GetFileImage 按需缩放图片并转换格式，支持 w、h、fit=contain|cover|fill（默认 contain）和
+// format=jpeg|png|webp（默认与原图相同）参数。宽高只能取允许的尺寸，结果缓存在文件存储中。
+// 非公开图片的访问权限见 canAccessFile
+func GetFileImage(c *gin.Context) {
+	options := services.ImageResizeOptions{
+		Fit:    strings.ToLower(c.DefaultQuery("fit", services.ImageFitContain)),
+		Format: strings.ToLower(c.Query("format")),
+	}
+	if options.Format == "jpg" {
+		options.Format = "jpeg"
+	}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"w", &options.Width}, {"h", &options.Height}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param.name + " must be a positive integer"})
+			return
+		}
+		*param.value = n
+	}
+	if err := options.Validate(); err != nil {
+		if errors.Is(err, services.ErrImageDimensionNotAllowed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed": services.AllowedImageDimensions})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 派生文件按其原图检查权限
+	db := database.GetDB()
+	var source models.FileObject
+	if err := db.Where("id = ? AND pending = ?", c.Param("id"), false).First(&source).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if source.SourceID != "" {
+		if err := db.Where("id = ?", source.SourceID).First(&source).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+	}
+	if !canAccessFile(c, db, &source) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	if services.NewMinIOService().GetActiveConfig() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage is not configured"})
+		return
+	}
+
+	reader, fileObject, err := services.ImageSvc.Resize(source.ID, options)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrImageFileNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		case errors.Is(err, services.ErrNotImage):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is not a supported image"})
+		default:
+			log.Printf("Failed to resize image %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to process image"})
+		}
+		return
+	}
+	defer reader.Close()
+
+	// 缩放结果保存后不再变化，可以长期缓存
+	if source.IsPublic {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "private, max-age=31536000, immutable")
+		c.Header("Vary", "Authorization")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	etag := `"` + fileObject.ID + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, fileObject.FileSize, fileObject.ContentType, reader, nil)
+}
+
+// canAccessFile 公开文件任何人可以访问；非公开文件只有管理员、上传者、所属组织成员，
+// 以及可以查看引用它（封面图片、版本缩略图或版本资源文件）的作品的用户可以访问
+func canAccessFile(c *gin.Context, db *gorm.DB, file *models.FileObject) bool {
+	if file.IsPublic || middleware.IsAdmin(c) {
+		return true
+	}
+	if userID, ok := middleware.GetCurrentUserID(c); ok {
+		if file.UploadedBy == userID || orgRole(db, file.OrganizationID, userID) != "" {
+			return true
+		}
+	}
+
+	var portfolios []models.Portfolio
+	if err := db.Where("image_object_id = ?", file.ID).
+		Or("id IN (?)", db.Model(&models.PortfolioVersion{}).Select("portfolio_id").Where("thumbnail_object_id = ?", file.ID)).
+		Or("id IN (?)", db.Model(&models.PortfolioVersion{}).Select("portfolio_id").
+			Where("id IN (?)", db.Model(&models.VersionAsset{}).Select("version_id").Where("file_object_id = ?", file.ID))).
+		Find(&portfolios).Error; err != nil {
+		log.Printf("Failed to find portfolios referencing file %s: %v", file.ID, err)
+		return false
+	}
+	for i := range portfolios {
+		if canViewPortfolio(c, db, &portfolios[i]) {
+			return true
+		}
+	}
+	return false
+}
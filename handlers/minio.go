@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/oldweipro/design-ai/middleware"
 	"github.com/oldweipro/design-ai/models"
 	"github.com/oldweipro/design-ai/services"
+	"gorm.io/gorm"
 )
 
 // CreateMinIOConfig 创建MinIO配置
@@ -264,13 +266,35 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
+	// 按 Content-Length 提前拒绝超过所有类型上限的请求，不接收请求体
+	limits := services.UploadSizeLimits()
+	maxSize := models.MaxUploadSize(limits)
+	if !limitUploadBody(c, maxSize) {
+		return
+	}
+
 	// 获取上传的文件
 	file, err := c.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "limit": maxSize})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded", "details": err.Error()})
 		return
 	}
 
+	// 按文件类型检查大小上限
+	if limit := models.UploadSizeLimit(limits, file.Header.Get("Content-Type")); limit > 0 && file.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":       "File exceeds the maximum size for its type",
+			"contentType": file.Header.Get("Content-Type"),
+			"limit":       limit,
+		})
+		return
+	}
+
 	// 获取参数
 	isPublic := c.PostForm("is_public") == "true"
 	tags := make(map[string]string)
@@ -283,10 +307,19 @@ func UploadFile(c *gin.Context) {
 		tags["purpose"] = purpose
 	}
 
+	// 以组织名义上传：需要是组织成员，且不能超过组织存储配额
+	organizationID := c.PostForm("organization_id")
+	if !checkOrganizationUpload(c, database.GetDB(), organizationID, userID, file.Size) {
+		return
+	}
+
 	// 上传文件
 	minioService := services.NewMinIOService()
-	fileObject, err := minioService.UploadFile(file, userID, isPublic, tags)
+	fileObject, err := minioService.UploadFile(file, userID, organizationID, isPublic, tags)
 	if err != nil {
+		if respondUploadTypeError(c, err, tags["purpose"]) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file", "details": err.Error()})
 		return
 	}
@@ -294,6 +327,70 @@ func UploadFile(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "File uploaded successfully", "file": fileObject})
 }
 
+// checkOrganizationUpload 以组织名义上传时检查用户是否为组织成员，以及上传后是否超过组织存储配额。
+// 个人文件不做检查，返回 false 时已写入响应
+func checkOrganizationUpload(c *gin.Context, db *gorm.DB, organizationID, userID string, size int64) bool {
+	if organizationID == "" {
+		return true
+	}
+	var org models.Organization
+	if err := db.Where("id = ?", organizationID).First(&org).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return false
+	}
+	if orgRole(db, org.ID, userID) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of the organization"})
+		return false
+	}
+	if org.StorageQuota > 0 {
+		used, _, err := services.OrganizationStorageUsage(db, org.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate storage usage"})
+			return false
+		}
+		if used+size > org.StorageQuota {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Organization storage quota exceeded",
+				"used":  used,
+				"quota": org.StorageQuota,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// respondUploadTypeError 文件类型校验失败时返回 415 和该用途允许的类型，超过实际类型的大小上限时返回 413，
+// 其他错误返回 false
+func respondUploadTypeError(c *gin.Context, err error, purpose string) bool {
+	if errors.Is(err, services.ErrUploadTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return true
+	}
+	if !errors.Is(err, services.ErrUploadTypeMismatch) && !errors.Is(err, services.ErrUploadTypeNotAllowed) {
+		return false
+	}
+	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error(), "allowed": services.AllowedUploadTypes(purpose)})
+	return true
+}
+
+// uploadBodyOverhead 请求体中表单字段和分隔符的余量
+const uploadBodyOverhead = 1024 * 1024
+
+// limitUploadBody 按 Content-Length 提前拒绝超过上限的上传请求，并限制实际读取的请求体大小，
+// 防止不带 Content-Length 的请求绕过检查。limit 为 0 表示不限制，返回 false 时已写入响应
+func limitUploadBody(c *gin.Context, limit int64) bool {
+	if limit <= 0 {
+		return true
+	}
+	if c.Request.ContentLength > limit+uploadBodyOverhead {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "limit": limit})
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+uploadBodyOverhead)
+	return true
+}
+
 // GetFileURL 获取文件URL
 func GetFileURL(c *gin.Context) {
 	objectID := c.Param("id")
@@ -339,12 +436,16 @@ func GetFiles(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 	offset := (page - 1) * pageSize
 
-	query := db.Preload("User").Preload("Config")
+	// 派生文件（图片缩放版本）通过原始文件的元数据访问，不单独列出；未确认的直传文件不列出
+	query := db.Preload("User").Preload("Config").Where("source_id IS NULL OR source_id = ''").Where("pending = ?", false)
 
 	// 过滤参数
 	if userID := c.Query("user_id"); userID != "" {
 		query = query.Where("uploaded_by = ?", userID)
 	}
+	if organizationID := c.Query("organization_id"); organizationID != "" {
+		query = query.Where("organization_id = ?", organizationID)
+	}
 	if contentType := c.Query("content_type"); contentType != "" {
 		query = query.Where("content_type LIKE ?", "%"+contentType+"%")
 	}
@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"gorm.io/gorm"
+)
+
+var (
+	errReviewNotInQueue    = errors.New("item is not waiting for review")
+	errReviewClaimedByPeer = errors.New("item is claimed by another reviewer")
+)
+
+// reviewTarget 描述一种审核对象对应的表和状态
+type reviewTarget struct {
+	model         interface{}
+	pendingStatus string
+	statusFor     map[string]string // 审核决定 -> 目标状态
+}
+
+var reviewTargets = map[string]reviewTarget{
+	models.ReviewTargetPortfolio: {
+		model:         &models.Portfolio{},
+		pendingStatus: "pending_review",
+		statusFor: map[string]string{
+			models.ReviewDecisionApproved: "published",
+			models.ReviewDecisionRejected: "rejected",
+		},
+	},
+	models.ReviewTargetUser: {
+		model:         &models.User{},
+		pendingStatus: "pending",
+		statusFor: map[string]string{
+			models.ReviewDecisionApproved: "approved",
+			models.ReviewDecisionRejected: "rejected",
+		},
+	},
+}
+
+// GetReviewQueue 获取审核队列，按提交时间从早到晚排序
+func GetReviewQueue(c *gin.Context) {
+	var query models.ReviewQueueQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	reviewerID, _ := middleware.GetCurrentUserID(c)
+	db := database.GetDB()
+	offset := (query.Page - 1) * query.PageSize
+	// 合并多种类型时，每种类型都需要取到 offset+pageSize 条才能正确分页
+	limit := offset + query.PageSize
+
+	var items []models.ReviewQueueItem
+	var total int64
+
+	if query.Type == "" || query.Type == models.ReviewTargetPortfolio {
+		dbQuery := db.Model(&models.Portfolio{}).Where("status = ?", reviewTargets[models.ReviewTargetPortfolio].pendingStatus)
+		if query.Mine {
+			dbQuery = dbQuery.Where("reviewer_id = ?", reviewerID)
+		}
+
+		var count int64
+		dbQuery.Count(&count)
+		total += count
+
+		var portfolios []models.Portfolio
+		if err := dbQuery.Preload("User").Order("created_at ASC").Limit(limit).Find(&portfolios).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review queue"})
+			return
+		}
+		for _, portfolio := range portfolios {
+			item := models.ReviewQueueItem{
+				Type:        models.ReviewTargetPortfolio,
+				ID:          portfolio.ID,
+				Title:       portfolio.Title,
+				OwnerID:     portfolio.UserID,
+				SubmittedAt: portfolio.CreatedAt,
+			}
+			if claimActive(portfolio.ReviewerID, portfolio.ReviewClaimedAt) {
+				item.ReviewerID = portfolio.ReviewerID
+				item.ReviewClaimedAt = portfolio.ReviewClaimedAt
+			}
+			if portfolio.User != nil {
+				owner := portfolio.User.ToResponse()
+				item.Owner = &owner
+			}
+			items = append(items, item)
+		}
+	}
+
+	if query.Type == "" || query.Type == models.ReviewTargetUser {
+		dbQuery := db.Model(&models.User{}).Where("status = ?", reviewTargets[models.ReviewTargetUser].pendingStatus)
+		if query.Mine {
+			dbQuery = dbQuery.Where("reviewer_id = ?", reviewerID)
+		}
+
+		var count int64
+		dbQuery.Count(&count)
+		total += count
+
+		var users []models.User
+		if err := dbQuery.Order("created_at ASC").Limit(limit).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review queue"})
+			return
+		}
+		for _, user := range users {
+			item := models.ReviewQueueItem{
+				Type:        models.ReviewTargetUser,
+				ID:          user.ID,
+				Title:       user.Username,
+				OwnerID:     user.ID,
+				SubmittedAt: user.CreatedAt,
+			}
+			if claimActive(user.ReviewerID, user.ReviewClaimedAt) {
+				item.ReviewerID = user.ReviewerID
+				item.ReviewClaimedAt = user.ReviewClaimedAt
+			}
+			owner := user.ToResponse()
+			item.Owner = &owner
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].SubmittedAt.Before(items[j].SubmittedAt)
+	})
+
+	// 分页
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + query.PageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	page := items[offset:end]
+
+	now := time.Now()
+	for i := range page {
+		page[i].WaitingSeconds = int64(now.Sub(page[i].SubmittedAt).Seconds())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        page,
+		"total":       total,
+		"page":        query.Page,
+		"page_size":   query.PageSize,
+		"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+	})
+}
+
+// ClaimReviewItem 认领审核条目
+func ClaimReviewItem(c *gin.Context) {
+	targetType := c.Param("type")
+	target, ok := reviewTargets[targetType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review type"})
+		return
+	}
+
+	reviewerID, _ := middleware.GetCurrentUserID(c)
+	now := time.Now()
+
+	db := database.GetDB()
+	result := db.Model(target.model).
+		Where("id = ? AND status = ?", c.Param("id"), target.pendingStatus).
+		Where("(reviewer_id IS NULL OR reviewer_id = '' OR reviewer_id = ? OR review_claimed_at IS NULL OR review_claimed_at < ?)",
+			reviewerID, now.Add(-models.ReviewClaimTTL)).
+		Updates(map[string]interface{}{
+			"reviewer_id":       reviewerID,
+			"review_claimed_at": now,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim item"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Item is not in the review queue or already claimed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Item claimed successfully",
+		"claimedAt": now,
+		"expiresAt": now.Add(models.ReviewClaimTTL),
+	})
+}
+
+// ReleaseReviewItem 释放自己认领的审核条目
+func ReleaseReviewItem(c *gin.Context) {
+	targetType := c.Param("type")
+	target, ok := reviewTargets[targetType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review type"})
+		return
+	}
+
+	reviewerID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	result := db.Model(target.model).
+		Where("id = ? AND reviewer_id = ?", c.Param("id"), reviewerID).
+		Updates(map[string]interface{}{
+			"reviewer_id":       "",
+			"review_claimed_at": nil,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release item"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item is not claimed by you"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item released successfully"})
+}
+
+// DecideReviewItem 审核单个条目
+func DecideReviewItem(c *gin.Context) {
+	targetType := c.Param("type")
+	if _, ok := reviewTargets[targetType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review type"})
+		return
+	}
+
+	var req models.ReviewDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviewerID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return applyReviewDecision(tx, targetType, c.Param("id"), reviewerID, req.Decision, req.Reason)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		case errors.Is(err, errReviewNotInQueue), errors.Is(err, errReviewClaimedByPeer):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, errSecurityFindings):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": portfolioFindings(db, c.Param("id"))})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review decision"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review decision saved successfully"})
+}
+
+// BulkDecideReviewItems 批量审核
+func BulkDecideReviewItems(c *gin.Context) {
+	var req models.BulkReviewDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviewerID, _ := middleware.GetCurrentUserID(c)
+	db := database.GetDB()
+
+	results := make([]gin.H, 0, len(req.IDs))
+	succeeded := 0
+	for _, id := range req.IDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return applyReviewDecision(tx, req.Type, id, reviewerID, req.Decision, req.Reason)
+		})
+		if err != nil {
+			message := err.Error()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				message = "item not found"
+			}
+			results = append(results, gin.H{"id": id, "success": false, "error": message})
+			continue
+		}
+		succeeded++
+		results = append(results, gin.H{"id": id, "success": true})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Bulk review completed",
+		"succeeded": succeeded,
+		"failed":    len(req.IDs) - succeeded,
+		"results":   results,
+	})
+}
+
+// GetReviewStats 获取审核队列深度和审核耗时统计
+func GetReviewStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+	staleBefore := now.Add(-models.ReviewClaimTTL)
+
+	queue := gin.H{}
+	for targetType, target := range reviewTargets {
+		var depth, claimed int64
+		db.Model(target.model).Where("status = ?", target.pendingStatus).Count(&depth)
+		db.Model(target.model).
+			Where("status = ? AND reviewer_id <> '' AND review_claimed_at >= ?", target.pendingStatus, staleBefore).
+			Count(&claimed)
+
+		var oldest struct{ CreatedAt time.Time }
+		oldestWaiting := int64(0)
+		if depth > 0 {
+			if err := db.Model(target.model).Select("created_at").
+				Where("status = ?", target.pendingStatus).
+				Order("created_at ASC").Limit(1).Scan(&oldest).Error; err == nil {
+				oldestWaiting = int64(now.Sub(oldest.CreatedAt).Seconds())
+			}
+		}
+
+		queue[targetType] = gin.H{
+			"depth":                depth,
+			"claimed":              claimed,
+			"oldestWaitingSeconds": oldestWaiting,
+		}
+	}
+
+	var decisions []models.ModerationDecision
+	if err := db.Where("created_at >= ?", now.AddDate(0, 0, -days)).Find(&decisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review decisions"})
+		return
+	}
+
+	durations := make([]float64, 0, len(decisions))
+	approved, rejected := 0, 0
+	for _, decision := range decisions {
+		durations = append(durations, decision.CreatedAt.Sub(decision.SubmittedAt).Seconds())
+		if decision.Decision == models.ReviewDecisionApproved {
+			approved++
+		} else {
+			rejected++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"queue": queue,
+			"decisions": gin.H{
+				"days":     days,
+				"total":    len(decisions),
+				"approved": approved,
+				"rejected": rejected,
+			},
+			"timeToDecisionSeconds": durationStats(durations),
+		},
+	})
+}
+
+// reviewState 审核对象当前的状态和认领信息
+type reviewState struct {
+	Status          string
+	ReviewerID      string
+	ReviewClaimedAt *time.Time
+	CreatedAt       time.Time
+}
+
+// loadReviewState 读取审核对象的状态，对象不存在时返回 gorm.ErrRecordNotFound
+func loadReviewState(tx *gorm.DB, target reviewTarget, id string) (*reviewState, error) {
+	var current reviewState
+	result := tx.Model(target.model).
+		Select("status, reviewer_id, review_claimed_at, created_at").
+		Where("id = ?", id).
+		Limit(1).
+		Scan(&current)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &current, nil
+}
+
+// applyReviewDecision 在事务中应用审核决定并记录审核日志，只处理仍在审核队列中的条目
+func applyReviewDecision(tx *gorm.DB, targetType, id, reviewerID, decision, reason string) error {
+	target := reviewTargets[targetType]
+	current, err := loadReviewState(tx, target, id)
+	if err != nil {
+		return err
+	}
+	if current.Status != target.pendingStatus {
+		return errReviewNotInQueue
+	}
+	return commitReviewDecision(tx, targetType, id, reviewerID, decision, reason, current, current.CreatedAt)
+}
+
+// setReviewStatus 管理员直接设置审核结果，除队列中的条目外也可以处理已审核的条目，如下架已发布的作品。
+// 与审核队列一样检查认领并记录审核决定；已审核的条目没有在队列中等待，提交时间按当前时间记录
+func setReviewStatus(tx *gorm.DB, targetType, id, reviewerID, decision, reason string) error {
+	target := reviewTargets[targetType]
+	current, err := loadReviewState(tx, target, id)
+	if err != nil {
+		return err
+	}
+	// 回收站中的作品只能通过恢复接口恢复
+	if current.Status == "deleted" {
+		return gorm.ErrRecordNotFound
+	}
+	submittedAt := time.Now()
+	if current.Status == target.pendingStatus {
+		submittedAt = current.CreatedAt
+	}
+	return commitReviewDecision(tx, targetType, id, reviewerID, decision, reason, current, submittedAt)
+}
+
+// commitReviewDecision 检查认领后更新审核对象的状态，作品通过时发布其版本，并记录审核决定
+func commitReviewDecision(tx *gorm.DB, targetType, id, reviewerID, decision, reason string, current *reviewState, submittedAt time.Time) error {
+	target := reviewTargets[targetType]
+	if current.ReviewerID != reviewerID && claimActive(current.ReviewerID, current.ReviewClaimedAt) {
+		return errReviewClaimedByPeer
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            target.statusFor[decision],
+		"reviewer_id":       "",
+		"review_claimed_at": nil,
+		"reviewed_at":       now,
+		"rejection_reason":  "",
+	}
+	if decision == models.ReviewDecisionRejected {
+		updates["rejection_reason"] = reason
+	}
+
+	// 以原状态作为条件，防止并发审核同一条目
+	update := tx.Model(target.model).Where("id = ? AND status = ?", id, current.Status).Updates(updates)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return errReviewNotInQueue
+	}
+
+	// 作品通过审核后，其版本成为不可修改的快照
+	if targetType == models.ReviewTargetPortfolio && target.statusFor[decision] == "published" {
+		if err := publishVersions(tx, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&models.ModerationDecision{
+		TargetType:  targetType,
+		TargetID:    id,
+		ReviewerID:  reviewerID,
+		Decision:    decision,
+		Reason:      reason,
+		SubmittedAt: submittedAt,
+	}).Error
+}
+
+// reviewStatusError 将管理员直接设置审核结果时的错误转换为响应
+func reviewStatusError(c *gin.Context, db *gorm.DB, err error, notFound string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, errReviewNotInQueue), errors.Is(err, errReviewClaimedByPeer):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errSecurityFindings):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": portfolioFindings(db, c.Param("id"))})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review decision"})
+	}
+}
+
+// claimActive 判断认领是否仍然有效
+func claimActive(reviewerID string, claimedAt *time.Time) bool {
+	return reviewerID != "" && claimedAt != nil && time.Since(*claimedAt) < models.ReviewClaimTTL
+}
+
+// durationStats 计算耗时统计（平均值、中位数、P90、最大值）
+func durationStats(values []float64) gin.H {
+	if len(values) == 0 {
+		return gin.H{"avg": 0, "median": 0, "p90": 0, "max": 0}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	percentile := func(p float64) float64 {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+
+	return gin.H{
+		"avg":    int64(sum / float64(len(sorted))),
+		"median": int64(percentile(0.5)),
+		"p90":    int64(percentile(0.9)),
+		"max":    int64(sorted[len(sorted)-1]),
+	}
+}
@@ -0,0 +1,640 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+	"gorm.io/gorm"
+)
+
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]$`)
+
+var errLastOrgOwner = errors.New("organization must have at least one owner")
+
+// orgRole 获取用户在组织中的角色，非成员返回空字符串
+func orgRole(db *gorm.DB, orgID, userID string) string {
+	if orgID == "" || userID == "" {
+		return ""
+	}
+	var member models.OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// isOrgManager 组织所有者和管理员可以管理组织及其作品
+func isOrgManager(role string) bool {
+	return role == models.OrgRoleOwner || role == models.OrgRoleAdmin
+}
+
+// userOrganizationIDs 用户所属组织ID子查询
+func userOrganizationIDs(db *gorm.DB, userID string) *gorm.DB {
+	return db.Model(&models.OrganizationMember{}).Select("organization_id").Where("user_id = ?", userID)
+}
+
+// findOrganization 根据slug或ID查找组织
+func findOrganization(db *gorm.DB, slugOrID string) (*models.Organization, error) {
+	var org models.Organization
+	if err := db.Where("slug = ? OR id = ?", slugOrID, slugOrID).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// buildOrganizationResponse 构建组织响应数据
+func buildOrganizationResponse(db *gorm.DB, org *models.Organization, userID string) models.OrganizationResponse {
+	response := models.OrganizationResponse{
+		ID:          org.ID,
+		Slug:        org.Slug,
+		Name:        org.Name,
+		Description: org.Description,
+		Avatar:      org.Avatar,
+		Website:     org.Website,
+		Role:        orgRole(db, org.ID, userID),
+		CreatedAt:   org.CreatedAt,
+	}
+
+	db.Model(&models.OrganizationMember{}).Where("organization_id = ?", org.ID).Count(&response.MemberCount)
+
+	portfolioQuery := db.Model(&models.Portfolio{}).Where("organization_id = ? AND status = ?", org.ID, "published")
+	if response.Role == "" {
+		portfolioQuery = portfolioQuery.Where("members_only = ? AND visibility = ?", false, models.VisibilityPublic)
+	}
+	portfolioQuery.Count(&response.PortfolioCount)
+
+	return response
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slug := strings.ToLower(req.Slug)
+	if !orgSlugPattern.MatchString(slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug may only contain lowercase letters, digits and hyphens"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var existing int64
+	db.Model(&models.Organization{}).Where("slug = ?", slug).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization slug already exists"})
+		return
+	}
+
+	org := models.Organization{
+		Slug:        slug,
+		Name:        req.Name,
+		Description: req.Description,
+		Avatar:      req.Avatar,
+		Website:     req.Website,
+		CreatedBy:   userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         userID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Organization created successfully",
+		"data":    buildOrganizationResponse(db, &org, userID),
+	})
+}
+
+// GetMyOrganizations 获取当前用户所属的组织
+func GetMyOrganizations(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+	var orgs []models.Organization
+	if err := db.Where("id IN (?)", userOrganizationIDs(db, userID)).
+		Order("name ASC").Find(&orgs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organizations"})
+		return
+	}
+
+	responses := make([]models.OrganizationResponse, 0, len(orgs))
+	for i := range orgs {
+		responses = append(responses, buildOrganizationResponse(db, &orgs[i], userID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// GetOrganization 获取组织公开资料
+func GetOrganization(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": buildOrganizationResponse(db, org, userID)})
+}
+
+// UpdateOrganization 更新组织资料
+func UpdateOrganization(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	if !middleware.IsAdmin(c) && !isOrgManager(orgRole(db, org.ID, userID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	if req.Name != "" {
+		org.Name = req.Name
+	}
+	if req.Description != "" {
+		org.Description = req.Description
+	}
+	if req.Avatar != "" {
+		org.Avatar = req.Avatar
+	}
+	if req.Website != "" {
+		org.Website = req.Website
+	}
+
+	if err := db.Save(org).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Organization updated successfully",
+		"data":    buildOrganizationResponse(db, org, userID),
+	})
+}
+
+// DeleteOrganization 删除组织，组织的作品和文件归还给各自的创建者，仅成员可见的作品改为私有
+func DeleteOrganization(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	if !middleware.IsAdmin(c) && orgRole(db, org.ID, userID) != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 仅成员可见的作品在组织删除后改为私有，不扩大可见范围
+		if err := tx.Model(&models.Portfolio{}).Where("organization_id = ? AND members_only = ?", org.ID, true).
+			Updates(map[string]interface{}{"visibility": models.VisibilityPrivate, "members_only": false}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Portfolio{}).Where("organization_id = ?", org.ID).
+			Update("organization_id", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.FileObject{}).Where("organization_id = ?", org.ID).
+			Update("organization_id", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", org.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(org).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// GetOrganizationPortfolios 获取组织作品列表，成员可以看到仅成员可见的作品
+func GetOrganizationPortfolios(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var query models.PortfolioQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 12
+	}
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	role := orgRole(db, org.ID, userID)
+	isMember := role != "" || middleware.IsAdmin(c)
+
+	dbQuery := db.Model(&models.Portfolio{}).
+		Preload("User").
+		Preload("Versions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).
+		Preload("ActiveVersion").
+		Preload("Collaborators.User").
+		Where("organization_id = ?", org.ID)
+
+	if isMember {
+		// 成员可以看到组织的所有作品（回收站除外），并可按状态过滤
+		if query.Status != "" {
+			dbQuery = dbQuery.Where("status = ?", query.Status)
+		} else {
+			dbQuery = dbQuery.Where("status <> ?", "deleted")
+		}
+	} else {
+		dbQuery = dbQuery.Where("status = ? AND members_only = ? AND visibility = ?", "published", false, models.VisibilityPublic)
+	}
+
+	if query.Category != "" && query.Category != "all" {
+		dbQuery = dbQuery.Where("category = ?", query.Category)
+	}
+
+	var total int64
+	dbQuery.Count(&total)
+
+	var portfolios []models.Portfolio
+	offset := (query.Page - 1) * query.PageSize
+	if err := dbQuery.Order("created_at DESC").Offset(offset).Limit(query.PageSize).Find(&portfolios).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolios"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        buildPortfolioResponses(portfolios),
+		"total":       total,
+		"page":        query.Page,
+		"page_size":   query.PageSize,
+		"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+	})
+}
+
+// GetOrganizationMembers 获取组织成员列表，成员和管理员可以看到完整的用户信息，其他人只能看到公开信息
+func GetOrganizationMembers(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var members []models.OrganizationMember
+	if err := db.Preload("User").Where("organization_id = ?", org.ID).
+		Order("created_at ASC").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get members"})
+		return
+	}
+
+	if !middleware.IsAdmin(c) && orgRole(db, org.ID, userID) == "" {
+		responses := make([]models.PublicOrganizationMemberResponse, 0, len(members))
+		for i := range members {
+			responses = append(responses, members[i].ToPublicResponse())
+		}
+		c.JSON(http.StatusOK, gin.H{"data": responses})
+		return
+	}
+
+	responses := make([]models.OrganizationMemberResponse, 0, len(members))
+	for i := range members {
+		responses = append(responses, members[i].ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// AddOrganizationMember 添加组织成员
+func AddOrganizationMember(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req models.AddOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	if !middleware.IsAdmin(c) && !isOrgManager(orgRole(db, org.ID, userID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	// 查找目标用户
+	var user models.User
+	userQuery := db.Where("status = ?", "approved")
+	switch {
+	case req.UserID != "":
+		userQuery = userQuery.Where("id = ?", req.UserID)
+	case req.Username != "":
+		userQuery = userQuery.Where("username = ?", req.Username)
+	case req.Email != "":
+		userQuery = userQuery.Where("email = ?", req.Email)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId, username or email is required"})
+		return
+	}
+	if err := userQuery.First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if orgRole(db, org.ID, user.ID) != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	member := models.OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         user.ID,
+		Role:           req.Role,
+	}
+	if err := db.Create(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	member.User = &user
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Member added successfully",
+		"data":    member.ToResponse(),
+	})
+}
+
+// UpdateOrganizationMember 修改组织成员角色，只有所有者可以任命所有者
+func UpdateOrganizationMember(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req models.UpdateOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	role := orgRole(db, org.ID, userID)
+	isAdmin := middleware.IsAdmin(c)
+	if !isAdmin && !isOrgManager(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var member models.OrganizationMember
+	if err := db.Preload("User").Where("organization_id = ? AND user_id = ?", org.ID, c.Param("userId")).
+		First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	// 组织管理员不能修改所有者，也不能任命所有者
+	if !isAdmin && role != models.OrgRoleOwner &&
+		(member.Role == models.OrgRoleOwner || req.Role == models.OrgRoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can manage owners"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&member).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return ensureOrgHasOwner(tx, org.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errLastOrgOwner) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	member.Role = req.Role
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member updated successfully",
+		"data":    member.ToResponse(),
+	})
+}
+
+// RemoveOrganizationMember 移除组织成员，成员也可以主动退出
+func RemoveOrganizationMember(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	targetUserID := c.Param("userId")
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	role := orgRole(db, org.ID, userID)
+	targetRole := orgRole(db, org.ID, targetUserID)
+	if targetRole == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	allowed := middleware.IsAdmin(c) || targetUserID == userID || role == models.OrgRoleOwner ||
+		(role == models.OrgRoleAdmin && targetRole == models.OrgRoleMember)
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND user_id = ?", org.ID, targetUserID).
+			Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return ensureOrgHasOwner(tx, org.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errLastOrgOwner) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// GetOrganizationStorage 获取组织存储用量
+func GetOrganizationStorage(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	if !middleware.IsAdmin(c) && orgRole(db, org.ID, userID) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	used, count, err := services.OrganizationStorageUsage(db, org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate storage usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": models.OrganizationStorageResponse{
+		Used:      used,
+		Quota:     org.StorageQuota,
+		FileCount: count,
+	}})
+}
+
+// 管理员：设置组织存储配额
+func UpdateOrganizationQuota(c *gin.Context) {
+	var req models.OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	if err := db.Model(org).Update("storage_quota", *req.StorageQuota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quota"})
+		return
+	}
+
+	used, count, err := services.OrganizationStorageUsage(db, org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate storage usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Quota updated successfully",
+		"data": models.OrganizationStorageResponse{
+			Used:      used,
+			Quota:     *req.StorageQuota,
+			FileCount: count,
+		},
+	})
+}
+
+// OrganizationPage 组织主页
+func OrganizationPage(c *gin.Context) {
+	db := database.GetDB()
+	org, err := findOrganization(db, c.Param("slug"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "pages/org", gin.H{"Title": "组织不存在"})
+		return
+	}
+
+	var portfolios []models.Portfolio
+	db.Preload("User").Preload("ActiveVersion").
+		Where("organization_id = ? AND status = ? AND members_only = ? AND visibility = ?", org.ID, "published", false, models.VisibilityPublic).
+		Order("created_at DESC").Limit(24).Find(&portfolios)
+
+	var members []models.OrganizationMember
+	db.Preload("User").Where("organization_id = ?", org.ID).Order("created_at ASC").Find(&members)
+	publicMembers := make([]models.PublicOrganizationMemberResponse, 0, len(members))
+	for i := range members {
+		if members[i].User != nil {
+			publicMembers = append(publicMembers, members[i].ToPublicResponse())
+		}
+	}
+
+	c.HTML(http.StatusOK, "pages/org", gin.H{
+		"Title":        org.Name,
+		"Organization": buildOrganizationResponse(db, org, ""),
+		"Portfolios":   buildPortfolioResponses(portfolios),
+		"Members":      publicMembers,
+	})
+}
+
+// ensureOrgHasOwner 确保组织至少保留一个所有者
+func ensureOrgHasOwner(tx *gorm.DB, orgID string) error {
+	var owners int64
+	if err := tx.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", orgID, models.OrgRoleOwner).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errLastOrgOwner
+	}
+	return nil
+}
@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oldweipro/design-ai/database"
@@ -37,15 +41,54 @@ func buildPortfolioResponse(portfolio models.Portfolio) models.PortfolioResponse
 		Likes:         portfolio.Likes,
 		Views:         portfolio.Views,
 		Status:        portfolio.Status,
+		Visibility:    portfolio.Visibility,
 		CreatedAt:     portfolio.CreatedAt,
 		UpdatedAt:     portfolio.UpdatedAt,
 	}
 
-	// 生成图片URL
+	// 作者列表（所有者和编辑者）
+	response.Authors = buildPortfolioAuthors(portfolio)
+
+	// 组织信息
+	response.OrganizationID = portfolio.OrganizationID
+	response.MembersOnly = portfolio.MembersOnly
+	if portfolio.Organization != nil {
+		response.Organization = &models.OrganizationResponse{
+			ID:     portfolio.Organization.ID,
+			Slug:   portfolio.Organization.Slug,
+			Name:   portfolio.Organization.Name,
+			Avatar: portfolio.Organization.Avatar,
+		}
+	}
+
+	// 派生来源署名
+	if portfolio.ForkedFromID != "" {
+		response.ForkedFrom = &models.ForkSource{
+			PortfolioID: portfolio.ForkedFromID,
+			VersionID:   portfolio.ForkedFromVersionID,
+			Version:     portfolio.ForkedFromVersion,
+			Title:       portfolio.ForkedFromTitle,
+			Author:      portfolio.ForkedFromAuthor,
+		}
+	}
+
+	// 审核结果（拒绝原因返回给作者）
+	response.ReviewedAt = portfolio.ReviewedAt
+	if portfolio.Status == "rejected" {
+		response.RejectionReason = portfolio.RejectionReason
+	}
+
+	// 生成图片URL和响应式图片信息
 	if portfolio.ImageObjectID != "" {
-		minioService := services.NewMinIOService()
-		if url, err := minioService.GetFileURL(portfolio.ImageObjectID); err == nil {
-			response.ImageURL = url
+		if sources, err := services.ImageSvc.Sources(portfolio.ImageObjectID); err == nil {
+			response.ImageURL = sources.URL
+			response.ImageSrcSet = sources.SrcSet
+			if image := sources.Metadata; image != nil {
+				response.ImageWidth = image.Width
+				response.ImageHeight = image.Height
+				response.ImageColor = image.DominantColor
+				response.ImageBlurHash = image.BlurHash
+			}
 		} else {
 			log.Printf("Failed to generate URL for object %s: %v", portfolio.ImageObjectID, err)
 		}
@@ -87,6 +130,17 @@ func buildPortfolioResponses(portfolios []models.Portfolio) []models.PortfolioRe
 	return responses
 }
 
+// loadPortfolioContents 详情响应需要返回版本内容，用一次查询加载作品所有版本的HTML内容；
+// 列表响应不调用，版本中不包含 htmlContent
+func loadPortfolioContents(db *gorm.DB, portfolio *models.Portfolio) error {
+	versions := make([]*models.PortfolioVersion, 0, len(portfolio.Versions)+1)
+	for i := range portfolio.Versions {
+		versions = append(versions, &portfolio.Versions[i])
+	}
+	versions = append(versions, portfolio.ActiveVersion)
+	return models.LoadVersionContents(db, versions...)
+}
+
 func GetPortfolios(c *gin.Context) {
 	var query models.PortfolioQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
@@ -103,13 +157,20 @@ func GetPortfolios(c *gin.Context) {
 		Preload("Versions", func(db *gorm.DB) *gorm.DB {
 			return db.Order("created_at DESC")
 		}).
-		Preload("ActiveVersion")
+		Preload("ActiveVersion").
+		Preload("Collaborators.User").
+		Preload("Organization")
 
 	// 根据用户角色决定可见性
 	isAdmin := middleware.IsAdmin(c)
 	if !isAdmin {
-		// 普通用户只能看到已发布的作品
-		dbQuery = dbQuery.Where("status = ?", "published")
+		// 普通用户只能看到已发布的公开作品，仅成员可见的组织作品需要是组织成员
+		dbQuery = dbQuery.Where("status = ? AND visibility = ?", "published", models.VisibilityPublic)
+		if userID, hasUser := middleware.GetCurrentUserID(c); hasUser {
+			dbQuery = dbQuery.Where("(members_only = ? OR organization_id IN (?))", false, userOrganizationIDs(db, userID))
+		} else {
+			dbQuery = dbQuery.Where("members_only = ?", false)
+		}
 	} else if query.Status != "" {
 		// 管理员可以按状态过滤
 		dbQuery = dbQuery.Where("status = ?", query.Status)
@@ -120,14 +181,20 @@ func GetPortfolios(c *gin.Context) {
 		dbQuery = dbQuery.Where("user_id = ?", query.UserID)
 	}
 
+	// 组织过滤（查看特定组织的作品）
+	if query.OrgID != "" {
+		dbQuery = dbQuery.Where("organization_id = ?", query.OrgID)
+	}
+
 	if query.Category != "" && query.Category != "all" {
 		dbQuery = dbQuery.Where("category = ?", query.Category)
 	}
 
+	// 同时搜索活跃版本的标题和可见文字
 	if query.Search != "" {
 		searchTerm := "%" + query.Search + "%"
-		dbQuery = dbQuery.Where("title LIKE ? OR author LIKE ? OR description LIKE ? OR tags LIKE ?",
-			searchTerm, searchTerm, searchTerm, searchTerm)
+		dbQuery = dbQuery.Where("title LIKE ? OR author LIKE ? OR description LIKE ? OR tags LIKE ? OR active_version_id IN (SELECT id FROM portfolio_versions WHERE search_text LIKE ?)",
+			searchTerm, searchTerm, searchTerm, searchTerm, searchTerm)
 	}
 
 	dbQuery.Count(&total)
@@ -199,14 +266,17 @@ func GetPortfolioByID(c *gin.Context) {
 			return db.Order("created_at DESC")
 		}).
 		Preload("ActiveVersion").
+		Preload("Collaborators.User").
+		Preload("Organization").
 		Where("id = ?", id)
 
 	if !isAdmin {
-		// 普通用户只能看到已发布的作品，或者自己的作品
+		// 普通用户只能看到已发布的公开或不公开列出的作品，私有作品只有所有者、协作者和组织成员可见
 		if hasUser {
-			query = query.Where("(status = ? OR user_id = ?)", "published", userID)
+			query = query.Where("((status = ? AND members_only = ? AND visibility <> ?) OR user_id = ? OR id IN (?) OR organization_id IN (?))",
+				"published", false, models.VisibilityPrivate, userID, collaboratorPortfolioIDs(db, userID), userOrganizationIDs(db, userID))
 		} else {
-			query = query.Where("status = ?", "published")
+			query = query.Where("status = ? AND members_only = ? AND visibility <> ?", "published", false, models.VisibilityPrivate)
 		}
 	}
 
@@ -222,6 +292,11 @@ func GetPortfolioByID(c *gin.Context) {
 		db.Save(&portfolio)
 	}
 
+	if err := loadPortfolioContents(db, &portfolio); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
 	response := buildPortfolioResponse(portfolio)
 	c.JSON(http.StatusOK, gin.H{"data": response})
 }
@@ -260,7 +335,18 @@ func CreatePortfolio(c *gin.Context) {
 	// 根据管理员设置决定作品初始状态
 	portfolioStatus := "published" // 默认直接发布
 	if adminSettings.PortfolioApprovalRequired {
-		portfolioStatus = "draft" // 需要管理员审核
+		portfolioStatus = "pending_review" // 需要管理员审核
+	}
+
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+
+	// 以组织名义发布需要是组织成员
+	if req.OrganizationID != "" && orgRole(db, req.OrganizationID, userID) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of the organization"})
+		return
 	}
 
 	// 使用用户昵称作为作者，如果昵称为空则使用用户名
@@ -279,6 +365,10 @@ func CreatePortfolio(c *gin.Context) {
 		ImageObjectID: req.ImageObjectID,
 		AILevel:       req.AILevel,
 		Status:        portfolioStatus,
+		Visibility:    visibility,
+
+		OrganizationID: req.OrganizationID,
+		MembersOnly:    req.OrganizationID != "" && req.MembersOnly,
 	}
 
 	// 使用事务来创建作品和版本
@@ -316,15 +406,15 @@ func CreatePortfolio(c *gin.Context) {
 
 			// 创建版本
 			for _, versionReq := range req.Versions {
-				// 生成缩略图
+				// 先使用内容摘要作为缩略图，渲染后的缩略图由后台任务生成
 				thumbnail := ""
 				if versionReq.HTMLContent != "" {
-					thumbnail = services.ThumbnailSvc.GenerateHTMLThumbnail(versionReq.HTMLContent)
+					thumbnail = services.ThumbnailSvc.SummaryThumbnail(versionReq.HTMLContent)
 				}
 
 				version := models.PortfolioVersion{
 					PortfolioID: portfolio.ID,
-					Version:     versionReq.Name,
+					Branch:      versionReq.Branch,
 					Title:       versionReq.Title,
 					Description: versionReq.Description,
 					HTMLContent: versionReq.HTMLContent,
@@ -333,22 +423,52 @@ func CreatePortfolio(c *gin.Context) {
 					ChangeLog:   versionReq.ChangeLog,
 				}
 
+				if err := assignVersionNumber(tx, &version, versionReq.Name, versionReq.Bump); err != nil {
+					return err
+				}
 				if err := tx.Create(&version).Error; err != nil {
 					return err
 				}
+				if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+					return err
+				}
+				if version.IsActive {
+					if err := activateVersion(tx, portfolio.ID, version.ID); err != nil {
+						return err
+					}
+				}
 			}
 		}
 
+		// 直接发布的作品，其版本即为不可修改的快照
+		if portfolio.Status == "published" {
+			err := publishVersions(tx, portfolio.ID)
+			if !errors.Is(err, errSecurityFindings) {
+				return err
+			}
+			// 内容存在安全问题且不允许发布时，转入审核队列
+			portfolio.Status = "pending_review"
+			return tx.Model(&portfolio).Update("status", portfolio.Status).Error
+		}
 		return nil
 	})
 
+	if errors.Is(err, errInvalidBranch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create portfolio"})
 		return
 	}
+	services.ThumbnailQueueSvc.Notify()
 
 	// 预加载用户信息和版本信息
-	db.Preload("User").Preload("Versions").Preload("ActiveVersion").First(&portfolio, portfolio.ID)
+	db.Preload("User").Preload("Versions").Preload("ActiveVersion").Preload("Collaborators.User").Preload("Organization").First(&portfolio, "id = ?", portfolio.ID)
+	if err := loadPortfolioContents(db, &portfolio); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
 
 	response := buildPortfolioResponse(portfolio)
 	c.JSON(http.StatusCreated, gin.H{"data": response})
@@ -372,35 +492,42 @@ func UpdatePortfolio(c *gin.Context) {
 	db := database.GetDB()
 	var portfolio models.Portfolio
 
-	if err := db.Where("id = ?", id).First(&portfolio).Error; err != nil {
+	// 回收站中的作品只能通过恢复接口取回，不能直接修改
+	if err := db.Where("id = ? AND status <> ?", id, "deleted").First(&portfolio).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
 
-	// 权限检查：只有作品所有者或管理员可以修改
+	// 权限检查：作品所有者、编辑者或管理员可以修改
 	isAdmin := middleware.IsAdmin(c)
-	if !isAdmin && portfolio.UserID != userID {
+	if !canEditPortfolio(c, db, &portfolio) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
+	isOwner := portfolio.UserID == userID
+
+	// 更改组织归属和可见范围：只有所有者或管理员可以操作，编辑者不能扩大或改变谁能看到作品
+	orgChanged := req.OrganizationID != nil && *req.OrganizationID != portfolio.OrganizationID
+	membersOnlyChanged := req.MembersOnly != nil && *req.MembersOnly != portfolio.MembersOnly
+	visibilityChanged := req.Visibility != "" && req.Visibility != portfolio.Visibility
+	if (orgChanged || membersOnlyChanged || visibilityChanged) && !canManagePortfolio(c, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change the organization or visibility"})
+		return
+	}
+	// 移入组织需要是目标组织成员
+	if orgChanged && *req.OrganizationID != "" && !isAdmin && orgRole(db, *req.OrganizationID, userID) == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of the organization"})
+		return
+	}
 
 	// 使用事务处理更新操作
+	var changes *models.VersionChanges
 	err := db.Transaction(func(tx *gorm.DB) error {
 		// 更新主表数据
 		if req.Title != "" {
 			portfolio.Title = req.Title
 		}
 
-		// 更新作者信息：从用户信息获取最新的昵称
-		var currentUser models.User
-		if err := tx.Where("id = ?", portfolio.UserID).First(&currentUser).Error; err == nil {
-			authorName := currentUser.Nickname
-			if authorName == "" {
-				authorName = currentUser.Username
-			}
-			portfolio.Author = authorName
-		}
-
 		if req.Description != "" {
 			portfolio.Description = req.Description
 		}
@@ -417,13 +544,28 @@ func UpdatePortfolio(c *gin.Context) {
 		if req.AILevel != "" {
 			portfolio.AILevel = req.AILevel
 		}
+		if req.OrganizationID != nil {
+			portfolio.OrganizationID = *req.OrganizationID
+		}
+		if req.MembersOnly != nil {
+			portfolio.MembersOnly = *req.MembersOnly
+		}
+		if portfolio.OrganizationID == "" {
+			portfolio.MembersOnly = false
+		}
+		if req.Visibility != "" {
+			portfolio.Visibility = req.Visibility
+		}
 
-		// 状态更新：普通用户只能设为草稿，管理员可以设任何状态
-		if req.Status != "" {
+		// 状态更新：管理员可以设除删除外的任何状态；所有者可以设为草稿，或提交未发布的作品，
+		// 需要审核时进入审核队列；编辑者不能修改状态。移入和移出回收站只能通过删除和恢复接口
+		if req.Status != "" && req.Status != "deleted" {
 			if isAdmin {
 				portfolio.Status = req.Status
-			} else if req.Status == "draft" {
+			} else if isOwner && req.Status == "draft" {
 				portfolio.Status = req.Status
+			} else if isOwner && req.Status == "published" && portfolio.Status != "published" {
+				portfolio.Status = submissionStatus(tx, portfolio.Status)
 			}
 		}
 
@@ -432,91 +574,346 @@ func UpdatePortfolio(c *gin.Context) {
 			return err
 		}
 
-		// 处理版本数据：先删除旧的版本记录，再插入新的版本列表
+		// 更新作者信息：根据所有者和编辑者的最新昵称计算
+		if err := refreshPortfolioAuthor(tx, portfolio.ID); err != nil {
+			return err
+		}
+
+		// 处理版本数据：与现有版本比对，原地更新、新建或移入回收站
 		if len(req.Versions) > 0 {
-			// 1. 删除所有旧的版本记录
-			if err := tx.Where("portfolio_id = ?", id).Delete(&models.PortfolioVersion{}).Error; err != nil {
+			result, err := applyVersionChanges(tx, id, req.Versions)
+			if err != nil {
 				return err
 			}
+			changes = result
+		}
 
-			// 2. 确保只有一个活跃版本
-			var activeVersionCount int
-			for _, versionReq := range req.Versions {
-				if versionReq.IsActive {
-					activeVersionCount++
-				}
-			}
+		if portfolio.Status == "published" {
+			return publishVersions(tx, portfolio.ID)
+		}
+		return nil
+	})
 
-			// 如果没有活跃版本，默认第一个为活跃版本
-			if activeVersionCount == 0 && len(req.Versions) > 0 {
-				req.Versions[0].IsActive = true
-			} else if activeVersionCount > 1 {
-				// 如果有多个活跃版本，只保留第一个
-				foundActive := false
-				for i := range req.Versions {
-					if req.Versions[i].IsActive {
-						if foundActive {
-							req.Versions[i].IsActive = false
-						} else {
-							foundActive = true
-						}
-					}
+	if errors.Is(err, errInvalidBranch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errSecurityFindings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": portfolioFindings(db, id)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update portfolio", "details": err.Error()})
+		return
+	}
+	services.ThumbnailQueueSvc.Notify()
+
+	// 预加载用户信息和版本信息
+	db.Preload("User").
+		Preload("Versions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).
+		Preload("ActiveVersion").
+		Preload("Collaborators.User").
+		Preload("Organization").
+		First(&portfolio, "id = ?", id)
+	if err := loadPortfolioContents(db, &portfolio); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	response := buildPortfolioResponse(portfolio)
+	c.JSON(http.StatusOK, gin.H{"data": response, "changes": changes})
+}
+
+// submissionStatus 所有者提交发布时作品的新状态：开启了作品审核或作品曾被拒绝时进入审核队列，否则直接发布
+func submissionStatus(tx *gorm.DB, currentStatus string) string {
+	if currentStatus == "rejected" || currentStatus == "pending_review" {
+		return "pending_review"
+	}
+	var settings models.AdminSettings
+	if err := tx.First(&settings).Error; err != nil || settings.PortfolioApprovalRequired {
+		return "pending_review"
+	}
+	return "published"
+}
+
+// applyVersionChanges 将请求中的版本列表应用到作品：ID匹配的版本原地更新，
+// 新版本创建，请求中缺失的版本移入回收站（可通过回收站恢复）
+func applyVersionChanges(tx *gorm.DB, portfolioID string, versionReqs []models.UpdatePortfolioVersionReq) (*models.VersionChanges, error) {
+	var existing []models.PortfolioVersion
+	if err := tx.Where("portfolio_id = ?", portfolioID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	var portfolio models.Portfolio
+	if err := tx.Select("id, active_version_id").Where("id = ?", portfolioID).First(&portfolio).Error; err != nil {
+		return nil, err
+	}
+	existingByID := make(map[string]*models.PortfolioVersion, len(existing))
+	versions := make([]*models.PortfolioVersion, len(existing))
+	for i := range existing {
+		existing[i].IsActive = existing[i].ID == portfolio.ActiveVersionID
+		existingByID[existing[i].ID] = &existing[i]
+		versions[i] = &existing[i]
+	}
+	// 比较内容是否变化需要现有版本的内容
+	if err := models.LoadVersionContents(tx, versions...); err != nil {
+		return nil, err
+	}
+
+	// 确保只有一个活跃版本：没有则默认第一个，多个则只保留第一个
+	activeIndex := 0
+	for i, versionReq := range versionReqs {
+		if versionReq.IsActive {
+			activeIndex = i
+			break
+		}
+	}
+
+	changes := &models.VersionChanges{
+		Created:   []string{},
+		Updated:   []string{},
+		Deleted:   []string{},
+		Unchanged: []string{},
+	}
+	kept := make(map[string]bool, len(versionReqs))
+	activeVersionID := ""
+
+	for i, versionReq := range versionReqs {
+		isActive := i == activeIndex
+
+		if version, ok := existingByID[versionReq.ID]; ok && !kept[versionReq.ID] {
+			kept[version.ID] = true
+
+			changed := version.Title != versionReq.Title ||
+				version.Description != versionReq.Description ||
+				version.ChangeLog != versionReq.ChangeLog ||
+				version.IsActive != isActive
+
+			// 已发布版本的内容不可修改，内容变化时基于它创建新版本
+			if version.PublishedAt != nil && version.HTMLContent != versionReq.HTMLContent {
+				forked, err := createVersionFrom(tx, version, versionReq.Title, versionReq.Description,
+					versionReq.HTMLContent, versionReq.ChangeLog, versionReq.Bump, false)
+				if err != nil {
+					return nil, err
 				}
+				if isActive {
+					activeVersionID = forked.ID
+				}
+				kept[forked.ID] = true
+				changes.Unchanged = append(changes.Unchanged, version.ID)
+				changes.Created = append(changes.Created, forked.ID)
+				continue
 			}
 
-			// 3. 插入新的版本列表
-			for _, versionReq := range req.Versions {
-				// 生成缩略图
-				thumbnail := ""
-				if versionReq.HTMLContent != "" {
-					thumbnail = services.ThumbnailSvc.GenerateHTMLThumbnail(versionReq.HTMLContent)
-				}
+			if isActive {
+				activeVersionID = version.ID
+			}
 
-				// 对于现有版本，如果ID为空或者不是有效的UUID，生成新的版本号
-				versionNumber := versionReq.Name
-				if versionReq.ID == "" || len(versionReq.ID) < 36 {
-					// 这是一个新版本，生成版本号
-					nextVersion, err := getNextVersionNumber(tx, id)
-					if err == nil && nextVersion != "" {
-						versionNumber = nextVersion
-					}
-				}
+			// 内容变化时重新生成缩略图
+			contentChanged := version.HTMLContent != versionReq.HTMLContent
+			if contentChanged {
+				version.HTMLContent = versionReq.HTMLContent
+				version.Thumbnail = services.ThumbnailSvc.SummaryThumbnail(versionReq.HTMLContent)
+				changed = true
+			}
 
-				version := models.PortfolioVersion{
-					PortfolioID: id,
-					Version:     versionNumber,
-					Title:       versionReq.Title,
-					Description: versionReq.Description,
-					HTMLContent: versionReq.HTMLContent,
-					Thumbnail:   thumbnail,
-					IsActive:    versionReq.IsActive,
-					ChangeLog:   versionReq.ChangeLog,
-				}
+			if !changed {
+				changes.Unchanged = append(changes.Unchanged, version.ID)
+				continue
+			}
 
-				if err := tx.Create(&version).Error; err != nil {
-					return err
+			version.Title = versionReq.Title
+			version.Description = versionReq.Description
+			version.ChangeLog = versionReq.ChangeLog
+			version.IsActive = isActive
+			if err := tx.Save(version).Error; err != nil {
+				return nil, err
+			}
+			if contentChanged {
+				if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+					return nil, err
 				}
 			}
+			changes.Updated = append(changes.Updated, version.ID)
+			continue
 		}
 
-		return nil
-	})
+		// 新版本：ID为空或不属于该作品，分配新的版本号
+		thumbnail := ""
+		if versionReq.HTMLContent != "" {
+			thumbnail = services.ThumbnailSvc.SummaryThumbnail(versionReq.HTMLContent)
+		}
+
+		version := models.PortfolioVersion{
+			PortfolioID: portfolioID,
+			Branch:      versionReq.Branch,
+			Title:       versionReq.Title,
+			Description: versionReq.Description,
+			HTMLContent: versionReq.HTMLContent,
+			Thumbnail:   thumbnail,
+			IsActive:    isActive,
+			ChangeLog:   versionReq.ChangeLog,
+		}
+		if err := assignVersionNumber(tx, &version, versionReq.Name, versionReq.Bump); err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return nil, err
+		}
+		if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+			return nil, err
+		}
+		if isActive {
+			activeVersionID = version.ID
+		}
+		kept[version.ID] = true
+		changes.Created = append(changes.Created, version.ID)
+	}
+
+	// 请求中缺失的版本移入回收站
+	for _, version := range existing {
+		if kept[version.ID] {
+			continue
+		}
+		if err := tx.Delete(&version).Error; err != nil {
+			return nil, err
+		}
+		changes.Deleted = append(changes.Deleted, version.ID)
+	}
 
+	// 最后一次性切换活跃版本，版本列表为空时清空
+	if activeVersionID == "" {
+		if err := tx.Table("portfolios").Where("id = ?", portfolioID).Update("active_version_id", "").Error; err != nil {
+			return nil, err
+		}
+	} else if err := activateVersion(tx, portfolioID, activeVersionID); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// ForkPortfolio 将已发布作品的某个版本复制为当前用户的新作品草稿，并保留来源署名
+func ForkPortfolio(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.ForkPortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var source models.Portfolio
+	if err := db.Where("id = ? AND status = ?", c.Param("id"), "published").First(&source).Error; err != nil || !canViewPortfolio(c, db, &source) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	// 来源版本：指定版本，否则使用活跃版本，没有活跃版本时使用最新版本
+	var sourceVersion models.PortfolioVersion
+	versionQuery := db.Where("portfolio_id = ?", source.ID)
+	switch {
+	case req.VersionID != "":
+		versionQuery = versionQuery.Where("id = ?", req.VersionID)
+	case source.ActiveVersionID != "":
+		versionQuery = versionQuery.Where("id = ?", source.ActiveVersionID)
+	default:
+		versionQuery = versionQuery.Order("created_at DESC")
+	}
+	if err := versionQuery.First(&sourceVersion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := sourceVersion.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	var currentUser models.User
+	if err := db.Where("id = ?", userID).First(&currentUser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户未找到"})
+		return
+	}
+
+	title := source.Title
+	if req.Title != "" {
+		title = req.Title
+	}
+
+	portfolio := models.Portfolio{
+		UserID:        userID,
+		Title:         title,
+		Author:        currentUser.DisplayName(),
+		Description:   source.Description,
+		Content:       source.Content,
+		Category:      source.Category,
+		Tags:          source.Tags,
+		ImageObjectID: source.ImageObjectID,
+		AILevel:       source.AILevel,
+		Status:        "draft",
+		Visibility:    models.VisibilityPublic,
+
+		ForkedFromID:        source.ID,
+		ForkedFromVersionID: sourceVersion.ID,
+		ForkedFromVersion:   sourceVersion.Version,
+		ForkedFromTitle:     source.Title,
+		ForkedFromAuthor:    source.Author,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&portfolio).Error; err != nil {
+			return err
+		}
+
+		version := models.PortfolioVersion{
+			PortfolioID:       portfolio.ID,
+			Title:             sourceVersion.Title,
+			Description:       sourceVersion.Description,
+			HTMLContent:       sourceVersion.HTMLContent,
+			Thumbnail:         sourceVersion.Thumbnail,
+			ThumbnailObjectID: sourceVersion.ThumbnailObjectID,
+			ContentSummary:    sourceVersion.ContentSummary,
+			SearchText:        sourceVersion.SearchText,
+			IsActive:          true,
+			ChangeLog:         fmt.Sprintf("Forked from %s (%s) by %s", source.Title, sourceVersion.Version, source.Author),
+		}
+		if err := assignVersionNumber(tx, &version, "", ""); err != nil {
+			return err
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		if err := copyVersionAssets(tx, sourceVersion.ID, version.ID); err != nil {
+			return err
+		}
+		return activateVersion(tx, portfolio.ID, version.ID)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update portfolio", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fork portfolio"})
 		return
 	}
 
-	// 预加载用户信息和版本信息
 	db.Preload("User").
 		Preload("Versions", func(db *gorm.DB) *gorm.DB {
 			return db.Order("created_at DESC")
 		}).
 		Preload("ActiveVersion").
-		First(&portfolio, "id = ?", id)
+		Preload("Collaborators.User").
+		Preload("Organization").
+		First(&portfolio, "id = ?", portfolio.ID)
+	if err := loadPortfolioContents(db, &portfolio); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
 
-	response := buildPortfolioResponse(portfolio)
-	c.JSON(http.StatusOK, gin.H{"data": response})
+	c.JSON(http.StatusCreated, gin.H{"data": buildPortfolioResponse(portfolio)})
 }
 
 func DeletePortfolio(c *gin.Context) {
@@ -543,13 +940,12 @@ func DeletePortfolio(c *gin.Context) {
 		return
 	}
 
-	portfolio.Status = "deleted"
-	if err := db.Save(&portfolio).Error; err != nil {
+	if err := moveToTrash(db, &portfolio); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Portfolio deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Portfolio moved to trash"})
 }
 
 func LikePortfolio(c *gin.Context) {
@@ -558,7 +954,7 @@ func LikePortfolio(c *gin.Context) {
 	db := database.GetDB()
 	var portfolio models.Portfolio
 
-	if err := db.Where("id = ? AND status = ?", id, "published").First(&portfolio).Error; err != nil {
+	if err := db.Where("id = ? AND status = ? AND visibility <> ?", id, "published", models.VisibilityPrivate).First(&portfolio).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
@@ -591,36 +987,50 @@ func GetCategories(c *gin.Context) {
 
 // 管理员：审核作品
 func ApprovePortfolio(c *gin.Context) {
-	id := c.Param("id")
+	portfolio, ok := setPortfolioReviewStatus(c)
+	if !ok {
+		return
+	}
+
+	response := buildPortfolioResponse(*portfolio)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Portfolio status updated successfully",
+		"data":    response,
+	})
+}
+
+// setPortfolioReviewStatus 按审核决定处理管理员直接设置的作品状态：拒绝时必须填写原因，
+// 通过时在同一事务中发布版本，并记录审核决定。失败时已写入响应
+func setPortfolioReviewStatus(c *gin.Context) (*models.Portfolio, bool) {
+	portfolioID := c.Param("id")
 
 	var req models.AdminPortfolioRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return nil, false
 	}
 
-	db := database.GetDB()
-	var portfolio models.Portfolio
-
-	if err := db.Where("id = ?", id).First(&portfolio).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
-		return
+	decision := models.ReviewDecisionApproved
+	if req.Status == "rejected" {
+		decision = models.ReviewDecisionRejected
 	}
+	reviewerID, _ := middleware.GetCurrentUserID(c)
 
-	portfolio.Status = req.Status
-	if err := db.Save(&portfolio).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update portfolio"})
-		return
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return setReviewStatus(tx, models.ReviewTargetPortfolio, portfolioID, reviewerID, decision, req.Reason)
+	})
+	if err != nil {
+		reviewStatusError(c, db, err, "Portfolio not found")
+		return nil, false
 	}
 
-	// 预加载用户信息
-	db.Preload("User").First(&portfolio, portfolio.ID)
-
-	response := buildPortfolioResponse(portfolio)
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Portfolio status updated successfully",
-		"data":    response,
-	})
+	var portfolio models.Portfolio
+	if err := db.Preload("User").Where("id = ?", portfolioID).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load portfolio"})
+		return nil, false
+	}
+	return &portfolio, true
 }
 
 // 获取用户自己的作品
@@ -650,10 +1060,15 @@ func GetMyPortfolios(c *gin.Context) {
 			return db.Order("created_at DESC")
 		}).
 		Preload("ActiveVersion").
-		Where("user_id = ?", userID)
+		Preload("Collaborators.User").
+		Preload("Organization").
+		Where("(user_id = ? OR id IN (?))", userID, collaboratorPortfolioIDs(db, userID))
 
 	if query.Status != "" {
 		dbQuery = dbQuery.Where("status = ?", query.Status)
+	} else {
+		// 回收站中的作品通过回收站接口查看
+		dbQuery = dbQuery.Where("status <> ?", "deleted")
 	}
 
 	if query.Category != "" && query.Category != "all" {
@@ -662,8 +1077,8 @@ func GetMyPortfolios(c *gin.Context) {
 
 	if query.Search != "" {
 		searchTerm := "%" + query.Search + "%"
-		dbQuery = dbQuery.Where("title LIKE ? OR description LIKE ? OR tags LIKE ?",
-			searchTerm, searchTerm, searchTerm)
+		dbQuery = dbQuery.Where("title LIKE ? OR description LIKE ? OR tags LIKE ? OR active_version_id IN (SELECT id FROM portfolio_versions WHERE search_text LIKE ?)",
+			searchTerm, searchTerm, searchTerm, searchTerm)
 	}
 
 	dbQuery.Count(&total)
@@ -748,6 +1163,8 @@ func GetAllPortfolios(c *gin.Context) {
 			return db.Order("created_at DESC")
 		}).
 		Preload("ActiveVersion").
+		Preload("Collaborators.User").
+		Preload("Organization").
 		Model(&models.Portfolio{})
 
 	// 状态过滤
@@ -763,7 +1180,8 @@ func GetAllPortfolios(c *gin.Context) {
 	// 搜索过滤
 	if query.Search != "" {
 		searchPattern := "%" + query.Search + "%"
-		dbQuery = dbQuery.Where("title LIKE ? OR description LIKE ? OR author LIKE ?", searchPattern, searchPattern, searchPattern)
+		dbQuery = dbQuery.Where("title LIKE ? OR description LIKE ? OR author LIKE ? OR active_version_id IN (SELECT id FROM portfolio_versions WHERE search_text LIKE ?)",
+			searchPattern, searchPattern, searchPattern, searchPattern)
 	}
 
 	// 获取总数
@@ -793,32 +1211,14 @@ func GetAllPortfolios(c *gin.Context) {
 
 // 管理员：更新作品状态
 func UpdatePortfolioStatus(c *gin.Context) {
-	portfolioID := c.Param("id")
-
-	var req models.AdminPortfolioRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	db := database.GetDB()
-	var portfolio models.Portfolio
-
-	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
-		return
-	}
-
-	portfolio.Status = req.Status
-
-	if err := db.Save(&portfolio).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update portfolio"})
+	portfolio, ok := setPortfolioReviewStatus(c)
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Portfolio updated successfully",
-		"data":    buildPortfolioResponse(portfolio),
+		"data":    buildPortfolioResponse(*portfolio),
 	})
 }
 
@@ -834,11 +1234,41 @@ func AdminDeletePortfolio(c *gin.Context) {
 		return
 	}
 
-	// 硬删除作品
-	if err := db.Delete(&portfolio).Error; err != nil {
+	// 默认移入回收站，permanent=true 时连同版本一起永久删除
+	if c.Query("permanent") != "true" {
+		if err := moveToTrash(db, &portfolio); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Portfolio moved to trash"})
+		return
+	}
+
+	// 连同版本、协作者、分享链接、缩略图任务和资源记录一起删除
+	var thumbnailIDs []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		_, thumbnailIDs, err = services.PurgePortfolios(tx, []string{portfolio.ID})
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
 		return
 	}
+	services.ThumbnailSvc.ReleaseThumbnailObjects(thumbnailIDs...)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Portfolio deleted permanently"})
+}
+
+// moveToTrash 将作品移入回收站，记录删除前的状态以便恢复
+func moveToTrash(db *gorm.DB, portfolio *models.Portfolio) error {
+	if portfolio.Status == "deleted" {
+		return nil
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Portfolio deleted successfully"})
+	now := time.Now()
+	portfolio.PreviousStatus = portfolio.Status
+	portfolio.Status = "deleted"
+	portfolio.TrashedAt = &now
+	return db.Save(portfolio).Error
 }
@@ -1,22 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oldweipro/design-ai/database"
 	"github.com/oldweipro/design-ai/middleware"
 	"github.com/oldweipro/design-ai/models"
 	"github.com/oldweipro/design-ai/services"
+	"github.com/oldweipro/design-ai/utils"
 	"gorm.io/gorm"
 )
 
 // CreatePortfolioVersion 创建作品版本
 func CreatePortfolioVersion(c *gin.Context) {
-	userID, exists := middleware.GetCurrentUserID(c)
+	_, exists := middleware.GetCurrentUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -38,35 +41,27 @@ func CreatePortfolioVersion(c *gin.Context) {
 
 	// 验证作品存在且用户有权限
 	var portfolio models.Portfolio
-	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil {
+	if err := db.Where("id = ? AND status <> ?", portfolioID, "deleted").First(&portfolio).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
 
-	// 权限检查：只有作品所有者或管理员可以创建版本
-	isAdmin := middleware.IsAdmin(c)
-	if !isAdmin && portfolio.UserID != userID {
+	// 权限检查：作品所有者、编辑者或管理员可以创建版本
+	if !canEditPortfolio(c, db, &portfolio) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 
-	// 获取下一个版本号
-	nextVersion, err := getNextVersionNumber(db, portfolioID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate version number"})
-		return
-	}
-
-	// 生成缩略图
+	// 先使用内容摘要作为缩略图，渲染后的缩略图由后台任务生成
 	thumbnail := ""
 	if req.HTMLContent != "" {
-		thumbnail = services.ThumbnailSvc.GenerateHTMLThumbnail(req.HTMLContent)
+		thumbnail = services.ThumbnailSvc.SummaryThumbnail(req.HTMLContent)
 	}
 
 	// 创建新版本
 	version := models.PortfolioVersion{
 		PortfolioID: portfolioID,
-		Version:     nextVersion,
+		Branch:      req.Branch,
 		Title:       req.Title,
 		Description: req.Description,
 		HTMLContent: req.HTMLContent,
@@ -75,10 +70,43 @@ func CreatePortfolioVersion(c *gin.Context) {
 		ChangeLog:   req.ChangeLog,
 	}
 
-	if err := db.Create(&version).Error; err != nil {
+	// 分配版本号并创建
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := assignVersionNumber(tx, &version, req.Version, req.Bump); err != nil {
+			return err
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+			return err
+		}
+		if version.IsActive {
+			if err := activateVersion(tx, portfolioID, version.ID); err != nil {
+				return err
+			}
+		}
+		if portfolio.Status == "published" {
+			return publishVersions(tx, portfolioID)
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidBranch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errSecurityFindings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": version.Findings()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create version"})
 		return
 	}
+	services.ThumbnailQueueSvc.Notify()
+	if portfolio.Status == "published" {
+		db.Where("id = ?", version.ID).First(&version)
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Version created successfully",
@@ -96,15 +124,21 @@ func GetPortfolioVersions(c *gin.Context) {
 
 	db := database.GetDB()
 
-	// 验证作品存在
+	// 验证作品存在且有查看权限
 	var portfolio models.Portfolio
-	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil {
+	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
 
+	// 可按分支过滤
+	query := db.Where("portfolio_id = ?", portfolioID)
+	if branch := c.Query("branch"); branch != "" {
+		query = query.Where("branch = ?", branch)
+	}
+
 	var versions []models.PortfolioVersion
-	if err := db.Where("portfolio_id = ?", portfolioID).
+	if err := query.
 		Order("created_at DESC").
 		Find(&versions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get versions"})
@@ -114,6 +148,7 @@ func GetPortfolioVersions(c *gin.Context) {
 	// 转换为响应格式
 	responses := make([]models.PortfolioVersionResponse, len(versions))
 	for i, version := range versions {
+		version.IsActive = version.ID == portfolio.ActiveVersionID
 		responses[i] = version.ToResponse()
 	}
 
@@ -122,6 +157,51 @@ func GetPortfolioVersions(c *gin.Context) {
 	})
 }
 
+// GetPortfolioBranches 获取作品的分支列表
+func GetPortfolioBranches(c *gin.Context) {
+	portfolioID := c.Param("id")
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var versions []models.PortfolioVersion
+	if err := db.Select("id, branch, version, major, minor, patch, created_at").
+		Where("portfolio_id = ?", portfolioID).
+		Order("major DESC, minor DESC, patch DESC").
+		Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get branches"})
+		return
+	}
+
+	// 按分支汇总，版本已按版本号降序排列，第一个即为分支最新版本
+	branches := make([]models.BranchResponse, 0)
+	index := make(map[string]int)
+	for _, version := range versions {
+		i, exists := index[version.Branch]
+		if !exists {
+			index[version.Branch] = len(branches)
+			branches = append(branches, models.BranchResponse{
+				Name:            version.Branch,
+				LatestVersion:   version.Version,
+				LatestVersionID: version.ID,
+				UpdatedAt:       version.CreatedAt,
+			})
+			i = len(branches) - 1
+		}
+		branches[i].VersionCount++
+		if version.CreatedAt.After(branches[i].UpdatedAt) {
+			branches[i].UpdatedAt = version.CreatedAt
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": branches})
+}
+
 // GetPortfolioVersion 获取特定版本
 func GetPortfolioVersion(c *gin.Context) {
 	portfolioID := c.Param("id")
@@ -134,21 +214,172 @@ func GetPortfolioVersion(c *gin.Context) {
 
 	db := database.GetDB()
 
+	// 私有作品的版本只有有权限的用户可以查看
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
 	var version models.PortfolioVersion
 	if err := db.Where("portfolio_id = ? AND id = ?", portfolioID, versionID).
 		First(&version).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
 		return
 	}
+	version.IsActive = version.ID == portfolio.ActiveVersionID
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": version.ToResponse(),
 	})
 }
 
+// RevertPortfolioVersion 回退到历史版本：基于历史版本的内容创建新版本，并自动记录变更日志
+func RevertPortfolioVersion(c *gin.Context) {
+	_, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	portfolioID := c.Param("id")
+	versionID := c.Param("versionId")
+
+	var req models.RevertVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", portfolioID, "deleted").First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if !canEditPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var source models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolioID, versionID).First(&source).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := source.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	branch := source.Branch
+	if req.Branch != "" {
+		branch = req.Branch
+	}
+	activate := req.Activate == nil || *req.Activate
+
+	changeLog := fmt.Sprintf("Reverted to %s", source.Version)
+	if req.Message != "" {
+		changeLog += "\n\n" + req.Message
+	}
+
+	// 内容相同，直接复用内容存储和缩略图
+	version := models.PortfolioVersion{
+		PortfolioID:       portfolioID,
+		Branch:            branch,
+		Title:             source.Title,
+		Description:       source.Description,
+		HTMLContent:       source.HTMLContent,
+		Thumbnail:         source.Thumbnail,
+		ThumbnailObjectID: source.ThumbnailObjectID,
+		ContentSummary:    source.ContentSummary,
+		SearchText:        source.SearchText,
+		IsActive:          activate,
+		ChangeLog:         changeLog,
+		ParentID:          source.ID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := assignVersionNumber(tx, &version, "", req.Bump); err != nil {
+			return err
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		if err := copyVersionAssets(tx, source.ID, version.ID); err != nil {
+			return err
+		}
+		if activate {
+			if err := activateVersion(tx, portfolioID, version.ID); err != nil {
+				return err
+			}
+		}
+		if portfolio.Status == "published" {
+			return publishVersions(tx, portfolioID)
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidBranch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errSecurityFindings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": version.Findings()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revert version"})
+		return
+	}
+
+	db.Where("id = ?", version.ID).First(&version)
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Version reverted successfully",
+		"version": version.ToResponse(),
+	})
+}
+
+// DiffPortfolioVersions 对比两个版本，返回行级差异和HTML结构差异
+func DiffPortfolioVersions(c *gin.Context) {
+	portfolioID := c.Param("id")
+	fromID := c.Param("versionId")
+	toID := c.Param("otherVersionId")
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", portfolioID).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var from, to models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolioID, fromID).First(&from).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolioID, toID).First(&to).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := models.LoadVersionContents(db, &from, &to); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": services.DiffSvc.DiffVersions(&from, &to),
+	})
+}
+
 // UpdatePortfolioVersion 更新版本
 func UpdatePortfolioVersion(c *gin.Context) {
-	userID, exists := middleware.GetCurrentUserID(c)
+	_, exists := middleware.GetCurrentUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -178,24 +409,70 @@ func UpdatePortfolioVersion(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
 		return
 	}
+	// 回收站中的作品只能先恢复再修改
+	if version.Portfolio == nil || version.Portfolio.Status == "deleted" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
 
-	// 权限检查
-	isAdmin := middleware.IsAdmin(c)
-	if !isAdmin && version.Portfolio.UserID != userID {
+	// 权限检查：作品所有者、编辑者或管理员
+	if !canEditPortfolio(c, db, version.Portfolio) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
 
-	// 使用事务来处理版本激活逻辑
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// 如果要设置为激活版本，先取消其他版本的激活状态
-		if req.IsActive != nil && *req.IsActive {
-			if err := tx.Model(&models.PortfolioVersion{}).
-				Where("portfolio_id = ? AND id != ?", portfolioID, versionID).
-				Update("is_active", false).Error; err != nil {
+	// 已发布版本是不可修改的快照：修改内容时基于它创建新版本，其余信息仍可原地修改
+	if req.HTMLContent != "" && req.HTMLContent != version.HTMLContent &&
+		(version.PublishedAt != nil || version.Portfolio.Status == "published") {
+		var forked *models.PortfolioVersion
+		err := db.Transaction(func(tx *gorm.DB) error {
+			title := version.Title
+			if req.Title != "" {
+				title = req.Title
+			}
+			description := version.Description
+			if req.Description != "" {
+				description = req.Description
+			}
+			isActive := version.IsActive
+			if req.IsActive != nil {
+				isActive = *req.IsActive
+			}
+
+			var err error
+			forked, err = createVersionFrom(tx, &version, title, description, req.HTMLContent, req.ChangeLog, req.Bump, isActive)
+			if err != nil {
 				return err
 			}
+			if version.Portfolio.Status == "published" {
+				return publishVersions(tx, portfolioID)
+			}
+			return nil
+		})
+		if errors.Is(err, errSecurityFindings) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": forked.Findings()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create version"})
+			return
 		}
+		services.ThumbnailQueueSvc.Notify()
+
+		db.Where("id = ?", forked.ID).First(forked)
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Published versions are immutable, a new version was created",
+			"version": forked.ToResponse(),
+		})
+		return
+	}
+
+	// 版本信息和活跃版本在同一事务中更新
+	err := db.Transaction(func(tx *gorm.DB) error {
 
 		// 更新版本信息
 		updates := make(map[string]interface{})
@@ -205,33 +482,45 @@ func UpdatePortfolioVersion(c *gin.Context) {
 		if req.Description != "" {
 			updates["description"] = req.Description
 		}
-		if req.HTMLContent != "" {
-			updates["html_content"] = req.HTMLContent
-			updates["thumbnail"] = services.ThumbnailSvc.GenerateHTMLThumbnail(req.HTMLContent)
+		contentChanged := req.HTMLContent != "" && req.HTMLContent != version.HTMLContent
+		if contentChanged {
+			version.HTMLContent = req.HTMLContent
+			version.Thumbnail = services.ThumbnailSvc.SummaryThumbnail(req.HTMLContent)
+			updates["thumbnail"] = version.Thumbnail
 		}
 		if req.ChangeLog != "" {
 			updates["change_log"] = req.ChangeLog
 		}
-		if req.IsActive != nil {
-			updates["is_active"] = *req.IsActive
-		}
 
 		if len(updates) > 0 {
 			if err := tx.Model(&version).Updates(updates).Error; err != nil {
 				return err
 			}
 		}
+		if contentChanged {
+			if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+				return err
+			}
+		}
 
-		return nil
+		switch {
+		case req.IsActive == nil:
+			return nil
+		case *req.IsActive:
+			return activateVersion(tx, portfolioID, versionID)
+		default:
+			return deactivateVersion(tx, portfolioID, versionID)
+		}
 	})
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update version"})
 		return
 	}
+	services.ThumbnailQueueSvc.Notify()
 
 	// 重新加载更新后的版本
-	db.Where("id = ?", versionID).First(&version)
+	db.Preload("Portfolio").Where("id = ?", versionID).First(&version)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Version updated successfully",
@@ -241,7 +530,7 @@ func UpdatePortfolioVersion(c *gin.Context) {
 
 // DeletePortfolioVersion 删除版本
 func DeletePortfolioVersion(c *gin.Context) {
-	userID, exists := middleware.GetCurrentUserID(c)
+	_, exists := middleware.GetCurrentUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -265,15 +554,25 @@ func DeletePortfolioVersion(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
 		return
 	}
+	// 回收站中的作品只能先恢复再修改
+	if version.Portfolio == nil || version.Portfolio.Status == "deleted" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
 
-	// 权限检查
-	isAdmin := middleware.IsAdmin(c)
-	if !isAdmin && version.Portfolio.UserID != userID {
+	// 权限检查：作品所有者、编辑者或管理员
+	if !canEditPortfolio(c, db, version.Portfolio) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 
-	if err := db.Delete(&version).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := deactivateVersion(tx, portfolioID, versionID); err != nil {
+			return err
+		}
+		return tx.Delete(&version).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete version"})
 		return
 	}
@@ -285,7 +584,7 @@ func DeletePortfolioVersion(c *gin.Context) {
 
 // SetActiveVersion 设置激活版本
 func SetActiveVersion(c *gin.Context) {
-	userID, exists := middleware.GetCurrentUserID(c)
+	_, exists := middleware.GetCurrentUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -309,31 +608,24 @@ func SetActiveVersion(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
 		return
 	}
+	// 回收站中的作品只能先恢复再修改
+	if version.Portfolio == nil || version.Portfolio.Status == "deleted" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
 
-	// 权限检查
-	isAdmin := middleware.IsAdmin(c)
-	if !isAdmin && version.Portfolio.UserID != userID {
+	// 权限检查：作品所有者、编辑者或管理员
+	if !canEditPortfolio(c, db, version.Portfolio) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 
-	// 使用事务处理激活逻辑
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// 取消所有版本的激活状态
-		if err := tx.Model(&models.PortfolioVersion{}).
-			Where("portfolio_id = ?", portfolioID).
-			Update("is_active", false).Error; err != nil {
-			return err
-		}
-
-		// 设置当前版本为激活状态
-		if err := tx.Model(&version).Update("is_active", true).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-
+	// 单条语句切换活跃版本，并发请求中最后提交的一个生效
+	err := activateVersion(db, portfolioID, versionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set active version"})
 		return
@@ -344,40 +636,167 @@ func SetActiveVersion(c *gin.Context) {
 	})
 }
 
-// 辅助函数：获取下一个版本号
-func getNextVersionNumber(db *gorm.DB, portfolioID string) (string, error) {
-	var maxVersion string
-	err := db.Model(&models.PortfolioVersion{}).
-		Where("portfolio_id = ?", portfolioID).
-		Select("version").
-		Order("created_at DESC").
-		Limit(1).
-		Pluck("version", &maxVersion).Error
+// errSecurityFindings 管理员设置了存在安全问题的内容不允许发布
+var errSecurityFindings = errors.New("portfolio content has security findings that block publishing")
 
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return "v1.0", nil
+// ensurePublishable 管理员开启了发布拦截时，作品的任一版本内容中仍存在安全问题都不允许发布
+func ensurePublishable(tx *gorm.DB, portfolioID string) error {
+	var settings models.AdminSettings
+	if err := tx.First(&settings).Error; err != nil || !settings.BlockPublishOnFindings {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.PortfolioVersion{}).
+		Where("portfolio_id = ? AND finding_count > 0", portfolioID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errSecurityFindings
+	}
+	return nil
+}
+
+// portfolioFindings 返回作品中存在安全问题的版本及其问题列表，用于发布被拦截时的响应
+func portfolioFindings(db *gorm.DB, portfolioID string) []gin.H {
+	var versions []models.PortfolioVersion
+	db.Select("id, version, security_findings").
+		Where("portfolio_id = ? AND finding_count > 0", portfolioID).
+		Order("created_at ASC").
+		Find(&versions)
+
+	findings := make([]gin.H, 0, len(versions))
+	for i := range versions {
+		findings = append(findings, gin.H{
+			"versionId": versions[i].ID,
+			"version":   versions[i].Version,
+			"findings":  versions[i].Findings(),
+		})
+	}
+	return findings
+}
+
+// publishVersions 将作品当前的所有版本标记为已发布，已发布版本的内容不可修改
+func publishVersions(tx *gorm.DB, portfolioID string) error {
+	if err := ensurePublishable(tx, portfolioID); err != nil {
+		return err
+	}
+	return tx.Model(&models.PortfolioVersion{}).
+		Where("portfolio_id = ? AND published_at IS NULL", portfolioID).
+		Update("published_at", time.Now()).Error
+}
+
+// createVersionFrom 基于已有版本在同一分支上创建新版本，新版本使用下一个版本号并记录来源，
+// 同时沿用来源版本的资源文件
+func createVersionFrom(tx *gorm.DB, parent *models.PortfolioVersion, title, description, htmlContent, changeLog, bump string, isActive bool) (*models.PortfolioVersion, error) {
+	version := &models.PortfolioVersion{
+		PortfolioID: parent.PortfolioID,
+		Branch:      parent.Branch,
+		Title:       title,
+		Description: description,
+		HTMLContent: htmlContent,
+		Thumbnail:   services.ThumbnailSvc.SummaryThumbnail(htmlContent),
+		IsActive:    isActive,
+		ChangeLog:   changeLog,
+		ParentID:    parent.ID,
+	}
+	if err := assignVersionNumber(tx, version, "", bump); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return nil, err
+	}
+	if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+		return nil, err
+	}
+	if err := copyVersionAssets(tx, parent.ID, version.ID); err != nil {
+		return nil, err
+	}
+	if isActive {
+		if err := activateVersion(tx, parent.PortfolioID, version.ID); err != nil {
+			return nil, err
 		}
-		return "", err
 	}
+	return version, nil
+}
+
+// activateVersion 将作品的活跃版本指向指定版本。检查版本归属和更新在同一条语句中完成，
+// 版本不存在、不属于该作品或已被删除时返回 gorm.ErrRecordNotFound。
+// active_version_id 在模型上只允许创建时写入，因此这里按表名更新
+func activateVersion(tx *gorm.DB, portfolioID, versionID string) error {
+	exists := tx.Session(&gorm.Session{NewDB: true}).Model(&models.PortfolioVersion{}).
+		Select("1").
+		Where("id = ? AND portfolio_id = ?", versionID, portfolioID)
+	result := tx.Table("portfolios").
+		Where("id = ? AND EXISTS (?)", portfolioID, exists).
+		Update("active_version_id", versionID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// deactivateVersion 指定版本是活跃版本时清空作品的活跃版本
+func deactivateVersion(tx *gorm.DB, portfolioID, versionID string) error {
+	return tx.Table("portfolios").
+		Where("id = ? AND active_version_id = ?", portfolioID, versionID).
+		Update("active_version_id", "").Error
+}
+
+// errInvalidBranch 分支名不合法
+var errInvalidBranch = errors.New("invalid branch name: use lowercase letters, digits and hyphens")
+
+// normalizeBranch 规范化分支名，空分支名表示默认分支
+func normalizeBranch(branch string) (string, error) {
+	branch = strings.ToLower(strings.TrimSpace(branch))
+	if branch == "" {
+		return utils.DefaultBranch, nil
+	}
+	if !utils.ValidBranchName(branch) {
+		return "", errInvalidBranch
+	}
+	return branch, nil
+}
 
-	if maxVersion == "" {
-		return "v1.0", nil
+// assignVersionNumber 为新版本分配语义化版本号：指定的版本号合法且未被占用时直接使用，
+// 否则在同一分支最大版本号的基础上按 bump 递增。回收站中的版本同样占用版本号
+func assignVersionNumber(tx *gorm.DB, version *models.PortfolioVersion, requested, bump string) error {
+	branch, err := normalizeBranch(version.Branch)
+	if err != nil {
+		return err
 	}
+	version.Branch = branch
 
-	// 解析版本号（假设格式为 v1.0, v1.1, v2.0 等）
-	if strings.HasPrefix(maxVersion, "v") {
-		versionParts := strings.Split(maxVersion[1:], ".")
-		if len(versionParts) >= 2 {
-			major, err1 := strconv.Atoi(versionParts[0])
-			minor, err2 := strconv.Atoi(versionParts[1])
-			if err1 == nil && err2 == nil {
-				minor++
-				return fmt.Sprintf("v%d.%d", major, minor), nil
-			}
+	if major, minor, patch, ok := utils.ParseSemver(requested); ok {
+		label := utils.FormatVersion(major, minor, patch, branch)
+		var count int64
+		if err := tx.Unscoped().Model(&models.PortfolioVersion{}).
+			Where("portfolio_id = ? AND version = ?", version.PortfolioID, label).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			version.Major, version.Minor, version.Patch, version.Version = major, minor, patch, label
+			return nil
 		}
 	}
 
-	// 如果解析失败，返回一个默认的新版本
-	return "v1.0", nil
+	var latest models.PortfolioVersion
+	err = tx.Unscoped().Select("major, minor, patch").
+		Where("portfolio_id = ? AND branch = ?", version.PortfolioID, branch).
+		Order("major DESC, minor DESC, patch DESC").
+		First(&latest).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		version.Major, version.Minor, version.Patch = 1, 0, 0
+	case err != nil:
+		return err
+	default:
+		version.Major, version.Minor, version.Patch = utils.BumpVersion(latest.Major, latest.Minor, latest.Patch, bump)
+	}
+	version.Version = utils.FormatVersion(version.Major, version.Minor, version.Patch, branch)
+	return nil
 }
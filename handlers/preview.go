@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+	"github.com/oldweipro/design-ai/utils"
+)
+
+// PreviewVersion 以沙箱方式输出版本的HTML内容，供 iframe 嵌入预览。
+// 支持 viewport=mobile|tablet|desktop 参数将内容嵌入设备框架中。
+// 预览页面运行作者的脚本，不接受登录凭证，非公开的版本需要通过 token 参数携带预览令牌
+func PreviewVersion(c *gin.Context) {
+	// 配置了独立预览域名时，预览内容只在预览域名下输出
+	if target, ok := services.PreviewSvc.RedirectURL(c.Request.Host, c.Request.URL); ok {
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+
+	var viewport *services.ViewportPreset
+	viewportName := c.Query("viewport")
+	if viewportName != "" {
+		preset, ok := services.PreviewSvc.Viewport(viewportName)
+		if !ok {
+			c.String(http.StatusBadRequest, "Unsupported viewport")
+			return
+		}
+		viewport = &preset
+		viewportName = preset.Name
+	}
+
+	db := database.GetDB()
+
+	portfolio, version, ok := loadPreviewVersion(c)
+	if !ok {
+		return
+	}
+
+	var assets []models.VersionAsset
+	db.Where("version_id = ?", version.ID).Find(&assets)
+
+	setPreviewHeaders(c, portfolio, version)
+
+	// 预览令牌会写入资源地址，页面内容随令牌变化，不缓存
+	token := c.Query("token")
+	if token != "" {
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		// 资源文件变化时入口页面中的资源地址也会变化，实体标签需要包含资源摘要
+		contentHash := version.ContentHash
+		if digest := services.PreviewSvc.AssetDigest(assets); digest != "" {
+			contentHash += "." + digest
+		}
+		etag := services.PreviewSvc.ETag(contentHash, viewportName)
+		c.Header("ETag", etag)
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	// 命中缓存时不需要读取内容
+	if err := version.LoadContent(db); err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load version content")
+		return
+	}
+
+	// 预览令牌只能访问当前版本，资源地址同样需要携带
+	query := url.Values{}
+	if token != "" {
+		query.Set("token", token)
+	}
+	content := services.PreviewSvc.NewAssetRewriter(portfolio.ID, version.ID, assets, query).Rewrite(version.HTMLContent)
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(services.PreviewSvc.Render(content, viewport)))
+}
+
+// PreviewVersionAsset 输出版本中的资源文件，资源文件之间的相对地址按目录结构自然解析
+func PreviewVersionAsset(c *gin.Context) {
+	if target, ok := services.PreviewSvc.RedirectURL(c.Request.Host, c.Request.URL); ok {
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+
+	assetPath, err := models.NormalizeAssetPath(strings.TrimPrefix(c.Param("filepath"), "/"))
+	if err != nil {
+		c.String(http.StatusNotFound, "Asset not found")
+		return
+	}
+
+	portfolio, version, ok := loadPreviewVersion(c)
+	if !ok {
+		return
+	}
+
+	var asset models.VersionAsset
+	if err := database.GetDB().Where("version_id = ? AND path = ?", version.ID, assetPath).First(&asset).Error; err != nil {
+		c.String(http.StatusNotFound, "Asset not found")
+		return
+	}
+
+	setPreviewHeaders(c, portfolio, version)
+	if c.Query("token") != "" {
+		c.Header("Cache-Control", "private, no-store")
+	}
+	// 沙箱中的页面没有源，字体等需要跨源许可的资源才能加载。预览令牌不依赖 Cookie，允许任意来源是安全的
+	c.Header("Access-Control-Allow-Origin", "*")
+
+	etag := fmt.Sprintf(`"%s"`, asset.Hash)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, _, err := services.NewMinIOService().OpenFile(asset.FileObjectID)
+	if err != nil {
+		c.String(http.StatusBadGateway, "Failed to load asset")
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, reader, nil)
+}
+
+// loadPreviewVersion 加载预览的作品和版本并检查访问权限，失败时已写入响应。
+// 携带预览令牌时按令牌授权，否则只能预览公开的作品
+func loadPreviewVersion(c *gin.Context) (*models.Portfolio, *models.PortfolioVersion, bool) {
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", c.Param("portfolioId"), "deleted").First(&portfolio).Error; err != nil {
+		c.String(http.StatusNotFound, "Portfolio not found")
+		return nil, nil, false
+	}
+	if token := c.Query("token"); token != "" {
+		if err := utils.VerifyPreviewToken(token, portfolio.ID, c.Param("versionId")); err != nil {
+			c.String(http.StatusNotFound, "Portfolio not found")
+			return nil, nil, false
+		}
+	} else if !canViewPortfolio(c, db, &portfolio) {
+		c.String(http.StatusNotFound, "Portfolio not found")
+		return nil, nil, false
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.String(http.StatusNotFound, "Version not found")
+		return nil, nil, false
+	}
+	return &portfolio, &version, true
+}
+
+// setPreviewHeaders 设置预览内容的安全和缓存响应头
+func setPreviewHeaders(c *gin.Context, portfolio *models.Portfolio, version *models.PortfolioVersion) {
+	c.Header("Content-Security-Policy", services.PreviewSvc.ContentSecurityPolicy())
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cross-Origin-Opener-Policy", "same-origin")
+
+	// 已发布的公开版本内容不可变，可以长期缓存；其他版本每次都需要重新验证
+	if version.PublishedAt != nil && portfolio.Status == "published" && !portfolio.MembersOnly && portfolio.Visibility != models.VisibilityPrivate {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+}
+
+// CreatePreviewToken 为有权查看的用户签发短期预览令牌，用于在 iframe 中预览非公开的版本
+func CreatePreviewToken(c *gin.Context) {
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", c.Param("id"), "deleted").First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Select("id").Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	expiresAt := time.Now().Add(utils.PreviewTokenTTL)
+	token := utils.SignPreviewToken(portfolio.ID, version.ID, expiresAt)
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"token":     token,
+		"url":       fmt.Sprintf("%s/preview/%s/%s?token=%s", services.PreviewSvc.Origin(), url.PathEscape(portfolio.ID), url.PathEscape(version.ID), url.QueryEscape(token)),
+		"expiresAt": expiresAt,
+	}})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/utils"
+	"gorm.io/gorm"
+)
+
+// buildShareLinkResponse 生成分享链接响应，附带签名令牌和访问地址
+func buildShareLinkResponse(link models.ShareLink) models.ShareLinkResponse {
+	token := utils.SignShareToken(link.ID)
+	return models.ShareLinkResponse{
+		ID:                link.ID,
+		PortfolioID:       link.PortfolioID,
+		VersionID:         link.VersionID,
+		Token:             token,
+		URL:               "/api/v1/shared/" + token,
+		PasswordProtected: link.PasswordHash != "",
+		ExpiresAt:         link.ExpiresAt,
+		RevokedAt:         link.RevokedAt,
+		AccessCount:       link.AccessCount,
+		LastAccessedAt:    link.LastAccessedAt,
+		FailedAttempts:    link.TotalFailures,
+		LockedUntil:       link.LockedUntil,
+		CreatedAt:         link.CreatedAt,
+	}
+}
+
+// findEditablePortfolio 查找作品并检查编辑权限，失败时已写入响应
+func findEditablePortfolio(c *gin.Context, db *gorm.DB) (*models.Portfolio, bool) {
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", c.Param("id"), "deleted").First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return nil, false
+	}
+	if !canEditPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return nil, false
+	}
+	return &portfolio, true
+}
+
+// GetShareLinks 获取作品的分享链接列表
+func GetShareLinks(c *gin.Context) {
+	db := database.GetDB()
+	portfolio, ok := findEditablePortfolio(c, db)
+	if !ok {
+		return
+	}
+
+	var links []models.ShareLink
+	if err := db.Where("portfolio_id = ?", portfolio.ID).Order("created_at DESC").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get share links"})
+		return
+	}
+
+	responses := make([]models.ShareLinkResponse, 0, len(links))
+	for _, link := range links {
+		responses = append(responses, buildShareLinkResponse(link))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// CreateShareLink 创建分享链接，可以限定版本、有效期和访问密码
+func CreateShareLink(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	portfolio, ok := findEditablePortfolio(c, db)
+	if !ok {
+		return
+	}
+
+	if req.VersionID != "" {
+		var count int64
+		db.Model(&models.PortfolioVersion{}).Where("portfolio_id = ? AND id = ?", portfolio.ID, req.VersionID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+	}
+
+	link := models.ShareLink{
+		PortfolioID: portfolio.ID,
+		VersionID:   req.VersionID,
+		CreatedBy:   userID,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+	if err := link.SetPassword(req.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := db.Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": buildShareLinkResponse(link)})
+}
+
+// RevokeShareLink 撤销分享链接
+func RevokeShareLink(c *gin.Context) {
+	db := database.GetDB()
+	portfolio, ok := findEditablePortfolio(c, db)
+	if !ok {
+		return
+	}
+
+	var link models.ShareLink
+	if err := db.Where("id = ? AND portfolio_id = ?", c.Param("linkId"), portfolio.ID).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	if link.RevokedAt == nil {
+		now := time.Now()
+		link.RevokedAt = &now
+		if err := db.Save(&link).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
+
+// GetSharedPortfolio 通过分享链接只读访问作品或指定版本
+func GetSharedPortfolio(c *gin.Context) {
+	linkID, err := utils.VerifyShareToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	db := database.GetDB()
+	var link models.ShareLink
+	if err := db.Where("id = ?", linkID).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	if !link.IsUsable() {
+		c.JSON(http.StatusGone, gin.H{"error": "Share link has expired or been revoked"})
+		return
+	}
+
+	// 密码只通过请求头传递，避免出现在访问日志、浏览历史和 Referer 中
+	if link.PasswordHash != "" {
+		if link.IsLocked() {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(*link.LockedUntil).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many incorrect passwords, try again later"})
+			return
+		}
+		password := c.GetHeader("X-Share-Password")
+		if !link.CheckPassword(password) {
+			if password != "" {
+				recordSharePasswordFailure(db, link.ID)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password required", "passwordRequired": true})
+			return
+		}
+		if link.FailedAttempts > 0 {
+			db.Model(&link).Update("failed_attempts", 0)
+		}
+	}
+
+	var portfolio models.Portfolio
+	if err := db.Preload("User").
+		Preload("Versions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).
+		Preload("ActiveVersion").
+		Preload("Collaborators.User").
+		Preload("Organization").
+		Where("id = ? AND status <> ?", link.PortfolioID, "deleted").
+		First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	// 记录访问次数
+	now := time.Now()
+	db.Model(&link).Updates(map[string]interface{}{
+		"access_count":     gorm.Expr("access_count + 1"),
+		"last_accessed_at": now,
+	})
+
+	if link.VersionID == "" {
+		if err := loadPortfolioContents(db, &portfolio); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": buildPortfolioResponse(portfolio), "readOnly": true})
+		return
+	}
+
+	// 只分享指定版本时不返回其他版本
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, link.VersionID).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+	response := buildPortfolioResponse(portfolio)
+	response.Versions = nil
+	response.ActiveVersion = nil
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     response,
+		"version":  version.ToResponse(),
+		"readOnly": true,
+	})
+}
+
+// recordSharePasswordFailure 记录一次密码错误，连续错误次数达到上限时锁定分享链接。
+// 计数在数据库中累加，多个服务实例共享同一限制
+func recordSharePasswordFailure(db *gorm.DB, linkID string) {
+	db.Model(&models.ShareLink{}).Where("id = ?", linkID).Updates(map[string]interface{}{
+		"failed_attempts": gorm.Expr("failed_attempts + 1"),
+		"total_failures":  gorm.Expr("total_failures + 1"),
+	})
+	db.Model(&models.ShareLink{}).
+		Where("id = ? AND failed_attempts >= ?", linkID, models.ShareLinkMaxPasswordAttempts).
+		Updates(map[string]interface{}{
+			"failed_attempts": 0,
+			"locked_until":    time.Now().Add(models.ShareLinkLockout),
+		})
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+)
+
+// GetTrash 获取回收站内容
+func GetTrash(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var query models.TrashQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 只有管理员可以查看所有用户的回收站
+	allUsers := query.All && middleware.IsAdmin(c)
+	retention := services.TrashRetention()
+	db := database.GetDB()
+	items := make([]models.TrashItem, 0)
+
+	if query.Type == "" || query.Type == models.TrashTypePortfolio {
+		dbQuery := db.Where("status = ?", "deleted")
+		if !allUsers {
+			dbQuery = dbQuery.Where("user_id = ?", userID)
+		}
+		var portfolios []models.Portfolio
+		if err := dbQuery.Find(&portfolios).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+			return
+		}
+		for _, portfolio := range portfolios {
+			deletedAt := portfolio.UpdatedAt
+			if portfolio.TrashedAt != nil {
+				deletedAt = *portfolio.TrashedAt
+			}
+			items = append(items, models.TrashItem{
+				Type:      models.TrashTypePortfolio,
+				ID:        portfolio.ID,
+				Title:     portfolio.Title,
+				OwnerID:   portfolio.UserID,
+				DeletedAt: deletedAt,
+				PurgeAt:   deletedAt.Add(retention),
+			})
+		}
+	}
+
+	if query.Type == "" || query.Type == models.TrashTypeVersion {
+		dbQuery := db.Unscoped().Preload("Portfolio").
+			Where("portfolio_versions.deleted_at IS NOT NULL")
+		if !allUsers {
+			dbQuery = dbQuery.Where("portfolio_id IN (?)",
+				db.Model(&models.Portfolio{}).Select("id").Where("user_id = ?", userID))
+		}
+		var versions []models.PortfolioVersion
+		if err := dbQuery.Find(&versions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+			return
+		}
+		for _, version := range versions {
+			item := models.TrashItem{
+				Type:        models.TrashTypeVersion,
+				ID:          version.ID,
+				Title:       version.Version + " " + version.Title,
+				PortfolioID: version.PortfolioID,
+				DeletedAt:   version.DeletedAt.Time,
+				PurgeAt:     version.DeletedAt.Time.Add(retention),
+			}
+			if version.Portfolio != nil {
+				item.OwnerID = version.Portfolio.UserID
+			}
+			items = append(items, item)
+		}
+	}
+
+	if query.Type == "" || query.Type == models.TrashTypeFile {
+		// 派生文件随原始文件恢复和清理，不单独列出
+		dbQuery := db.Unscoped().Where("deleted_at IS NOT NULL AND (source_id IS NULL OR source_id = '')")
+		if !allUsers {
+			dbQuery = dbQuery.Where("uploaded_by = ?", userID)
+		}
+		var files []models.FileObject
+		if err := dbQuery.Find(&files).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+			return
+		}
+		for _, file := range files {
+			items = append(items, models.TrashItem{
+				Type:      models.TrashTypeFile,
+				ID:        file.ID,
+				Title:     file.OriginalName,
+				OwnerID:   file.UploadedBy,
+				DeletedAt: file.DeletedAt.Time,
+				PurgeAt:   file.DeletedAt.Time.Add(retention),
+			})
+		}
+	}
+
+	// 最近删除的排在前面
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":           items,
+		"retention_days": int(retention.Hours() / 24),
+	})
+}
+
+// RestorePortfolio 从回收站恢复作品
+func RestorePortfolio(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status = ?", c.Param("id"), "deleted").First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found in trash"})
+		return
+	}
+
+	// 权限检查：只有作品所有者或管理员可以恢复
+	if !middleware.IsAdmin(c) && portfolio.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	status := portfolio.PreviousStatus
+	if status == "" || status == "deleted" {
+		status = "draft"
+	}
+
+	if err := db.Model(&portfolio).Updates(map[string]interface{}{
+		"status":          status,
+		"previous_status": "",
+		"trashed_at":      nil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore portfolio"})
+		return
+	}
+
+	db.Preload("User").First(&portfolio, "id = ?", portfolio.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Portfolio restored successfully",
+		"data":    buildPortfolioResponse(portfolio),
+	})
+}
+
+// RestorePortfolioVersion 从回收站恢复版本
+func RestorePortfolioVersion(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+	var version models.PortfolioVersion
+	if err := db.Unscoped().Preload("Portfolio").
+		Where("id = ? AND deleted_at IS NOT NULL", c.Param("id")).
+		First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found in trash"})
+		return
+	}
+
+	// 权限检查
+	if !middleware.IsAdmin(c) && (version.Portfolio == nil || version.Portfolio.UserID != userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	// 删除时已清空活跃版本，恢复的版本不会自动成为活跃版本
+	if err := db.Unscoped().Model(&version).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore version"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Version restored successfully",
+		"version": version.ToResponse(),
+	})
+}
+
+// RestoreFile 从回收站恢复文件
+func RestoreFile(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	objectID := c.Param("id")
+
+	db := database.GetDB()
+	var file models.FileObject
+	if err := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", objectID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found in trash"})
+		return
+	}
+
+	// 权限检查
+	if !middleware.IsAdmin(c) && file.UploadedBy != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	minioService := services.NewMinIOService()
+	if err := minioService.RestoreFile(objectID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore file", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File restored successfully"})
+}
+
+// 管理员：立即清理过期的回收站内容
+func PurgeTrash(c *gin.Context) {
+	result, err := services.PurgeExpiredTrash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge trash", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trash purged successfully",
+		"data":    result,
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+)
+
+// CreateUploadSession 创建可断点续传的分片上传会话，返回分片大小和分片数量。
+// 客户端按分片编号逐个上传，中断后查询会话获取缺失的分片继续上传，全部上传后调用完成接口
+func CreateUploadSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.UploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if services.NewMinIOService().GetActiveConfig() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage is not configured"})
+		return
+	}
+
+	if err := services.CheckDeclaredUploadType(req.ContentType, req.Purpose); err != nil {
+		respondUploadTypeError(c, err, req.Purpose)
+		return
+	}
+
+	db := database.GetDB()
+	if limit := models.UploadSizeLimit(services.UploadSizeLimits(), req.ContentType); limit > 0 && req.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":       "File exceeds the maximum size for its type",
+			"contentType": req.ContentType,
+			"limit":       limit,
+		})
+		return
+	}
+	if !checkOrganizationUpload(c, db, req.OrganizationID, userID, req.Size) {
+		return
+	}
+
+	session, err := services.UploadSessionSvc.Create(req, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadTooManyParts) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload session", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Upload session created", "data": session.ToResponse(nil)})
+}
+
+// GetUploadSession 查询上传会话的进度，包括已上传和缺失的分片
+func GetUploadSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, parts, err := services.UploadSessionSvc.Get(c.Param("id"), userID)
+	if err != nil {
+		uploadSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": session.ToResponse(parts)})
+}
+
+// UploadSessionPart 上传一个分片，请求体为分片的原始内容，必须带 Content-Length。
+// 分片编号从1开始，重复上传同一分片会覆盖之前的内容
+func UploadSessionPart(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	partNumber, err := strconv.Atoi(c.Param("number"))
+	if err != nil || partNumber < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid part number"})
+		return
+	}
+	if c.Request.ContentLength < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return
+	}
+	if c.Request.ContentLength > models.MaxUploadChunkSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Part is too large", "limit": models.MaxUploadChunkSize})
+		return
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, c.Request.ContentLength)
+
+	part, err := services.UploadSessionSvc.UploadPart(c.Param("id"), userID, partNumber, body, c.Request.ContentLength)
+	if err != nil {
+		uploadSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Part uploaded successfully", "data": part})
+}
+
+// CompleteUploadSession 合并全部分片生成文件。会话已完成时返回之前生成的文件，可以安全重试
+func CompleteUploadSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, parts, err := services.UploadSessionSvc.Get(c.Param("id"), userID)
+	if err != nil {
+		uploadSessionError(c, err)
+		return
+	}
+	// 上传期间组织可能已用掉配额，合并前先检查一次，保存文件记录时在事务中再次检查
+	if session.Status == models.UploadSessionActive &&
+		!checkOrganizationUpload(c, database.GetDB(), session.OrganizationID, userID, session.FileSize) {
+		return
+	}
+
+	fileObject, err := services.UploadSessionSvc.Complete(session.ID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadIncomplete) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":        err.Error(),
+				"missingParts": session.ToResponse(parts).MissingParts,
+			})
+			return
+		}
+		uploadSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "file": fileObject})
+}
+
+// AbortUploadSession 取消上传会话，释放已上传的分片
+func AbortUploadSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := services.UploadSessionSvc.Abort(c.Param("id"), userID); err != nil {
+		uploadSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload session aborted"})
+}
+
+// uploadSessionError 将分片上传的错误转换为响应
+func uploadSessionError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrUploadSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+	case errors.Is(err, services.ErrUploadSessionClosed):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadSessionBusy):
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadStorageChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadTypeMismatch), errors.Is(err, services.ErrUploadTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadQuotaExceeded), errors.Is(err, services.ErrUploadTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadPartNumber), errors.Is(err, services.ErrUploadPartSize):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Part is too large"})
+	default:
+		log.Printf("Upload session %s failed: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process upload", "details": err.Error()})
+	}
+}
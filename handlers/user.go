@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oldweipro/design-ai/database"
 	"github.com/oldweipro/design-ai/middleware"
 	"github.com/oldweipro/design-ai/models"
 	"github.com/oldweipro/design-ai/utils"
+	"gorm.io/gorm"
 )
 
 // 用户注册
@@ -110,7 +113,8 @@ func Login(c *gin.Context) {
 		case "pending":
 			message = "Account is pending approval"
 		case "rejected":
-			message = "Account has been rejected"
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account has been rejected", "reason": user.RejectionReason})
+			return
 		case "banned":
 			message = "Account has been banned"
 		default:
@@ -203,6 +207,13 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// 同步更新用户参与的作品作者信息
+	if req.Nickname != "" || req.Username != "" {
+		if err := refreshUserPortfolioAuthors(db, userID); err != nil {
+			log.Printf("Failed to refresh portfolio authors for user %s: %v", userID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Profile updated successfully",
 		"data":    user.ToResponse(),
@@ -277,13 +288,33 @@ func UpdateUserStatus(c *gin.Context) {
 		return
 	}
 
-	user.Status = req.Status
-	if req.Role != "" {
-		user.Role = req.Role
+	// 通过和拒绝按审核决定处理并记录，封禁不属于审核，直接修改状态
+	reviewerID, _ := middleware.GetCurrentUserID(c)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if req.Role != "" {
+			if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("role", req.Role).Error; err != nil {
+				return err
+			}
+		}
+		if req.Status == "banned" {
+			return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+				"status":           req.Status,
+				"rejection_reason": "",
+			}).Error
+		}
+		decision := models.ReviewDecisionApproved
+		if req.Status == "rejected" {
+			decision = models.ReviewDecisionRejected
+		}
+		return setReviewStatus(tx, models.ReviewTargetUser, user.ID, reviewerID, decision, req.Reason)
+	})
+	if err != nil {
+		reviewStatusError(c, db, err, "User not found")
+		return
 	}
 
-	if err := db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	if err := db.Where("id = ?", user.ID).First(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
 		return
 	}
 
@@ -312,8 +343,14 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// 软删除用户的所有作品
-	if err := db.Model(&models.Portfolio{}).Where("user_id = ?", userID).Update("status", "deleted").Error; err != nil {
+	// 将用户的所有作品移入回收站
+	if err := db.Model(&models.Portfolio{}).
+		Where("user_id = ? AND status <> ?", userID, "deleted").
+		Updates(map[string]interface{}{
+			"previous_status": gorm.Expr("status"),
+			"status":          "deleted",
+			"trashed_at":      time.Now(),
+		}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user portfolios"})
 		return
 	}
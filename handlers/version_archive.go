@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+	"gorm.io/gorm"
+)
+
+// maxArchiveUploadSize 导入压缩包的请求体上限，压缩后的大小不会超过解压后的版本总大小限制太多
+const maxArchiveUploadSize = models.MaxVersionTotalSize + 1024*1024
+
+// ImportPortfolioVersion 从ZIP压缩包导入版本：根目录的 index.html 为入口，其余文件作为资源文件。
+// 压缩包中有 manifest.json 时，其中的标题、描述和变更日志作为默认值
+func ImportPortfolioVersion(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", c.Param("id"), "deleted").First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if !canEditPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveUploadSize)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Archive is too large", "limit": maxArchiveUploadSize})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded", "details": err.Error()})
+		return
+	}
+
+	bump := c.PostForm("bump")
+	if bump != "" && bump != "major" && bump != "minor" && bump != "patch" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bump must be one of major, minor, patch"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer src.Close()
+
+	archive, err := services.VersionArchiveSvc.Read(src, file.Size)
+	switch {
+	case errors.Is(err, services.ErrArchiveTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": err.Error(),
+			"limits": gin.H{
+				"maxAssets":    models.MaxVersionAssetCount,
+				"maxAssetSize": models.MaxVersionAssetSize,
+				"maxTotalSize": models.MaxVersionTotalSize,
+			},
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 资源文件的内容必须与扩展名对应的类型一致，且在资源文件允许的类型中
+	for _, f := range archive.Files {
+		if _, _, err := services.CheckUploadType(f.Data, f.Path, f.ContentType, models.UploadPurposeVersionAsset); err != nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   fmt.Sprintf("%s: %v", f.Path, err),
+				"allowed": services.AllowedUploadTypes(models.UploadPurposeVersionAsset),
+			})
+			return
+		}
+	}
+
+	// 表单字段优先，其次使用压缩包中的元数据
+	var manifest models.ManifestVersion
+	if archive.Manifest != nil {
+		manifest = archive.Manifest.Version
+	}
+	title := firstNonEmpty(c.PostForm("title"), manifest.Title, strings.TrimSuffix(file.Filename, path.Ext(file.Filename)))
+	description := firstNonEmpty(c.PostForm("description"), manifest.Description)
+	changeLog := firstNonEmpty(c.PostForm("changeLog"), fmt.Sprintf("Imported from %s", file.Filename))
+
+	var assetSize int64
+	for _, f := range archive.Files {
+		assetSize += int64(len(f.Data))
+	}
+	if !checkPortfolioStorageQuota(c, db, &portfolio, assetSize) {
+		return
+	}
+
+	// 先上传资源文件，内容相同的文件只上传一次
+	minioService := services.NewMinIOService()
+	assets := make([]models.VersionAsset, 0, len(archive.Files))
+	uploaded := make(map[string]string, len(archive.Files))
+	var fileIDs []string
+	for _, f := range archive.Files {
+		sum := sha256.Sum256(f.Data)
+		hash := hex.EncodeToString(sum[:])
+		fileID, ok := uploaded[hash]
+		if !ok {
+			fileObject, err := minioService.UploadReader(bytes.NewReader(f.Data), int64(len(f.Data)), path.Base(f.Path), f.ContentType,
+				userID, portfolio.OrganizationID, false, map[string]string{"purpose": models.UploadPurposeVersionAsset})
+			if err != nil {
+				releaseAssetFiles(db, fileIDs...)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file", "details": err.Error()})
+				return
+			}
+			fileID = fileObject.ID
+			uploaded[hash] = fileID
+			fileIDs = append(fileIDs, fileID)
+		}
+		assets = append(assets, models.VersionAsset{
+			Path:         f.Path,
+			FileObjectID: fileID,
+			ContentType:  f.ContentType,
+			Size:         int64(len(f.Data)),
+			Hash:         hash,
+		})
+	}
+
+	isActive := c.PostForm("isActive") == "true"
+	version := models.PortfolioVersion{
+		PortfolioID: portfolio.ID,
+		Branch:      c.PostForm("branch"),
+		Title:       title,
+		Description: description,
+		HTMLContent: archive.HTMLContent,
+		Thumbnail:   services.ThumbnailSvc.SummaryThumbnail(archive.HTMLContent),
+		IsActive:    isActive,
+		ChangeLog:   changeLog,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := assignVersionNumber(tx, &version, c.PostForm("version"), bump); err != nil {
+			return err
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		if len(assets) > 0 {
+			for i := range assets {
+				assets[i].VersionID = version.ID
+			}
+			if err := tx.Create(&assets).Error; err != nil {
+				return err
+			}
+		}
+		if err := services.ThumbnailQueueSvc.Enqueue(tx, version.ID, version.ContentHash, models.ThumbnailJobReasonSave); err != nil {
+			return err
+		}
+		if isActive {
+			if err := activateVersion(tx, portfolio.ID, version.ID); err != nil {
+				return err
+			}
+		}
+		if portfolio.Status == "published" {
+			return publishVersions(tx, portfolio.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		releaseAssetFiles(db, fileIDs...)
+	}
+	if errors.Is(err, errInvalidBranch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errSecurityFindings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": version.Findings()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import version"})
+		return
+	}
+	services.ThumbnailQueueSvc.Notify()
+
+	db.Where("id = ?", version.ID).First(&version)
+	version.IsActive = isActive
+	responses := make([]models.VersionAssetResponse, 0, len(assets))
+	for _, asset := range assets {
+		responses = append(responses, asset.ToResponse(portfolio.ID))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Version imported successfully",
+		"version": version.ToResponse(),
+		"assets":  responses,
+	})
+}
+
+// ExportPortfolioVersion 将版本导出为ZIP压缩包：index.html、资源文件和 manifest.json，解压后可离线打开
+func ExportPortfolioVersion(c *gin.Context) {
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	var assets []models.VersionAsset
+	db.Where("version_id = ?", version.ID).Order("path ASC").Find(&assets)
+
+	minioService := services.NewMinIOService()
+	if len(assets) > 0 && minioService.GetActiveConfig() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage is not configured"})
+		return
+	}
+
+	// 同一分支上截至该版本的变更记录
+	var history []models.PortfolioVersion
+	db.Select("version, title, change_log, created_at").
+		Where("portfolio_id = ? AND branch = ? AND created_at <= ?", portfolio.ID, version.Branch, version.CreatedAt).
+		Order("major DESC, minor DESC, patch DESC").
+		Find(&history)
+
+	manifest := &models.VersionManifest{
+		Format:     models.VersionArchiveFormat,
+		ExportedAt: time.Now(),
+		Entry:      models.VersionEntryFile,
+		Portfolio: models.ManifestPortfolio{
+			ID:       portfolio.ID,
+			Title:    portfolio.Title,
+			Author:   portfolio.Author,
+			Category: portfolio.Category,
+		},
+		Version: models.ManifestVersion{
+			ID:          version.ID,
+			Version:     version.Version,
+			Branch:      version.Branch,
+			Title:       version.Title,
+			Description: version.Description,
+			ChangeLog:   version.ChangeLog,
+			ParentID:    version.ParentID,
+			ContentHash: version.ContentHash,
+			PublishedAt: version.PublishedAt,
+			CreatedAt:   version.CreatedAt,
+		},
+		Assets:    make([]models.ManifestAsset, 0, len(assets)),
+		Changelog: make([]models.ManifestChangelogRow, 0, len(history)),
+	}
+	fileIDs := make(map[string]string, len(assets))
+	for _, asset := range assets {
+		manifest.Assets = append(manifest.Assets, models.ManifestAsset{
+			Path:        asset.Path,
+			ContentType: asset.ContentType,
+			Size:        asset.Size,
+			Hash:        asset.Hash,
+		})
+		fileIDs[asset.Path] = asset.FileObjectID
+	}
+	for _, entry := range history {
+		manifest.Changelog = append(manifest.Changelog, models.ManifestChangelogRow{
+			Version:   entry.Version,
+			Title:     entry.Title,
+			ChangeLog: entry.ChangeLog,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+
+	filename := fmt.Sprintf("%s-%s.zip", portfolio.ID[:8], version.Version)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Status(http.StatusOK)
+
+	err := services.VersionArchiveSvc.Write(c.Writer, manifest, version.HTMLContent, func(asset models.ManifestAsset) (io.ReadCloser, error) {
+		reader, _, err := minioService.OpenFile(fileIDs[asset.Path])
+		return reader, err
+	})
+	if err != nil {
+		// 响应已经开始输出，无法再返回错误状态，客户端会得到不完整的压缩包
+		log.Printf("Failed to export version %s: %v", version.ID, err)
+		c.Abort()
+	}
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/middleware"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+	"gorm.io/gorm"
+)
+
+// GetVersionAssets 获取版本的资源文件列表
+func GetVersionAssets(c *gin.Context) {
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	var assets []models.VersionAsset
+	if err := db.Where("version_id = ?", version.ID).Order("path ASC").Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+
+	totalSize := int64(len(version.HTMLContent))
+	responses := make([]models.VersionAssetResponse, 0, len(assets))
+	for _, asset := range assets {
+		totalSize += asset.Size
+		responses = append(responses, asset.ToResponse(portfolio.ID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      responses,
+		"totalSize": totalSize,
+		"limits": gin.H{
+			"maxAssets":    models.MaxVersionAssetCount,
+			"maxAssetSize": models.MaxVersionAssetSize,
+			"maxTotalSize": models.MaxVersionTotalSize,
+		},
+	})
+}
+
+// UploadVersionAsset 上传版本资源文件，同一路径已存在时替换。
+// 已发布版本的资源不可修改，此时基于它创建新版本并在新版本上修改
+func UploadVersionAsset(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if !limitUploadBody(c, models.MaxVersionAssetSize) {
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Asset exceeds the maximum file size", "limit": models.MaxVersionAssetSize})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded", "details": err.Error()})
+		return
+	}
+
+	// 未指定路径时使用文件名，放在版本根目录下
+	assetPath := c.PostForm("path")
+	if assetPath == "" {
+		assetPath = file.Filename
+	}
+	assetPath, err = models.NormalizeAssetPath(assetPath)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if models.IsReservedAssetPath(assetPath) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Asset path is reserved for the entry HTML or manifest"})
+		return
+	}
+
+	if file.Size > models.MaxVersionAssetSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Asset exceeds the maximum file size",
+			"limit": models.MaxVersionAssetSize,
+		})
+		return
+	}
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", c.Param("id"), "deleted").First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if !canEditPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	// 检查版本的文件数和总大小，替换同一路径的文件时不计入原文件
+	var others []models.VersionAsset
+	if err := db.Select("size").Where("version_id = ? AND path <> ?", version.ID, assetPath).Find(&others).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+	if len(others)+1 > models.MaxVersionAssetCount {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Version has too many assets",
+			"limit": models.MaxVersionAssetCount,
+		})
+		return
+	}
+	totalSize := int64(len(version.HTMLContent)) + file.Size
+	for _, other := range others {
+		totalSize += other.Size
+	}
+	if totalSize > models.MaxVersionTotalSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Version exceeds the maximum total size",
+			"size":  totalSize,
+			"limit": models.MaxVersionTotalSize,
+		})
+		return
+	}
+
+	if !checkPortfolioStorageQuota(c, db, &portfolio, file.Size) {
+		return
+	}
+
+	hash, err := hashUploadedFile(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+
+	// 内容未变化时直接返回已有资源
+	var existing models.VersionAsset
+	found := db.Where("version_id = ? AND path = ?", version.ID, assetPath).First(&existing).Error == nil
+	if found && existing.Hash == hash {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Asset unchanged",
+			"asset":   existing.ToResponse(portfolio.ID),
+		})
+		return
+	}
+
+	// 资源文件按路径的扩展名提供给浏览器，内容必须与扩展名对应的类型一致
+	declaredType := file.Header.Get("Content-Type")
+	file.Header.Set("Content-Type", services.AssetContentType(assetPath, declaredType))
+
+	minioService := services.NewMinIOService()
+	fileObject, err := minioService.UploadFile(file, userID, portfolio.OrganizationID, false, map[string]string{
+		"purpose": models.UploadPurposeVersionAsset,
+	})
+	if err != nil {
+		if respondUploadTypeError(c, err, models.UploadPurposeVersionAsset) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file", "details": err.Error()})
+		return
+	}
+
+	// 优先按扩展名确定类型，浏览器上传的类型经常是 application/octet-stream
+	asset := models.VersionAsset{
+		Path:         assetPath,
+		FileObjectID: fileObject.ID,
+		ContentType:  services.AssetContentType(assetPath, declaredType),
+		Size:         fileObject.FileSize,
+		Hash:         hash,
+	}
+	var forked *models.PortfolioVersion
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		forked, err = forkPublishedVersion(tx, &portfolio, &version, fmt.Sprintf("Updated asset %s", assetPath))
+		if err != nil {
+			return err
+		}
+		asset.VersionID = version.ID
+		if forked != nil {
+			asset.VersionID = forked.ID
+		}
+
+		// 同一路径已存在时替换文件，记录ID保持不变
+		var current models.VersionAsset
+		if err := tx.Where("version_id = ? AND path = ?", asset.VersionID, assetPath).First(&current).Error; err == nil {
+			asset.ID = current.ID
+			asset.CreatedAt = current.CreatedAt
+			if err := tx.Save(&asset).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(&asset).Error; err != nil {
+			return err
+		}
+
+		if forked != nil && portfolio.Status == "published" {
+			return publishVersions(tx, portfolio.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		releaseAssetFiles(db, fileObject.ID)
+		respondAssetError(c, err, forked)
+		return
+	}
+	if found {
+		releaseAssetFiles(db, existing.FileObjectID)
+	}
+
+	respondAssetChange(c, db, forked, gin.H{
+		"message": "Asset uploaded successfully",
+		"asset":   asset.ToResponse(portfolio.ID),
+	})
+}
+
+// DeleteVersionAsset 删除版本资源文件，已发布版本同样基于它创建新版本后删除
+func DeleteVersionAsset(c *gin.Context) {
+	_, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ? AND status <> ?", c.Param("id"), "deleted").First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if !canEditPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	var asset models.VersionAsset
+	if err := db.Where("version_id = ? AND id = ?", version.ID, c.Param("assetId")).First(&asset).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	var forked *models.PortfolioVersion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		forked, err = forkPublishedVersion(tx, &portfolio, &version, fmt.Sprintf("Removed asset %s", asset.Path))
+		if err != nil {
+			return err
+		}
+		if forked == nil {
+			return tx.Delete(&asset).Error
+		}
+		if err := tx.Where("version_id = ? AND path = ?", forked.ID, asset.Path).Delete(&models.VersionAsset{}).Error; err != nil {
+			return err
+		}
+		if portfolio.Status == "published" {
+			return publishVersions(tx, portfolio.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		respondAssetError(c, err, forked)
+		return
+	}
+	releaseAssetFiles(db, asset.FileObjectID)
+
+	respondAssetChange(c, db, forked, gin.H{
+		"message": "Asset deleted successfully",
+	})
+}
+
+// checkPortfolioStorageQuota 组织作品的资源文件计入组织存储配额，超出配额或统计失败时已写入响应
+func checkPortfolioStorageQuota(c *gin.Context, db *gorm.DB, portfolio *models.Portfolio, size int64) bool {
+	if portfolio.OrganizationID == "" || size <= 0 {
+		return true
+	}
+	var org models.Organization
+	if err := db.Where("id = ?", portfolio.OrganizationID).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return false
+	}
+	if org.StorageQuota <= 0 {
+		return true
+	}
+	used, _, err := services.OrganizationStorageUsage(db, org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate storage usage"})
+		return false
+	}
+	if used+size > org.StorageQuota {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Organization storage quota exceeded",
+			"used":  used,
+			"quota": org.StorageQuota,
+		})
+		return false
+	}
+	return true
+}
+
+// forkPublishedVersion 版本已发布或作品已发布时，基于版本创建新版本用于修改资源文件，
+// 返回新版本；版本可以原地修改时返回 nil
+func forkPublishedVersion(tx *gorm.DB, portfolio *models.Portfolio, version *models.PortfolioVersion, changeLog string) (*models.PortfolioVersion, error) {
+	if version.PublishedAt == nil && portfolio.Status != "published" {
+		return nil, nil
+	}
+	if err := version.LoadContent(tx); err != nil {
+		return nil, err
+	}
+	return createVersionFrom(tx, version, version.Title, version.Description, version.HTMLContent, changeLog, "patch",
+		portfolio.ActiveVersionID == version.ID)
+}
+
+// respondAssetError 返回修改资源文件失败的响应
+func respondAssetError(c *gin.Context, err error, forked *models.PortfolioVersion) {
+	if errors.Is(err, errSecurityFindings) && forked != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "findings": forked.Findings()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assets"})
+}
+
+// respondAssetChange 返回修改资源文件成功的响应，创建了新版本时一并返回新版本
+func respondAssetChange(c *gin.Context, db *gorm.DB, forked *models.PortfolioVersion, body gin.H) {
+	if forked == nil {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	db.Where("id = ?", forked.ID).First(forked)
+	body["message"] = "Published versions are immutable, a new version was created"
+	body["version"] = forked.ToResponse()
+	c.JSON(http.StatusCreated, body)
+}
+
+// copyVersionAssets 将来源版本的资源文件复制到新版本，文件对象直接共享不重复上传
+func copyVersionAssets(tx *gorm.DB, fromVersionID, toVersionID string) error {
+	var assets []models.VersionAsset
+	if err := tx.Where("version_id = ?", fromVersionID).Find(&assets).Error; err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+	copies := make([]models.VersionAsset, 0, len(assets))
+	for _, asset := range assets {
+		copies = append(copies, models.VersionAsset{
+			VersionID:    toVersionID,
+			Path:         asset.Path,
+			FileObjectID: asset.FileObjectID,
+			ContentType:  asset.ContentType,
+			Size:         asset.Size,
+			Hash:         asset.Hash,
+		})
+	}
+	return tx.Create(&copies).Error
+}
+
+// releaseAssetFiles 文件对象不再被任何版本引用时移入回收站，保留期结束后由清理任务删除
+func releaseAssetFiles(db *gorm.DB, fileObjectIDs ...string) {
+	minioService := services.NewMinIOService()
+	for _, id := range fileObjectIDs {
+		var count int64
+		db.Model(&models.VersionAsset{}).Where("file_object_id = ?", id).Count(&count)
+		if count > 0 {
+			continue
+		}
+		if err := minioService.DeleteFile(id); err != nil {
+			log.Printf("Warning: failed to release asset file %s: %v", id, err)
+		}
+	}
+}
+
+// hashUploadedFile 计算上传文件内容的SHA-256
+func hashUploadedFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"github.com/oldweipro/design-ai/services"
+	"gorm.io/gorm"
+)
+
+// GetVersionThumbnail 渲染版本HTML并输出栅格缩略图。
+// 支持 size=small|medium|large（默认 medium）和 format=png|webp（默认 webp）参数
+func GetVersionThumbnail(c *gin.Context) {
+	size, ok := services.ThumbnailSvc.Size(c.DefaultQuery("size", "medium"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be one of small, medium, large"})
+		return
+	}
+	format := strings.ToLower(c.DefaultQuery("format", services.ThumbnailFormatWebP))
+	if format != services.ThumbnailFormatPNG && format != services.ThumbnailFormatWebP {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of png, webp"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	var assets []models.VersionAsset
+	db.Where("version_id = ?", version.ID).Find(&assets)
+
+	setPreviewHeaders(c, &portfolio, &version)
+
+	// 缩略图由入口HTML和资源文件共同决定，实体标签包含资源摘要
+	contentHash := version.ContentHash
+	if digest := services.PreviewSvc.AssetDigest(assets); digest != "" {
+		contentHash += "." + digest
+	}
+	etag := services.PreviewSvc.ETag(contentHash, size.Name+"."+format)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// 命中缓存时不需要读取内容
+	if err := version.LoadContent(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load version content"})
+		return
+	}
+
+	// 未配置文件存储时只能使用内联资源渲染
+	var loader services.ResourceLoader
+	if len(assets) > 0 && services.NewMinIOService().GetActiveConfig() != nil {
+		loader = services.ThumbnailSvc.AssetLoader(assets)
+	}
+
+	data, contentType, err := services.ThumbnailSvc.RenderThumbnail(version.HTMLContent, size, format, loader)
+	if err != nil {
+		log.Printf("Failed to render thumbnail for version %s: %v", version.ID, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to render thumbnail"})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// GetVersionThumbnailStatus 查询版本最近一次缩略图任务的状态
+func GetVersionThumbnailStatus(c *gin.Context) {
+	db := database.GetDB()
+
+	var portfolio models.Portfolio
+	if err := db.Where("id = ?", c.Param("id")).First(&portfolio).Error; err != nil || !canViewPortfolio(c, db, &portfolio) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var version models.PortfolioVersion
+	if err := db.Where("portfolio_id = ? AND id = ?", portfolio.ID, c.Param("versionId")).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	// 没有任务记录的旧版本返回 null
+	var job *models.ThumbnailJob
+	var latest models.ThumbnailJob
+	if err := db.Where("version_id = ?", version.ID).Order("created_at DESC").First(&latest).Error; err == nil {
+		job = &latest
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"versionId": version.ID,
+			"thumbnail": version.ThumbnailURL(),
+			"job":       job,
+		},
+	})
+}
+
+// 管理员：查询缩略图任务队列
+func GetThumbnailJobs(c *gin.Context) {
+	var query models.ThumbnailJobQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	db := database.GetDB()
+	dbQuery := db.Model(&models.ThumbnailJob{})
+	if query.Status != "" {
+		dbQuery = dbQuery.Where("status = ?", query.Status)
+	}
+	if query.VersionID != "" {
+		dbQuery = dbQuery.Where("version_id = ?", query.VersionID)
+	}
+
+	var total int64
+	dbQuery.Count(&total)
+
+	var jobs []models.ThumbnailJob
+	if err := dbQuery.Order("created_at DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thumbnail jobs"})
+		return
+	}
+
+	stats, err := services.ThumbnailQueueSvc.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thumbnail jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        jobs,
+		"stats":       stats,
+		"total":       total,
+		"page":        query.Page,
+		"page_size":   query.PageSize,
+		"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+	})
+}
+
+// 管理员：为所有版本重新生成缩略图，缩略图生成方式变化后使用
+func RegenerateThumbnails(c *gin.Context) {
+	count, err := services.ThumbnailQueueSvc.EnqueueAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue thumbnail jobs", "details": err.Error(), "enqueued": count})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Thumbnail regeneration scheduled",
+		"enqueued": count,
+	})
+}
+
+// 管理员：重新执行已放弃的缩略图任务
+func RetryThumbnailJob(c *gin.Context) {
+	err := services.ThumbnailQueueSvc.Retry(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead thumbnail job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry thumbnail job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Thumbnail job rescheduled"})
+}
+
+// GetStoredThumbnail 输出保存在文件存储中的版本缩略图。缩略图对象不可变，可以查看任一引用它的版本即可访问
+func GetStoredThumbnail(c *gin.Context) {
+	objectID := c.Param("objectId")
+	db := database.GetDB()
+
+	var versions []models.PortfolioVersion
+	db.Preload("Portfolio").Where("thumbnail_object_id = ?", objectID).Find(&versions)
+
+	var visible *models.PortfolioVersion
+	for i := range versions {
+		if versions[i].Portfolio != nil && canViewPortfolio(c, db, versions[i].Portfolio) {
+			visible = &versions[i]
+			break
+		}
+	}
+	if visible == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+		return
+	}
+
+	portfolio := visible.Portfolio
+	if portfolio.Status == "published" && !portfolio.MembersOnly && portfolio.Visibility != models.VisibilityPrivate {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "private, max-age=31536000, immutable")
+		c.Header("Vary", "Authorization")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	etag := `"` + objectID + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, fileObject, err := services.NewMinIOService().OpenFile(objectID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load thumbnail"})
+		return
+	}
+	defer reader.Close()
+
+	// 摘要缩略图为SVG，禁止其中的脚本在直接打开时执行
+	if fileObject.ContentType == "image/svg+xml" {
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+	c.DataFromReader(http.StatusOK, fileObject.FileSize, fileObject.ContentType, reader, nil)
+}
+
+// 管理员：将数据库中的内联缩略图迁移到文件存储
+func MigrateThumbnails(c *gin.Context) {
+	if services.NewMinIOService().GetActiveConfig() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File storage is not configured"})
+		return
+	}
+
+	migrated, err := services.ThumbnailSvc.MigrateInlineThumbnails()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to migrate thumbnails", "details": err.Error(), "migrated": migrated})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Thumbnails migrated successfully",
+		"migrated": migrated,
+	})
+}
@@ -28,6 +28,12 @@ var (
 // 应用启动时间
 var startTime = time.Now()
 
+// 回收站清理间隔
+const trashPurgeInterval = time.Hour
+
+// 过期上传会话和未确认直传文件的清理间隔
+const uploadCleanupInterval = 30 * time.Minute
+
 //go:embed templates/**/*.html assets/css assets/js
 var staticFS embed.FS
 
@@ -44,13 +50,42 @@ func main() {
 		log.Printf("Warning: Failed to load MinIO config: %v", err)
 	}
 
+	// 启动回收站清理任务
+	services.StartTrashPurger(trashPurgeInterval)
+
+	// 启动过期上传会话和未确认直传文件的清理任务
+	services.StartUploadCleaner(uploadCleanupInterval)
+
+	// 启动缩略图生成任务，工作协程数量由 THUMBNAIL_WORKERS 配置
+	services.ThumbnailQueueSvc.Start()
+
+	// 为尚未分析内容的版本补充生成内容摘要
+	go func() {
+		enqueued, err := services.ThumbnailQueueSvc.EnqueueUnanalyzed()
+		if err != nil {
+			log.Printf("Warning: Failed to enqueue content analysis: %v", err)
+		} else if enqueued > 0 {
+			log.Printf("Enqueued content analysis for %d versions", enqueued)
+		}
+	}()
+
+	// 将数据库中的内联缩略图迁移到文件存储
+	go func() {
+		migrated, err := services.ThumbnailSvc.MigrateInlineThumbnails()
+		if err != nil {
+			log.Printf("Warning: Failed to migrate inline thumbnails: %v", err)
+		} else if migrated > 0 {
+			log.Printf("Migrated %d inline thumbnails to file storage", migrated)
+		}
+	}()
+
 	r := gin.Default()
 
 	// 启用CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Share-Password")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -89,8 +124,9 @@ func main() {
 	// 页面路由
 	r.GET("/", func(c *gin.Context) {
 		data := gin.H{
-			"Title": "首页",
-			"User":  "gin",
+			"Title":         "首页",
+			"User":          "gin",
+			"PreviewOrigin": services.PreviewSvc.Origin(),
 		}
 		c.HTML(http.StatusOK, "pages/home", data)
 	})
@@ -109,6 +145,14 @@ func main() {
 		c.HTML(http.StatusOK, "pages/dashboard", gin.H{"Title": "用户仪表板"})
 	})
 
+	// 组织主页
+	r.GET("/orgs/:slug", handlers.OrganizationPage)
+
+	// 版本实时预览（沙箱隔离，可通过 PREVIEW_ORIGIN 部署到独立域名）
+	// 预览页面运行作者的脚本，不使用登录凭证，非公开版本通过预览令牌访问
+	r.GET("/preview/:portfolioId/:versionId", handlers.PreviewVersion)
+	r.GET("/preview/:portfolioId/:versionId/*filepath", handlers.PreviewVersionAsset)
+
 	// MinIO设置页面（重定向到仪表板）
 	r.GET("/minio-settings", func(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/dashboard#minio-settings")
@@ -130,8 +174,22 @@ func main() {
 		api.POST("/portfolios/:id/like", handlers.LikePortfolio)
 		api.GET("/categories", handlers.GetCategories)
 
-		// 不需要认证的接口
-		api.GET("/portfolios/:id/versions/:versionId", handlers.GetPortfolioVersion)
+		// 组织公开接口（可选认证，成员可以看到仅成员可见的作品）
+		api.GET("/orgs/:slug", middleware.OptionalAuthMiddleware(), handlers.GetOrganization)
+		api.GET("/orgs/:slug/portfolios", middleware.OptionalAuthMiddleware(), handlers.GetOrganizationPortfolios)
+		api.GET("/orgs/:slug/members", middleware.OptionalAuthMiddleware(), handlers.GetOrganizationMembers)
+
+		// 不需要认证的接口（可选认证，私有作品的版本需要权限）
+		api.GET("/portfolios/:id/versions/:versionId", middleware.OptionalAuthMiddleware(), handlers.GetPortfolioVersion)
+		api.GET("/portfolios/:id/versions/:versionId/diff/:otherVersionId", middleware.OptionalAuthMiddleware(), handlers.DiffPortfolioVersions)
+		api.GET("/portfolios/:id/versions/:versionId/assets", middleware.OptionalAuthMiddleware(), handlers.GetVersionAssets)
+		api.GET("/portfolios/:id/versions/:versionId/export", middleware.OptionalAuthMiddleware(), handlers.ExportPortfolioVersion)
+		api.GET("/portfolios/:id/versions/:versionId/thumbnail", middleware.OptionalAuthMiddleware(), handlers.GetVersionThumbnail)
+		api.GET("/portfolios/:id/versions/:versionId/thumbnail/status", middleware.OptionalAuthMiddleware(), handlers.GetVersionThumbnailStatus)
+		api.GET("/thumbnails/:objectId", middleware.OptionalAuthMiddleware(), handlers.GetStoredThumbnail)
+
+		// 分享链接访问（无需账号，密码通过 X-Share-Password 头传递）
+		api.GET("/shared/:token", handlers.GetSharedPortfolio)
 		// 需要认证的接口
 		protected := api.Group("/")
 		protected.Use(middleware.AuthMiddleware())
@@ -148,11 +206,48 @@ func main() {
 
 			// 作品版本管理
 			protected.POST("/portfolios/:id/versions", handlers.CreatePortfolioVersion)
+			protected.POST("/portfolios/:id/versions/import", handlers.ImportPortfolioVersion)
 			protected.GET("/portfolios/:id/versions", handlers.GetPortfolioVersions)
+			protected.GET("/portfolios/:id/branches", handlers.GetPortfolioBranches)
+			protected.POST("/portfolios/:id/versions/:versionId/revert", handlers.RevertPortfolioVersion)
+			protected.POST("/portfolios/:id/fork", handlers.ForkPortfolio)
 
 			protected.PUT("/portfolios/:id/versions/:versionId", handlers.UpdatePortfolioVersion)
 			protected.DELETE("/portfolios/:id/versions/:versionId", handlers.DeletePortfolioVersion)
 			protected.POST("/portfolios/:id/versions/:versionId/activate", handlers.SetActiveVersion)
+			protected.POST("/portfolios/:id/versions/:versionId/preview-token", handlers.CreatePreviewToken)
+			protected.POST("/portfolios/:id/versions/:versionId/assets", handlers.UploadVersionAsset)
+			protected.DELETE("/portfolios/:id/versions/:versionId/assets/:assetId", handlers.DeleteVersionAsset)
+
+			// 协作者和所有权转移
+			protected.GET("/portfolios/:id/collaborators", handlers.GetCollaborators)
+			protected.POST("/portfolios/:id/collaborators", handlers.AddCollaborator)
+			protected.PUT("/portfolios/:id/collaborators/:userId", handlers.UpdateCollaborator)
+			protected.DELETE("/portfolios/:id/collaborators/:userId", handlers.RemoveCollaborator)
+			protected.GET("/portfolios/:id/share-links", handlers.GetShareLinks)
+			protected.POST("/portfolios/:id/share-links", handlers.CreateShareLink)
+			protected.DELETE("/portfolios/:id/share-links/:linkId", handlers.RevokeShareLink)
+			protected.POST("/portfolios/:id/transfer", handlers.TransferPortfolio)
+			protected.GET("/transfers", handlers.GetMyTransfers)
+			protected.POST("/transfers/:id/accept", handlers.AcceptTransfer)
+			protected.POST("/transfers/:id/decline", handlers.DeclineTransfer)
+			protected.DELETE("/transfers/:id", handlers.CancelTransfer)
+
+			// 组织管理
+			protected.GET("/my-orgs", handlers.GetMyOrganizations)
+			protected.POST("/orgs", handlers.CreateOrganization)
+			protected.PUT("/orgs/:slug", handlers.UpdateOrganization)
+			protected.DELETE("/orgs/:slug", handlers.DeleteOrganization)
+			protected.POST("/orgs/:slug/members", handlers.AddOrganizationMember)
+			protected.PUT("/orgs/:slug/members/:userId", handlers.UpdateOrganizationMember)
+			protected.DELETE("/orgs/:slug/members/:userId", handlers.RemoveOrganizationMember)
+			protected.GET("/orgs/:slug/storage", handlers.GetOrganizationStorage)
+
+			// 回收站
+			protected.GET("/trash", handlers.GetTrash)
+			protected.POST("/trash/portfolios/:id/restore", handlers.RestorePortfolio)
+			protected.POST("/trash/versions/:id/restore", handlers.RestorePortfolioVersion)
+			protected.POST("/trash/files/:id/restore", handlers.RestoreFile)
 		}
 
 		// 文件管理接口
@@ -162,8 +257,20 @@ func main() {
 			files.POST("/upload", middleware.AuthMiddleware(), handlers.UploadFile)
 			files.DELETE("/:id", middleware.AuthMiddleware(), handlers.DeleteFile)
 
+			// 可断点续传的分片上传
+			files.POST("/uploads", middleware.AuthMiddleware(), handlers.CreateUploadSession)
+			files.GET("/uploads/:id", middleware.AuthMiddleware(), handlers.GetUploadSession)
+			files.PUT("/uploads/:id/parts/:number", middleware.AuthMiddleware(), handlers.UploadSessionPart)
+			files.POST("/uploads/:id/complete", middleware.AuthMiddleware(), handlers.CompleteUploadSession)
+			files.DELETE("/uploads/:id", middleware.AuthMiddleware(), handlers.AbortUploadSession)
+
+			// 客户端直传存储桶
+			files.POST("/direct-uploads", middleware.AuthMiddleware(), handlers.CreateDirectUpload)
+			files.POST("/direct-uploads/:id/complete", middleware.AuthMiddleware(), handlers.CompleteDirectUpload)
+
 			// 公开接口
 			files.GET("/:id/url", handlers.GetFileURL)
+			files.GET("/:id/image", middleware.OptionalAuthMiddleware(), handlers.GetFileImage)
 			files.GET("", handlers.GetFiles)
 		}
 
@@ -183,6 +290,17 @@ func main() {
 			admin.PUT("/portfolios/:id", handlers.UpdatePortfolioStatus)
 			admin.DELETE("/portfolios/:id", handlers.AdminDeletePortfolio)
 
+			// 组织存储配额
+			admin.PUT("/orgs/:slug/quota", handlers.UpdateOrganizationQuota)
+
+			// 审核队列
+			admin.GET("/review-queue", handlers.GetReviewQueue)
+			admin.GET("/review-queue/stats", handlers.GetReviewStats)
+			admin.POST("/review-queue/bulk", handlers.BulkDecideReviewItems)
+			admin.POST("/review-queue/:type/:id/claim", handlers.ClaimReviewItem)
+			admin.DELETE("/review-queue/:type/:id/claim", handlers.ReleaseReviewItem)
+			admin.POST("/review-queue/:type/:id/decision", handlers.DecideReviewItem)
+
 			// MinIO配置管理
 			admin.GET("/minio", handlers.GetMinIOConfigs)
 			admin.POST("/minio", handlers.CreateMinIOConfig)
@@ -193,6 +311,15 @@ func main() {
 			admin.POST("/minio/test", handlers.TestMinIOConnection)
 			admin.POST("/minio/:id/test", handlers.TestMinIOConfigConnection)
 
+			// 回收站清理
+			admin.POST("/trash/purge", handlers.PurgeTrash)
+
+			// 缩略图任务
+			admin.GET("/thumbnail-jobs", handlers.GetThumbnailJobs)
+			admin.POST("/thumbnail-jobs/regenerate", handlers.RegenerateThumbnails)
+			admin.POST("/thumbnail-jobs/:id/retry", handlers.RetryThumbnailJob)
+			admin.POST("/thumbnails/migrate", handlers.MigrateThumbnails)
+
 			// 管理员设置
 			admin.GET("/settings", handlers.GetAdminSettings)
 			admin.PUT("/settings", handlers.UpdateAdminSettings)
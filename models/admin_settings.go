@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"mime"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,6 +14,12 @@ type AdminSettings struct {
 	ID                        uint      `json:"id" gorm:"primaryKey"`
 	UserApprovalRequired      bool      `json:"userApprovalRequired" gorm:"default:false"`      // 新用户是否需要审核
 	PortfolioApprovalRequired bool      `json:"portfolioApprovalRequired" gorm:"default:false"` // 新作品是否需要审核
+	TrashRetentionDays        int       `json:"trashRetentionDays" gorm:"default:30"`           // 回收站保留天数
+	StripUnsafeHTML           bool      `json:"stripUnsafeHTML" gorm:"default:false"`           // 保存版本时移除检测到的危险内容
+	BlockPublishOnFindings    bool      `json:"blockPublishOnFindings" gorm:"default:false"`    // 存在安全问题的作品不允许发布
+	TrustedScriptHosts        string    `json:"trustedScriptHosts" gorm:"type:text"`            // JSON格式存储的可信外部脚本域名
+	UploadSizeLimits          string    `json:"uploadSizeLimits" gorm:"type:text"`              // JSON格式存储的各内容类型的上传大小上限（字节）
+	UploadAllowedTypes        string    `json:"uploadAllowedTypes" gorm:"type:text"`            // JSON格式存储的各用途允许上传的内容类型
 	CreatedAt                 time.Time `json:"createdAt"`
 	UpdatedAt                 time.Time `json:"updatedAt"`
 }
@@ -22,17 +31,174 @@ func (s *AdminSettings) BeforeCreate(tx *gorm.DB) error {
 
 // AdminSettingsRequest 更新设置请求
 type AdminSettingsRequest struct {
-	UserApprovalRequired      *bool `json:"userApprovalRequired"`
-	PortfolioApprovalRequired *bool `json:"portfolioApprovalRequired"`
+	UserApprovalRequired      *bool               `json:"userApprovalRequired"`
+	PortfolioApprovalRequired *bool               `json:"portfolioApprovalRequired"`
+	TrashRetentionDays        *int                `json:"trashRetentionDays" binding:"omitempty,min=1,max=365"`
+	StripUnsafeHTML           *bool               `json:"stripUnsafeHTML"`
+	BlockPublishOnFindings    *bool               `json:"blockPublishOnFindings"`
+	TrustedScriptHosts        []string            `json:"trustedScriptHosts" binding:"omitempty,dive,hostname"`
+	UploadSizeLimits          map[string]int64    `json:"uploadSizeLimits" binding:"omitempty,dive,min=1"` // 键为 "*"、"image/*" 或 "image/png" 形式的内容类型
+	UploadAllowedTypes        map[string][]string `json:"uploadAllowedTypes"`                              // 键为上传用途，如 avatar、cover、version-asset，"*" 用于其他用途
 }
 
 // AdminSettingsResponse 设置响应
 type AdminSettingsResponse struct {
-	ID                        uint      `json:"id"`
-	UserApprovalRequired      bool      `json:"userApprovalRequired"`
-	PortfolioApprovalRequired bool      `json:"portfolioApprovalRequired"`
-	CreatedAt                 time.Time `json:"createdAt"`
-	UpdatedAt                 time.Time `json:"updatedAt"`
+	ID                        uint                `json:"id"`
+	UserApprovalRequired      bool                `json:"userApprovalRequired"`
+	PortfolioApprovalRequired bool                `json:"portfolioApprovalRequired"`
+	TrashRetentionDays        int                 `json:"trashRetentionDays"`
+	StripUnsafeHTML           bool                `json:"stripUnsafeHTML"`
+	BlockPublishOnFindings    bool                `json:"blockPublishOnFindings"`
+	TrustedScriptHosts        []string            `json:"trustedScriptHosts"`
+	UploadSizeLimits          map[string]int64    `json:"uploadSizeLimits"`
+	UploadAllowedTypes        map[string][]string `json:"uploadAllowedTypes"`
+	CreatedAt                 time.Time           `json:"createdAt"`
+	UpdatedAt                 time.Time           `json:"updatedAt"`
+}
+
+// TrashRetention 回收站保留时长
+func (s *AdminSettings) TrashRetention() time.Duration {
+	days := s.TrashRetentionDays
+	if days <= 0 {
+		days = DefaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DefaultTrustedScriptHosts 默认信任的外部脚本域名（常用的公共CDN）
+var DefaultTrustedScriptHosts = []string{
+	"cdn.tailwindcss.com",
+	"cdn.jsdelivr.net",
+	"unpkg.com",
+	"cdnjs.cloudflare.com",
+}
+
+// ScriptHosts 可信外部脚本域名，未设置时使用默认列表
+func (s *AdminSettings) ScriptHosts() []string {
+	if s.TrustedScriptHosts == "" {
+		return DefaultTrustedScriptHosts
+	}
+	var hosts []string
+	if err := json.Unmarshal([]byte(s.TrustedScriptHosts), &hosts); err != nil {
+		return DefaultTrustedScriptHosts
+	}
+	return hosts
+}
+
+// DefaultUploadSizeLimits 默认的上传大小上限，"*" 匹配其他所有类型
+var DefaultUploadSizeLimits = map[string]int64{
+	"image/*": 20 << 20,
+	"video/*": 500 << 20,
+	"*":       100 << 20,
+}
+
+// UploadLimits 各内容类型的上传大小上限，未设置时使用默认值
+func (s *AdminSettings) UploadLimits() map[string]int64 {
+	if s.UploadSizeLimits == "" {
+		return DefaultUploadSizeLimits
+	}
+	var limits map[string]int64
+	if err := json.Unmarshal([]byte(s.UploadSizeLimits), &limits); err != nil || len(limits) == 0 {
+		return DefaultUploadSizeLimits
+	}
+	return limits
+}
+
+// UploadSizeLimit 按内容类型查找上传大小上限，依次匹配完整类型、"主类型/*" 和 "*"，都不匹配时返回 0 表示不限制
+func UploadSizeLimit(limits map[string]int64, contentType string) int64 {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	contentType = strings.ToLower(contentType)
+	if limit, ok := limits[contentType]; ok {
+		return limit
+	}
+	if slash := strings.Index(contentType, "/"); slash > 0 {
+		if limit, ok := limits[contentType[:slash]+"/*"]; ok {
+			return limit
+		}
+	}
+	return limits["*"]
+}
+
+// MaxUploadSize 所有内容类型中最大的上传上限，用于在解析请求体之前按 Content-Length 拒绝请求。
+// 存在不限制的类型时返回 0
+func MaxUploadSize(limits map[string]int64) int64 {
+	var maxLimit int64
+	for _, limit := range limits {
+		if limit <= 0 {
+			return 0
+		}
+		maxLimit = max(maxLimit, limit)
+	}
+	if _, ok := limits["*"]; !ok {
+		return 0
+	}
+	return maxLimit
+}
+
+// 上传用途
+const (
+	UploadPurposeAvatar       = "avatar"
+	UploadPurposeCover        = "cover"
+	UploadPurposeVersionAsset = "version-asset"
+)
+
+// webImageTypes 浏览器可以直接显示的位图格式
+var webImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// DefaultUploadAllowedTypes 默认的上传类型白名单，按用途配置，"*" 用于未单独配置的用途。
+// 默认不允许可以执行脚本的 HTML，SVG 只允许作为版本资源文件
+var DefaultUploadAllowedTypes = map[string][]string{
+	UploadPurposeAvatar:       webImageTypes,
+	UploadPurposeCover:        webImageTypes,
+	UploadPurposeVersionAsset: {"text/css", "text/javascript", "application/json", "text/plain", "image/*", "font/*", "audio/*", "video/*", "application/octet-stream"},
+	"*": {"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif", "video/*", "audio/*", "font/*",
+		"image/vnd.adobe.photoshop", "application/pdf", "application/zip", "application/json", "text/plain", "text/csv", "text/markdown",
+		"application/octet-stream"},
+}
+
+// AllowedUploadTypes 各用途允许上传的内容类型，未设置时使用默认值
+func (s *AdminSettings) AllowedUploadTypes() map[string][]string {
+	if s.UploadAllowedTypes == "" {
+		return DefaultUploadAllowedTypes
+	}
+	var allowed map[string][]string
+	if err := json.Unmarshal([]byte(s.UploadAllowedTypes), &allowed); err != nil || len(allowed) == 0 {
+		return DefaultUploadAllowedTypes
+	}
+	return allowed
+}
+
+// UploadTypesForPurpose 用途允许的内容类型，用途未单独配置时使用 "*" 的配置，都没有配置时返回 nil 表示不限制
+func UploadTypesForPurpose(allowed map[string][]string, purpose string) []string {
+	if types, ok := allowed[purpose]; ok {
+		return types
+	}
+	return allowed["*"]
+}
+
+// UploadTypeAllowed 内容类型是否匹配白名单中的 "*"、"主类型/*" 或完整类型，白名单为 nil 时不限制
+func UploadTypeAllowed(types []string, contentType string) bool {
+	if types == nil {
+		return true
+	}
+	mainType, _, _ := strings.Cut(contentType, "/")
+	for _, pattern := range types {
+		if pattern == "*" || pattern == contentType || pattern == mainType+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidUploadLimitKey 上传上限的键是否为 "*"、"主类型/*" 或完整的内容类型
+func ValidUploadLimitKey(key string) bool {
+	if key == "*" {
+		return true
+	}
+	mainType, subType, ok := strings.Cut(key, "/")
+	return ok && mainType != "" && subType != "" && mainType != "*" && !strings.ContainsAny(key, " ;,") && key == strings.ToLower(key)
 }
 
 // ToResponse 转换为响应结构
@@ -41,6 +207,12 @@ func (s *AdminSettings) ToResponse() AdminSettingsResponse {
 		ID:                        s.ID,
 		UserApprovalRequired:      s.UserApprovalRequired,
 		PortfolioApprovalRequired: s.PortfolioApprovalRequired,
+		TrashRetentionDays:        s.TrashRetentionDays,
+		StripUnsafeHTML:           s.StripUnsafeHTML,
+		BlockPublishOnFindings:    s.BlockPublishOnFindings,
+		TrustedScriptHosts:        s.ScriptHosts(),
+		UploadSizeLimits:          s.UploadLimits(),
+		UploadAllowedTypes:        s.AllowedUploadTypes(),
 		CreatedAt:                 s.CreatedAt,
 		UpdatedAt:                 s.UpdatedAt,
 	}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 作品协作角色
+const (
+	PortfolioRoleOwner  = "owner"
+	PortfolioRoleEditor = "editor"
+	PortfolioRoleViewer = "viewer"
+)
+
+// 所有权转移状态
+const (
+	TransferStatusPending   = "pending"
+	TransferStatusAccepted  = "accepted"
+	TransferStatusDeclined  = "declined"
+	TransferStatusCancelled = "cancelled"
+)
+
+// PortfolioCollaborator 作品协作者，所有者仍由 Portfolio.UserID 表示
+type PortfolioCollaborator struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	PortfolioID string    `json:"portfolioId" gorm:"type:char(36);not null;uniqueIndex:idx_collaborator"`
+	UserID      string    `json:"userId" gorm:"type:char(36);not null;uniqueIndex:idx_collaborator;index"`
+	Role        string    `json:"role" gorm:"size:20;not null"` // editor, viewer
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// 关联用户
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
+}
+
+// PortfolioTransfer 作品所有权转移请求，需接收人确认
+type PortfolioTransfer struct {
+	ID          string     `json:"id" gorm:"type:char(36);primary_key"`
+	PortfolioID string     `json:"portfolioId" gorm:"type:char(36);not null;index"`
+	FromUserID  string     `json:"fromUserId" gorm:"type:char(36);not null;index"`
+	ToUserID    string     `json:"toUserId" gorm:"type:char(36);not null;index"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:'pending'"` // pending, accepted, declined, cancelled
+	Message     string     `json:"message" gorm:"size:500"`
+	RespondedAt *time.Time `json:"respondedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+
+	// 关联
+	Portfolio *Portfolio `json:"portfolio,omitempty" gorm:"foreignKey:PortfolioID;references:ID"`
+	FromUser  *User      `json:"fromUser,omitempty" gorm:"foreignKey:FromUserID;references:ID"`
+	ToUser    *User      `json:"toUser,omitempty" gorm:"foreignKey:ToUserID;references:ID"`
+}
+
+// PortfolioAuthor 作品作者信息（所有者和编辑者）
+type PortfolioAuthor struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+	Role   string `json:"role"`
+}
+
+// CollaboratorResponse 协作者响应结构
+type CollaboratorResponse struct {
+	UserID    string        `json:"userId"`
+	Role      string        `json:"role"`
+	CreatedAt time.Time     `json:"createdAt"`
+	User      *UserResponse `json:"user,omitempty"`
+}
+
+// AddCollaboratorRequest 添加协作者请求，通过用户ID、用户名或邮箱指定用户
+type AddCollaboratorRequest struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+// UpdateCollaboratorRequest 修改协作者角色请求
+type UpdateCollaboratorRequest struct {
+	Role string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+// TransferPortfolioRequest 发起所有权转移请求
+type TransferPortfolioRequest struct {
+	ToUserID string `json:"toUserId" binding:"required"`
+	Message  string `json:"message" binding:"max=500"`
+}
+
+// BeforeCreate 创建前钩子，生成ID
+func (pt *PortfolioTransfer) BeforeCreate(tx *gorm.DB) error {
+	if pt.ID == "" {
+		pt.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ToResponse 转换为响应结构
+func (pc *PortfolioCollaborator) ToResponse() CollaboratorResponse {
+	response := CollaboratorResponse{
+		UserID:    pc.UserID,
+		Role:      pc.Role,
+		CreatedAt: pc.CreatedAt,
+	}
+	if pc.User != nil {
+		user := pc.User.ToResponse()
+		response.User = &user
+	}
+	return response
+}
+
+// TableName 指定表名
+func (PortfolioCollaborator) TableName() string {
+	return "portfolio_collaborators"
+}
+
+// TableName 指定表名
+func (PortfolioTransfer) TableName() string {
+	return "portfolio_transfers"
+}
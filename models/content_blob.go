@@ -0,0 +1,44 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContentBlob 内容寻址存储的版本HTML，以内容的SHA-256为主键，
+// 相同内容在不同版本和作品之间只存储一份
+type ContentBlob struct {
+	Hash      string    `json:"hash" gorm:"type:char(64);primary_key"`
+	Size      int64     `json:"size"`
+	Content   string    `json:"-" gorm:"type:longtext;not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ContentHash 计算内容的SHA-256哈希
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreContentBlob 保存内容并返回其哈希，已存在的内容不会重复写入
+func StoreContentBlob(tx *gorm.DB, content string) (string, error) {
+	hash := ContentHash(content)
+	blob := ContentBlob{
+		Hash:    hash,
+		Size:    int64(len(content)),
+		Content: content,
+	}
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&blob).Error
+	return hash, err
+}
+
+// TableName 指定表名
+func (ContentBlob) TableName() string {
+	return "content_blobs"
+}
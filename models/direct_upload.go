@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// 直传存储桶的时限
+const (
+	DirectUploadExpiry = 15 * time.Minute // 预签名上传表单的有效期
+	DirectUploadTTL    = time.Hour        // 未确认的直传文件保留时长，超时后清理记录和已上传的对象
+)
+
+// DirectUploadRequest 申请直传存储桶的请求，文件大小和类型写入上传策略，由存储服务强制校验
+type DirectUploadRequest struct {
+	FileName       string `json:"fileName" binding:"required,max=255"`
+	ContentType    string `json:"contentType" binding:"required,max=100"`
+	Size           int64  `json:"size" binding:"required,min=1"`
+	SHA256         string `json:"sha256" binding:"omitempty,len=64,hexadecimal"` // 文件内容的SHA-256，提供时上传和确认都会校验
+	IsPublic       bool   `json:"isPublic"`
+	Category       string `json:"category" binding:"max=50"`
+	Purpose        string `json:"purpose" binding:"max=50"`
+	OrganizationID string `json:"organizationId"`
+}
+
+// DirectUploadResponse 直传存储桶的上传表单，客户端以 multipart/form-data 将 FormData 和文件（字段名 file，放在最后）POST 到 URL
+type DirectUploadResponse struct {
+	FileID    string            `json:"fileId"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	FormData  map[string]string `json:"formData"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
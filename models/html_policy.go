@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// HTML安全检查规则
+const (
+	FindingEventHandler   = "event_handler"   // 内联事件处理器，如 onclick
+	FindingJavascriptURL  = "javascript_url"  // javascript: 等脚本协议链接
+	FindingExternalScript = "external_script" // 来自非信任域名的外部脚本
+	FindingForeignForm    = "foreign_form"    // 提交到外部地址的表单
+	FindingTrackingPixel  = "tracking_pixel"  // 外部 1x1 跟踪像素
+	FindingIframeSrcdoc   = "iframe_srcdoc"   // iframe 的 srcdoc 内联文档，其中的脚本不经过其他检查
+	FindingMetaRefresh    = "meta_refresh"    // <meta http-equiv="refresh"> 自动跳转
+)
+
+// 安全问题严重程度
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// SecurityFinding HTML内容中检测到的安全问题
+type SecurityFinding struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	Path      string `json:"path"` // 类似 CSS 选择器的元素路径
+	Tag       string `json:"tag"`
+	Attribute string `json:"attribute,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Stripped  bool   `json:"stripped"` // 是否已在保存时移除
+}
+
+// ContentPolicy 保存版本内容前执行的HTML安全策略，返回处理后的内容和检测到的问题。
+// 由 services 包注册，未注册时不做检查
+var ContentPolicy func(tx *gorm.DB, content string) (string, []SecurityFinding, error)
+
+// Findings 解析版本记录的安全问题
+func (pv *PortfolioVersion) Findings() []SecurityFinding {
+	findings := []SecurityFinding{}
+	if pv.SecurityFindings != "" {
+		json.Unmarshal([]byte(pv.SecurityFindings), &findings)
+	}
+	return findings
+}
+
+// applyContentPolicy 对版本内容执行安全策略并记录检测结果
+func (pv *PortfolioVersion) applyContentPolicy(tx *gorm.DB) error {
+	if ContentPolicy == nil {
+		return nil
+	}
+	content, findings, err := ContentPolicy(tx, pv.HTMLContent)
+	if err != nil {
+		return err
+	}
+
+	encoded := ""
+	if len(findings) > 0 {
+		data, err := json.Marshal(findings)
+		if err != nil {
+			return err
+		}
+		encoded = string(data)
+	}
+
+	// 只统计仍保留在内容中的问题，已移除的问题只作记录
+	remaining := 0
+	for _, finding := range findings {
+		if !finding.Stripped {
+			remaining++
+		}
+	}
+
+	pv.HTMLContent = content
+	pv.SecurityFindings = encoded
+	pv.FindingCount = remaining
+	tx.Statement.SetColumn("security_findings", pv.SecurityFindings)
+	tx.Statement.SetColumn("finding_count", pv.FindingCount)
+	return nil
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MaxSearchTextLength 版本搜索文本的最大字符数
+const MaxSearchTextLength = 20000
+
+// HTMLSummary 版本HTML内容的结构化摘要，用于缩略图、搜索索引和元数据
+type HTMLSummary struct {
+	Title        string          `json:"title"`
+	Description  string          `json:"description,omitempty"` // <meta name="description"> 的内容
+	Language     string          `json:"language,omitempty"`    // <html lang> 的值
+	Headings     []HTMLHeading   `json:"headings"`
+	Colors       []HTMLColor     `json:"colors"` // 主要颜色，按覆盖面积从大到小排列
+	Fonts        []string        `json:"fonts"`  // 使用的字体，按文字面积从大到小排列
+	ElementCount int             `json:"elementCount"`
+	TextLength   int             `json:"textLength"` // 可见文字的字符数
+	WordCount    int             `json:"wordCount"`  // 可见文字的词数，中日文每个字计为一个词
+	ImageCount   int             `json:"imageCount"`
+	TableCount   int             `json:"tableCount"`
+	FormCount    int             `json:"formCount"`
+	CanvasCount  int             `json:"canvasCount"`
+	Interactive  HTMLInteractive `json:"interactive"`
+
+	Text string `json:"-"` // 可见文字，不包括脚本、样式和隐藏元素中的内容
+}
+
+// HTMLHeading 标题元素
+type HTMLHeading struct {
+	Level int    `json:"level"` // 1-6
+	Text  string `json:"text"`
+}
+
+// HTMLColor 颜色及其覆盖面积占比
+type HTMLColor struct {
+	Value string  `json:"value"` // #rrggbb
+	Share float64 `json:"share"` // 0-1
+}
+
+// HTMLInteractive 可交互元素数量
+type HTMLInteractive struct {
+	Links   int `json:"links"`
+	Buttons int `json:"buttons"`
+	Inputs  int `json:"inputs"` // input、textarea 和 select
+}
+
+// SearchText 用于搜索索引的文本：标题、描述、各级标题和正文
+func (s *HTMLSummary) SearchText() string {
+	parts := make([]string, 0, len(s.Headings)+3)
+	for _, part := range []string{s.Title, s.Description} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	for _, heading := range s.Headings {
+		parts = append(parts, heading.Text)
+	}
+	if s.Text != "" {
+		parts = append(parts, s.Text)
+	}
+
+	text := []rune(strings.Join(parts, "\n"))
+	if len(text) > MaxSearchTextLength {
+		text = text[:MaxSearchTextLength]
+	}
+	return string(text)
+}
+
+// Summary 解析保存的内容摘要，尚未分析时返回 nil
+func (pv *PortfolioVersion) Summary() *HTMLSummary {
+	if pv.ContentSummary == "" {
+		return nil
+	}
+	var summary HTMLSummary
+	if err := json.Unmarshal([]byte(pv.ContentSummary), &summary); err != nil {
+		return nil
+	}
+	return &summary
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,22 +28,58 @@ type MinIOConfig struct {
 
 // FileObject 文件对象模型
 type FileObject struct {
-	ID           string         `json:"id" gorm:"primaryKey;size:36"`           // 对象ID (UUID)
-	OriginalName string         `json:"original_name" gorm:"size:255;not null"` // 原始文件名
-	StoragePath  string         `json:"storage_path" gorm:"size:500;not null"`  // MinIO存储路径
-	ContentType  string         `json:"content_type" gorm:"size:100"`           // MIME类型
-	FileSize     int64          `json:"file_size" gorm:"not null"`              // 文件大小(字节)
-	MD5Hash      string         `json:"md5_hash" gorm:"size:32"`                // MD5哈希值
-	ConfigID     uint           `json:"config_id" gorm:"not null"`              // 所属MinIO配置ID
-	Config       MinIOConfig    `json:"config" gorm:"foreignKey:ConfigID"`      // 关联的MinIO配置
-	IsPublic     bool           `json:"is_public" gorm:"default:false"`         // 是否为公开文件
-	Tags         string         `json:"tags" gorm:"size:500"`                   // 标签(JSON格式)
-	Metadata     string         `json:"metadata" gorm:"type:text"`              // 元数据(JSON格式)
-	UploadedBy   string         `json:"uploaded_by" gorm:"not null"`            // 上传者用户ID
-	User         User           `json:"user" gorm:"foreignKey:UploadedBy"`      // 上传者用户信息
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index"` // 软删除
+	ID             string         `json:"id" gorm:"primaryKey;size:36"`           // 对象ID (UUID)
+	OriginalName   string         `json:"original_name" gorm:"size:255;not null"` // 原始文件名
+	StoragePath    string         `json:"storage_path" gorm:"size:500;not null"`  // MinIO存储路径
+	ContentType    string         `json:"content_type" gorm:"size:100"`           // MIME类型
+	FileSize       int64          `json:"file_size" gorm:"not null"`              // 文件大小(字节)
+	MD5Hash        string         `json:"md5_hash" gorm:"size:32"`                // MD5哈希值
+	SHA256Hash     string         `json:"sha256_hash" gorm:"size:64;index"`       // SHA-256哈希值
+	ConfigID       uint           `json:"config_id" gorm:"not null"`              // 所属MinIO配置ID
+	Config         MinIOConfig    `json:"config" gorm:"foreignKey:ConfigID"`      // 关联的MinIO配置
+	IsPublic       bool           `json:"is_public" gorm:"default:false"`         // 是否为公开文件
+	Tags           string         `json:"tags" gorm:"size:500"`                   // 标签(JSON格式)
+	Metadata       string         `json:"metadata" gorm:"type:text"`              // 元数据(JSON格式)
+	UploadedBy     string         `json:"uploaded_by" gorm:"not null"`            // 上传者用户ID
+	OrganizationID string         `json:"organization_id" gorm:"size:36;index"`   // 所属组织ID，为空表示个人文件
+	SourceID       string         `json:"source_id" gorm:"size:36;index"`         // 派生文件（如图片的缩放版本）对应的原始文件ID
+	DerivedKey     string         `json:"derived_key" gorm:"size:64;index"`       // 按需生成的派生文件的参数，如 "w320-h200-cover.webp"
+	Pending        bool           `json:"pending" gorm:"default:false;index"`     // 直传存储桶的文件，客户端确认上传完成前为 true
+	User           User           `json:"user" gorm:"foreignKey:UploadedBy"`      // 上传者用户信息
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"index"` // 软删除
+}
+
+// ImageMetadata 图片文件的元数据，上传时生成，以JSON格式保存在 FileObject.Metadata 中
+type ImageMetadata struct {
+	Width         int            `json:"width"`
+	Height        int            `json:"height"`
+	Format        string         `json:"format"`        // png, jpeg, gif, webp
+	DominantColor string         `json:"dominantColor"` // #rrggbb，图片加载前的背景色
+	BlurHash      string         `json:"blurHash"`      // 图片加载前的模糊占位图
+	Variants      []ImageVariant `json:"variants"`      // 缩放版本，按宽度从小到大排列
+}
+
+// ImageVariant 图片的缩放版本，保存为派生文件
+type ImageVariant struct {
+	ObjectID    string `json:"objectId"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+// Image 解析图片元数据，不是图片或尚未处理时返回 nil
+func (f *FileObject) Image() *ImageMetadata {
+	if f.Metadata == "" {
+		return nil
+	}
+	var metadata ImageMetadata
+	if err := json.Unmarshal([]byte(f.Metadata), &metadata); err != nil || metadata.Width == 0 {
+		return nil
+	}
+	return &metadata
 }
 
 // BeforeCreate 创建前钩子，生成UUID
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// 审核对象类型
+const (
+	ReviewTargetPortfolio = "portfolio"
+	ReviewTargetUser      = "user"
+)
+
+// 审核决定
+const (
+	ReviewDecisionApproved = "approved"
+	ReviewDecisionRejected = "rejected"
+)
+
+// ReviewClaimTTL 审核认领的有效期，过期后其他审核员可以重新认领
+const ReviewClaimTTL = 30 * time.Minute
+
+// ModerationDecision 审核决定记录，用于审计和统计
+type ModerationDecision struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TargetType  string    `json:"targetType" gorm:"size:20;not null;index:idx_moderation_target"` // portfolio, user
+	TargetID    string    `json:"targetId" gorm:"type:char(36);not null;index:idx_moderation_target"`
+	ReviewerID  string    `json:"reviewerId" gorm:"type:char(36);index"`
+	Decision    string    `json:"decision" gorm:"size:20;not null"` // approved, rejected
+	Reason      string    `json:"reason" gorm:"type:text"`
+	SubmittedAt time.Time `json:"submittedAt"` // 进入审核队列的时间
+	CreatedAt   time.Time `json:"createdAt" gorm:"index"`
+}
+
+// ReviewQueueQuery 审核队列查询参数
+type ReviewQueueQuery struct {
+	Type     string `form:"type" binding:"omitempty,oneof=portfolio user"`
+	Mine     bool   `form:"mine"` // 只看自己认领的
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+// ReviewQueueItem 审核队列条目
+type ReviewQueueItem struct {
+	Type            string        `json:"type"`
+	ID              string        `json:"id"`
+	Title           string        `json:"title"`
+	OwnerID         string        `json:"ownerId"`
+	SubmittedAt     time.Time     `json:"submittedAt"`
+	WaitingSeconds  int64         `json:"waitingSeconds"`
+	ReviewerID      string        `json:"reviewerId,omitempty"`
+	ReviewClaimedAt *time.Time    `json:"reviewClaimedAt,omitempty"`
+	Owner           *UserResponse `json:"owner,omitempty"`
+}
+
+// ReviewDecisionRequest 审核决定请求，拒绝时必须填写原因
+type ReviewDecisionRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approved rejected"`
+	Reason   string `json:"reason" binding:"required_if=Decision rejected,max=2000"`
+}
+
+// BulkReviewDecisionRequest 批量审核请求
+type BulkReviewDecisionRequest struct {
+	Type     string   `json:"type" binding:"required,oneof=portfolio user"`
+	IDs      []string `json:"ids" binding:"required,min=1,max=100"`
+	Decision string   `json:"decision" binding:"required,oneof=approved rejected"`
+	Reason   string   `json:"reason" binding:"required_if=Decision rejected,max=2000"`
+}
+
+// TableName 指定表名
+func (ModerationDecision) TableName() string {
+	return "moderation_decisions"
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 组织成员角色
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Organization 组织/团队，可以拥有作品和文件
+type Organization struct {
+	ID           string    `json:"id" gorm:"type:char(36);primary_key"`
+	Slug         string    `json:"slug" gorm:"unique;not null;size:50"` // 用于URL的唯一标识
+	Name         string    `json:"name" gorm:"not null;size:100"`
+	Description  string    `json:"description" gorm:"type:text"`
+	Avatar       string    `json:"avatar" gorm:"size:500"`
+	Website      string    `json:"website" gorm:"size:255"`
+	StorageQuota int64     `json:"storageQuota" gorm:"default:0"` // 存储配额(字节)，0表示不限制
+	CreatedBy    string    `json:"createdBy" gorm:"type:char(36);index"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+
+	// 关联成员
+	Members []OrganizationMember `json:"members,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// OrganizationMember 组织成员
+type OrganizationMember struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID string    `json:"organizationId" gorm:"type:char(36);not null;uniqueIndex:idx_org_member"`
+	UserID         string    `json:"userId" gorm:"type:char(36);not null;uniqueIndex:idx_org_member;index"`
+	Role           string    `json:"role" gorm:"size:20;not null;default:'member'"` // owner, admin, member
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+
+	// 关联
+	User         *User         `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
+	Organization *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID;references:ID"`
+}
+
+// OrganizationResponse 组织响应结构
+type OrganizationResponse struct {
+	ID             string    `json:"id"`
+	Slug           string    `json:"slug"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Avatar         string    `json:"avatar"`
+	Website        string    `json:"website"`
+	MemberCount    int64     `json:"memberCount"`
+	PortfolioCount int64     `json:"portfolioCount"`
+	Role           string    `json:"role,omitempty"` // 当前用户在组织中的角色
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// OrganizationMemberResponse 组织成员响应结构
+type OrganizationMemberResponse struct {
+	UserID    string        `json:"userId"`
+	Role      string        `json:"role"`
+	CreatedAt time.Time     `json:"createdAt"`
+	User      *UserResponse `json:"user,omitempty"`
+}
+
+// PublicOrganizationMemberResponse 非成员看到的组织成员信息，不包含邮箱等个人信息
+type PublicOrganizationMemberResponse struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Role     string `json:"role"`
+}
+
+// OrganizationStorageResponse 组织存储用量
+type OrganizationStorageResponse struct {
+	Used      int64 `json:"used"`
+	Quota     int64 `json:"quota"` // 0表示不限制
+	FileCount int64 `json:"fileCount"`
+}
+
+// CreateOrganizationRequest 创建组织请求
+type CreateOrganizationRequest struct {
+	Slug        string `json:"slug" binding:"required,min=3,max=50"` // 小写字母、数字和连字符
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	Avatar      string `json:"avatar"`
+	Website     string `json:"website" binding:"omitempty,url"`
+}
+
+// UpdateOrganizationRequest 更新组织请求
+type UpdateOrganizationRequest struct {
+	Name        string `json:"name" binding:"max=100"`
+	Description string `json:"description"`
+	Avatar      string `json:"avatar"`
+	Website     string `json:"website" binding:"omitempty,url"`
+}
+
+// AddOrganizationMemberRequest 添加成员请求，通过用户ID、用户名或邮箱指定用户
+type AddOrganizationMemberRequest struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role" binding:"required,oneof=admin member"`
+}
+
+// UpdateOrganizationMemberRequest 修改成员角色请求
+type UpdateOrganizationMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// OrganizationQuotaRequest 管理员设置组织存储配额
+type OrganizationQuotaRequest struct {
+	StorageQuota *int64 `json:"storageQuota" binding:"required,min=0"`
+}
+
+// BeforeCreate 创建前钩子，生成ID
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ToResponse 转换为响应结构
+func (m *OrganizationMember) ToResponse() OrganizationMemberResponse {
+	response := OrganizationMemberResponse{
+		UserID:    m.UserID,
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+	}
+	if m.User != nil {
+		user := m.User.ToResponse()
+		response.User = &user
+	}
+	return response
+}
+
+// ToPublicResponse 转换为公开的成员信息
+func (m *OrganizationMember) ToPublicResponse() PublicOrganizationMemberResponse {
+	response := PublicOrganizationMemberResponse{
+		UserID: m.UserID,
+		Role:   m.Role,
+	}
+	if m.User != nil {
+		response.Username = m.User.Username
+		response.Nickname = m.User.Nickname
+		response.Avatar = m.User.Avatar
+	}
+	return response
+}
+
+// TableName 指定表名
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// TableName 指定表名
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
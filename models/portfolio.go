@@ -7,6 +7,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// 作品可见性
+const (
+	VisibilityPublic   = "public"   // 公开，出现在作品列表中
+	VisibilityUnlisted = "unlisted" // 不公开列出，但可以通过直接链接访问
+	VisibilityPrivate  = "private"  // 私有，只能通过分享链接或协作者访问
+)
+
 type Portfolio struct {
 	ID            string    `json:"id" gorm:"type:char(36);primary_key"`
 	UserID        string    `json:"userId" gorm:"type:char(36);index"` // 关联用户
@@ -21,16 +28,48 @@ type Portfolio struct {
 	AILevel       string    `json:"aiLevel" gorm:"size:50"`       // AI完全生成, AI辅助设计, 手工设计
 	Likes         int       `json:"likes" gorm:"default:0"`
 	Views         int       `json:"views" gorm:"default:0"`
-	Status        string    `json:"status" gorm:"default:'draft';size:20"` // draft, published, rejected, deleted
+	Status        string    `json:"status" gorm:"default:'draft';size:20"` // draft, pending_review, published, rejected, deleted
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
 
+	// 审核信息
+	ReviewerID      string     `json:"reviewerId" gorm:"type:char(36);index"` // 认领审核的管理员
+	ReviewClaimedAt *time.Time `json:"reviewClaimedAt"`                       // 认领时间
+	ReviewedAt      *time.Time `json:"reviewedAt"`                            // 最近一次审核时间
+	RejectionReason string     `json:"rejectionReason" gorm:"type:text"`      // 拒绝原因，返回给作者
+
+	// 组织归属
+	OrganizationID string `json:"organizationId" gorm:"type:char(36);index"`        // 所属组织，为空表示个人作品
+	MembersOnly    bool   `json:"membersOnly" gorm:"default:false"`                 // 仅组织成员可见
+	Visibility     string `json:"visibility" gorm:"default:'public';size:20;index"` // public, unlisted, private
+
+	// 回收站信息
+	TrashedAt      *time.Time `json:"trashedAt" gorm:"index"`        // 移入回收站的时间
+	PreviousStatus string     `json:"previousStatus" gorm:"size:20"` // 删除前的状态，恢复时使用
+
+	// 派生来源：从其他作品复制而来时记录来源作品和版本，来源被删除后仍保留署名
+	ForkedFromID        string `json:"forkedFromId" gorm:"type:char(36);index"`
+	ForkedFromVersionID string `json:"forkedFromVersionId" gorm:"type:char(36)"`
+	ForkedFromVersion   string `json:"forkedFromVersion" gorm:"size:80"`
+	ForkedFromTitle     string `json:"forkedFromTitle" gorm:"size:255"`
+	ForkedFromAuthor    string `json:"forkedFromAuthor" gorm:"size:100"`
+
+	// 活跃版本：切换活跃版本只需原子地更新这一列，保证任何时候最多只有一个活跃版本。
+	// 只能通过按列更新修改，避免整行 Save 用旧值覆盖并发的切换
+	ActiveVersionID string `json:"activeVersionId" gorm:"type:char(36);index;<-:create"`
+
 	// 关联用户
 	User *User `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
 
+	// 关联组织
+	Organization *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID;references:ID"`
+
+	// 关联协作者
+	Collaborators []PortfolioCollaborator `json:"collaborators,omitempty" gorm:"foreignKey:PortfolioID"`
+
 	// 关联版本
 	Versions      []PortfolioVersion `json:"versions,omitempty" gorm:"foreignKey:PortfolioID"`
-	ActiveVersion *PortfolioVersion  `json:"activeVersion,omitempty" gorm:"foreignKey:PortfolioID;constraint:OnDelete:SET NULL;where:is_active = true"`
+	ActiveVersion *PortfolioVersion  `json:"activeVersion,omitempty" gorm:"foreignKey:ActiveVersionID;references:ID;constraint:-"` // 版本为软删除，删除时由业务代码清空
 }
 
 func (p *Portfolio) BeforeCreate(tx *gorm.DB) error {
@@ -40,76 +79,126 @@ func (p *Portfolio) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// AfterFind 根据 active_version_id 标记预加载的版本是否为活跃版本
+func (p *Portfolio) AfterFind(tx *gorm.DB) error {
+	for i := range p.Versions {
+		p.Versions[i].IsActive = p.Versions[i].ID == p.ActiveVersionID
+	}
+	if p.ActiveVersion != nil {
+		p.ActiveVersion.IsActive = true
+	}
+	return nil
+}
+
 type PortfolioResponse struct {
-	ID            string                     `json:"id"`
-	UserID        string                     `json:"user_id"`
-	Title         string                     `json:"title"`
-	Author        string                     `json:"author"`
-	AuthorInitial string                     `json:"authorInitial"`
-	Description   string                     `json:"description"`
-	Content       string                     `json:"content"`
-	Category      string                     `json:"category"`
-	Tags          []string                   `json:"tags"`
-	Image         string                     `json:"image"`
-	ImageURL      string                     `json:"imageUrl"`
-	AILevel       string                     `json:"aiLevel"`
-	Likes         int                        `json:"likes"`
-	Views         int                        `json:"views"`
-	Status        string                     `json:"status"`
-	CreatedAt     time.Time                  `json:"createdAt"`
-	UpdatedAt     time.Time                  `json:"updatedAt"`
-	User          *UserResponse              `json:"user,omitempty"`
-	Versions      []PortfolioVersionResponse `json:"versions,omitempty"`
-	ActiveVersion *PortfolioVersionResponse  `json:"activeVersion,omitempty"`
-	Thumbnail     string                     `json:"thumbnail,omitempty"` // 从活跃版本获取的缩略图
+	ID              string                     `json:"id"`
+	UserID          string                     `json:"user_id"`
+	Title           string                     `json:"title"`
+	Author          string                     `json:"author"`
+	Authors         []PortfolioAuthor          `json:"authors"`
+	AuthorInitial   string                     `json:"authorInitial"`
+	Description     string                     `json:"description"`
+	Content         string                     `json:"content"`
+	Category        string                     `json:"category"`
+	Tags            []string                   `json:"tags"`
+	Image           string                     `json:"image"`
+	ImageURL        string                     `json:"imageUrl"`
+	ImageSrcSet     string                     `json:"imageSrcSet,omitempty"` // 封面图各尺寸版本，用于 <img srcset>
+	ImageWidth      int                        `json:"imageWidth,omitempty"`  // 封面图原始尺寸，用于预留布局空间
+	ImageHeight     int                        `json:"imageHeight,omitempty"`
+	ImageColor      string                     `json:"imageColor,omitempty"`    // 封面图主色，图片加载前的背景色
+	ImageBlurHash   string                     `json:"imageBlurHash,omitempty"` // 封面图模糊占位图
+	AILevel         string                     `json:"aiLevel"`
+	Likes           int                        `json:"likes"`
+	Views           int                        `json:"views"`
+	Status          string                     `json:"status"`
+	OrganizationID  string                     `json:"organizationId,omitempty"`
+	Organization    *OrganizationResponse      `json:"organization,omitempty"`
+	MembersOnly     bool                       `json:"membersOnly"`
+	Visibility      string                     `json:"visibility"`
+	RejectionReason string                     `json:"rejectionReason,omitempty"`
+	ReviewedAt      *time.Time                 `json:"reviewedAt,omitempty"`
+	ForkedFrom      *ForkSource                `json:"forkedFrom,omitempty"`
+	CreatedAt       time.Time                  `json:"createdAt"`
+	UpdatedAt       time.Time                  `json:"updatedAt"`
+	User            *UserResponse              `json:"user,omitempty"`
+	Versions        []PortfolioVersionResponse `json:"versions,omitempty"`
+	ActiveVersion   *PortfolioVersionResponse  `json:"activeVersion,omitempty"`
+	Thumbnail       string                     `json:"thumbnail,omitempty"` // 从活跃版本获取的缩略图
 }
 
 type CreatePortfolioRequest struct {
-	Title         string                      `json:"title" binding:"required"`
-	Description   string                      `json:"description"`
-	Category      string                      `json:"category" binding:"required"`
-	Tags          []string                    `json:"tags"`
-	ImageObjectID string                      `json:"imageObjectId"`
-	AILevel       string                      `json:"aiLevel" binding:"required"`
-	Versions      []CreatePortfolioVersionReq `json:"versions"` // 版本信息
+	Title          string                      `json:"title" binding:"required"`
+	Description    string                      `json:"description"`
+	Category       string                      `json:"category" binding:"required"`
+	Tags           []string                    `json:"tags"`
+	ImageObjectID  string                      `json:"imageObjectId"`
+	AILevel        string                      `json:"aiLevel" binding:"required"`
+	OrganizationID string                      `json:"organizationId"` // 以组织名义发布
+	MembersOnly    bool                        `json:"membersOnly"`    // 仅组织成员可见
+	Visibility     string                      `json:"visibility" binding:"omitempty,oneof=public unlisted private"`
+	Versions       []CreatePortfolioVersionReq `json:"versions"` // 版本信息
+}
+
+// ForkSource 派生作品的来源署名
+type ForkSource struct {
+	PortfolioID string `json:"portfolioId"`
+	VersionID   string `json:"versionId"`
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	Author      string `json:"author"`
+}
+
+// ForkPortfolioRequest 派生作品请求
+type ForkPortfolioRequest struct {
+	VersionID string `json:"versionId"`               // 来源版本，为空时使用活跃版本
+	Title     string `json:"title" binding:"max=255"` // 新作品标题，为空时沿用来源标题
 }
 
 // CreatePortfolioVersionReq 创建作品时的版本请求
 type CreatePortfolioVersionReq struct {
-	Name        string `json:"name" binding:"required"`        // 版本名称，如 "v1.0"
-	Title       string `json:"title" binding:"required"`       // 版本标题
-	Description string `json:"description"`                    // 版本描述
-	HTMLContent string `json:"htmlContent" binding:"required"` // HTML内容
-	ChangeLog   string `json:"changeLog"`                      // 版本变更日志
-	IsActive    bool   `json:"isActive"`                       // 是否为活跃版本
+	Name        string `json:"name"`                                             // 指定版本号，如 "v1.0.0"，为空或已被占用时自动生成
+	Branch      string `json:"branch" binding:"omitempty,max=50"`                // 分支名，默认 main
+	Bump        string `json:"bump" binding:"omitempty,oneof=major minor patch"` // 自动生成版本号时的递增方式，默认 minor
+	Title       string `json:"title" binding:"required"`                         // 版本标题
+	Description string `json:"description"`                                      // 版本描述
+	HTMLContent string `json:"htmlContent" binding:"required"`                   // HTML内容
+	ChangeLog   string `json:"changeLog"`                                        // 版本变更日志
+	IsActive    bool   `json:"isActive"`                                         // 是否为活跃版本
 }
 
 type UpdatePortfolioRequest struct {
-	Title         string                         `json:"title"`
-	Description   string                         `json:"description"`
-	Content       string                         `json:"content"`
-	Category      string                         `json:"category"`
-	Tags          []string                       `json:"tags"`
-	ImageObjectID string                         `json:"imageObjectId"`
-	AILevel       string                         `json:"aiLevel"`
-	Status        string                         `json:"status"`
-	Versions      []UpdatePortfolioVersionReq    `json:"versions"` // 最终的版本列表
+	Title          string                      `json:"title"`
+	Description    string                      `json:"description"`
+	Content        string                      `json:"content"`
+	Category       string                      `json:"category"`
+	Tags           []string                    `json:"tags"`
+	ImageObjectID  string                      `json:"imageObjectId"`
+	AILevel        string                      `json:"aiLevel"`
+	Status         string                      `json:"status"`
+	OrganizationID *string                     `json:"organizationId"` // 空字符串表示转为个人作品
+	MembersOnly    *bool                       `json:"membersOnly"`
+	Visibility     string                      `json:"visibility" binding:"omitempty,oneof=public unlisted private"`
+	Versions       []UpdatePortfolioVersionReq `json:"versions"` // 最终的版本列表
 }
 
 // UpdatePortfolioVersionReq 更新作品时的版本请求
 type UpdatePortfolioVersionReq struct {
-	ID          string `json:"id,omitempty"`                   // 版本ID，空表示新版本
-	Name        string `json:"name" binding:"required"`        // 版本名称，如 "v1.0"
-	Title       string `json:"title" binding:"required"`       // 版本标题
-	Description string `json:"description"`                    // 版本描述
-	HTMLContent string `json:"htmlContent" binding:"required"` // HTML内容
-	ChangeLog   string `json:"changeLog"`                      // 版本变更日志
-	IsActive    bool   `json:"isActive"`                       // 是否为活跃版本
+	ID          string `json:"id,omitempty"`                                     // 版本ID，空表示新版本
+	Name        string `json:"name"`                                             // 新版本指定的版本号，已有版本的版本号不可修改
+	Branch      string `json:"branch" binding:"omitempty,max=50"`                // 新版本的分支名，默认 main
+	Bump        string `json:"bump" binding:"omitempty,oneof=major minor patch"` // 新版本自动生成版本号时的递增方式
+	Title       string `json:"title" binding:"required"`                         // 版本标题
+	Description string `json:"description"`                                      // 版本描述
+	HTMLContent string `json:"htmlContent" binding:"required"`                   // HTML内容
+	ChangeLog   string `json:"changeLog"`                                        // 版本变更日志
+	IsActive    bool   `json:"isActive"`                                         // 是否为活跃版本
 }
 
 // 管理员审核作品请求
 type AdminPortfolioRequest struct {
 	Status string `json:"status" binding:"required,oneof=published rejected"`
+	Reason string `json:"reason" binding:"required_if=Status rejected,max=2000"` // 拒绝原因，拒绝时必填
 }
 
 type PortfolioQuery struct {
@@ -117,6 +206,7 @@ type PortfolioQuery struct {
 	Search   string `form:"search"`
 	Status   string `form:"status"`
 	UserID   string `form:"user_id"`
+	OrgID    string `form:"org_id"`
 	Page     int    `form:"page,default=1"`
 	PageSize int    `form:"page_size,default=12"`
 	SortBy   string `form:"sort_by,default=created_at"`
@@ -1,25 +1,48 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrVersionImmutable 已发布版本的内容不可修改，修改需要创建新版本
+var ErrVersionImmutable = errors.New("published versions are immutable")
+
 // PortfolioVersion 作品版本模型
 type PortfolioVersion struct {
-	ID          string    `json:"id" gorm:"type:char(36);primary_key"`
-	PortfolioID string    `json:"portfolioId" gorm:"type:char(36);index;not null"` // 关联作品
-	Version     string    `json:"version" gorm:"size:20;not null"`                 // 版本号，如 "v1.0", "v1.1"
-	Title       string    `json:"title" gorm:"size:255;not null"`                  // 版本标题
-	Description string    `json:"description" gorm:"type:text"`                    // 版本描述
-	HTMLContent string    `json:"htmlContent" gorm:"type:longtext;not null"`       // HTML内容
-	Thumbnail   string    `json:"thumbnail" gorm:"type:text"`                      // 缩略图URL
-	IsActive    bool      `json:"isActive" gorm:"default:false;index"`             // 是否为活跃版本
-	ChangeLog   string    `json:"changeLog" gorm:"type:text"`                      // 版本变更日志
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          string         `json:"id" gorm:"type:char(36);primary_key"`
+	PortfolioID string         `json:"portfolioId" gorm:"type:char(36);index;not null;uniqueIndex:idx_portfolio_version"` // 关联作品
+	Version     string         `json:"version" gorm:"size:80;not null;uniqueIndex:idx_portfolio_version"`                 // 版本号，如 "v1.2.0"，非默认分支为 "v1.2.0-dark-mode"
+	Branch      string         `json:"branch" gorm:"size:50;not null;default:'main';index"`                               // 分支名，用于并行探索不同方案
+	Major       int            `json:"major" gorm:"not null;default:0"`
+	Minor       int            `json:"minor" gorm:"not null;default:0"`
+	Patch       int            `json:"patch" gorm:"not null;default:0"`
+	Title       string         `json:"title" gorm:"size:255;not null"`         // 版本标题
+	Description string         `json:"description" gorm:"type:text"`           // 版本描述
+	HTMLContent string         `json:"htmlContent" gorm:"-"`                   // HTML内容，保存在 content_blobs 中
+	ContentHash string         `json:"contentHash" gorm:"type:char(64);index"` // HTML内容的SHA-256，引用 ContentBlob
+	Thumbnail   string         `json:"thumbnail" gorm:"type:text"`             // 内联缩略图（data URL），未配置文件存储或尚未生成时使用
+	IsActive    bool           `json:"isActive" gorm:"-"`                      // 是否为活跃版本，由作品的 active_version_id 决定
+	ChangeLog   string         `json:"changeLog" gorm:"type:text"`             // 版本变更日志
+	ParentID    string         `json:"parentId" gorm:"type:char(36)"`          // 基于哪个版本修改而来
+	PublishedAt *time.Time     `json:"publishedAt"`                            // 发布时间，发布后内容不可修改
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"` // 软删除，进入回收站
+
+	// 安全检查结果，保存内容时生成
+	SecurityFindings string `json:"-" gorm:"type:text"`                  // JSON格式存储的 SecurityFinding 列表
+	FindingCount     int    `json:"findingCount" gorm:"default:0;index"` // 内容中仍存在的安全问题数量
+
+	// 保存在文件存储中的缩略图对象ID，优先于内联缩略图
+	ThumbnailObjectID string `json:"-" gorm:"type:char(36);index"`
+
+	// 内容分析结果，由缩略图任务在后台生成
+	ContentSummary string `json:"-" gorm:"type:text"` // JSON格式存储的 HTMLSummary
+	SearchText     string `json:"-" gorm:"type:text"` // 标题和可见文字，用于全文搜索
 
 	// 关联作品
 	Portfolio *Portfolio `json:"portfolio,omitempty" gorm:"foreignKey:PortfolioID;references:ID"`
@@ -27,21 +50,32 @@ type PortfolioVersion struct {
 
 // PortfolioVersionResponse 版本响应结构
 type PortfolioVersionResponse struct {
-	ID          string    `json:"id"`
-	PortfolioID string    `json:"portfolioId"`
-	Version     string    `json:"version"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	HTMLContent string    `json:"htmlContent"`
-	Thumbnail   string    `json:"thumbnail"`
-	IsActive    bool      `json:"isActive"`
-	ChangeLog   string    `json:"changeLog"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          string     `json:"id"`
+	PortfolioID string     `json:"portfolioId"`
+	Version     string     `json:"version"`
+	Branch      string     `json:"branch"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	HTMLContent string     `json:"htmlContent,omitempty"` // 列表响应中不返回内容
+	ContentHash string     `json:"contentHash"`
+	Thumbnail   string     `json:"thumbnail"`
+	IsActive    bool       `json:"isActive"`
+	ChangeLog   string     `json:"changeLog"`
+	ParentID    string     `json:"parentId,omitempty"`
+	Immutable   bool       `json:"immutable"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+
+	SecurityFindings []SecurityFinding `json:"securityFindings"`
+	Summary          *HTMLSummary      `json:"summary"` // 内容摘要，尚未分析时为 null
 }
 
 // CreateVersionRequest 创建版本请求
 type CreateVersionRequest struct {
+	Version     string `json:"version"`                                          // 指定版本号，为空或已被占用时自动生成
+	Branch      string `json:"branch" binding:"omitempty,max=50"`                // 分支名，默认 main
+	Bump        string `json:"bump" binding:"omitempty,oneof=major minor patch"` // 自动生成版本号时的递增方式，默认 minor
 	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
 	HTMLContent string `json:"htmlContent" binding:"required"`
@@ -51,6 +85,7 @@ type CreateVersionRequest struct {
 
 // UpdateVersionRequest 更新版本请求
 type UpdateVersionRequest struct {
+	Bump        string `json:"bump" binding:"omitempty,oneof=major minor patch"` // 修改已发布版本产生新版本时的递增方式
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	HTMLContent string `json:"htmlContent"`
@@ -58,23 +93,69 @@ type UpdateVersionRequest struct {
 	IsActive    *bool  `json:"isActive"` // 使用指针以区分false和未设置
 }
 
+// VersionChanges 更新作品时版本列表的变更结果
+type VersionChanges struct {
+	Created   []string `json:"created"`   // 新建的版本ID
+	Updated   []string `json:"updated"`   // 原地更新的版本ID
+	Deleted   []string `json:"deleted"`   // 移入回收站的版本ID
+	Unchanged []string `json:"unchanged"` // 未变化的版本ID
+}
+
+// RevertVersionRequest 回退到历史版本请求，会基于历史版本创建新版本
+type RevertVersionRequest struct {
+	Branch   string `json:"branch" binding:"omitempty,max=50"`                // 新版本所在分支，默认与历史版本相同
+	Bump     string `json:"bump" binding:"omitempty,oneof=major minor patch"` // 版本号递增方式，默认 minor
+	Message  string `json:"message" binding:"max=1000"`                       // 附加在自动变更日志后的说明
+	Activate *bool  `json:"activate"`                                         // 是否设为活跃版本，默认是
+}
+
+// BranchResponse 分支信息
+type BranchResponse struct {
+	Name            string    `json:"name"`
+	VersionCount    int64     `json:"versionCount"`
+	LatestVersion   string    `json:"latestVersion"`
+	LatestVersionID string    `json:"latestVersionId"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
 // ToResponse 转换为响应结构
 func (pv *PortfolioVersion) ToResponse() PortfolioVersionResponse {
 	return PortfolioVersionResponse{
 		ID:          pv.ID,
 		PortfolioID: pv.PortfolioID,
 		Version:     pv.Version,
+		Branch:      pv.Branch,
 		Title:       pv.Title,
 		Description: pv.Description,
 		HTMLContent: pv.HTMLContent,
-		Thumbnail:   pv.Thumbnail,
+		ContentHash: pv.ContentHash,
+		Thumbnail:   pv.ThumbnailURL(),
 		IsActive:    pv.IsActive,
 		ChangeLog:   pv.ChangeLog,
+		ParentID:    pv.ParentID,
+		Immutable:   pv.PublishedAt != nil,
+		PublishedAt: pv.PublishedAt,
 		CreatedAt:   pv.CreatedAt,
 		UpdatedAt:   pv.UpdatedAt,
+
+		SecurityFindings: pv.Findings(),
+		Summary:          pv.Summary(),
 	}
 }
 
+// ThumbnailURL 返回缩略图地址，文件存储中的缩略图优先于内联缩略图
+func (pv *PortfolioVersion) ThumbnailURL() string {
+	if pv.ThumbnailObjectID != "" {
+		return ThumbnailObjectURL(pv.ThumbnailObjectID)
+	}
+	return pv.Thumbnail
+}
+
+// ThumbnailObjectURL 文件存储中缩略图的访问地址，对象不可变，地址可以长期缓存
+func ThumbnailObjectURL(objectID string) string {
+	return "/api/v1/thumbnails/" + objectID
+}
+
 // BeforeCreate 创建前钩子，生成ID
 func (pv *PortfolioVersion) BeforeCreate(tx *gorm.DB) error {
 	if pv.ID == "" {
@@ -82,3 +163,81 @@ func (pv *PortfolioVersion) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// BeforeSave 保存前执行HTML安全策略并将内容写入内容寻址存储，已发布版本的内容不允许修改
+func (pv *PortfolioVersion) BeforeSave(tx *gorm.DB) error {
+	if pv.HTMLContent == "" || ContentHash(pv.HTMLContent) == pv.ContentHash {
+		return nil
+	}
+	if err := pv.applyContentPolicy(tx); err != nil {
+		return err
+	}
+	hash := ContentHash(pv.HTMLContent)
+	if hash == pv.ContentHash {
+		return nil
+	}
+	if pv.PublishedAt != nil && pv.ContentHash != "" {
+		return ErrVersionImmutable
+	}
+
+	if _, err := StoreContentBlob(tx, pv.HTMLContent); err != nil {
+		return err
+	}
+	// 内容变化后原有的分析结果失效，由缩略图任务重新生成
+	if pv.ContentHash != "" {
+		pv.ContentSummary, pv.SearchText = "", ""
+		tx.Statement.SetColumn("content_summary", "")
+		tx.Statement.SetColumn("search_text", "")
+	}
+	pv.ContentHash = hash
+	tx.Statement.SetColumn("content_hash", hash)
+	return nil
+}
+
+// AfterFind 预加载了作品时标记是否为活跃版本。HTML内容不在这里加载，
+// 避免列表查询逐行读取内容，需要内容的地方调用 LoadVersionContents
+func (pv *PortfolioVersion) AfterFind(tx *gorm.DB) error {
+	if pv.Portfolio != nil {
+		pv.IsActive = pv.Portfolio.ActiveVersionID == pv.ID
+	}
+	return nil
+}
+
+// LoadContent 从内容寻址存储加载版本的HTML内容
+func (pv *PortfolioVersion) LoadContent(tx *gorm.DB) error {
+	return LoadVersionContents(tx, pv)
+}
+
+// LoadVersionContents 用一次查询从内容寻址存储加载多个版本的HTML内容，
+// 已加载内容或没有内容的版本会被跳过
+func LoadVersionContents(tx *gorm.DB, versions ...*PortfolioVersion) error {
+	var hashes []string
+	for _, pv := range versions {
+		if pv != nil && pv.ContentHash != "" && pv.HTMLContent == "" {
+			hashes = append(hashes, pv.ContentHash)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	var blobs []ContentBlob
+	if err := tx.Session(&gorm.Session{NewDB: true}).Where("hash IN ?", hashes).Find(&blobs).Error; err != nil {
+		return err
+	}
+	contents := make(map[string]string, len(blobs))
+	for _, blob := range blobs {
+		contents[blob.Hash] = blob.Content
+	}
+	for _, pv := range versions {
+		if pv == nil || pv.ContentHash == "" || pv.HTMLContent != "" {
+			continue
+		}
+		content, ok := contents[pv.ContentHash]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		pv.HTMLContent = content
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 分享链接密码错误次数限制：连续输错 ShareLinkMaxPasswordAttempts 次后锁定 ShareLinkLockout
+const (
+	ShareLinkMaxPasswordAttempts = 5
+	ShareLinkLockout             = 15 * time.Minute
+)
+
+// ShareLink 作品分享链接，无需账号即可只读访问私有作品或指定版本
+type ShareLink struct {
+	ID             string     `json:"id" gorm:"type:char(36);primary_key"`
+	PortfolioID    string     `json:"portfolioId" gorm:"type:char(36);not null;index"`
+	VersionID      string     `json:"versionId" gorm:"type:char(36)"` // 为空表示分享整个作品
+	CreatedBy      string     `json:"createdBy" gorm:"type:char(36);not null"`
+	PasswordHash   string     `json:"-" gorm:"size:255"` // 为空表示无需密码
+	ExpiresAt      *time.Time `json:"expiresAt"`         // 为空表示永不过期
+	RevokedAt      *time.Time `json:"revokedAt"`
+	AccessCount    int        `json:"accessCount" gorm:"default:0"`
+	LastAccessedAt *time.Time `json:"lastAccessedAt"`
+	FailedAttempts int        `json:"failedAttempts" gorm:"default:0"` // 锁定前连续输错密码的次数
+	TotalFailures  int        `json:"totalFailures" gorm:"default:0"`  // 累计输错密码的次数
+	LockedUntil    *time.Time `json:"lockedUntil"`                     // 输错次数过多时锁定到该时间
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// ShareLinkResponse 分享链接响应结构
+type ShareLinkResponse struct {
+	ID                string     `json:"id"`
+	PortfolioID       string     `json:"portfolioId"`
+	VersionID         string     `json:"versionId,omitempty"`
+	Token             string     `json:"token"`
+	URL               string     `json:"url"`
+	PasswordProtected bool       `json:"passwordProtected"`
+	ExpiresAt         *time.Time `json:"expiresAt"`
+	RevokedAt         *time.Time `json:"revokedAt,omitempty"`
+	AccessCount       int        `json:"accessCount"`
+	LastAccessedAt    *time.Time `json:"lastAccessedAt,omitempty"`
+	FailedAttempts    int        `json:"failedAttempts"`
+	LockedUntil       *time.Time `json:"lockedUntil,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+// CreateShareLinkRequest 创建分享链接请求
+type CreateShareLinkRequest struct {
+	VersionID string `json:"versionId"`                                         // 只分享指定版本
+	ExpiresIn int    `json:"expiresIn" binding:"omitempty,min=60,max=31536000"` // 有效期(秒)，为空表示永不过期
+	Password  string `json:"password" binding:"omitempty,min=4,max=100"`
+}
+
+// BeforeCreate 创建前钩子，生成ID
+func (sl *ShareLink) BeforeCreate(tx *gorm.DB) error {
+	if sl.ID == "" {
+		sl.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// SetPassword 设置访问密码
+func (sl *ShareLink) SetPassword(password string) error {
+	if password == "" {
+		sl.PasswordHash = ""
+		return nil
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	sl.PasswordHash = string(hashed)
+	return nil
+}
+
+// CheckPassword 验证访问密码
+func (sl *ShareLink) CheckPassword(password string) bool {
+	if sl.PasswordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(sl.PasswordHash), []byte(password)) == nil
+}
+
+// IsLocked 是否因输错密码次数过多而暂时锁定
+func (sl *ShareLink) IsLocked() bool {
+	return sl.LockedUntil != nil && time.Now().Before(*sl.LockedUntil)
+}
+
+// IsUsable 分享链接未被撤销且未过期
+func (sl *ShareLink) IsUsable() bool {
+	if sl.RevokedAt != nil {
+		return false
+	}
+	return sl.ExpiresAt == nil || time.Now().Before(*sl.ExpiresAt)
+}
+
+// TableName 指定表名
+func (ShareLink) TableName() string {
+	return "share_links"
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 缩略图任务状态
+const (
+	ThumbnailJobPending   = "pending"   // 等待执行，包括等待重试
+	ThumbnailJobRunning   = "running"   // 正在执行
+	ThumbnailJobSucceeded = "succeeded" // 已生成缩略图
+	ThumbnailJobCanceled  = "canceled"  // 版本已删除或内容已变化，任务不再需要
+	ThumbnailJobDead      = "dead"      // 重试次数用尽，版本保留摘要缩略图
+)
+
+// 缩略图任务来源
+const (
+	ThumbnailJobReasonSave       = "save"       // 保存版本内容
+	ThumbnailJobReasonRegenerate = "regenerate" // 管理员重新生成
+	ThumbnailJobReasonBackfill   = "backfill"   // 补充分析升级前保存的版本
+)
+
+// ThumbnailJobMaxAttempts 缩略图任务的最大执行次数
+const ThumbnailJobMaxAttempts = 5
+
+// ThumbnailJobLease 执行中任务的租约时长，超时未完成的任务视为进程中断，可以被重新领取
+const ThumbnailJobLease = 5 * time.Minute
+
+// ThumbnailJob 缩略图生成任务，保存在数据库中，服务重启后继续执行
+type ThumbnailJob struct {
+	ID          string     `json:"id" gorm:"type:char(36);primary_key"`
+	VersionID   string     `json:"versionId" gorm:"type:char(36);not null;index"`
+	ContentHash string     `json:"contentHash" gorm:"type:char(64)"`                             // 入队时的版本内容，内容变化后任务作废
+	Reason      string     `json:"reason" gorm:"size:20;not null"`                               // save, regenerate, backfill
+	Status      string     `json:"status" gorm:"size:20;not null;index:idx_thumbnail_job_queue"` // pending, running, succeeded, canceled, dead
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts int        `json:"maxAttempts" gorm:"default:5"`
+	LastError   string     `json:"lastError" gorm:"type:text"`
+	RunAt       time.Time  `json:"runAt" gorm:"index:idx_thumbnail_job_queue"` // 最早执行时间，重试时按退避时间推后
+	StartedAt   *time.Time `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// ThumbnailJobQuery 缩略图任务查询参数
+type ThumbnailJobQuery struct {
+	Status    string `form:"status" binding:"omitempty,oneof=pending running succeeded canceled dead"`
+	VersionID string `form:"versionId"`
+	Page      int    `form:"page,default=1"`
+	PageSize  int    `form:"page_size,default=20"`
+}
+
+// ThumbnailJobStats 各状态的缩略图任务数量
+type ThumbnailJobStats map[string]int64
+
+// TableName 指定表名
+func (ThumbnailJob) TableName() string {
+	return "thumbnail_jobs"
+}
+
+// BeforeCreate 创建前钩子，生成ID
+func (tj *ThumbnailJob) BeforeCreate(tx *gorm.DB) error {
+	if tj.ID == "" {
+		tj.ID = uuid.New().String()
+	}
+	return nil
+}
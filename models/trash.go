@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// DefaultTrashRetentionDays 回收站默认保留天数
+const DefaultTrashRetentionDays = 30
+
+// 回收站条目类型
+const (
+	TrashTypePortfolio = "portfolio"
+	TrashTypeVersion   = "version"
+	TrashTypeFile      = "file"
+)
+
+// TrashItem 回收站条目
+type TrashItem struct {
+	Type        string    `json:"type"` // portfolio, version, file
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	PortfolioID string    `json:"portfolioId,omitempty"` // 版本所属作品
+	OwnerID     string    `json:"ownerId"`
+	DeletedAt   time.Time `json:"deletedAt"`
+	PurgeAt     time.Time `json:"purgeAt"` // 到期后将被永久删除
+}
+
+// TrashQuery 回收站查询参数
+type TrashQuery struct {
+	Type string `form:"type" binding:"omitempty,oneof=portfolio version file"`
+	All  bool   `form:"all"` // 管理员查看所有用户的回收站
+}
+
+// PurgeResult 回收站清理结果
+type PurgeResult struct {
+	Portfolios int64 `json:"portfolios"`
+	Versions   int64 `json:"versions"`
+	Files      int64 `json:"files"`
+	Blobs      int64 `json:"blobs"` // 不再被任何版本引用的内容
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 分片上传会话状态
+const (
+	UploadSessionActive     = "active"     // 正在上传分片
+	UploadSessionCompleting = "completing" // 正在合并分片，同一时间只有一个完成请求可以进入该状态
+	UploadSessionCompleted  = "completed"  // 已合并为文件
+	UploadSessionAborted    = "aborted"    // 已取消或超时清理
+)
+
+// 分片上传的参数限制，分片大小受 S3 分片上传的限制：除最后一片外不小于 5MB，最多 10000 片
+const (
+	DefaultUploadChunkSize = 8 * 1024 * 1024
+	MinUploadChunkSize     = 5 * 1024 * 1024
+	MaxUploadChunkSize     = 64 * 1024 * 1024
+	MaxUploadParts         = 10000
+)
+
+// UploadSessionTTL 上传会话在最后一次上传分片后的保留时长，超时未完成的会话被清理
+const UploadSessionTTL = 24 * time.Hour
+
+// UploadSession 可断点续传的分片上传会话，对应 MinIO 中的一个分片上传
+type UploadSession struct {
+	ID             string    `json:"id" gorm:"type:char(36);primary_key"`
+	UploadID       string    `json:"-" gorm:"size:255;not null"` // MinIO 分片上传ID
+	ObjectName     string    `json:"-" gorm:"size:500;not null"` // MinIO 对象名
+	ConfigID       uint      `json:"-" gorm:"not null"`          // 创建会话时激活的MinIO配置
+	FileName       string    `json:"fileName" gorm:"size:255;not null"`
+	ContentType    string    `json:"contentType" gorm:"size:100"`
+	FileSize       int64     `json:"fileSize" gorm:"not null"`
+	ChunkSize      int64     `json:"chunkSize" gorm:"not null"`
+	IsPublic       bool      `json:"isPublic" gorm:"default:false"`
+	Tags           string    `json:"-" gorm:"size:500"`                              // 完成后写入文件对象的标签(JSON格式)
+	UploadedBy     string    `json:"uploadedBy" gorm:"type:char(36);not null;index"` // 上传者用户ID
+	OrganizationID string    `json:"organizationId" gorm:"size:36"`                  // 所属组织ID，为空表示个人文件
+	Status         string    `json:"status" gorm:"size:20;not null;index"`           // active, completing, completed, aborted
+	FileObjectID   string    `json:"fileObjectId" gorm:"size:36"`                    // 完成后生成的文件对象
+	ExpiresAt      time.Time `json:"expiresAt" gorm:"index"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// UploadPart 已上传的分片，同一分片重复上传时覆盖
+type UploadPart struct {
+	SessionID  string    `json:"-" gorm:"type:char(36);primaryKey"`
+	PartNumber int       `json:"partNumber" gorm:"primaryKey;autoIncrement:false"` // 从1开始
+	ETag       string    `json:"etag" gorm:"size:100;not null"`
+	Size       int64     `json:"size" gorm:"not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// UploadSessionRequest 创建分片上传会话请求
+type UploadSessionRequest struct {
+	FileName       string `json:"fileName" binding:"required,max=255"`
+	ContentType    string `json:"contentType" binding:"max=100"`
+	Size           int64  `json:"size" binding:"required,min=1"`
+	ChunkSize      int64  `json:"chunkSize" binding:"omitempty,min=5242880,max=67108864"`
+	IsPublic       bool   `json:"isPublic"`
+	Category       string `json:"category" binding:"max=50"`
+	Purpose        string `json:"purpose" binding:"max=50"`
+	OrganizationID string `json:"organizationId"`
+}
+
+// UploadSessionResponse 分片上传会话响应结构，包含已上传和缺失的分片，客户端据此续传
+type UploadSessionResponse struct {
+	ID             string    `json:"id"`
+	FileName       string    `json:"fileName"`
+	ContentType    string    `json:"contentType"`
+	FileSize       int64     `json:"fileSize"`
+	ChunkSize      int64     `json:"chunkSize"`
+	TotalParts     int       `json:"totalParts"`
+	UploadedParts  []int     `json:"uploadedParts"`
+	MissingParts   []int     `json:"missingParts"`
+	UploadedBytes  int64     `json:"uploadedBytes"`
+	IsPublic       bool      `json:"isPublic"`
+	OrganizationID string    `json:"organizationId"`
+	Status         string    `json:"status"`
+	FileObjectID   string    `json:"fileObjectId,omitempty"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// TableName 指定表名
+func (UploadPart) TableName() string {
+	return "upload_parts"
+}
+
+// BeforeCreate 创建前钩子，生成ID
+func (us *UploadSession) BeforeCreate(tx *gorm.DB) error {
+	if us.ID == "" {
+		us.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TotalParts 文件的分片数量
+func (us *UploadSession) TotalParts() int {
+	return int((us.FileSize + us.ChunkSize - 1) / us.ChunkSize)
+}
+
+// PartSize 指定分片的大小，最后一片可能小于分片大小，分片编号不合法时返回 0
+func (us *UploadSession) PartSize(partNumber int) int64 {
+	if partNumber < 1 || partNumber > us.TotalParts() {
+		return 0
+	}
+	if partNumber < us.TotalParts() {
+		return us.ChunkSize
+	}
+	return us.FileSize - int64(partNumber-1)*us.ChunkSize
+}
+
+// ToResponse 转换为响应结构
+func (us *UploadSession) ToResponse(parts []UploadPart) UploadSessionResponse {
+	response := UploadSessionResponse{
+		ID:             us.ID,
+		FileName:       us.FileName,
+		ContentType:    us.ContentType,
+		FileSize:       us.FileSize,
+		ChunkSize:      us.ChunkSize,
+		TotalParts:     us.TotalParts(),
+		UploadedParts:  []int{},
+		MissingParts:   []int{},
+		IsPublic:       us.IsPublic,
+		OrganizationID: us.OrganizationID,
+		Status:         us.Status,
+		FileObjectID:   us.FileObjectID,
+		ExpiresAt:      us.ExpiresAt,
+		CreatedAt:      us.CreatedAt,
+	}
+
+	uploaded := make(map[int]bool, len(parts))
+	for _, part := range parts {
+		uploaded[part.PartNumber] = true
+		response.UploadedParts = append(response.UploadedParts, part.PartNumber)
+		response.UploadedBytes += part.Size
+	}
+	if us.Status == UploadSessionActive {
+		for n := 1; n <= response.TotalParts; n++ {
+			if !uploaded[n] {
+				response.MissingParts = append(response.MissingParts, n)
+			}
+		}
+	}
+	return response
+}
@@ -21,6 +21,12 @@ type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
+	// 审核信息
+	ReviewerID      string     `json:"reviewerId" gorm:"type:char(36);index"` // 认领审核的管理员
+	ReviewClaimedAt *time.Time `json:"reviewClaimedAt"`                       // 认领时间
+	ReviewedAt      *time.Time `json:"reviewedAt"`                            // 最近一次审核时间
+	RejectionReason string     `json:"rejectionReason" gorm:"type:text"`      // 拒绝原因
+
 	// 关联作品
 	Portfolios []Portfolio `json:"portfolios,omitempty" gorm:"foreignKey:UserID"`
 }
@@ -60,6 +66,8 @@ type UserResponse struct {
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	RejectionReason string `json:"rejectionReason,omitempty"`
 }
 
 // 注册请求
@@ -94,6 +102,7 @@ type UpdateUserRequest struct {
 type AdminUserRequest struct {
 	Status string `json:"status" binding:"required,oneof=approved rejected banned"`
 	Role   string `json:"role,omitempty" binding:"omitempty,oneof=user admin"`
+	Reason string `json:"reason" binding:"required_if=Status rejected,max=2000"` // 拒绝原因，拒绝时必填
 }
 
 // 用户查询参数
@@ -105,6 +114,14 @@ type UserQuery struct {
 	PageSize int    `form:"page_size,default=20"`
 }
 
+// 获取用户显示名称，昵称为空时使用用户名
+func (u *User) DisplayName() string {
+	if u.Nickname != "" {
+		return u.Nickname
+	}
+	return u.Username
+}
+
 // 转换为用户响应结构
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
@@ -118,5 +135,7 @@ func (u *User) ToResponse() UserResponse {
 		Status:    u.Status,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
+
+		RejectionReason: u.RejectionReason,
 	}
 }
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// 版本压缩包中的保留文件
+const (
+	VersionEntryFile     = "index.html"    // 入口HTML
+	VersionManifestFile  = "manifest.json" // 版本元数据
+	VersionArchiveFormat = "design-ai/version@1"
+)
+
+// VersionManifest 导出压缩包中的版本元数据，导入时用作默认的标题、描述和变更日志
+type VersionManifest struct {
+	Format     string                 `json:"format"`
+	ExportedAt time.Time              `json:"exportedAt"`
+	Entry      string                 `json:"entry"`
+	Portfolio  ManifestPortfolio      `json:"portfolio"`
+	Version    ManifestVersion        `json:"version"`
+	Assets     []ManifestAsset        `json:"assets"`
+	Changelog  []ManifestChangelogRow `json:"changelog"` // 同一分支上截至该版本的变更记录，从新到旧
+}
+
+// ManifestPortfolio 版本所属作品信息
+type ManifestPortfolio struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Category string `json:"category"`
+}
+
+// ManifestVersion 版本信息
+type ManifestVersion struct {
+	ID          string     `json:"id"`
+	Version     string     `json:"version"`
+	Branch      string     `json:"branch"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	ChangeLog   string     `json:"changeLog"`
+	ParentID    string     `json:"parentId,omitempty"`
+	ContentHash string     `json:"contentHash"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// ManifestAsset 资源文件信息
+type ManifestAsset struct {
+	Path        string `json:"path"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Hash        string `json:"hash"`
+}
+
+// ManifestChangelogRow 变更记录
+type ManifestChangelogRow struct {
+	Version   string    `json:"version"`
+	Title     string    `json:"title"`
+	ChangeLog string    `json:"changeLog"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsReservedAssetPath 判断路径是否为入口HTML或元数据文件，资源文件不能使用这些路径
+func IsReservedAssetPath(p string) bool {
+	return p == VersionEntryFile || p == VersionManifestFile
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 单个版本的资源限制
+const (
+	MaxVersionAssetCount = 200              // 每个版本最多的资源文件数
+	MaxVersionAssetSize  = 10 * 1024 * 1024 // 单个资源文件的最大字节数
+	MaxVersionTotalSize  = 50 * 1024 * 1024 // 入口HTML与全部资源文件的总字节数上限
+)
+
+// ErrInvalidAssetPath 资源路径不合法
+var ErrInvalidAssetPath = errors.New("invalid asset path: use a relative path without '..', backslashes or any of ?#%\"'<>*:|")
+
+var assetPathInvalidRegex = regexp.MustCompile(`[\x00-\x1f\x7f\\?#%"'<>*:|]`)
+
+// VersionAsset 版本的资源文件（CSS、JS、图片等），与入口HTML一起构成版本的文件树。
+// 文件内容通过 MinIO 存储，同一文件对象可以被多个版本引用
+type VersionAsset struct {
+	ID           string    `json:"id" gorm:"type:char(36);primary_key"`
+	VersionID    string    `json:"versionId" gorm:"type:char(36);not null;uniqueIndex:idx_version_asset_path"`
+	Path         string    `json:"path" gorm:"size:255;not null;uniqueIndex:idx_version_asset_path"` // 相对入口HTML的路径，如 css/style.css
+	FileObjectID string    `json:"fileObjectId" gorm:"type:char(36);not null;index"`                 // 关联 FileObject
+	ContentType  string    `json:"contentType" gorm:"size:100"`
+	Size         int64     `json:"size" gorm:"not null"`
+	Hash         string    `json:"hash" gorm:"type:char(64)"` // 文件内容的SHA-256
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// VersionAssetResponse 资源文件响应结构
+type VersionAssetResponse struct {
+	ID          string    `json:"id"`
+	VersionID   string    `json:"versionId"`
+	Path        string    `json:"path"`
+	URL         string    `json:"url"` // 预览地址
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Hash        string    `json:"hash"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// BeforeCreate 创建前钩子，生成ID
+func (va *VersionAsset) BeforeCreate(tx *gorm.DB) error {
+	if va.ID == "" {
+		va.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName 指定表名
+func (VersionAsset) TableName() string {
+	return "version_assets"
+}
+
+// ToResponse 转换为响应结构，portfolioID 用于生成预览地址
+func (va *VersionAsset) ToResponse(portfolioID string) VersionAssetResponse {
+	return VersionAssetResponse{
+		ID:          va.ID,
+		VersionID:   va.VersionID,
+		Path:        va.Path,
+		URL:         "/preview/" + portfolioID + "/" + va.VersionID + "/" + (&url.URL{Path: va.Path}).EscapedPath(),
+		ContentType: va.ContentType,
+		Size:        va.Size,
+		Hash:        va.Hash,
+		CreatedAt:   va.CreatedAt,
+		UpdatedAt:   va.UpdatedAt,
+	}
+}
+
+// NormalizeAssetPath 规范化资源路径，拒绝绝对路径、越出版本根目录的路径和特殊字符
+func NormalizeAssetPath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "" || len(p) > 255 || strings.HasPrefix(p, "/") || assetPathInvalidRegex.MatchString(p) {
+		return "", ErrInvalidAssetPath
+	}
+	cleaned := path.Clean(p)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidAssetPath
+	}
+	return cleaned, nil
+}
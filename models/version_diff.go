@@ -0,0 +1,80 @@
+package models
+
+// 差异类型
+const (
+	DiffEqual   = "equal"
+	DiffInsert  = "insert"
+	DiffDelete  = "delete"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// DiffLine 行级差异，用于并排对比界面
+type DiffLine struct {
+	Type    string `json:"type"`              // equal, insert, delete
+	OldLine int    `json:"oldLine,omitempty"` // 在旧版本中的行号（从1开始）
+	NewLine int    `json:"newLine,omitempty"` // 在新版本中的行号（从1开始）
+	Content string `json:"content"`
+}
+
+// DiffStats 差异统计
+type DiffStats struct {
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// AttributeChange 元素属性变化
+type AttributeChange struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// ElementChange 元素级变化，Path 为类似 CSS 选择器的元素路径
+type ElementChange struct {
+	Type       string            `json:"type"` // added, removed, changed
+	Path       string            `json:"path"`
+	Tag        string            `json:"tag"`
+	Attributes []AttributeChange `json:"attributes,omitempty"`
+	TextFrom   string            `json:"textFrom,omitempty"`
+	TextTo     string            `json:"textTo,omitempty"`
+}
+
+// DeclarationChange CSS 声明变化
+type DeclarationChange struct {
+	Property string `json:"property"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// CSSRuleChange CSS 规则变化，@media 等嵌套规则的选择器带有外层前缀
+type CSSRuleChange struct {
+	Type         string              `json:"type"` // added, removed, changed
+	Selector     string              `json:"selector"`
+	Declarations []DeclarationChange `json:"declarations,omitempty"`
+}
+
+// StructuralDiff 基于解析后的 HTML 计算的结构差异
+type StructuralDiff struct {
+	Elements []ElementChange `json:"elements"`
+	Styles   []CSSRuleChange `json:"styles"`
+}
+
+// VersionRef 参与对比的版本
+type VersionRef struct {
+	ID          string `json:"id"`
+	Version     string `json:"version"`
+	ContentHash string `json:"contentHash"`
+}
+
+// VersionDiffResponse 版本对比响应结构
+type VersionDiffResponse struct {
+	From      VersionRef     `json:"from"`
+	To        VersionRef     `json:"to"`
+	Identical bool           `json:"identical"`
+	Unified   string         `json:"unified"` // unified diff 格式文本
+	Stats     DiffStats      `json:"stats"`
+	Lines     []DiffLine     `json:"lines"`
+	Structure StructuralDiff `json:"structure"`
+}
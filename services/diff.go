@@ -0,0 +1,641 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/net/html"
+)
+
+// diffContextLines unified diff 中每个变更块前后保留的上下文行数
+const diffContextLines = 3
+
+// maxDiffEdits 行级差异的最大编辑距离，超过后按整体替换处理，避免内存占用过大
+const maxDiffEdits = 1000
+
+// maxDiffText 结构差异中元素文本的最大长度
+const maxDiffText = 200
+
+// DiffService 版本差异服务
+type DiffService struct{}
+
+// NewDiffService 创建版本差异服务实例
+func NewDiffService() *DiffService {
+	return &DiffService{}
+}
+
+// diffOp 序列差异操作，AIndex/BIndex 为在两个序列中的下标（从0开始）
+type diffOp struct {
+	Type   string
+	AIndex int
+	BIndex int
+}
+
+// DiffVersions 对比两个版本的HTML内容，返回行级差异和结构差异
+func (ds *DiffService) DiffVersions(from, to *models.PortfolioVersion) models.VersionDiffResponse {
+	response := models.VersionDiffResponse{
+		From:      models.VersionRef{ID: from.ID, Version: from.Version, ContentHash: from.ContentHash},
+		To:        models.VersionRef{ID: to.ID, Version: to.Version, ContentHash: to.ContentHash},
+		Identical: from.HTMLContent == to.HTMLContent,
+	}
+
+	response.Lines, response.Stats = ds.DiffLines(from.HTMLContent, to.HTMLContent)
+	response.Unified = ds.UnifiedDiff(response.Lines, from.Version, to.Version)
+	response.Structure = ds.DiffHTMLStructure(from.HTMLContent, to.HTMLContent)
+	return response
+}
+
+// DiffLines 计算两段文本的行级差异
+func (ds *DiffService) DiffLines(oldText, newText string) ([]models.DiffLine, models.DiffStats) {
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+
+	var stats models.DiffStats
+	lines := make([]models.DiffLine, 0, len(newLines))
+	for _, op := range diffSequences(oldLines, newLines) {
+		switch op.Type {
+		case models.DiffEqual:
+			lines = append(lines, models.DiffLine{Type: op.Type, OldLine: op.AIndex + 1, NewLine: op.BIndex + 1, Content: oldLines[op.AIndex]})
+		case models.DiffDelete:
+			stats.Deletions++
+			lines = append(lines, models.DiffLine{Type: op.Type, OldLine: op.AIndex + 1, Content: oldLines[op.AIndex]})
+		case models.DiffInsert:
+			stats.Additions++
+			lines = append(lines, models.DiffLine{Type: op.Type, NewLine: op.BIndex + 1, Content: newLines[op.BIndex]})
+		}
+	}
+	return lines, stats
+}
+
+// UnifiedDiff 将行级差异格式化为 unified diff 文本
+func (ds *DiffService) UnifiedDiff(lines []models.DiffLine, oldName, newName string) string {
+	// 找出需要输出的行：变更行及其上下文
+	include := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Type == models.DiffEqual {
+			continue
+		}
+		for j := max(0, i-diffContextLines); j <= min(len(lines)-1, i+diffContextLines); j++ {
+			include[j] = true
+		}
+	}
+
+	var builder strings.Builder
+	oldPos, newPos := 0, 0 // 当前行之前已经过的旧/新行数
+	for i := 0; i < len(lines); {
+		if !include[i] {
+			oldPos, newPos = advanceDiffPos(lines[i], oldPos, newPos)
+			i++
+			continue
+		}
+
+		// 收集一个连续的变更块
+		end := i
+		for end < len(lines) && include[end] {
+			end++
+		}
+		oldStart, newStart := oldPos+1, newPos+1
+		oldCount, newCount := 0, 0
+		var hunk strings.Builder
+		for _, line := range lines[i:end] {
+			switch line.Type {
+			case models.DiffEqual:
+				hunk.WriteString(" " + line.Content + "\n")
+				oldCount++
+				newCount++
+			case models.DiffDelete:
+				hunk.WriteString("-" + line.Content + "\n")
+				oldCount++
+			case models.DiffInsert:
+				hunk.WriteString("+" + line.Content + "\n")
+				newCount++
+			}
+			oldPos, newPos = advanceDiffPos(line, oldPos, newPos)
+		}
+		// 空范围按惯例使用前一行的行号
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		if builder.Len() == 0 {
+			builder.WriteString("--- " + oldName + "\n+++ " + newName + "\n")
+		}
+		fmt.Fprintf(&builder, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		builder.WriteString(hunk.String())
+		i = end
+	}
+	return builder.String()
+}
+
+func advanceDiffPos(line models.DiffLine, oldPos, newPos int) (int, int) {
+	switch line.Type {
+	case models.DiffEqual:
+		return oldPos + 1, newPos + 1
+	case models.DiffDelete:
+		return oldPos + 1, newPos
+	default:
+		return oldPos, newPos + 1
+	}
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffSequences 使用 Myers 算法计算两个字符串序列的最短编辑脚本
+func diffSequences(a, b []string) []diffOp {
+	// 先去掉公共前缀和后缀，缩小计算范围
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{Type: models.DiffEqual, AIndex: i, BIndex: i})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, diffOp{Type: models.DiffEqual, AIndex: len(a) - suffix + i, BIndex: len(b) - suffix + i})
+	}
+	return ops
+}
+
+func myersDiff(a, b []string, aOffset, bOffset int) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// 差异过大：按整体删除再插入处理
+	if !found {
+		ops := make([]diffOp, 0, n+m)
+		for i := range a {
+			ops = append(ops, diffOp{Type: models.DiffDelete, AIndex: aOffset + i, BIndex: bOffset})
+		}
+		for j := range b {
+			ops = append(ops, diffOp{Type: models.DiffInsert, AIndex: aOffset + n, BIndex: bOffset + j})
+		}
+		return ops
+	}
+
+	// 回溯得到编辑脚本（倒序）
+	var reversed []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffOp{Type: models.DiffEqual, AIndex: aOffset + x, BIndex: bOffset + y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				reversed = append(reversed, diffOp{Type: models.DiffInsert, AIndex: aOffset + x, BIndex: bOffset + y})
+			} else {
+				x--
+				reversed = append(reversed, diffOp{Type: models.DiffDelete, AIndex: aOffset + x, BIndex: bOffset + y})
+			}
+		}
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// DiffHTMLStructure 解析两段HTML，计算元素、属性和CSS规则的变化
+func (ds *DiffService) DiffHTMLStructure(oldHTML, newHTML string) models.StructuralDiff {
+	result := models.StructuralDiff{
+		Elements: []models.ElementChange{},
+		Styles:   []models.CSSRuleChange{},
+	}
+
+	oldDoc, errOld := html.Parse(strings.NewReader(oldHTML))
+	newDoc, errNew := html.Parse(strings.NewReader(newHTML))
+	if errOld != nil || errNew != nil {
+		return result
+	}
+
+	diffElementChildren(oldDoc, newDoc, "", "", &result.Elements)
+	result.Styles = diffCSSRules(collectStyles(oldDoc), collectStyles(newDoc))
+	return result
+}
+
+// diffElementChildren 按元素标识对齐两个节点的子元素并递归比较
+func diffElementChildren(oldNode, newNode *html.Node, oldPath, newPath string, changes *[]models.ElementChange) {
+	oldChildren := childElements(oldNode)
+	newChildren := childElements(newNode)
+
+	oldKeys := make([]string, len(oldChildren))
+	for i, child := range oldChildren {
+		oldKeys[i] = elementKey(child)
+	}
+	newKeys := make([]string, len(newChildren))
+	for i, child := range newChildren {
+		newKeys[i] = elementKey(child)
+	}
+
+	ops := pairElementOps(diffSequences(oldKeys, newKeys), oldChildren, newChildren)
+	for _, op := range ops {
+		switch op.Type {
+		case models.DiffEqual:
+			oldChild, newChild := oldChildren[op.AIndex], newChildren[op.BIndex]
+			childOldPath := joinElementPath(oldPath, oldChildren, op.AIndex)
+			childNewPath := joinElementPath(newPath, newChildren, op.BIndex)
+			if change, ok := compareElements(oldChild, newChild, childNewPath); ok {
+				*changes = append(*changes, change)
+			}
+			diffElementChildren(oldChild, newChild, childOldPath, childNewPath, changes)
+		case models.DiffDelete:
+			child := oldChildren[op.AIndex]
+			*changes = append(*changes, models.ElementChange{
+				Type:     models.DiffRemoved,
+				Path:     joinElementPath(oldPath, oldChildren, op.AIndex),
+				Tag:      child.Data,
+				TextFrom: truncateDiffText(subtreeText(child)),
+			})
+		case models.DiffInsert:
+			child := newChildren[op.BIndex]
+			*changes = append(*changes, models.ElementChange{
+				Type:   models.DiffAdded,
+				Path:   joinElementPath(newPath, newChildren, op.BIndex),
+				Tag:    child.Data,
+				TextTo: truncateDiffText(subtreeText(child)),
+			})
+		}
+	}
+}
+
+// pairElementOps 将同一变更区间内标签相同的删除和插入配对为同一元素的修改，
+// 例如只修改了类名的元素不会被报告为删除再新增
+func pairElementOps(ops []diffOp, oldChildren, newChildren []*html.Node) []diffOp {
+	result := make([]diffOp, 0, len(ops))
+	for i := 0; i < len(ops); {
+		if ops[i].Type == models.DiffEqual {
+			result = append(result, ops[i])
+			i++
+			continue
+		}
+
+		// 一段连续的删除和插入
+		end := i
+		for end < len(ops) && ops[end].Type != models.DiffEqual {
+			end++
+		}
+		run := ops[i:end]
+		paired := make([]bool, len(run))
+		for d, del := range run {
+			if del.Type != models.DiffDelete {
+				continue
+			}
+			for n, ins := range run {
+				if ins.Type == models.DiffInsert && !paired[n] &&
+					newChildren[ins.BIndex].Data == oldChildren[del.AIndex].Data {
+					paired[d], paired[n] = true, true
+					run[d] = diffOp{Type: models.DiffEqual, AIndex: del.AIndex, BIndex: ins.BIndex}
+					break
+				}
+			}
+		}
+		for n, op := range run {
+			if !paired[n] || op.Type == models.DiffEqual {
+				result = append(result, op)
+			}
+		}
+		i = end
+	}
+	return result
+}
+
+// compareElements 比较同一位置元素的属性和直接文本
+func compareElements(oldNode, newNode *html.Node, path string) (models.ElementChange, bool) {
+	change := models.ElementChange{Type: models.DiffChanged, Path: path, Tag: newNode.Data}
+
+	oldAttrs := attributeMap(oldNode)
+	newAttrs := attributeMap(newNode)
+	names := make([]string, 0, len(oldAttrs)+len(newAttrs))
+	for name := range oldAttrs {
+		names = append(names, name)
+	}
+	for name := range newAttrs {
+		if _, ok := oldAttrs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if oldAttrs[name] != newAttrs[name] {
+			change.Attributes = append(change.Attributes, models.AttributeChange{Name: name, From: oldAttrs[name], To: newAttrs[name]})
+		}
+	}
+
+	// <style> 的内容由CSS规则对比单独处理
+	if newNode.Data != "style" {
+		oldText, newText := ownText(oldNode), ownText(newNode)
+		if oldText != newText {
+			change.TextFrom = truncateDiffText(oldText)
+			change.TextTo = truncateDiffText(newText)
+		}
+	}
+
+	return change, len(change.Attributes) > 0 || change.TextFrom != change.TextTo
+}
+
+func childElements(node *html.Node) []*html.Node {
+	var children []*html.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// elementKey 用于对齐兄弟元素的标识：有ID时使用标签和ID，否则使用标签和类名
+func elementKey(node *html.Node) string {
+	attrs := attributeMap(node)
+	if id := attrs["id"]; id != "" {
+		return node.Data + "#" + id
+	}
+	key := node.Data
+	if class := strings.Fields(attrs["class"]); len(class) > 0 {
+		key += "." + strings.Join(class, ".")
+	}
+	return key
+}
+
+// joinElementPath 生成类似CSS选择器的元素路径，有ID时使用ID，否则使用 nth-of-type
+func joinElementPath(parentPath string, siblings []*html.Node, index int) string {
+	node := siblings[index]
+	segment := node.Data
+	if id := attributeMap(node)["id"]; id != "" {
+		segment += "#" + id
+	} else {
+		position, total := 0, 0
+		for i, sibling := range siblings {
+			if sibling.Data == node.Data {
+				total++
+				if i <= index {
+					position++
+				}
+			}
+		}
+		if total > 1 {
+			segment += fmt.Sprintf(":nth-of-type(%d)", position)
+		}
+	}
+	if parentPath == "" {
+		return segment
+	}
+	return parentPath + " > " + segment
+}
+
+func attributeMap(node *html.Node) map[string]string {
+	attrs := make(map[string]string, len(node.Attr))
+	for _, attr := range node.Attr {
+		attrs[attr.Key] = attr.Val
+	}
+	return attrs
+}
+
+// ownText 元素的直接文本（不含子元素）
+func ownText(node *html.Node) string {
+	var parts []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			if text := strings.TrimSpace(child.Data); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// subtreeText 元素及其子元素的全部文本
+func subtreeText(node *html.Node) string {
+	var builder strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			builder.WriteString(n.Data)
+			builder.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+func truncateDiffText(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxDiffText {
+		return text
+	}
+	return string(runes[:maxDiffText]) + "…"
+}
+
+// collectStyles 收集文档中所有 <style> 的内容
+func collectStyles(doc *html.Node) string {
+	var builder strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "style" {
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				builder.WriteString(child.Data)
+				builder.WriteString("\n")
+			}
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return builder.String()
+}
+
+var cssCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// cssRuleSet 解析后的CSS规则，保持规则出现顺序
+type cssRuleSet struct {
+	order []string
+	rules map[string]map[string]string
+}
+
+// diffCSSRules 按选择器比较两段CSS的规则和声明
+func diffCSSRules(oldCSS, newCSS string) []models.CSSRuleChange {
+	oldRules := parseCSSRules(oldCSS)
+	newRules := parseCSSRules(newCSS)
+
+	changes := []models.CSSRuleChange{}
+	for _, selector := range newRules.order {
+		newDecls := newRules.rules[selector]
+		oldDecls, existed := oldRules.rules[selector]
+		if !existed {
+			changes = append(changes, models.CSSRuleChange{
+				Type:         models.DiffAdded,
+				Selector:     selector,
+				Declarations: declarationChanges(nil, newDecls),
+			})
+			continue
+		}
+		if decls := declarationChanges(oldDecls, newDecls); len(decls) > 0 {
+			changes = append(changes, models.CSSRuleChange{Type: models.DiffChanged, Selector: selector, Declarations: decls})
+		}
+	}
+	for _, selector := range oldRules.order {
+		if _, exists := newRules.rules[selector]; !exists {
+			changes = append(changes, models.CSSRuleChange{
+				Type:         models.DiffRemoved,
+				Selector:     selector,
+				Declarations: declarationChanges(oldRules.rules[selector], nil),
+			})
+		}
+	}
+	return changes
+}
+
+func declarationChanges(oldDecls, newDecls map[string]string) []models.DeclarationChange {
+	properties := make([]string, 0, len(oldDecls)+len(newDecls))
+	for property := range oldDecls {
+		properties = append(properties, property)
+	}
+	for property := range newDecls {
+		if _, ok := oldDecls[property]; !ok {
+			properties = append(properties, property)
+		}
+	}
+	sort.Strings(properties)
+
+	var changes []models.DeclarationChange
+	for _, property := range properties {
+		if oldDecls[property] != newDecls[property] {
+			changes = append(changes, models.DeclarationChange{Property: property, From: oldDecls[property], To: newDecls[property]})
+		}
+	}
+	return changes
+}
+
+// parseCSSRules 简单的CSS解析，支持 @media 等嵌套规则，嵌套规则的选择器带外层前缀
+func parseCSSRules(css string) cssRuleSet {
+	set := cssRuleSet{rules: make(map[string]map[string]string)}
+	parseCSSBlock(cssCommentRegex.ReplaceAllString(css, ""), "", &set)
+	return set
+}
+
+func parseCSSBlock(css, prefix string, set *cssRuleSet) {
+	for len(css) > 0 {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			return
+		}
+		// 跳过 @import 等以分号结束的语句
+		if semi := strings.IndexByte(css, ';'); semi >= 0 && semi < open {
+			css = css[semi+1:]
+			continue
+		}
+
+		selector := strings.Join(strings.Fields(css[:open]), " ")
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			end = len(css)
+		}
+		body := css[open+1 : end]
+		if end < len(css) {
+			css = css[end+1:]
+		} else {
+			css = ""
+		}
+
+		if strings.HasPrefix(selector, "@") && strings.Contains(body, "{") {
+			parseCSSBlock(body, strings.TrimSpace(prefix+" "+selector), set)
+			continue
+		}
+
+		key := strings.TrimSpace(prefix + " " + selector)
+		decls, exists := set.rules[key]
+		if !exists {
+			decls = make(map[string]string)
+			set.rules[key] = decls
+			set.order = append(set.order, key)
+		}
+		for _, declaration := range strings.Split(body, ";") {
+			property, value, ok := strings.Cut(declaration, ":")
+			if !ok {
+				continue
+			}
+			property = strings.ToLower(strings.TrimSpace(property))
+			if property != "" {
+				decls[property] = strings.Join(strings.Fields(value), " ")
+			}
+		}
+	}
+}
+
+// 全局版本差异服务实例
+var DiffSvc = NewDiffService()
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/oldweipro/design-ai/models"
+)
+
+// applyDiffOps 按编辑脚本重建两个序列，并检查下标连续
+func applyDiffOps(t *testing.T, a, b []string, ops []diffOp) (edits int) {
+	t.Helper()
+	var gotA, gotB []string
+	for _, op := range ops {
+		switch op.Type {
+		case models.DiffEqual:
+			if op.AIndex != len(gotA) || op.BIndex != len(gotB) {
+				t.Fatalf("equal op at (%d,%d), want (%d,%d)", op.AIndex, op.BIndex, len(gotA), len(gotB))
+			}
+			if a[op.AIndex] != b[op.BIndex] {
+				t.Fatalf("equal op pairs %q with %q", a[op.AIndex], b[op.BIndex])
+			}
+			gotA = append(gotA, a[op.AIndex])
+			gotB = append(gotB, b[op.BIndex])
+		case models.DiffDelete:
+			if op.AIndex != len(gotA) {
+				t.Fatalf("delete op at %d, want %d", op.AIndex, len(gotA))
+			}
+			gotA = append(gotA, a[op.AIndex])
+			edits++
+		case models.DiffInsert:
+			if op.BIndex != len(gotB) {
+				t.Fatalf("insert op at %d, want %d", op.BIndex, len(gotB))
+			}
+			gotB = append(gotB, b[op.BIndex])
+			edits++
+		default:
+			t.Fatalf("unexpected op type %q", op.Type)
+		}
+	}
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || len(gotA) != len(a) {
+		t.Fatalf("ops rebuild old sequence as %q, want %q", gotA, a)
+	}
+	if strings.Join(gotB, "\n") != strings.Join(b, "\n") || len(gotB) != len(b) {
+		t.Fatalf("ops rebuild new sequence as %q, want %q", gotB, b)
+	}
+	return edits
+}
+
+func TestDiffSequences(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		edits int
+	}{
+		{"both empty", "", "", 0},
+		{"identical", "a b c", "a b c", 0},
+		{"insert into empty", "", "a b", 2},
+		{"delete everything", "a b", "", 2},
+		{"append", "a b", "a b c", 1},
+		{"prepend", "b c", "a b c", 1},
+		{"delete middle", "a b c", "a c", 1},
+		{"replace middle", "a b c", "a x c", 2},
+		{"swap", "a b", "b a", 2},
+		{"myers paper example", "a b c a b b a", "c b a b a c", 5},
+		{"repeated lines", "x x x x", "x x y x x", 1},
+		{"no common lines", "a b c", "d e f", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			if edits := applyDiffOps(t, a, b, diffSequences(a, b)); edits != tt.edits {
+				t.Errorf("edit distance = %d, want %d", edits, tt.edits)
+			}
+		})
+	}
+}
+
+func TestDiffSequencesTooManyEdits(t *testing.T) {
+	a := make([]string, maxDiffEdits)
+	b := make([]string, maxDiffEdits)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d", i)
+		b[i] = fmt.Sprintf("new %d", i)
+	}
+	// 公共前缀和后缀不计入编辑距离，仍按行相等输出
+	a = append(append([]string{"head"}, a...), "tail")
+	b = append(append([]string{"head"}, b...), "tail")
+
+	ops := diffSequences(a, b)
+	if edits := applyDiffOps(t, a, b, ops); edits != 2*maxDiffEdits {
+		t.Errorf("edit distance = %d, want %d", edits, 2*maxDiffEdits)
+	}
+	if ops[0].Type != models.DiffEqual || ops[len(ops)-1].Type != models.DiffEqual {
+		t.Errorf("common prefix and suffix should stay equal, got %q and %q", ops[0].Type, ops[len(ops)-1].Type)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		old, new  string
+		additions int
+		deletions int
+	}{
+		{"identical", "<p>a</p>\n<p>b</p>\n", "<p>a</p>\n<p>b</p>\n", 0, 0},
+		{"trailing newline ignored", "<p>a</p>\n", "<p>a</p>", 0, 0},
+		{"crlf normalized", "<p>a</p>\r\n<p>b</p>", "<p>a</p>\n<p>b</p>", 0, 0},
+		{"changed line", "<h1>Title</h1>\n<p>a</p>", "<h1>Title</h1>\n<p>b</p>", 1, 1},
+		{"added lines", "<ul>\n</ul>", "<ul>\n<li>1</li>\n<li>2</li>\n</ul>", 2, 0},
+		{"from empty", "", "<p>a</p>", 1, 0},
+	}
+	ds := NewDiffService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, stats := ds.DiffLines(tt.old, tt.new)
+			if stats.Additions != tt.additions || stats.Deletions != tt.deletions {
+				t.Errorf("stats = +%d -%d, want +%d -%d", stats.Additions, stats.Deletions, tt.additions, tt.deletions)
+			}
+			for _, line := range lines {
+				if line.Type != models.DiffInsert && line.OldLine == 0 {
+					t.Errorf("%s line %q has no old line number", line.Type, line.Content)
+				}
+				if line.Type != models.DiffDelete && line.NewLine == 0 {
+					t.Errorf("%s line %q has no new line number", line.Type, line.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{
+			"changed line",
+			"a\nb\nc\n", "a\nx\nc\n",
+			"--- v1\n+++ v2\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			"insert into empty",
+			"", "a\n",
+			"--- v1\n+++ v2\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			"--- v1\n+++ v2\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+	}
+	ds := NewDiffService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, _ := ds.DiffLines(tt.old, tt.new)
+			if got := ds.UnifiedDiff(lines, "v1", "v2"); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
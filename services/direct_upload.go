@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+)
+
+// 直传存储桶的错误
+var (
+	ErrDirectUploadNotFound = errors.New("pending upload not found")
+	ErrDirectUploadMissing  = errors.New("object has not been uploaded yet")
+	ErrDirectUploadMismatch = errors.New("uploaded object does not match the declared size, type or hash")
+)
+
+// DirectUploadService 客户端直传存储桶：服务端签发带大小和类型条件的上传策略并创建待确认的文件对象，
+// 文件内容不经过服务端，客户端上传后调用确认接口，服务端核对对象后标记为可用
+type DirectUploadService struct{}
+
+// NewDirectUploadService 创建直传服务
+func NewDirectUploadService() *DirectUploadService {
+	return &DirectUploadService{}
+}
+
+// Create 签发预签名 POST 上传策略并创建待确认的文件对象。策略限定对象名、内容类型和精确的文件大小，
+// 提供了SHA-256时还要求存储服务校验内容摘要
+func (ds *DirectUploadService) Create(req models.DirectUploadRequest, userID string) (*models.DirectUploadResponse, error) {
+	if minioClient == nil || activeConfig == nil {
+		return nil, errors.New("minio client not initialized")
+	}
+
+	contentType := normalizeMediaType(req.ContentType)
+	fileName := NormalizeUploadName(req.FileName, contentType)
+	objectID := uuid.New().String()
+	objectName := objectID + filepath.Ext(fileName)
+	expiresAt := time.Now().Add(models.DirectUploadExpiry)
+
+	tags := make(map[string]string)
+	if req.Category != "" {
+		tags["category"] = req.Category
+	}
+	if req.Purpose != "" {
+		tags["purpose"] = req.Purpose
+	}
+
+	policy := minio.NewPostPolicy()
+	policy.SetBucket(activeConfig.BucketName)
+	policy.SetKey(objectName)
+	policy.SetExpires(expiresAt.UTC())
+	policy.SetContentType(req.ContentType)
+	policy.SetContentLengthRange(req.Size, req.Size)
+	for k, v := range tags {
+		policy.SetUserMetadata(k, v)
+	}
+	policy.SetUserMetadata("uploaded-by", userID)
+	policy.SetUserMetadata("original-name", fileName)
+	expectedHash := strings.ToLower(req.SHA256)
+	if expectedHash != "" {
+		raw, _ := hex.DecodeString(expectedHash)
+		if err := policy.SetChecksum(minio.NewChecksum(minio.ChecksumSHA256, raw)); err != nil {
+			return nil, fmt.Errorf("failed to set upload checksum: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url, formData, err := minioClient.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate presigned upload: %w", err)
+	}
+
+	fileObject := &models.FileObject{
+		ID:             objectID,
+		OriginalName:   fileName,
+		StoragePath:    objectName,
+		ContentType:    req.ContentType,
+		FileSize:       req.Size,
+		SHA256Hash:     expectedHash,
+		ConfigID:       activeConfig.ID,
+		IsPublic:       req.IsPublic,
+		UploadedBy:     userID,
+		OrganizationID: req.OrganizationID,
+		Tags:           mapToJSON(tags),
+		Pending:        true,
+	}
+	if err := database.GetDB().Create(fileObject).Error; err != nil {
+		return nil, fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	return &models.DirectUploadResponse{
+		FileID:    objectID,
+		URL:       url.String(),
+		Method:    "POST",
+		FormData:  formData,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Complete 确认直传完成：核对存储桶中的对象大小、类型和摘要后将文件标记为可用。
+// 对象尚未上传时保持待确认状态，客户端可以稍后重试；内容不符时删除对象和文件记录。
+// 文件已确认时直接返回，可以安全重试
+func (ds *DirectUploadService) Complete(fileID, userID string) (*models.FileObject, error) {
+	db := database.GetDB()
+	var fileObject models.FileObject
+	if err := db.Where("id = ? AND uploaded_by = ?", fileID, userID).First(&fileObject).Error; err != nil {
+		return nil, ErrDirectUploadNotFound
+	}
+	if !fileObject.Pending {
+		return &fileObject, nil
+	}
+	if minioClient == nil || activeConfig == nil {
+		return nil, errors.New("minio client not initialized")
+	}
+	if activeConfig.ID != fileObject.ConfigID {
+		return nil, ErrUploadStorageChanged
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	info, err := minioClient.StatObject(ctx, activeConfig.BucketName, fileObject.StoragePath, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, ErrDirectUploadMissing
+		}
+		return nil, fmt.Errorf("failed to stat uploaded object: %w", err)
+	}
+	if info.Size != fileObject.FileSize || info.ContentType != fileObject.ContentType {
+		ds.discard(&fileObject)
+		return nil, ErrDirectUploadMismatch
+	}
+
+	digests, err := objectDigestsOf(ctx, fileObject.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded object: %w", err)
+	}
+	if fileObject.SHA256Hash != "" && digests.sha256 != fileObject.SHA256Hash {
+		ds.discard(&fileObject)
+		return nil, ErrDirectUploadMismatch
+	}
+	contentType, _, err := CheckUploadType(digests.head, fileObject.OriginalName, fileObject.ContentType, tagValue(fileObject.Tags, "purpose"))
+	if err == nil {
+		err = CheckUploadSize(contentType, info.Size)
+	}
+	if err != nil {
+		ds.discard(&fileObject)
+		return nil, err
+	}
+
+	// 直传的文件按上传内容保存，不做图片处理。只确认仍待确认的记录：
+	// 记录可能已被清理任务删除，或已被并发的确认请求确认
+	result := db.Model(&models.FileObject{}).Where("id = ? AND pending = ?", fileObject.ID, true).Updates(map[string]interface{}{
+		"pending":     false,
+		"md5_hash":    digests.md5,
+		"sha256_hash": digests.sha256,
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update file record: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if err := db.Where("id = ? AND pending = ?", fileObject.ID, false).First(&fileObject).Error; err != nil {
+			return nil, ErrDirectUploadNotFound
+		}
+		return &fileObject, nil
+	}
+	fileObject.Pending = false
+	fileObject.MD5Hash = digests.md5
+	fileObject.SHA256Hash = digests.sha256
+
+	log.Printf("Direct upload confirmed. ObjectID: %s, OriginalName: %s", fileObject.ID, fileObject.OriginalName)
+	return &fileObject, nil
+}
+
+// CleanupPending 删除超过保留时长仍未确认的直传文件，包括客户端已上传但未确认的对象
+func (ds *DirectUploadService) CleanupPending() (int64, error) {
+	var pending []models.FileObject
+	if err := database.GetDB().Unscoped().
+		Where("pending = ? AND created_at < ?", true, time.Now().Add(-models.DirectUploadTTL)).
+		Find(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to find pending uploads: %w", err)
+	}
+
+	var removed int64
+	for i := range pending {
+		if err := ds.discard(&pending[i]); err != nil {
+			log.Printf("Failed to remove pending upload %s: %v", pending[i].ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// discard 删除待确认文件的记录和对象。只删除仍待确认的记录，已被确认的文件保持不变；
+// 存储配置已切换时只删除记录
+func (ds *DirectUploadService) discard(fileObject *models.FileObject) error {
+	result := database.GetDB().Unscoped().Where("pending = ?", true).Delete(fileObject)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	if minioClient != nil && activeConfig != nil && activeConfig.ID == fileObject.ConfigID {
+		if err := (&minioService{}).removeFromMinIO(fileObject.StoragePath); err != nil &&
+			minio.ToErrorResponse(err).Code != minio.NoSuchKey {
+			return err
+		}
+	}
+	return nil
+}
+
+// 全局实例
+var DirectUploadSvc = NewDirectUploadService()
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"gorm.io/gorm"
+)
+
+// urlAttributes 可能包含链接的属性
+var urlAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"xlink:href": true,
+	"data":       true,
+	"poster":     true,
+	"background": true,
+}
+
+// scriptSchemes 会执行脚本的链接协议
+var scriptSchemes = []string{"javascript:", "vbscript:"}
+
+var documentRegex = regexp.MustCompile(`(?i)^\s*(<!doctype|<html)`)
+var pixelSizeRegex = regexp.MustCompile(`(?i)(?:^|;)\s*(width|height)\s*:\s*([0-9.]+)px`)
+
+// HTMLPolicyOptions HTML安全策略选项
+type HTMLPolicyOptions struct {
+	Strip              bool     // 是否移除检测到的危险内容
+	TrustedScriptHosts []string // 允许加载外部脚本的域名
+}
+
+// HTMLPolicyService HTML安全策略服务
+type HTMLPolicyService struct{}
+
+// NewHTMLPolicyService 创建HTML安全策略服务实例
+func NewHTMLPolicyService() *HTMLPolicyService {
+	return &HTMLPolicyService{}
+}
+
+// ApplyPolicy 按管理员设置对版本内容执行安全策略，注册为 models.ContentPolicy
+func (hs *HTMLPolicyService) ApplyPolicy(tx *gorm.DB, content string) (string, []models.SecurityFinding, error) {
+	var settings models.AdminSettings
+	if err := tx.Session(&gorm.Session{NewDB: true}).First(&settings).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	sanitized, findings := hs.Scan(content, HTMLPolicyOptions{
+		Strip:              settings.StripUnsafeHTML,
+		TrustedScriptHosts: settings.ScriptHosts(),
+	})
+	return sanitized, findings, nil
+}
+
+// Scan 检测HTML中的危险内容，开启 Strip 时同时移除并返回处理后的HTML
+func (hs *HTMLPolicyService) Scan(content string, options HTMLPolicyOptions) (string, []models.SecurityFinding) {
+	findings := []models.SecurityFinding{}
+
+	// 完整文档按文档解析，片段在 body 上下文中解析，避免序列化时补全 html/head/body
+	isDocument := documentRegex.MatchString(content)
+	var root *html.Node
+	if isDocument {
+		doc, err := html.Parse(strings.NewReader(content))
+		if err != nil {
+			return content, findings
+		}
+		root = doc
+	} else {
+		root = &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		nodes, err := html.ParseFragment(strings.NewReader(content), root)
+		if err != nil {
+			return content, findings
+		}
+		for _, node := range nodes {
+			root.AppendChild(node)
+		}
+	}
+
+	trusted := make(map[string]bool, len(options.TrustedScriptHosts))
+	for _, host := range options.TrustedScriptHosts {
+		trusted[strings.ToLower(host)] = true
+	}
+
+	scanChildren(root, "", trusted, options.Strip, &findings)
+	if !options.Strip || len(findings) == 0 {
+		return content, findings
+	}
+
+	// 重新序列化处理后的HTML，片段只输出 body 内的内容
+	var buf bytes.Buffer
+	if isDocument {
+		html.Render(&buf, root)
+	} else {
+		for child := root.FirstChild; child != nil; child = child.NextSibling {
+			html.Render(&buf, child)
+		}
+	}
+	return buf.String(), findings
+}
+
+// scanChildren 递归检查节点的子元素
+func scanChildren(parent *html.Node, parentPath string, trusted map[string]bool, strip bool, findings *[]models.SecurityFinding) {
+	children := childElements(parent)
+	for i, node := range children {
+		path := joinElementPath(parentPath, children, i)
+		if removed := scanElement(node, path, trusted, strip, findings); removed {
+			continue
+		}
+		scanChildren(node, path, trusted, strip, findings)
+	}
+}
+
+// scanElement 检查单个元素，返回元素是否已被移除
+func scanElement(node *html.Node, path string, trusted map[string]bool, strip bool, findings *[]models.SecurityFinding) bool {
+	record := func(rule, severity, attribute, value string) {
+		*findings = append(*findings, models.SecurityFinding{
+			Rule:      rule,
+			Severity:  severity,
+			Path:      path,
+			Tag:       node.Data,
+			Attribute: attribute,
+			Value:     truncateDiffText(value),
+			Stripped:  strip,
+		})
+	}
+
+	// 外部脚本、跟踪像素和自动跳转整体移除
+	switch node.Data {
+	case "script":
+		if src, ok := getAttr(node, "src"); ok {
+			if host := externalHost(src); host != "" && !trusted[host] {
+				record(models.FindingExternalScript, models.SeverityMedium, "src", src)
+				if strip {
+					node.Parent.RemoveChild(node)
+					return true
+				}
+			}
+		}
+	case "img":
+		if src, ok := getAttr(node, "src"); ok && externalHost(src) != "" && isTrackingPixel(node) {
+			record(models.FindingTrackingPixel, models.SeverityLow, "src", src)
+			if strip {
+				node.Parent.RemoveChild(node)
+				return true
+			}
+		}
+	case "meta":
+		if equiv, ok := getAttr(node, "http-equiv"); ok && strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+			content, _ := getAttr(node, "content")
+			record(models.FindingMetaRefresh, models.SeverityMedium, "http-equiv", content)
+			if strip {
+				node.Parent.RemoveChild(node)
+				return true
+			}
+		}
+	}
+
+	kept := node.Attr[:0]
+	for _, attr := range node.Attr {
+		name := strings.ToLower(attr.Key)
+		if attr.Namespace != "" {
+			name = strings.ToLower(attr.Namespace) + ":" + name
+		}
+		remove := false
+
+		switch {
+		case strings.HasPrefix(name, "on"):
+			record(models.FindingEventHandler, models.SeverityHigh, name, attr.Val)
+			remove = true
+		case urlAttributes[name] && isScriptURL(attr.Val):
+			record(models.FindingJavascriptURL, models.SeverityHigh, name, attr.Val)
+			remove = true
+		case (name == "action" && node.Data == "form" || name == "formaction") && externalHost(attr.Val) != "":
+			record(models.FindingForeignForm, models.SeverityHigh, name, attr.Val)
+			remove = true
+		case name == "srcdoc" && node.Data == "iframe":
+			// srcdoc 中的文档作为属性值保存，其中的脚本和事件处理器不会被逐个检查
+			record(models.FindingIframeSrcdoc, models.SeverityHigh, name, attr.Val)
+			remove = true
+		}
+
+		if !remove || !strip {
+			kept = append(kept, attr)
+		}
+	}
+	node.Attr = kept
+	return false
+}
+
+func getAttr(node *html.Node, key string) (string, bool) {
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// isScriptURL 判断链接是否使用脚本协议，忽略浏览器会跳过的空白和控制字符
+func isScriptURL(value string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, value)
+	cleaned = strings.ToLower(cleaned)
+	for _, scheme := range scriptSchemes {
+		if strings.HasPrefix(cleaned, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// externalHost 返回绝对地址或协议相对地址的域名，相对地址返回空字符串
+func externalHost(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "//") && !strings.Contains(value, "://") {
+		return ""
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// isTrackingPixel 宽高都不超过1像素（通过属性或内联样式声明）的图片视为跟踪像素
+func isTrackingPixel(node *html.Node) bool {
+	size := map[string]float64{}
+	if style, ok := getAttr(node, "style"); ok {
+		for _, match := range pixelSizeRegex.FindAllStringSubmatch(style, -1) {
+			if v, err := strconv.ParseFloat(match[2], 64); err == nil {
+				size[strings.ToLower(match[1])] = v
+			}
+		}
+	}
+	for _, key := range []string{"width", "height"} {
+		if value, ok := getAttr(node, key); ok {
+			if v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64); err == nil {
+				size[key] = v
+			}
+		}
+	}
+	width, hasWidth := size["width"]
+	height, hasHeight := size["height"]
+	return hasWidth && hasHeight && width <= 1 && height <= 1
+}
+
+// 全局HTML安全策略服务实例
+var HTMLPolicySvc = NewHTMLPolicyService()
+
+func init() {
+	models.ContentPolicy = HTMLPolicySvc.ApplyPolicy
+}
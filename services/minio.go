@@ -1,9 +1,13 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -27,9 +31,13 @@ var activeConfig *models.MinIOConfig
 // MinIOService MinIO服务接口
 type MinIOService interface {
 	InitializeClient(config *models.MinIOConfig) error
-	UploadFile(file *multipart.FileHeader, userID string, isPublic bool, tags map[string]string) (*models.FileObject, error)
+	UploadFile(file *multipart.FileHeader, userID, organizationID string, isPublic bool, tags map[string]string) (*models.FileObject, error)
+	UploadReader(reader io.Reader, size int64, filename, contentType, userID, organizationID string, isPublic bool, tags map[string]string) (*models.FileObject, error)
 	GetFileURL(objectID string) (string, error)
+	OpenFile(objectID string) (io.ReadCloser, *models.FileObject, error)
 	DeleteFile(objectID string) error
+	RestoreFile(objectID string) error
+	PurgeFile(objectID string) error
 	GetActiveConfig() *models.MinIOConfig
 	SetActiveConfig(configID uint) error
 	TestConnection(config *models.MinIOConfig) error
@@ -108,7 +116,7 @@ func (s *minioService) TestConnection(config *models.MinIOConfig) error {
 }
 
 // UploadFile 上传文件
-func (s *minioService) UploadFile(file *multipart.FileHeader, userID string, isPublic bool, tags map[string]string) (*models.FileObject, error) {
+func (s *minioService) UploadFile(file *multipart.FileHeader, userID, organizationID string, isPublic bool, tags map[string]string) (*models.FileObject, error) {
 	if minioClient == nil || activeConfig == nil {
 		return nil, errors.New("minio client not initialized")
 	}
@@ -120,31 +128,48 @@ func (s *minioService) UploadFile(file *multipart.FileHeader, userID string, isP
 	}
 	defer src.Close()
 
-	// 生成唯一的文件名
-	objectID := uuid.New().String()
-	ext := filepath.Ext(file.Filename)
-	objectName := fmt.Sprintf("%s%s", objectID, ext)
-
-	// 读取文件内容计算MD5
-	fileContent, err := io.ReadAll(src)
+	// 根据文件头检测实际类型，不信任客户端声明的类型和扩展名
+	reader := bufio.NewReaderSize(src, sniffLength)
+	head, _ := reader.Peek(sniffLength)
+	contentType, filename, err := CheckUploadType(head, file.Filename, file.Header.Get("Content-Type"), tags["purpose"])
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file content: %w", err)
+		return nil, err
+	}
+	if err := CheckUploadSize(contentType, file.Size); err != nil {
+		return nil, err
 	}
 
-	hasher := md5.New()
-	hasher.Write(fileContent)
-	md5Hash := hex.EncodeToString(hasher.Sum(nil))
-
-	// 重新打开文件用于上传
-	src, err = file.Open()
-	if err != nil {
-		return nil, fmt.Errorf("failed to reopen file for upload: %w", err)
+	// 生成唯一的文件名
+	objectID := uuid.New().String()
+	objectName := fmt.Sprintf("%s%s", objectID, filepath.Ext(filename))
+
+	// 图片需要完整解码处理，其他文件只读取一次，边上传边计算摘要
+	metadata := ""
+	var body io.Reader = reader
+	size := file.Size
+	var processed *ProcessedImage
+	if IsProcessableImage(head) && file.Size <= maxImageFileSize {
+		fileContent, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file content: %w", err)
+		}
+		// 图片去除元数据后保存，并记录尺寸和加载占位信息
+		processed, err = ImageSvc.Process(fileContent)
+		if err == nil {
+			fileContent = processed.Data
+			contentType = processed.ContentType
+			encoded, _ := json.Marshal(processed.Metadata)
+			metadata = string(encoded)
+		} else if !errors.Is(err, ErrNotImage) {
+			log.Printf("Warning: failed to process image %s, storing as uploaded: %v", filename, err)
+		}
+		body = bytes.NewReader(fileContent)
+		size = int64(len(fileContent))
 	}
-	defer src.Close()
 
 	// 设置上传选项
 	uploadOptions := minio.PutObjectOptions{
-		ContentType:  file.Header.Get("Content-Type"),
+		ContentType:  contentType,
 		UserMetadata: make(map[string]string),
 	}
 
@@ -153,30 +178,29 @@ func (s *minioService) UploadFile(file *multipart.FileHeader, userID string, isP
 		uploadOptions.UserMetadata[k] = v
 	}
 	uploadOptions.UserMetadata["uploaded-by"] = fmt.Sprintf("%s", userID)
-	uploadOptions.UserMetadata["original-name"] = file.Filename
+	uploadOptions.UserMetadata["original-name"] = filename
 
 	// 上传文件
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-
-	_, err = minioClient.PutObject(ctx, activeConfig.BucketName, objectName, src, file.Size, uploadOptions)
+	digests, err := putObject(objectName, body, size, uploadOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file to minio: %w", err)
+		return nil, err
 	}
 
 	// 创建文件对象记录
 	fileObject := &models.FileObject{
-		ID:           objectID,
-		OriginalName: file.Filename,
-		StoragePath:  objectName,
-		ContentType:  file.Header.Get("Content-Type"),
-		FileSize:     file.Size,
-		MD5Hash:      md5Hash,
-		ConfigID:     activeConfig.ID,
-		IsPublic:     isPublic,
-		UploadedBy:   userID,
-		Tags:         mapToJSON(tags),
-		Metadata:     "",
+		ID:             objectID,
+		OriginalName:   filename,
+		StoragePath:    objectName,
+		ContentType:    contentType,
+		FileSize:       size,
+		MD5Hash:        digests.md5,
+		SHA256Hash:     digests.sha256,
+		ConfigID:       activeConfig.ID,
+		IsPublic:       isPublic,
+		UploadedBy:     userID,
+		OrganizationID: organizationID,
+		Tags:           mapToJSON(tags),
+		Metadata:       metadata,
 	}
 
 	// 保存到数据库
@@ -187,7 +211,61 @@ func (s *minioService) UploadFile(file *multipart.FileHeader, userID string, isP
 		return nil, fmt.Errorf("failed to save file record: %w", err)
 	}
 
-	log.Printf("File uploaded successfully. ObjectID: %s, OriginalName: %s", objectID, file.Filename)
+	// 版本资源文件由HTML按原路径引用，不需要缩放版本
+	if processed != nil && tags["purpose"] != "version-asset" {
+		ImageSvc.CreateVariants(fileObject, processed)
+	}
+
+	log.Printf("File uploaded successfully. ObjectID: %s, OriginalName: %s", objectID, filename)
+	return fileObject, nil
+}
+
+// UploadReader 上传已在内存或其他来源中的文件内容，如压缩包中解出的文件
+func (s *minioService) UploadReader(reader io.Reader, size int64, filename, contentType, userID, organizationID string, isPublic bool, tags map[string]string) (*models.FileObject, error) {
+	if minioClient == nil || activeConfig == nil {
+		return nil, errors.New("minio client not initialized")
+	}
+
+	objectID := uuid.New().String()
+	objectName := fmt.Sprintf("%s%s", objectID, filepath.Ext(filename))
+
+	uploadOptions := minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: make(map[string]string),
+	}
+	for k, v := range tags {
+		uploadOptions.UserMetadata[k] = v
+	}
+	uploadOptions.UserMetadata["uploaded-by"] = userID
+	uploadOptions.UserMetadata["original-name"] = filename
+
+	digests, err := putObject(objectName, reader, size, uploadOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	fileObject := &models.FileObject{
+		ID:             objectID,
+		OriginalName:   filename,
+		StoragePath:    objectName,
+		ContentType:    contentType,
+		FileSize:       size,
+		MD5Hash:        digests.md5,
+		SHA256Hash:     digests.sha256,
+		ConfigID:       activeConfig.ID,
+		IsPublic:       isPublic,
+		UploadedBy:     userID,
+		OrganizationID: organizationID,
+		Tags:           mapToJSON(tags),
+	}
+
+	db := database.GetDB()
+	if err := db.Create(fileObject).Error; err != nil {
+		s.removeFromMinIO(objectName)
+		return nil, fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	log.Printf("File uploaded successfully. ObjectID: %s, OriginalName: %s", objectID, filename)
 	return fileObject, nil
 }
 
@@ -200,14 +278,18 @@ func (s *minioService) GetFileURL(objectID string) (string, error) {
 	// 从数据库获取文件对象信息
 	var fileObject models.FileObject
 	db := database.GetDB()
-	if err := db.Where("id = ?", objectID).First(&fileObject).Error; err != nil {
+	if err := db.Where("id = ? AND pending = ?", objectID, false).First(&fileObject).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return "", errors.New("file not found")
 		}
 		return "", fmt.Errorf("failed to get file record: %w", err)
 	}
 
-	// 如果是公开文件且存储桶不是私有的，直接返回公共URL
+	return fileURL(&fileObject)
+}
+
+// fileURL 生成文件的访问地址：公开文件且存储桶不是私有的返回公共URL，否则返回预签名URL
+func fileURL(fileObject *models.FileObject) (string, error) {
 	if fileObject.IsPublic && !activeConfig.IsPrivate {
 		protocol := "http"
 		if activeConfig.UseSSL {
@@ -231,15 +313,32 @@ func (s *minioService) GetFileURL(objectID string) (string, error) {
 	return url.String(), nil
 }
 
-// DeleteFile 删除文件
-func (s *minioService) DeleteFile(objectID string) error {
+// OpenFile 打开文件对象用于读取内容，调用方负责关闭
+func (s *minioService) OpenFile(objectID string) (io.ReadCloser, *models.FileObject, error) {
 	if minioClient == nil || activeConfig == nil {
-		return errors.New("minio client not initialized")
+		return nil, nil, errors.New("minio client not initialized")
 	}
 
-	// 从数据库获取文件对象信息
 	var fileObject models.FileObject
 	db := database.GetDB()
+	if err := db.Where("id = ? AND pending = ?", objectID, false).First(&fileObject).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("file not found")
+		}
+		return nil, nil, fmt.Errorf("failed to get file record: %w", err)
+	}
+
+	object, err := minioClient.GetObject(context.Background(), activeConfig.BucketName, fileObject.StoragePath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get object from minio: %w", err)
+	}
+	return object, &fileObject, nil
+}
+
+// DeleteFile 删除文件（移入回收站，对象在保留期结束后由清理任务删除）
+func (s *minioService) DeleteFile(objectID string) error {
+	db := database.GetDB()
+	var fileObject models.FileObject
 	if err := db.Where("id = ?", objectID).First(&fileObject).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("file not found")
@@ -247,17 +346,67 @@ func (s *minioService) DeleteFile(objectID string) error {
 		return fmt.Errorf("failed to get file record: %w", err)
 	}
 
-	// 从MinIO删除文件
-	if err := s.removeFromMinIO(fileObject.StoragePath); err != nil {
-		log.Printf("Warning: failed to delete file from MinIO: %v", err)
-	}
-
 	// 软删除数据库记录
 	if err := db.Delete(&fileObject).Error; err != nil {
 		return fmt.Errorf("failed to delete file record: %w", err)
 	}
 
-	log.Printf("File deleted successfully. ObjectID: %s", objectID)
+	// 派生文件随原始文件一起进入回收站
+	if err := db.Where("source_id = ?", objectID).Delete(&models.FileObject{}).Error; err != nil {
+		return fmt.Errorf("failed to delete derived files: %w", err)
+	}
+
+	log.Printf("File moved to trash. ObjectID: %s", objectID)
+	return nil
+}
+
+// RestoreFile 从回收站恢复文件
+func (s *minioService) RestoreFile(objectID string) error {
+	db := database.GetDB()
+	result := db.Unscoped().Model(&models.FileObject{}).
+		Where("id = ? AND deleted_at IS NOT NULL", objectID).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to restore file record: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("file not found in trash")
+	}
+	if err := db.Unscoped().Model(&models.FileObject{}).
+		Where("source_id = ? AND deleted_at IS NOT NULL", objectID).
+		Update("deleted_at", nil).Error; err != nil {
+		return fmt.Errorf("failed to restore derived files: %w", err)
+	}
+
+	log.Printf("File restored from trash. ObjectID: %s", objectID)
+	return nil
+}
+
+// PurgeFile 永久删除文件（MinIO对象和数据库记录）
+func (s *minioService) PurgeFile(objectID string) error {
+	if minioClient == nil || activeConfig == nil {
+		return errors.New("minio client not initialized")
+	}
+
+	db := database.GetDB()
+	var fileObject models.FileObject
+	if err := db.Unscoped().Where("id = ?", objectID).First(&fileObject).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("file not found")
+		}
+		return fmt.Errorf("failed to get file record: %w", err)
+	}
+
+	// 从MinIO删除文件，失败时保留记录以便下次重试
+	if err := s.removeFromMinIO(fileObject.StoragePath); err != nil {
+		return fmt.Errorf("failed to delete file from minio: %w", err)
+	}
+
+	if err := db.Unscoped().Delete(&fileObject).Error; err != nil {
+		return fmt.Errorf("failed to purge file record: %w", err)
+	}
+
+	log.Printf("File purged permanently. ObjectID: %s", objectID)
 	return nil
 }
 
@@ -294,6 +443,30 @@ func (s *minioService) SetActiveConfig(configID uint) error {
 	return s.InitializeClient(&config)
 }
 
+// objectDigests 上传内容的摘要
+type objectDigests struct {
+	md5    string
+	sha256 string
+	head   []byte // 内容开头的字节，用于检测类型
+}
+
+// putObject 将内容写入MinIO，读取的同时计算MD5和SHA-256，内容只读取一次
+func putObject(objectName string, reader io.Reader, size int64, options minio.PutObjectOptions) (*objectDigests, error) {
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if _, err := minioClient.PutObject(ctx, activeConfig.BucketName, objectName,
+		io.TeeReader(reader, io.MultiWriter(md5Hasher, sha256Hasher)), size, options); err != nil {
+		return nil, fmt.Errorf("failed to upload file to minio: %w", err)
+	}
+	return &objectDigests{
+		md5:    hex.EncodeToString(md5Hasher.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hasher.Sum(nil)),
+	}, nil
+}
+
 // removeFromMinIO 从MinIO删除文件
 func (s *minioService) removeFromMinIO(objectName string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -315,6 +488,26 @@ func mapToJSON(m map[string]string) string {
 	return fmt.Sprintf("{%s}", strings.Join(parts, ","))
 }
 
+// tagValue 读取 mapToJSON 保存的标签中的值
+func tagValue(tagsJSON, key string) string {
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(tagsJSON), &tags); err != nil {
+		return ""
+	}
+	return tags[key]
+}
+
+// OrganizationStorageUsage 统计组织已使用的存储空间和文件数量（不含回收站中的文件），
+// 配额接口和上传时的配额检查都使用此统计
+func OrganizationStorageUsage(db *gorm.DB, orgID string) (used int64, count int64, err error) {
+	if err = db.Model(&models.FileObject{}).Where("organization_id = ?", orgID).Count(&count).Error; err != nil {
+		return 0, 0, err
+	}
+	err = db.Model(&models.FileObject{}).Where("organization_id = ?", orgID).
+		Select("COALESCE(SUM(file_size), 0)").Scan(&used).Error
+	return used, count, err
+}
+
 // LoadActiveConfig 从数据库加载激活配置
 func LoadActiveConfig() error {
 	db := database.GetDB()
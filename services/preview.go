@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ViewportPreset 预览时模拟的设备视口
+type ViewportPreset struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// 支持的视口预设
+var viewportPresets = map[string]ViewportPreset{
+	"mobile":  {Name: "mobile", Width: 375, Height: 812},
+	"tablet":  {Name: "tablet", Width: 768, Height: 1024},
+	"desktop": {Name: "desktop", Width: 1440, Height: 900},
+}
+
+// previewSandbox 预览内容的沙箱权限：允许脚本运行，但不共享任何源，无法读取主站的 Cookie 和存储
+const previewSandbox = "allow-scripts allow-popups allow-popups-to-escape-sandbox allow-forms allow-modals"
+
+var viewportMetaRegex = regexp.MustCompile(`(?i)<meta[^>]+name\s*=\s*["']?viewport`)
+var headOpenRegex = regexp.MustCompile(`(?i)<head[^>]*>`)
+
+// PreviewService 版本实时预览服务
+type PreviewService struct {
+	origin         string // 独立的预览域名，如 https://preview.example.com，为空表示使用当前域名
+	frameAncestors string // 允许嵌入预览页的来源
+}
+
+// NewPreviewService 创建预览服务实例，通过 PREVIEW_ORIGIN 和 PREVIEW_FRAME_ANCESTORS 环境变量配置
+func NewPreviewService() *PreviewService {
+	origin := strings.TrimRight(strings.TrimSpace(os.Getenv("PREVIEW_ORIGIN")), "/")
+	frameAncestors := strings.TrimSpace(os.Getenv("PREVIEW_FRAME_ANCESTORS"))
+	if frameAncestors == "" {
+		frameAncestors = "'self'"
+	}
+	return &PreviewService{origin: origin, frameAncestors: frameAncestors}
+}
+
+// Origin 返回预览域名，为空表示与主站同域
+func (ps *PreviewService) Origin() string {
+	return ps.origin
+}
+
+// RedirectURL 配置了独立预览域名但请求来自其他域名时，返回应跳转到的预览地址
+func (ps *PreviewService) RedirectURL(host string, requestURL *url.URL) (string, bool) {
+	if ps.origin == "" {
+		return "", false
+	}
+	origin, err := url.Parse(ps.origin)
+	if err != nil || strings.EqualFold(origin.Host, host) {
+		return "", false
+	}
+	return ps.origin + requestURL.RequestURI(), true
+}
+
+// Viewport 查找视口预设，名称为空表示不注入视口框架
+func (ps *PreviewService) Viewport(name string) (ViewportPreset, bool) {
+	preset, ok := viewportPresets[strings.ToLower(name)]
+	return preset, ok
+}
+
+// ContentSecurityPolicy 预览页面的内容安全策略：以沙箱方式运行，禁止发起请求和提交表单，
+// 只允许加载版本自身的资源文件和 https 的脚本、样式、图片和字体
+func (ps *PreviewService) ContentSecurityPolicy() string {
+	return strings.Join([]string{
+		"sandbox " + previewSandbox,
+		"default-src 'none'",
+		"script-src 'self' 'unsafe-inline' 'unsafe-eval' https:",
+		"style-src 'self' 'unsafe-inline' https:",
+		"img-src 'self' data: blob: https:",
+		"font-src 'self' data: https:",
+		"media-src 'self' data: blob: https:",
+		"frame-src 'none'",
+		"connect-src 'none'",
+		"form-action 'none'",
+		"base-uri 'none'",
+		"frame-ancestors " + ps.frameAncestors,
+	}, "; ")
+}
+
+// ETag 预览内容的实体标签，内容按哈希寻址，同一内容和视口的预览结果不变
+func (ps *PreviewService) ETag(contentHash, viewport string) string {
+	if viewport == "" {
+		viewport = "raw"
+	}
+	return fmt.Sprintf(`"%s-%s"`, contentHash, viewport)
+}
+
+// Render 生成预览页面，指定视口时将内容嵌入对应尺寸的设备框架中
+func (ps *PreviewService) Render(htmlContent string, viewport *ViewportPreset) string {
+	if viewport == nil {
+		return htmlContent
+	}
+
+	// 内容没有声明视口时补充，保证在设备框架中按设备宽度布局
+	if !viewportMetaRegex.MatchString(htmlContent) {
+		meta := `<meta name="viewport" content="width=device-width, initial-scale=1">`
+		if loc := headOpenRegex.FindStringIndex(htmlContent); loc != nil {
+			htmlContent = htmlContent[:loc[1]] + meta + htmlContent[loc[1]:]
+		} else {
+			htmlContent = meta + htmlContent
+		}
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Preview (%[1]s)</title>
+<style>
+html, body { margin: 0; height: 100%%; background: #e5e7eb; }
+body { display: flex; align-items: flex-start; justify-content: center; overflow: auto; }
+.frame { margin: 24px; padding: 12px; border-radius: 24px; background: #111827; box-shadow: 0 10px 30px rgba(0, 0, 0, 0.25); }
+iframe { display: block; width: %[2]dpx; height: %[3]dpx; border: 0; border-radius: 12px; background: #fff; }
+</style>
+</head>
+<body>
+<div class="frame" data-viewport="%[1]s">
+<iframe sandbox="%[4]s" srcdoc="%[5]s"></iframe>
+</div>
+</body>
+</html>
+`, viewport.Name, viewport.Width, viewport.Height, previewSandbox, html.EscapeString(htmlContent))
+}
+
+// 全局预览服务实例
+var PreviewSvc = NewPreviewService()
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/net/html"
+)
+
+// assetURLAttributes 需要重写为资源地址的属性
+var assetURLAttributes = map[string]bool{
+	"src":        true,
+	"href":       true,
+	"poster":     true,
+	"data":       true,
+	"background": true,
+}
+
+var cssURLRegex = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// AssetRewriter 将入口HTML中引用版本资源文件的相对地址重写为预览地址。
+// 入口HTML的预览地址没有目录层级，不重写时相对地址会解析到错误的位置
+type AssetRewriter struct {
+	base   string          // 资源预览地址前缀，如 /preview/{portfolioId}/{versionId}/
+	query  string          // 追加到资源地址上的查询参数，用于传递访问凭证
+	assets map[string]bool // 版本中存在的资源路径
+}
+
+// NewAssetRewriter 创建资源地址重写器
+func (ps *PreviewService) NewAssetRewriter(portfolioID, versionID string, assets []models.VersionAsset, query url.Values) *AssetRewriter {
+	paths := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		paths[asset.Path] = true
+	}
+	return &AssetRewriter{
+		base:   "/preview/" + url.PathEscape(portfolioID) + "/" + url.PathEscape(versionID) + "/",
+		query:  query.Encode(),
+		assets: paths,
+	}
+}
+
+// AssetDigest 计算版本资源文件列表的摘要，资源变化时预览的实体标签随之变化
+func (ps *PreviewService) AssetDigest(assets []models.VersionAsset) string {
+	if len(assets) == 0 {
+		return ""
+	}
+	entries := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		entries = append(entries, asset.Path+":"+asset.Hash)
+	}
+	sort.Strings(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Rewrite 重写HTML中的资源引用，包括元素属性、srcset、内联样式和 <style> 中的 url()。
+// 未修改的部分按原始文本输出
+func (ar *AssetRewriter) Rewrite(content string) string {
+	if len(ar.assets) == 0 {
+		return content
+	}
+
+	var buf bytes.Buffer
+	z := html.NewTokenizer(strings.NewReader(content))
+	inStyle := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				// 解析失败时输出剩余的原始内容
+				buf.Write(z.Raw())
+			}
+			break
+		}
+		raw := string(z.Raw())
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			inStyle = tt == html.StartTagToken && token.Data == "style"
+			if ar.rewriteAttributes(&token) {
+				buf.WriteString(token.String())
+				continue
+			}
+		case html.EndTagToken:
+			inStyle = false
+		case html.TextToken:
+			if inStyle {
+				raw = ar.rewriteCSS(raw)
+			}
+		}
+		buf.WriteString(raw)
+	}
+	return buf.String()
+}
+
+// rewriteAttributes 重写元素属性中的资源引用，返回是否有修改
+func (ar *AssetRewriter) rewriteAttributes(token *html.Token) bool {
+	changed := false
+	for i, attr := range token.Attr {
+		var value string
+		switch key := strings.ToLower(attr.Key); {
+		case assetURLAttributes[key]:
+			value = ar.resolve(attr.Val)
+		case key == "srcset":
+			value = ar.rewriteSrcset(attr.Val)
+		case key == "style":
+			value = ar.rewriteCSS(attr.Val)
+		default:
+			continue
+		}
+		if value != attr.Val {
+			token.Attr[i].Val = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// rewriteSrcset 重写 srcset 中每个候选图片的地址
+func (ar *AssetRewriter) rewriteSrcset(value string) string {
+	candidates := strings.Split(value, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = ar.resolve(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// rewriteCSS 重写样式中 url() 引用的资源地址
+func (ar *AssetRewriter) rewriteCSS(css string) string {
+	return cssURLRegex.ReplaceAllStringFunc(css, func(match string) string {
+		parts := cssURLRegex.FindStringSubmatch(match)
+		resolved := ar.resolve(parts[2])
+		if resolved == parts[2] {
+			return match
+		}
+		return "url(" + parts[1] + resolved + parts[3] + ")"
+	})
+}
+
+// resolve 相对地址指向版本中的资源文件时返回其预览地址，否则原样返回
+func (ar *AssetRewriter) resolve(ref string) string {
+	trimmed := strings.TrimSpace(ref)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "/") {
+		return ref
+	}
+	parsed, err := url.Parse(trimmed)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" {
+		return ref
+	}
+	assetPath, err := models.NormalizeAssetPath(parsed.Path)
+	if err != nil || !ar.assets[assetPath] {
+		return ref
+	}
+
+	query := parsed.RawQuery
+	if ar.query != "" {
+		if query != "" {
+			query += "&"
+		}
+		query += ar.query
+	}
+	resolved := ar.base + (&url.URL{Path: assetPath}).EscapedPath()
+	if query != "" {
+		resolved += "?" + query
+	}
+	if parsed.Fragment != "" {
+		resolved += "#" + parsed.EscapedFragment()
+	}
+	return resolved
+}
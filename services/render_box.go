@@ -0,0 +1,535 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html"
+)
+
+// 缩略图渲染的盒子树构建：解析HTML，计算每个元素的样式，生成参与布局的盒子
+
+// 渲染限制，防止异常内容占用过多资源
+const (
+	maxRenderBoxes       = 5000
+	maxRenderTextLength  = 200000
+	maxRenderImagePixels = 4096 * 4096
+	maxRenderStylesheet  = 1024 * 1024
+)
+
+var errRenderTooComplex = errors.New("document is too complex to render")
+
+// ResourceLoader 按HTML中的引用地址读取外部资源（图片、样式表），无法读取时返回错误
+type ResourceLoader func(ref string) ([]byte, error)
+
+// renderBox 布局盒子
+type renderBox struct {
+	node     *html.Node
+	style    *computedStyle
+	text     string // 文本盒子的内容
+	children []*renderBox
+
+	// 替换元素（图片、视频等）
+	replaced        bool
+	image           image.Image
+	intrinsicWidth  float64
+	intrinsicHeight float64
+
+	marker     string      // 列表项标记，"disc" 等绘制为图形，其余绘制为文字
+	background image.Image // 背景图片
+
+	// 布局结果：边框盒的绝对坐标和尺寸
+	x, y, width, height     float64
+	margin, border, padding [4]float64
+	autoMargin              [2]bool // 左右外边距是否为 auto
+	staticX, staticY        float64 // 绝对定位元素在普通流中的位置，未指定偏移时使用
+
+	fragments []textFragment // 行内格式化上下文中的文字和行内块
+	absolutes []*renderBox   // 以该盒子为包含块的绝对定位元素，在普通流之后绘制
+
+	intrinsicComputed      bool
+	minContent, maxContent float64
+}
+
+// isText 是否为文本盒子，匿名块没有对应的节点但有子盒子
+func (b *renderBox) isText() bool {
+	if b.node == nil {
+		return b.children == nil
+	}
+	return b.node.Type == html.TextNode
+}
+
+// isBlockLevel 是否为块级盒子，块级盒子在普通流中独占一行
+func (b *renderBox) isBlockLevel() bool {
+	if b.isText() {
+		return false
+	}
+	switch b.style.display {
+	case "block", "flex", "grid", "list-item", "flow-root":
+		return true
+	}
+	return false
+}
+
+// isOutOfFlow 是否脱离普通流
+func (b *renderBox) isOutOfFlow() bool {
+	return !b.isText() && (b.style.position == "absolute" || b.style.position == "fixed")
+}
+
+// boxBuilder 盒子树构建器
+type boxBuilder struct {
+	sheets     []*cssStylesheet
+	selectors  selectorCache
+	ctx        *styleContext
+	loader     ResourceLoader
+	boxCount   int
+	textLength int
+	listIndex  []int
+}
+
+// buildDocument 解析HTML并构建盒子树，返回根元素的盒子
+func buildDocument(htmlContent string, ctx *styleContext, loader ResourceLoader) (*renderBox, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, err
+	}
+
+	builder := &boxBuilder{ctx: ctx, loader: loader, selectors: selectorCache{}}
+	builder.sheets = []*cssStylesheet{parseStylesheet(userAgentCSS, ctx.viewportWidth, 0), builder.collectStylesheets(doc)}
+
+	root := findElement(doc, "html")
+	if root == nil {
+		return nil, errors.New("document has no root element")
+	}
+	box, err := builder.build(root, nil)
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, errors.New("document root is not rendered")
+	}
+	return box, nil
+}
+
+// collectStylesheets 按文档顺序收集 <style> 内容和可以读取的外部样式表
+func (bb *boxBuilder) collectStylesheets(doc *html.Node) *cssStylesheet {
+	var css strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "style":
+				if node.FirstChild != nil && css.Len() < maxRenderStylesheet {
+					css.WriteString(node.FirstChild.Data)
+					css.WriteString("\n")
+				}
+				return
+			case "link":
+				rel, _ := getAttr(node, "rel")
+				href, _ := getAttr(node, "href")
+				if strings.EqualFold(strings.TrimSpace(rel), "stylesheet") && bb.loader != nil && css.Len() < maxRenderStylesheet {
+					if data, err := bb.loader(href); err == nil && len(data) <= maxRenderStylesheet {
+						css.Write(data)
+						css.WriteString("\n")
+					}
+				}
+				return
+			case "template", "svg", "noscript":
+				return
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return parseStylesheet(css.String(), bb.ctx.viewportWidth, 1<<20)
+}
+
+// build 构建元素及其子节点的盒子，不显示的元素返回 nil
+func (bb *boxBuilder) build(node *html.Node, parent *computedStyle) (*renderBox, error) {
+	bb.boxCount++
+	if bb.boxCount > maxRenderBoxes {
+		return nil, errRenderTooComplex
+	}
+
+	style := computeStyle(node, parent, cascade(bb.sheets, node, bb.selectors), bb.ctx)
+	if style.display == "none" {
+		return nil, nil
+	}
+	// 带背景、边框或内边距的行内元素按行内块处理，以便绘制其背景
+	if style.display == "inline" && (style.backgroundColor.A > 0 || style.backgroundImage != "" && style.backgroundImage != "none" ||
+		style.border[0].width+style.border[1].width+style.border[2].width+style.border[3].width > 0 ||
+		style.padding[1].px+style.padding[3].px > 0) {
+		style.display = "inline-block"
+	}
+	box := &renderBox{node: node, style: style}
+	if ref, ok := cssURL(style.backgroundImage); ok {
+		box.background = bb.loadImage(ref)
+	}
+
+	switch node.Data {
+	case "img", "svg", "video", "canvas", "iframe", "object", "embed", "picture":
+		bb.buildReplaced(box)
+		return box, nil
+	case "input", "textarea", "select":
+		bb.buildFormControl(box)
+		return box, nil
+	case "br":
+		box.text = "\n"
+		return box, nil
+	}
+
+	if style.display == "list-item" {
+		box.marker = bb.listMarker(node, style)
+	}
+	if node.Data == "ol" || node.Data == "ul" || node.Data == "menu" {
+		start := 0
+		if value, ok := getAttr(node, "start"); ok {
+			if n, err := strconv.Atoi(value); err == nil {
+				start = n - 1
+			}
+		}
+		bb.listIndex = append(bb.listIndex, start)
+		defer func() { bb.listIndex = bb.listIndex[:len(bb.listIndex)-1] }()
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			if bb.textLength > maxRenderTextLength {
+				continue
+			}
+			bb.textLength += len(child.Data)
+			box.children = append(box.children, &renderBox{node: child, style: style, text: child.Data})
+		case html.ElementNode:
+			childBox, err := bb.build(child, style)
+			if err != nil {
+				return nil, err
+			}
+			if childBox != nil {
+				box.children = append(box.children, childBox)
+			}
+		}
+	}
+
+	if style.display == "flex" || style.display == "inline-flex" || style.display == "grid" || style.display == "inline-grid" {
+		box.children = blockifyChildren(box.children, style)
+	}
+	return box, nil
+}
+
+// blockifyChildren 弹性和网格容器的子元素都按块级处理，连续的文字包装为匿名块
+func blockifyChildren(children []*renderBox, parent *computedStyle) []*renderBox {
+	result := make([]*renderBox, 0, len(children))
+	var run []*renderBox
+	flush := func() {
+		hasText := false
+		for _, child := range run {
+			if strings.TrimSpace(child.text) != "" || !child.isText() {
+				hasText = true
+			}
+		}
+		if hasText {
+			anonymous := newComputedStyle(parent)
+			anonymous.display = "block"
+			result = append(result, &renderBox{style: anonymous, children: run})
+		}
+		run = nil
+	}
+	for _, child := range children {
+		if child.isText() || child.style.display == "inline" && !child.replaced {
+			run = append(run, child)
+			continue
+		}
+		flush()
+		if !child.isOutOfFlow() {
+			switch child.style.display {
+			case "inline-block", "inline":
+				child.style.display = "block"
+			case "inline-flex":
+				child.style.display = "flex"
+			case "inline-grid":
+				child.style.display = "grid"
+			}
+		}
+		result = append(result, child)
+	}
+	flush()
+	return result
+}
+
+// buildReplaced 构建图片等替换元素，图片无法读取时绘制为占位块
+func (bb *boxBuilder) buildReplaced(box *renderBox) {
+	box.replaced = true
+	if box.style.display == "inline" {
+		box.style.display = "inline-block"
+	}
+
+	width, height := 300.0, 150.0
+	switch box.node.Data {
+	case "svg":
+		width, height = 24, 24
+		if viewBox, ok := getAttr(box.node, "viewBox"); ok {
+			if fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " ")); len(fields) == 4 {
+				w, errW := strconv.ParseFloat(fields[2], 64)
+				h, errH := strconv.ParseFloat(fields[3], 64)
+				if errW == nil && errH == nil && w > 0 && h > 0 {
+					height = width * h / w
+				}
+			}
+		}
+	case "img", "picture":
+		src, _ := getAttr(box.node, "src")
+		if box.node.Data == "picture" {
+			if img := findElement(box.node, "img"); img != nil {
+				src, _ = getAttr(img, "src")
+			}
+		}
+		if img := bb.loadImage(src); img != nil {
+			box.image = img
+			bounds := img.Bounds()
+			width, height = float64(bounds.Dx()), float64(bounds.Dy())
+		}
+	case "video":
+		if poster, ok := getAttr(box.node, "poster"); ok {
+			box.image = bb.loadImage(poster)
+		}
+	}
+
+	// width/height 属性指定固有尺寸，只指定一个时按比例换算
+	attrWidth, hasWidth := parseDimensionAttr(box.node, "width")
+	attrHeight, hasHeight := parseDimensionAttr(box.node, "height")
+	switch {
+	case hasWidth && hasHeight:
+		width, height = attrWidth, attrHeight
+	case hasWidth:
+		height = height * attrWidth / width
+		width = attrWidth
+	case hasHeight:
+		width = width * attrHeight / height
+		height = attrHeight
+	}
+	box.intrinsicWidth, box.intrinsicHeight = width, height
+}
+
+// buildFormControl 表单控件按行内块绘制，内容为当前值或占位文字
+func (bb *boxBuilder) buildFormControl(box *renderBox) {
+	if box.style.display == "inline" {
+		box.style.display = "inline-block"
+	}
+	inputType, _ := getAttr(box.node, "type")
+	inputType = strings.ToLower(inputType)
+
+	var text string
+	box.style.whiteSpace = "pre"
+	textStyle := box.style
+	switch box.node.Data {
+	case "textarea":
+		text = textContent(box.node)
+		if box.style.width.isAuto() {
+			box.style.width = cssLength{px: 20 * box.style.fontSize * 0.55}
+		}
+		if box.style.height.isAuto() {
+			box.style.height = cssLength{px: 2 * box.style.lineHeight}
+		}
+	case "select":
+		for _, option := range findElements(box.node, "option") {
+			if _, selected := getAttr(option, "selected"); selected || text == "" {
+				text = strings.TrimSpace(textContent(option))
+			}
+		}
+		text += " ▾"
+	default:
+		switch inputType {
+		case "checkbox", "radio":
+			box.replaced = true
+			box.intrinsicWidth, box.intrinsicHeight = 13, 13
+			return
+		case "submit", "button", "reset":
+			text, _ = getAttr(box.node, "value")
+			if text == "" && inputType == "submit" {
+				text = "Submit"
+			}
+		case "range", "color", "file", "image":
+			box.replaced = true
+			box.intrinsicWidth, box.intrinsicHeight = 130, 20
+			return
+		default:
+			text, _ = getAttr(box.node, "value")
+			if text == "" {
+				text, _ = getAttr(box.node, "placeholder")
+				placeholder := *box.style
+				placeholder.color.A /= 2
+				textStyle = &placeholder
+			}
+			if inputType == "password" && text != "" {
+				text = strings.Repeat("•", len([]rune(text)))
+			}
+			if box.style.width.isAuto() {
+				box.style.width = cssLength{px: 20 * box.style.fontSize * 0.55}
+			}
+		}
+	}
+	if text == "" {
+		text = " "
+	}
+	box.children = []*renderBox{{style: textStyle, text: text}}
+}
+
+// listMarker 计算列表项标记
+func (bb *boxBuilder) listMarker(node *html.Node, style *computedStyle) string {
+	index := 0
+	if len(bb.listIndex) > 0 {
+		bb.listIndex[len(bb.listIndex)-1]++
+		index = bb.listIndex[len(bb.listIndex)-1]
+	}
+	switch style.listStyleType {
+	case "none":
+		return ""
+	case "decimal", "decimal-leading-zero":
+		return strconv.Itoa(index) + "."
+	case "lower-alpha", "lower-latin":
+		if index >= 1 && index <= 26 {
+			return string(rune('a'+index-1)) + "."
+		}
+		return strconv.Itoa(index) + "."
+	case "upper-alpha", "upper-latin":
+		if index >= 1 && index <= 26 {
+			return string(rune('A'+index-1)) + "."
+		}
+		return strconv.Itoa(index) + "."
+	case "circle", "square":
+		return style.listStyleType
+	}
+	return "disc"
+}
+
+// loadImage 读取图片：data URI 直接解码，其他地址通过资源加载器读取。像素数超过限制的图片不解码
+func (bb *boxBuilder) loadImage(ref string) image.Image {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil
+	}
+	var data []byte
+	if strings.HasPrefix(ref, "data:") {
+		data = decodeDataURI(ref)
+	} else if bb.loader != nil {
+		data, _ = bb.loader(ref)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxRenderImagePixels {
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// decodeDataURI 解码 data URI 的内容
+func decodeDataURI(uri string) []byte {
+	comma := strings.Index(uri, ",")
+	if comma < 0 {
+		return nil
+	}
+	meta, payload := uri[len("data:"):comma], uri[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		payload = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+				return -1
+			}
+			return r
+		}, payload)
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+		}
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil
+	}
+	return []byte(decoded)
+}
+
+// cssURL 提取 url() 中的地址
+func cssURL(value string) (string, bool) {
+	m := cssURLRegex.FindStringSubmatch(value)
+	if m == nil {
+		return "", false
+	}
+	return m[2], true
+}
+
+// parseDimensionAttr 解析 width/height 属性中的像素值
+func parseDimensionAttr(node *html.Node, name string) (float64, bool) {
+	value, ok := getAttr(node, name)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// findElement 查找第一个指定标签的元素
+func findElement(node *html.Node, tag string) *html.Node {
+	if node.Type == html.ElementNode && node.Data == tag {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findElements 查找所有指定标签的元素
+func findElements(node *html.Node, tag string) []*html.Node {
+	var found []*html.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == tag {
+			found = append(found, child)
+		}
+		found = append(found, findElements(child, tag)...)
+	}
+	return found
+}
+
+// textContent 元素的全部文字内容
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return sb.String()
+}
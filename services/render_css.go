@@ -0,0 +1,636 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 简化的CSS解析与层叠，供缩略图渲染使用。
+// 支持类型、类、ID、属性选择器和后代/子/兄弟组合器，以及 @media 中按视口宽度判断的规则
+
+// cssDeclaration CSS声明
+type cssDeclaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// cssAttrSelector 属性选择器，如 [type="text"]
+type cssAttrSelector struct {
+	name  string
+	op    string // "" 表示只判断存在，支持 = ~= ^= $= *=
+	value string
+}
+
+// cssCompound 复合选择器，如 div.card#main
+type cssCompound struct {
+	tag        string
+	id         string
+	classes    []string
+	attrs      []cssAttrSelector
+	pseudo     []string
+	combinator byte // 与左侧复合选择器的关系：' ' 后代，'>' 子元素，'+' 相邻兄弟，'~' 兄弟；第一个为 0
+}
+
+// cssRule 单个选择器及其声明
+type cssRule struct {
+	selector     []cssCompound
+	specificity  int
+	order        int
+	declarations []cssDeclaration
+}
+
+// cssStylesheet 样式表
+type cssStylesheet struct {
+	rules []cssRule
+}
+
+// maxSelectorCompounds 单个选择器最多包含的复合选择器数量，超过时按不支持的选择器忽略
+const maxSelectorCompounds = 32
+
+var mediaWidthRegex = regexp.MustCompile(`\((min|max)-width\s*:\s*([0-9.]+)(px|em|rem)?\s*\)`)
+
+// parseStylesheet 解析样式表，viewportWidth 用于判断 @media 规则是否生效
+func parseStylesheet(css string, viewportWidth float64, orderBase int) *cssStylesheet {
+	sheet := &cssStylesheet{}
+	parseRuleBlock(cssCommentRegex.ReplaceAllString(css, ""), viewportWidth, sheet, orderBase)
+	return sheet
+}
+
+// parseRuleBlock 解析一段规则列表，@media 块中的规则在条件满足时递归解析
+func parseRuleBlock(css string, viewportWidth float64, sheet *cssStylesheet, orderBase int) {
+	pos := 0
+	for pos < len(css) {
+		// 跳过空白
+		for pos < len(css) && isCSSSpace(css[pos]) {
+			pos++
+		}
+		if pos >= len(css) {
+			return
+		}
+
+		open := strings.IndexAny(css[pos:], "{;")
+		if open < 0 {
+			return
+		}
+		open += pos
+		prelude := strings.TrimSpace(css[pos:open])
+
+		// 没有块的 @ 规则，如 @import、@charset
+		if css[open] == ';' {
+			pos = open + 1
+			continue
+		}
+
+		end := matchingBrace(css, open)
+		body := css[open+1 : end]
+		pos = end + 1
+
+		if strings.HasPrefix(prelude, "@") {
+			lower := strings.ToLower(prelude)
+			if strings.HasPrefix(lower, "@media") && mediaMatches(lower[len("@media"):], viewportWidth) {
+				parseRuleBlock(body, viewportWidth, sheet, orderBase)
+			} else if strings.HasPrefix(lower, "@supports") || strings.HasPrefix(lower, "@layer") {
+				parseRuleBlock(body, viewportWidth, sheet, orderBase)
+			}
+			continue
+		}
+
+		declarations := parseDeclarations(body)
+		if len(declarations) == 0 {
+			continue
+		}
+		for _, text := range splitTopLevel(prelude, ',') {
+			selector, ok := parseSelector(strings.TrimSpace(text))
+			if !ok {
+				continue
+			}
+			sheet.rules = append(sheet.rules, cssRule{
+				selector:     selector,
+				specificity:  selectorSpecificity(selector),
+				order:        orderBase + len(sheet.rules),
+				declarations: declarations,
+			})
+		}
+	}
+}
+
+// matchingBrace 返回与 open 位置的左花括号匹配的右花括号位置，未闭合时返回文本末尾
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+// mediaMatches 按屏幕媒体和视口宽度判断媒体查询，无法判断的条件视为满足
+func mediaMatches(query string, viewportWidth float64) bool {
+	for _, part := range strings.Split(query, ",") {
+		part = strings.TrimSpace(part)
+		if strings.Contains(part, "print") || strings.Contains(part, "prefers-color-scheme: dark") ||
+			strings.Contains(part, "prefers-color-scheme:dark") {
+			continue
+		}
+		matched := true
+		for _, m := range mediaWidthRegex.FindAllStringSubmatch(part, -1) {
+			width, _ := strconv.ParseFloat(m[2], 64)
+			if m[3] == "em" || m[3] == "rem" {
+				width *= 16
+			}
+			if (m[1] == "min" && viewportWidth < width) || (m[1] == "max" && viewportWidth > width) {
+				matched = false
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDeclarations 解析声明块
+func parseDeclarations(body string) []cssDeclaration {
+	var declarations []cssDeclaration
+	for _, item := range splitTopLevel(body, ';') {
+		colon := strings.Index(item, ":")
+		if colon < 0 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(item[:colon]))
+		if strings.HasPrefix(strings.TrimSpace(item[:colon]), "--") {
+			property = strings.TrimSpace(item[:colon])
+		}
+		value := strings.TrimSpace(item[colon+1:])
+		important := false
+		if idx := strings.LastIndex(strings.ToLower(value), "!important"); idx >= 0 {
+			important = true
+			value = strings.TrimSpace(value[:idx])
+		}
+		if property == "" || value == "" {
+			continue
+		}
+		declarations = append(declarations, cssDeclaration{property: property, value: value, important: important})
+	}
+	return declarations
+}
+
+// splitTopLevel 按分隔符拆分，忽略括号和引号中的分隔符
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// parseSelector 解析选择器，包含不支持的伪类或伪元素或选择器过长时返回 false
+func parseSelector(text string) ([]cssCompound, bool) {
+	if text == "" {
+		return nil, false
+	}
+	var compounds []cssCompound
+	var combinator byte
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		if isCSSSpace(c) {
+			if len(compounds) > 0 && combinator == 0 {
+				combinator = ' '
+			}
+			i++
+			continue
+		}
+		if c == '>' || c == '+' || c == '~' {
+			combinator = c
+			i++
+			continue
+		}
+
+		compound, next, ok := parseCompound(text, i)
+		if !ok {
+			return nil, false
+		}
+		if len(compounds) > 0 {
+			if combinator == 0 {
+				combinator = ' '
+			}
+			compound.combinator = combinator
+		}
+		compounds = append(compounds, compound)
+		if len(compounds) > maxSelectorCompounds {
+			return nil, false
+		}
+		combinator = 0
+		i = next
+	}
+	return compounds, len(compounds) > 0
+}
+
+// parseCompound 从 start 位置解析一个复合选择器
+func parseCompound(text string, start int) (cssCompound, int, bool) {
+	var compound cssCompound
+	i := start
+	readIdent := func() string {
+		begin := i
+		for i < len(text) {
+			c := text[i]
+			if c == '\\' && i+1 < len(text) {
+				i += 2
+				continue
+			}
+			if c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 {
+				i++
+				continue
+			}
+			break
+		}
+		return strings.ReplaceAll(text[begin:i], "\\", "")
+	}
+
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == '*':
+			compound.tag = "*"
+			i++
+		case c == '#':
+			i++
+			compound.id = readIdent()
+		case c == '.':
+			i++
+			compound.classes = append(compound.classes, readIdent())
+		case c == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return compound, i, false
+			}
+			compound.attrs = append(compound.attrs, parseAttrSelector(text[i+1:i+end]))
+			i += end + 1
+		case c == ':':
+			i++
+			if i < len(text) && text[i] == ':' {
+				// 伪元素不参与渲染
+				return compound, i, false
+			}
+			name := strings.ToLower(readIdent())
+			if i < len(text) && text[i] == '(' {
+				end := strings.IndexByte(text[i:], ')')
+				if end < 0 {
+					return compound, i, false
+				}
+				name += text[i : i+end+1]
+				i += end + 1
+			}
+			compound.pseudo = append(compound.pseudo, name)
+		case isCSSSpace(c) || c == '>' || c == '+' || c == '~':
+			return compound, i, true
+		default:
+			tag := readIdent()
+			if tag == "" {
+				return compound, i, false
+			}
+			compound.tag = strings.ToLower(tag)
+		}
+	}
+	return compound, i, true
+}
+
+// parseAttrSelector 解析属性选择器的内容
+func parseAttrSelector(body string) cssAttrSelector {
+	for _, op := range []string{"~=", "^=", "$=", "*=", "|=", "="} {
+		if idx := strings.Index(body, op); idx >= 0 {
+			value := strings.TrimSpace(body[idx+len(op):])
+			value = strings.Trim(strings.TrimSuffix(strings.TrimSuffix(value, " i"), " s"), `"'`)
+			return cssAttrSelector{name: strings.ToLower(strings.TrimSpace(body[:idx])), op: op, value: value}
+		}
+	}
+	return cssAttrSelector{name: strings.ToLower(strings.TrimSpace(body))}
+}
+
+// selectorSpecificity 计算选择器优先级
+func selectorSpecificity(selector []cssCompound) int {
+	ids, classes, tags := 0, 0, 0
+	for _, compound := range selector {
+		if compound.id != "" {
+			ids++
+		}
+		classes += len(compound.classes) + len(compound.attrs) + len(compound.pseudo)
+		if compound.tag != "" && compound.tag != "*" {
+			tags++
+		}
+	}
+	return ids*10000 + classes*100 + tags
+}
+
+// matchSelector 判断元素是否匹配选择器，从最右侧的复合选择器开始向左匹配。
+// cache 记录已确认失败的查找，同一文档中匹配各个元素时共用，为 nil 时只在本次匹配中使用
+func matchSelector(selector []cssCompound, node *html.Node, cache selectorCache) bool {
+	if cache == nil {
+		cache = selectorCache{}
+	}
+	m := &selectorMatcher{selector: selector, failed: cache}
+	return m.match(len(selector)-1, node)
+}
+
+// selectorState 后代或兄弟组合器的一次查找：从 node 开始，node 及其所有祖先（或之前的兄弟，由右侧的组合器决定）中
+// 是否有元素匹配 compound 及其左侧的选择器。结果与正在匹配的元素无关，可以在整个文档中复用
+type selectorState struct {
+	compound *cssCompound
+	node     *html.Node
+}
+
+// selectorCache 已确认失败的查找，避免长选择器在深层文档上回溯时重复查找导致指数级耗时
+type selectorCache map[selectorState]bool
+
+// maxSelectorCacheEntries 失败查找记录的数量上限，超过后不再记录，只影响匹配速度
+const maxSelectorCacheEntries = 1 << 20
+
+// selectorMatcher 单次选择器匹配
+type selectorMatcher struct {
+	selector []cssCompound
+	failed   selectorCache
+}
+
+func (m *selectorMatcher) match(index int, node *html.Node) bool {
+	compound := m.selector[index]
+	if !matchCompound(compound, node) {
+		return false
+	}
+	if index == 0 {
+		return true
+	}
+
+	switch compound.combinator {
+	case '>':
+		parent := parentElement(node)
+		return parent != nil && m.match(index-1, parent)
+	case '+':
+		prev := previousElement(node)
+		return prev != nil && m.match(index-1, prev)
+	case '~':
+		return m.search(index-1, previousElement(node), previousElement)
+	default:
+		return m.search(index-1, parentElement(node), parentElement)
+	}
+}
+
+// search 沿 next 依次查找匹配第 index 个复合选择器的元素，失败时记录途经的所有元素
+func (m *selectorMatcher) search(index int, node *html.Node, next func(*html.Node) *html.Node) bool {
+	compound := &m.selector[index]
+	var visited []*html.Node
+	for ; node != nil; node = next(node) {
+		if m.failed[selectorState{compound, node}] {
+			break
+		}
+		if m.match(index, node) {
+			return true
+		}
+		visited = append(visited, node)
+	}
+	for _, n := range visited {
+		if len(m.failed) >= maxSelectorCacheEntries {
+			break
+		}
+		m.failed[selectorState{compound, n}] = true
+	}
+	return false
+}
+
+// matchCompound 判断元素是否匹配复合选择器
+func matchCompound(compound cssCompound, node *html.Node) bool {
+	if compound.tag != "" && compound.tag != "*" && compound.tag != node.Data {
+		return false
+	}
+	if compound.id != "" {
+		if id, _ := getAttr(node, "id"); id != compound.id {
+			return false
+		}
+	}
+	if len(compound.classes) > 0 {
+		classAttr, _ := getAttr(node, "class")
+		classes := strings.Fields(classAttr)
+		for _, want := range compound.classes {
+			found := false
+			for _, class := range classes {
+				if class == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, attr := range compound.attrs {
+		value, ok := getAttr(node, attr.name)
+		if !ok {
+			return false
+		}
+		switch attr.op {
+		case "=":
+			ok = value == attr.value
+		case "~=":
+			ok = false
+			for _, field := range strings.Fields(value) {
+				if field == attr.value {
+					ok = true
+				}
+			}
+		case "^=":
+			ok = strings.HasPrefix(value, attr.value)
+		case "$=":
+			ok = strings.HasSuffix(value, attr.value)
+		case "*=":
+			ok = strings.Contains(value, attr.value)
+		case "|=":
+			ok = value == attr.value || strings.HasPrefix(value, attr.value+"-")
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, pseudo := range compound.pseudo {
+		switch pseudo {
+		case "root":
+			if node.Data != "html" {
+				return false
+			}
+		case "first-child":
+			if previousElement(node) != nil {
+				return false
+			}
+		case "last-child":
+			if nextElement(node) != nil {
+				return false
+			}
+		case "link", "any-link":
+			if node.Data != "a" {
+				return false
+			}
+		default:
+			// 交互状态等动态伪类在静态渲染中不匹配
+			return false
+		}
+	}
+	return true
+}
+
+func parentElement(node *html.Node) *html.Node {
+	for p := node.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func previousElement(node *html.Node) *html.Node {
+	for s := node.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nextElement(node *html.Node) *html.Node {
+	for s := node.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// matchedDeclaration 匹配到元素的声明及其层叠优先级
+type matchedDeclaration struct {
+	cssDeclaration
+	origin      int // 0 浏览器默认样式，1 作者样式，2 内联样式
+	specificity int
+	order       int
+}
+
+// cascade 收集匹配元素的所有声明并按层叠顺序排序，排在后面的优先
+func cascade(sheets []*cssStylesheet, node *html.Node, cache selectorCache) []matchedDeclaration {
+	var matched []matchedDeclaration
+	for origin, sheet := range sheets {
+		if origin > 1 {
+			origin = 1
+		}
+		for _, rule := range sheet.rules {
+			if !matchSelector(rule.selector, node, cache) {
+				continue
+			}
+			for _, declaration := range rule.declarations {
+				matched = append(matched, matchedDeclaration{
+					cssDeclaration: declaration,
+					origin:         origin,
+					specificity:    rule.specificity,
+					order:          rule.order,
+				})
+			}
+		}
+	}
+	if style, ok := getAttr(node, "style"); ok {
+		for i, declaration := range parseDeclarations(style) {
+			matched = append(matched, matchedDeclaration{cssDeclaration: declaration, origin: 2, order: i})
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.important != b.important {
+			return !a.important
+		}
+		if a.origin != b.origin {
+			return a.origin < b.origin
+		}
+		if a.specificity != b.specificity {
+			return a.specificity < b.specificity
+		}
+		return a.order < b.order
+	})
+	return matched
+}
+
+// userAgentCSS 简化的浏览器默认样式
+const userAgentCSS = `
+html, body, div, section, article, aside, header, footer, main, nav, p, h1, h2, h3, h4, h5, h6,
+ul, ol, dl, dt, dd, blockquote, pre, figure, figcaption, form, fieldset, address, details, summary,
+table, hr, center, legend, menu, hgroup { display: block; }
+head, script, style, title, meta, link, noscript, template, base, datalist, param, source, track { display: none; }
+[hidden], input[type=hidden] { display: none; }
+li { display: list-item; }
+tr { display: flex; }
+thead, tbody, tfoot, caption { display: block; }
+td, th { display: block; flex: 1; padding: 1px; }
+th { font-weight: bold; text-align: center; }
+body { margin: 8px; }
+h1 { font-size: 2em; margin: 0.67em 0; font-weight: bold; }
+h2 { font-size: 1.5em; margin: 0.83em 0; font-weight: bold; }
+h3 { font-size: 1.17em; margin: 1em 0; font-weight: bold; }
+h4 { margin: 1.33em 0; font-weight: bold; }
+h5 { font-size: 0.83em; margin: 1.67em 0; font-weight: bold; }
+h6 { font-size: 0.67em; margin: 2.33em 0; font-weight: bold; }
+p, blockquote, figure, dl, pre, fieldset { margin: 1em 0; }
+blockquote, figure { margin-left: 40px; margin-right: 40px; }
+ul, ol, menu { margin: 1em 0; padding-left: 40px; }
+ul { list-style-type: disc; }
+ol { list-style-type: decimal; }
+dd { margin-left: 40px; }
+b, strong { font-weight: bold; }
+i, em, cite, var, dfn { font-style: italic; }
+small { font-size: 0.83em; }
+big { font-size: 1.17em; }
+sub, sup { font-size: 0.75em; }
+a { color: #0000ee; text-decoration: underline; }
+u, ins { text-decoration: underline; }
+s, del, strike { text-decoration: line-through; }
+mark { background-color: #ffff00; color: #000; }
+code, kbd, samp, pre, tt { font-family: monospace; }
+pre { white-space: pre; }
+center { text-align: center; }
+hr { border-top: 1px solid #c0c0c0; margin: 0.5em 0; }
+button, input, select, textarea, img, svg, canvas, video, iframe, object, embed, progress, meter { display: inline-block; }
+button { padding: 2px 6px; border: 1px solid #767676; border-radius: 3px; background-color: #efefef; color: #000; font-size: 13.33px; }
+input, select, textarea { padding: 2px 3px; border: 1px solid #767676; border-radius: 2px; background-color: #ffffff; color: #000; font-size: 13.33px; }
+fieldset { border: 2px groove #c0c0c0; padding: 0.35em 0.75em 0.625em; margin-left: 2px; margin-right: 2px; }
+`
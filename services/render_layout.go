@@ -0,0 +1,1264 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 缩略图渲染的布局：块级流、行内换行、简化的弹性布局和网格布局，以及绝对定位
+
+// textFragment 行内格式化上下文中的一段文字或一个行内块
+type textFragment struct {
+	x, baseline float64
+	width       float64
+	text        string
+	style       *computedStyle
+	decoration  string
+	box         *renderBox
+}
+
+// sizing 布局时由父容器确定的尺寸，负数表示按样式计算
+type sizing struct {
+	shrink bool    // 宽度为 auto 时收缩到内容宽度
+	width  float64 // 边框盒宽度
+	height float64 // 边框盒高度
+}
+
+var autoSizing = sizing{width: -1, height: -1}
+
+// layoutEngine 布局引擎
+type layoutEngine struct {
+	shaper     *textShaper
+	ctx        *styleContext
+	root       *renderBox
+	containers []*renderBox // 定位祖先，栈顶为当前绝对定位元素的包含块
+}
+
+// layoutDocument 以视口宽度布局整个文档
+func (e *layoutEngine) layoutDocument(root *renderBox) {
+	e.root = root
+	e.containers = []*renderBox{root}
+	e.resolveEdges(root, e.ctx.viewportWidth)
+	e.layoutBlock(root, 0, 0, e.ctx.viewportWidth, e.ctx.viewportHeight, autoSizing)
+}
+
+// resolveEdges 计算外边距、边框和内边距，百分比相对于包含块宽度
+func (e *layoutEngine) resolveEdges(b *renderBox, cbWidth float64) {
+	for i := 0; i < 4; i++ {
+		b.margin[i] = b.style.margin[i].resolve(cbWidth, 0)
+		b.padding[i] = math.Max(0, b.style.padding[i].resolve(cbWidth, 0))
+		b.border[i] = b.style.border[i].width
+	}
+	b.autoMargin = [2]bool{b.style.margin[3].isAuto(), b.style.margin[1].isAuto()}
+}
+
+// layoutBlock 布局块级盒子，(x, y) 为外边距盒的左上角
+func (e *layoutEngine) layoutBlock(b *renderBox, x, y, cbWidth, cbHeight float64, sz sizing) {
+	e.resolveEdges(b, cbWidth)
+	b.fragments = nil
+	b.absolutes = nil
+	style := b.style
+
+	hEdges := b.border[1] + b.border[3] + b.padding[1] + b.padding[3]
+	vEdges := b.border[0] + b.border[2] + b.padding[0] + b.padding[2]
+	borderBox := style.boxSizing == "border-box"
+	toContent := func(l cssLength, base float64, edges float64) float64 {
+		v := l.resolve(base, 0)
+		if borderBox {
+			v -= edges
+		}
+		return v
+	}
+
+	// 内容宽度
+	var contentWidth, replacedHeight float64
+	fixedWidth := style.width.kind == lengthFixed
+	switch {
+	case sz.width >= 0:
+		contentWidth = sz.width - hEdges
+	case b.replaced:
+		contentWidth, replacedHeight = e.replacedSize(b, cbWidth, cbHeight, hEdges, vEdges)
+	case fixedWidth:
+		contentWidth = toContent(style.width, cbWidth, hEdges)
+	case sz.shrink:
+		available := cbWidth - b.margin[1] - b.margin[3] - hEdges
+		minContent, maxContent := e.contentIntrinsic(b)
+		contentWidth = math.Min(math.Max(minContent, available), maxContent)
+	default:
+		contentWidth = cbWidth - b.margin[1] - b.margin[3] - hEdges
+	}
+	if sz.width < 0 && !b.replaced {
+		if style.maxWidth.kind == lengthFixed {
+			contentWidth = math.Min(contentWidth, toContent(style.maxWidth, cbWidth, hEdges))
+		}
+		if style.minWidth.kind == lengthFixed {
+			contentWidth = math.Max(contentWidth, toContent(style.minWidth, cbWidth, hEdges))
+		}
+	}
+	contentWidth = math.Max(0, contentWidth)
+
+	// 块级盒子的 auto 外边距用于水平居中
+	if sz.width < 0 && !sz.shrink && (b.autoMargin[0] || b.autoMargin[1]) {
+		remaining := cbWidth - contentWidth - hEdges - b.margin[1] - b.margin[3]
+		if remaining > 0 {
+			switch {
+			case b.autoMargin[0] && b.autoMargin[1]:
+				b.margin[3] += remaining / 2
+				b.margin[1] += remaining / 2
+			case b.autoMargin[0]:
+				b.margin[3] += remaining
+			}
+		}
+	}
+
+	b.x = x + b.margin[3]
+	b.y = y + b.margin[0]
+	contentX := b.x + b.border[3] + b.padding[3]
+	contentY := b.y + b.border[0] + b.padding[0]
+
+	// 指定高度，百分比高度只在包含块高度确定时生效
+	specifiedHeight := -1.0
+	switch {
+	case sz.height >= 0:
+		specifiedHeight = sz.height - vEdges
+	case b.replaced:
+		specifiedHeight = replacedHeight
+	case style.height.kind == lengthFixed && (style.height.pct == 0 || cbHeight >= 0):
+		specifiedHeight = toContent(style.height, cbHeight, vEdges)
+	}
+	if specifiedHeight >= 0 && !b.replaced && sz.height < 0 {
+		specifiedHeight = e.clampHeight(b, specifiedHeight, cbHeight, vEdges)
+	}
+	if specifiedHeight >= 0 {
+		specifiedHeight = math.Max(0, specifiedHeight)
+	}
+
+	positioned := style.position != "static" && b != e.root
+	if positioned {
+		e.containers = append(e.containers, b)
+	}
+	contentHeight := 0.0
+	if !b.replaced {
+		switch style.display {
+		case "flex", "inline-flex":
+			contentHeight = e.layoutFlex(b, contentX, contentY, contentWidth, specifiedHeight)
+		case "grid", "inline-grid":
+			contentHeight = e.layoutGrid(b, contentX, contentY, contentWidth, specifiedHeight)
+		default:
+			contentHeight = e.layoutFlow(b, contentX, contentY, contentWidth, specifiedHeight)
+		}
+	}
+	if positioned {
+		e.containers = e.containers[:len(e.containers)-1]
+	}
+
+	height := contentHeight
+	if specifiedHeight >= 0 {
+		height = specifiedHeight
+	} else {
+		height = e.clampHeight(b, height, cbHeight, vEdges)
+	}
+	b.width = contentWidth + hEdges
+	b.height = math.Max(0, height) + vEdges
+
+	if positioned || b == e.root {
+		for _, abs := range b.absolutes {
+			e.layoutAbsolute(b, abs)
+		}
+	}
+}
+
+// clampHeight 按最小和最大高度限制内容高度
+func (e *layoutEngine) clampHeight(b *renderBox, height, cbHeight, vEdges float64) float64 {
+	style := b.style
+	adjust := func(v float64) float64 {
+		if style.boxSizing == "border-box" {
+			return v - vEdges
+		}
+		return v
+	}
+	if style.maxHeight.kind == lengthFixed && (style.maxHeight.pct == 0 || cbHeight >= 0) {
+		height = math.Min(height, adjust(style.maxHeight.resolve(cbHeight, 0)))
+	}
+	if style.minHeight.kind == lengthFixed && (style.minHeight.pct == 0 || cbHeight >= 0) {
+		height = math.Max(height, adjust(style.minHeight.resolve(cbHeight, 0)))
+	}
+	return height
+}
+
+// replacedSize 计算替换元素的内容尺寸，只指定一边时按固有宽高比换算
+func (e *layoutEngine) replacedSize(b *renderBox, cbWidth, cbHeight, hEdges, vEdges float64) (float64, float64) {
+	style := b.style
+	iw, ih := b.intrinsicWidth, b.intrinsicHeight
+	if iw <= 0 || ih <= 0 {
+		iw, ih = 300, 150
+	}
+	ratio := ih / iw
+	borderBox := style.boxSizing == "border-box"
+
+	hasWidth := style.width.kind == lengthFixed
+	hasHeight := style.height.kind == lengthFixed && (style.height.pct == 0 || cbHeight >= 0)
+	w, h := iw, ih
+	if hasWidth {
+		w = style.width.resolve(cbWidth, iw)
+		if borderBox {
+			w -= hEdges
+		}
+	}
+	if hasHeight {
+		h = style.height.resolve(cbHeight, ih)
+		if borderBox {
+			h -= vEdges
+		}
+	}
+	switch {
+	case hasWidth && !hasHeight:
+		h = w * ratio
+	case hasHeight && !hasWidth:
+		w = h / ratio
+	}
+
+	if style.maxWidth.kind == lengthFixed {
+		if maxWidth := style.maxWidth.resolve(cbWidth, w); w > maxWidth {
+			w = maxWidth
+			if !hasHeight {
+				h = w * ratio
+			}
+		}
+	}
+	if style.minWidth.kind == lengthFixed {
+		w = math.Max(w, style.minWidth.resolve(cbWidth, 0))
+	}
+	if style.maxHeight.kind == lengthFixed && style.maxHeight.pct == 0 && h > style.maxHeight.px {
+		h = style.maxHeight.px
+		if !hasWidth {
+			w = h / ratio
+		}
+	}
+	return math.Max(0, w), math.Max(0, h)
+}
+
+// layoutFlow 块级流布局，连续的行内内容组成匿名行框，相邻块的上下外边距合并
+func (e *layoutEngine) layoutFlow(b *renderBox, x, y, width, height float64) float64 {
+	cursor := y
+	pendingMargin := 0.0
+	var run []*renderBox
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if h := e.layoutInline(b, run, x, cursor+pendingMargin, width); h > 0 {
+			cursor += pendingMargin + h
+			pendingMargin = 0
+		}
+		run = nil
+	}
+
+	for _, child := range b.children {
+		switch {
+		case child.isOutOfFlow():
+			child.staticX, child.staticY = x, cursor+pendingMargin
+			e.registerAbsolute(child)
+		case child.isBlockLevel():
+			flush()
+			e.resolveEdges(child, width)
+			top := collapseMargins(pendingMargin, child.margin[0])
+			e.layoutBlock(child, x, cursor+top-child.margin[0], width, height, autoSizing)
+			cursor = child.y + child.height
+			pendingMargin = child.margin[2]
+		default:
+			run = append(run, child)
+		}
+	}
+	flush()
+	return cursor + pendingMargin - y
+}
+
+// collapseMargins 合并相邻的外边距
+func collapseMargins(a, b float64) float64 {
+	switch {
+	case a >= 0 && b >= 0:
+		return math.Max(a, b)
+	case a < 0 && b < 0:
+		return math.Min(a, b)
+	}
+	return a + b
+}
+
+// registerAbsolute 将绝对定位元素登记到包含块，固定定位元素的包含块为视口
+func (e *layoutEngine) registerAbsolute(b *renderBox) {
+	container := e.containers[len(e.containers)-1]
+	if b.style.position == "fixed" {
+		container = e.root
+	}
+	container.absolutes = append(container.absolutes, b)
+}
+
+// layoutAbsolute 布局绝对定位元素，偏移相对于包含块的内边距盒
+func (e *layoutEngine) layoutAbsolute(container, b *renderBox) {
+	px := container.x + container.border[3]
+	py := container.y + container.border[0]
+	pw := container.width - container.border[1] - container.border[3]
+	ph := container.height - container.border[0] - container.border[2]
+	if b.style.position == "fixed" || container == e.root {
+		px, py, pw, ph = 0, 0, e.ctx.viewportWidth, e.ctx.viewportHeight
+	}
+
+	e.resolveEdges(b, pw)
+	inset := b.style.inset
+	top, right, bottom, left := inset[0], inset[1], inset[2], inset[3]
+	sz := sizing{shrink: true, width: -1, height: -1}
+	if b.style.width.isAuto() && !left.isAuto() && !right.isAuto() {
+		sz.width = math.Max(0, pw-left.resolve(pw, 0)-right.resolve(pw, 0)-b.margin[1]-b.margin[3])
+	}
+	if b.style.height.isAuto() && !top.isAuto() && !bottom.isAuto() {
+		sz.height = math.Max(0, ph-top.resolve(ph, 0)-bottom.resolve(ph, 0)-b.margin[0]-b.margin[2])
+	}
+	e.layoutBlock(b, 0, 0, pw, ph, sz)
+
+	outerWidth := b.width + b.margin[1] + b.margin[3]
+	outerHeight := b.height + b.margin[0] + b.margin[2]
+	targetX, targetY := b.staticX, b.staticY
+	switch {
+	case !left.isAuto():
+		targetX = px + left.resolve(pw, 0)
+	case !right.isAuto():
+		targetX = px + pw - right.resolve(pw, 0) - outerWidth
+	}
+	switch {
+	case !top.isAuto():
+		targetY = py + top.resolve(ph, 0)
+	case !bottom.isAuto():
+		targetY = py + ph - bottom.resolve(ph, 0) - outerHeight
+	}
+	translateBox(b, targetX-(b.x-b.margin[3]), targetY-(b.y-b.margin[0]))
+}
+
+// translateBox 平移盒子及其所有后代
+func translateBox(b *renderBox, dx, dy float64) {
+	if dx == 0 && dy == 0 {
+		return
+	}
+	b.x += dx
+	b.y += dy
+	b.staticX += dx
+	b.staticY += dy
+	for i := range b.fragments {
+		b.fragments[i].x += dx
+		b.fragments[i].baseline += dy
+	}
+	for _, child := range b.children {
+		translateBox(child, dx, dy)
+	}
+	for _, abs := range b.absolutes {
+		translateBox(abs, dx, dy)
+	}
+}
+
+// 行内项类型
+const (
+	itemText = iota
+	itemSpace
+	itemAtomic
+	itemBreak
+)
+
+// inlineItem 参与换行的行内项
+type inlineItem struct {
+	kind        int
+	text        string
+	width       float64
+	style       *computedStyle
+	decoration  string
+	box         *renderBox
+	breakBefore bool // 可以在该项之前换行（中日文字符）
+	noWrap      bool // 不能在该项处换行
+	preserved   bool // 保留的空白，不在行首行尾去掉
+}
+
+// layoutInline 布局一组行内内容，生成的文字片段保存在容器上，返回行框的总高度
+func (e *layoutEngine) layoutInline(container *renderBox, run []*renderBox, x, y, width float64) float64 {
+	var items []inlineItem
+	decoration := container.style.textDecoration
+	if decoration == "none" {
+		decoration = ""
+	}
+	for _, child := range run {
+		items = e.flattenInline(child, decoration, width, x, y, items)
+	}
+
+	// 只有可合并空白的行内内容不产生行框
+	empty := true
+	for _, item := range items {
+		if item.kind != itemSpace {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return 0
+	}
+
+	// 贪心换行
+	var lines [][]inlineItem
+	var line []inlineItem
+	lineWidth := 0.0
+	lastBreak := -1
+	finish := func() {
+		lines = append(lines, trimTrailingSpaces(line))
+		line, lineWidth, lastBreak = nil, 0, -1
+	}
+	for _, item := range items {
+		if item.kind == itemBreak {
+			finish()
+			continue
+		}
+		if item.kind == itemSpace {
+			if len(line) == 0 && !item.preserved {
+				continue
+			}
+			line = append(line, item)
+			lineWidth += item.width
+			continue
+		}
+
+		if n := len(line); n > 0 {
+			prev := line[n-1]
+			if prev.kind == itemSpace && !prev.noWrap || item.breakBefore || prev.kind == itemAtomic || item.kind == itemAtomic {
+				if !(prev.noWrap && item.noWrap) {
+					lastBreak = n
+				}
+			}
+		}
+		if lineWidth+item.width > width+0.01 && len(line) > 0 && lastBreak > 0 {
+			carry := append([]inlineItem(nil), line[lastBreak:]...)
+			line = line[:lastBreak]
+			finish()
+			for len(carry) > 0 && carry[0].kind == itemSpace && !carry[0].preserved {
+				carry = carry[1:]
+			}
+			line = carry
+			for _, c := range carry {
+				lineWidth += c.width
+			}
+		}
+		line = append(line, item)
+		lineWidth += item.width
+	}
+	if len(line) > 0 {
+		finish()
+	}
+
+	// 逐行确定基线并生成片段
+	strutAscent, strutDescent := e.halfLeading(container.style)
+	cursor := y
+	for _, line := range lines {
+		ascent, descent := strutAscent, strutDescent
+		lineWidth := 0.0
+		for _, item := range line {
+			lineWidth += item.width
+			if item.kind == itemAtomic {
+				a, d := atomicBaseline(item.box)
+				ascent, descent = math.Max(ascent, a), math.Max(descent, d)
+				continue
+			}
+			a, d := e.halfLeading(item.style)
+			ascent, descent = math.Max(ascent, a), math.Max(descent, d)
+		}
+		baseline := cursor + ascent
+
+		offset := 0.0
+		if free := width - lineWidth; free > 0 {
+			switch container.style.textAlign {
+			case "center":
+				offset = free / 2
+			case "right":
+				offset = free
+			}
+		}
+
+		cx := x + offset
+		for _, item := range line {
+			switch item.kind {
+			case itemAtomic:
+				above, _ := atomicBaseline(item.box)
+				translateBox(item.box, cx-(item.box.x-item.box.margin[3]), baseline-above-(item.box.y-item.box.margin[0]))
+				container.fragments = append(container.fragments, textFragment{x: cx, baseline: baseline, width: item.width, box: item.box})
+			default:
+				// 相同样式的相邻文字合并为一个片段
+				if n := len(container.fragments); n > 0 {
+					last := &container.fragments[n-1]
+					if last.box == nil && last.style == item.style && last.decoration == item.decoration &&
+						last.baseline == baseline && math.Abs(last.x+last.width-cx) < 0.01 {
+						last.text += item.text
+						last.width += item.width
+						break
+					}
+				}
+				container.fragments = append(container.fragments, textFragment{
+					x: cx, baseline: baseline, width: item.width, text: item.text,
+					style: item.style, decoration: item.decoration,
+				})
+			}
+			cx += item.width
+		}
+		cursor += ascent + descent
+	}
+	return cursor - y
+}
+
+// atomicBaseline 行内块在基线上下占据的高度：有文字时以最后一行文字的基线对齐，否则底边对齐基线
+func atomicBaseline(b *renderBox) (float64, float64) {
+	top := b.y - b.margin[0]
+	outerHeight := b.height + b.margin[0] + b.margin[2]
+	if !b.style.overflowHidden {
+		if baseline := lastBaseline(b); baseline >= 0 {
+			above := math.Min(outerHeight, baseline-top)
+			return above, outerHeight - above
+		}
+	}
+	return outerHeight, 0
+}
+
+// lastBaseline 查找盒子中最后一行文字的基线，没有文字时返回 -1
+func lastBaseline(b *renderBox) float64 {
+	for i := len(b.children) - 1; i >= 0; i-- {
+		child := b.children[i]
+		if child.isBlockLevel() && !child.isOutOfFlow() {
+			if baseline := lastBaseline(child); baseline >= 0 {
+				return baseline
+			}
+		}
+	}
+	if n := len(b.fragments); n > 0 {
+		return b.fragments[n-1].baseline
+	}
+	return -1
+}
+
+// halfLeading 按行高计算文字在基线上下占据的高度
+func (e *layoutEngine) halfLeading(style *computedStyle) (float64, float64) {
+	ascent, descent := e.shaper.metrics(style)
+	lineHeight := style.lineHeight
+	above := (lineHeight-(ascent+descent))/2 + ascent
+	return above, lineHeight - above
+}
+
+// trimTrailingSpaces 去掉行尾的可合并空白
+func trimTrailingSpaces(line []inlineItem) []inlineItem {
+	for len(line) > 0 && line[len(line)-1].kind == itemSpace && !line[len(line)-1].preserved {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// flattenInline 将行内盒子展开为行内项，行内块在这里按收缩宽度布局，之后再平移到所在行
+func (e *layoutEngine) flattenInline(b *renderBox, decoration string, width, x, y float64, items []inlineItem) []inlineItem {
+	if b.isText() {
+		return e.splitText(b.text, b.style, decoration, items)
+	}
+	if b.isOutOfFlow() {
+		b.staticX, b.staticY = x, y
+		e.registerAbsolute(b)
+		return items
+	}
+	if b.node != nil && b.node.Data == "br" {
+		return append(items, inlineItem{kind: itemBreak, style: b.style})
+	}
+	if b.style.display != "inline" || b.replaced {
+		e.layoutBlock(b, 0, 0, width, -1, sizing{shrink: true, width: -1, height: -1})
+		return append(items, inlineItem{
+			kind:  itemAtomic,
+			width: b.width + b.margin[1] + b.margin[3],
+			style: b.style,
+			box:   b,
+		})
+	}
+
+	if d := b.style.textDecoration; d != "" && d != "none" {
+		decoration = d
+	}
+	// 行内元素的左右外边距按不可断开的空白处理
+	spacer := func(margin cssLength) {
+		if m := margin.resolve(width, 0); m > 0 {
+			items = append(items, inlineItem{kind: itemText, width: m, style: b.style, noWrap: true})
+		}
+	}
+	spacer(b.style.margin[3])
+	for _, child := range b.children {
+		items = e.flattenInline(child, decoration, width, x, y, items)
+	}
+	spacer(b.style.margin[1])
+	return items
+}
+
+// splitText 按空白处理规则将文字拆分为单词和空格
+func (e *layoutEngine) splitText(text string, style *computedStyle, decoration string, items []inlineItem) []inlineItem {
+	text = applyTextTransform(text, style.textTransform)
+	whiteSpace := style.whiteSpace
+	noWrap := whiteSpace == "nowrap" || whiteSpace == "pre"
+	preserveSpaces := whiteSpace == "pre" || whiteSpace == "pre-wrap" || whiteSpace == "break-spaces"
+	preserveBreaks := preserveSpaces || whiteSpace == "pre-line"
+
+	spaceWidth := e.shaper.measure(" ", style)
+	lastIsSpace := func() bool {
+		return len(items) > 0 && items[len(items)-1].kind == itemSpace
+	}
+	var word strings.Builder
+	breakNext := false
+	flushWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		w := word.String()
+		items = append(items, inlineItem{
+			kind: itemText, text: w, width: e.shaper.measure(w, style), style: style,
+			decoration: decoration, breakBefore: breakNext, noWrap: noWrap,
+		})
+		word.Reset()
+		breakNext = false
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '\n' && preserveBreaks:
+			flushWord()
+			items = append(items, inlineItem{kind: itemBreak, style: style})
+		case r == '\t' && preserveSpaces:
+			flushWord()
+			items = append(items, inlineItem{
+				kind: itemSpace, text: "    ", width: 4 * spaceWidth, style: style, decoration: decoration,
+				noWrap: noWrap, preserved: true,
+			})
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f':
+			flushWord()
+			if preserveSpaces || !lastIsSpace() {
+				items = append(items, inlineItem{
+					kind: itemSpace, text: " ", width: spaceWidth, style: style, decoration: decoration,
+					noWrap: noWrap, preserved: preserveSpaces,
+				})
+			}
+		case isBreakableRune(r) && !noWrap:
+			flushWord()
+			breakNext = true
+			word.WriteRune(r)
+			flushWord()
+			breakNext = true
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flushWord()
+	return items
+}
+
+// applyTextTransform 应用大小写转换
+func applyTextTransform(text, transform string) string {
+	switch transform {
+	case "uppercase":
+		return strings.ToUpper(text)
+	case "lowercase":
+		return strings.ToLower(text)
+	case "capitalize":
+		runes := []rune(text)
+		for i, r := range runes {
+			if i == 0 || unicode.IsSpace(runes[i-1]) {
+				runes[i] = unicode.ToUpper(r)
+			}
+		}
+		return string(runes)
+	}
+	return text
+}
+
+// flexItem 弹性布局中的项目
+type flexItem struct {
+	box    *renderBox
+	base   float64 // 主轴方向的基准内容尺寸
+	min    float64
+	max    float64
+	extra  float64 // 外边距、边框和内边距
+	target float64
+	grow   float64
+	shrink float64
+}
+
+// layoutFlex 弹性布局，支持换行、伸缩、主轴和交叉轴对齐
+func (e *layoutEngine) layoutFlex(b *renderBox, x, y, width, height float64) float64 {
+	children := e.inFlowChildren(b, x, y)
+	if strings.HasPrefix(b.style.flexDirection, "column") {
+		return e.layoutFlexColumn(b, children, x, y, width, height)
+	}
+
+	style := b.style
+	gap := style.columnGap
+	items := make([]flexItem, 0, len(children))
+	for _, child := range children {
+		e.resolveEdges(child, width)
+		cs := child.style
+		hEdges := child.border[1] + child.border[3] + child.padding[1] + child.padding[3]
+		item := flexItem{
+			box:    child,
+			extra:  hEdges + child.margin[1] + child.margin[3],
+			grow:   cs.flexGrow,
+			shrink: cs.flexShrink,
+			max:    math.Inf(1),
+		}
+		toContent := func(l cssLength) float64 {
+			v := l.resolve(width, 0)
+			if cs.boxSizing == "border-box" {
+				v -= hEdges
+			}
+			return math.Max(0, v)
+		}
+		minContent, maxContent := e.contentIntrinsic(child)
+		switch {
+		case cs.flexBasis.kind == lengthFixed:
+			item.base = toContent(cs.flexBasis)
+		case cs.width.kind == lengthFixed:
+			item.base = toContent(cs.width)
+		case child.replaced:
+			w, _ := e.replacedSize(child, width, -1, hEdges, 0)
+			item.base = w
+		default:
+			item.base = maxContent
+		}
+		switch {
+		case cs.minWidth.kind == lengthFixed:
+			item.min = toContent(cs.minWidth)
+		case cs.overflowHidden:
+			item.min = 0
+		default:
+			item.min = math.Min(minContent, item.base)
+			if cs.width.kind == lengthFixed {
+				item.min = math.Min(item.min, toContent(cs.width))
+			}
+		}
+		if cs.maxWidth.kind == lengthFixed {
+			item.max = toContent(cs.maxWidth)
+		}
+		item.base = math.Max(item.min, math.Min(item.max, item.base))
+		items = append(items, item)
+	}
+
+	// 分行
+	var lines [][]flexItem
+	start := 0
+	used := 0.0
+	for i, item := range items {
+		outer := item.base + item.extra
+		if style.flexWrap && i > start && used+gap+outer > width {
+			lines = append(lines, items[start:i])
+			start, used = i, 0
+		}
+		if i > start {
+			used += gap
+		}
+		used += outer
+	}
+	if start < len(items) {
+		lines = append(lines, items[start:])
+	}
+
+	cursor := y
+	for li, line := range lines {
+		if li > 0 {
+			cursor += style.rowGap
+		}
+		resolveFlexibleLengths(line, width-gap*float64(len(line)-1))
+
+		crossHeight := -1.0
+		if len(lines) == 1 && height >= 0 {
+			crossHeight = height
+		}
+		lineX := x
+		lineCross := 0.0
+		for _, item := range line {
+			e.layoutBlock(item.box, lineX, cursor, width, crossHeight, sizing{width: item.target + item.extra - item.box.margin[1] - item.box.margin[3], height: -1})
+			lineX += item.target + item.extra + gap
+			lineCross = math.Max(lineCross, item.box.height+item.box.margin[0]+item.box.margin[2])
+		}
+		if crossHeight >= 0 {
+			lineCross = math.Max(lineCross, crossHeight)
+		}
+
+		// 主轴对齐，auto 外边距优先占用剩余空间
+		free := width - (lineX - gap - x)
+		autoMargins := 0
+		for _, item := range line {
+			for _, auto := range item.box.autoMargin {
+				if auto {
+					autoMargins++
+				}
+			}
+		}
+		offset, spacing := 0.0, 0.0
+		if free > 0 && autoMargins == 0 {
+			offset, spacing = distributeSpace(style.justifyContent, free, len(line))
+		}
+		shift := offset
+		for _, item := range line {
+			if free > 0 && autoMargins > 0 && item.box.autoMargin[0] {
+				shift += free / float64(autoMargins)
+			}
+			dy := alignCross(item.box, style, lineCross)
+			translateBox(item.box, shift, dy)
+			if free > 0 && autoMargins > 0 && item.box.autoMargin[1] {
+				shift += free / float64(autoMargins)
+			}
+			shift += spacing
+		}
+		cursor += lineCross
+	}
+	return cursor - y
+}
+
+// resolveFlexibleLengths 按伸缩系数分配剩余空间或收缩溢出部分
+func resolveFlexibleLengths(line []flexItem, available float64) {
+	used := 0.0
+	totalGrow, totalShrink := 0.0, 0.0
+	for i := range line {
+		used += line[i].base + line[i].extra
+		totalGrow += line[i].grow
+		totalShrink += line[i].shrink * line[i].base
+	}
+	free := available - used
+	for i := range line {
+		item := &line[i]
+		item.target = item.base
+		switch {
+		case free > 0 && totalGrow > 0:
+			item.target = math.Min(item.max, item.base+free*item.grow/totalGrow)
+		case free < 0 && totalShrink > 0:
+			item.target = math.Max(item.min, item.base+free*item.shrink*item.base/totalShrink)
+		}
+	}
+}
+
+// distributeSpace 按 justify-content 计算起始偏移和项目间额外间距
+func distributeSpace(justify string, free float64, count int) (float64, float64) {
+	switch justify {
+	case "center":
+		return free / 2, 0
+	case "flex-end", "end", "right":
+		return free, 0
+	case "space-between":
+		if count > 1 {
+			return 0, free / float64(count-1)
+		}
+	case "space-around":
+		return free / float64(count) / 2, free / float64(count)
+	case "space-evenly":
+		return free / float64(count+1), free / float64(count+1)
+	}
+	return 0, 0
+}
+
+// alignCross 交叉轴对齐：拉伸时调整高度，其余情况返回需要的纵向偏移
+func alignCross(b *renderBox, container *computedStyle, lineCross float64) float64 {
+	align := b.style.alignSelf
+	if align == "auto" || align == "" {
+		align = container.alignItems
+	}
+	outer := b.height + b.margin[0] + b.margin[2]
+	switch align {
+	case "center":
+		return (lineCross - outer) / 2
+	case "flex-end", "end", "self-end":
+		return lineCross - outer
+	case "stretch", "normal":
+		if b.style.height.isAuto() && !b.replaced {
+			b.height = math.Max(b.height, lineCross-b.margin[0]-b.margin[2])
+		}
+	}
+	return 0
+}
+
+// layoutFlexColumn 纵向弹性布局
+func (e *layoutEngine) layoutFlexColumn(b *renderBox, children []*renderBox, x, y, width, height float64) float64 {
+	style := b.style
+	cursor := y
+	totalGrow := 0.0
+	for i, child := range children {
+		if i > 0 {
+			cursor += style.rowGap
+		}
+		e.resolveEdges(child, width)
+		align := child.style.alignSelf
+		if align == "auto" {
+			align = style.alignItems
+		}
+		sz := autoSizing
+		sz.shrink = align != "stretch" && align != "normal"
+		if basis := child.style.flexBasis; basis.kind == lengthFixed && basis.pct == 0 {
+			sz.height = basis.px
+			if child.style.boxSizing != "border-box" {
+				sz.height += child.border[0] + child.border[2] + child.padding[0] + child.padding[2]
+			}
+		}
+		e.layoutBlock(child, x, cursor, width, -1, sz)
+
+		outerWidth := child.width + child.margin[1] + child.margin[3]
+		switch {
+		case sz.shrink && child.autoMargin[0] && child.autoMargin[1]:
+			translateBox(child, (width-outerWidth)/2, 0)
+		case align == "center":
+			translateBox(child, (width-outerWidth)/2, 0)
+		case align == "flex-end" || align == "end":
+			translateBox(child, width-outerWidth, 0)
+		}
+		cursor = child.y + child.height + child.margin[2]
+		totalGrow += child.style.flexGrow
+	}
+	used := cursor - y
+
+	if height >= 0 && height > used && len(children) > 0 {
+		free := height - used
+		shift := 0.0
+		if totalGrow > 0 {
+			for _, child := range children {
+				translateBox(child, 0, shift)
+				if child.style.flexGrow > 0 {
+					grow := free * child.style.flexGrow / totalGrow
+					child.height += grow
+					shift += grow
+				}
+			}
+		} else {
+			offset, spacing := distributeSpace(style.justifyContent, free, len(children))
+			for _, child := range children {
+				translateBox(child, 0, offset+shift)
+				shift += spacing
+			}
+		}
+		used = height
+	}
+	return used
+}
+
+// inFlowChildren 返回参与布局的子元素，绝对定位的子元素登记到包含块
+func (e *layoutEngine) inFlowChildren(b *renderBox, x, y float64) []*renderBox {
+	children := make([]*renderBox, 0, len(b.children))
+	for _, child := range b.children {
+		if child.isOutOfFlow() {
+			child.staticX, child.staticY = x, y
+			e.registerAbsolute(child)
+			continue
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
+var repeatRegex = regexp.MustCompile(`repeat\(\s*([^,]+),\s*(.+)\)$`)
+
+// gridTrack 网格列：固定宽度或按比例分配
+type gridTrack struct {
+	fixed float64
+	fr    float64
+	min   float64
+}
+
+// parseGridTracks 解析 grid-template-columns，auto-fill/auto-fit 按最小宽度计算列数
+func parseGridTracks(value string, width, gap, fontSize float64, ctx *styleContext) []gridTrack {
+	var tracks []gridTrack
+	parseTrack := func(token string) (gridTrack, bool) {
+		token = strings.TrimSpace(token)
+		switch {
+		case strings.HasSuffix(token, "fr"):
+			v, err := strconv.ParseFloat(strings.TrimSuffix(token, "fr"), 64)
+			return gridTrack{fr: v}, err == nil
+		case token == "auto" || token == "min-content" || token == "max-content" || strings.HasPrefix(token, "fit-content"):
+			return gridTrack{fr: 1}, true
+		case strings.HasPrefix(token, "minmax(") && strings.HasSuffix(token, ")"):
+			args := splitTopLevel(token[len("minmax("):len(token)-1], ',')
+			if len(args) != 2 {
+				return gridTrack{}, false
+			}
+			minimum := 0.0
+			if l, ok := parseLength(strings.TrimSpace(args[0]), fontSize, ctx); ok && l.kind == lengthFixed {
+				minimum = l.resolve(width, 0)
+			}
+			track, ok := parseTrackValue(strings.TrimSpace(args[1]), width, fontSize, ctx)
+			track.min = minimum
+			return track, ok
+		}
+		return parseTrackValue(token, width, fontSize, ctx)
+	}
+
+	for _, token := range splitTopLevel(strings.TrimSpace(value), ' ') {
+		token = strings.TrimSpace(token)
+		if token == "" || strings.HasPrefix(token, "[") {
+			continue
+		}
+		m := repeatRegex.FindStringSubmatch(token)
+		if m == nil {
+			if track, ok := parseTrack(token); ok {
+				tracks = append(tracks, track)
+			}
+			continue
+		}
+
+		var pattern []gridTrack
+		for _, t := range splitTopLevel(strings.TrimSpace(m[2]), ' ') {
+			if track, ok := parseTrack(t); ok && strings.TrimSpace(t) != "" {
+				pattern = append(pattern, track)
+			}
+		}
+		if len(pattern) == 0 {
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(m[1]))
+		if err != nil {
+			// auto-fill / auto-fit
+			size := 0.0
+			for _, track := range pattern {
+				size += math.Max(track.fixed, track.min) + gap
+			}
+			if size <= gap {
+				size = 200
+			}
+			count = int((width + gap) / size)
+		}
+		count = max(1, min(count, 64))
+		for i := 0; i < count; i++ {
+			tracks = append(tracks, pattern...)
+		}
+	}
+	return tracks
+}
+
+// parseTrackValue 解析单个列宽
+func parseTrackValue(token string, width, fontSize float64, ctx *styleContext) (gridTrack, bool) {
+	if strings.HasSuffix(token, "fr") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(token, "fr"), 64)
+		return gridTrack{fr: v}, err == nil
+	}
+	if token == "auto" || token == "max-content" || token == "min-content" {
+		return gridTrack{fr: 1}, true
+	}
+	l, ok := parseLength(token, fontSize, ctx)
+	if !ok || l.kind != lengthFixed {
+		return gridTrack{}, false
+	}
+	return gridTrack{fixed: l.resolve(width, 0)}, true
+}
+
+// layoutGrid 网格布局：按列模板计算列宽，子元素按顺序逐行放置
+func (e *layoutEngine) layoutGrid(b *renderBox, x, y, width, height float64) float64 {
+	style := b.style
+	children := e.inFlowChildren(b, x, y)
+	gap := style.columnGap
+
+	tracks := parseGridTracks(style.gridColumns, width, gap, style.fontSize, e.ctx)
+	if len(tracks) == 0 {
+		tracks = []gridTrack{{fr: 1}}
+	}
+	free := width - gap*float64(len(tracks)-1)
+	totalFr := 0.0
+	for _, track := range tracks {
+		free -= track.fixed
+		totalFr += track.fr
+	}
+	columns := make([]float64, len(tracks))
+	for i, track := range tracks {
+		columns[i] = track.fixed
+		if track.fr > 0 && totalFr > 0 {
+			columns[i] = math.Max(track.min, math.Max(0, free)*track.fr/totalFr)
+		}
+	}
+
+	cursor := y
+	var row []*renderBox
+	column := 0
+	finishRow := func() {
+		if len(row) == 0 {
+			return
+		}
+		rowHeight := 0.0
+		for _, child := range row {
+			rowHeight = math.Max(rowHeight, child.height+child.margin[0]+child.margin[2])
+		}
+		for _, child := range row {
+			translateBox(child, 0, alignCross(child, style, rowHeight))
+		}
+		cursor += rowHeight + style.rowGap
+		row = nil
+		column = 0
+	}
+
+	for _, child := range children {
+		span := child.style.gridSpan
+		if span <= 0 || span > len(columns) {
+			if span < 0 || span > len(columns) {
+				span = len(columns)
+			} else {
+				span = 1
+			}
+		}
+		if column+span > len(columns) {
+			finishRow()
+		}
+		cellX := x
+		for i := 0; i < column; i++ {
+			cellX += columns[i] + gap
+		}
+		cellWidth := gap * float64(span-1)
+		for i := column; i < column+span; i++ {
+			cellWidth += columns[i]
+		}
+
+		e.resolveEdges(child, cellWidth)
+		e.layoutBlock(child, cellX, cursor, cellWidth, -1, autoSizing)
+		row = append(row, child)
+		column += span
+		if column >= len(columns) {
+			finishRow()
+		}
+	}
+	finishRow()
+
+	used := cursor - y
+	if len(children) > 0 {
+		used -= style.rowGap
+	}
+	return math.Max(0, used)
+}
+
+// outerIntrinsic 盒子外边距盒的最小和最大内容宽度
+func (e *layoutEngine) outerIntrinsic(b *renderBox) (float64, float64) {
+	style := b.style
+	hEdges := style.border[1].width + style.border[3].width + style.padding[1].px + style.padding[3].px
+	margins := style.margin[1].px + style.margin[3].px
+
+	if style.width.kind == lengthFixed && style.width.pct == 0 {
+		w := style.width.px
+		if style.boxSizing != "border-box" {
+			w += hEdges
+		}
+		return w + margins, w + margins
+	}
+	minContent, maxContent := e.contentIntrinsic(b)
+	if style.maxWidth.kind == lengthFixed && style.maxWidth.pct == 0 {
+		limit := style.maxWidth.px
+		if style.boxSizing == "border-box" {
+			limit -= hEdges
+		}
+		maxContent = math.Min(maxContent, limit)
+		minContent = math.Min(minContent, maxContent)
+	}
+	if style.minWidth.kind == lengthFixed && style.minWidth.pct == 0 {
+		minContent = math.Max(minContent, style.minWidth.px)
+		maxContent = math.Max(maxContent, minContent)
+	}
+	return minContent + hEdges + margins, maxContent + hEdges + margins
+}
+
+// contentIntrinsic 盒子内容区的最小和最大内容宽度，结果缓存在盒子上
+func (e *layoutEngine) contentIntrinsic(b *renderBox) (float64, float64) {
+	if b.intrinsicComputed {
+		return b.minContent, b.maxContent
+	}
+	var minContent, maxContent float64
+	switch {
+	case b.replaced:
+		w, _ := e.replacedSize(b, 0, -1, 0, 0)
+		minContent, maxContent = w, w
+	case b.style.display == "flex" || b.style.display == "inline-flex":
+		column := strings.HasPrefix(b.style.flexDirection, "column")
+		count := 0
+		for _, child := range b.children {
+			if child.isOutOfFlow() {
+				continue
+			}
+			childMin, childMax := e.outerIntrinsic(child)
+			if column {
+				minContent = math.Max(minContent, childMin)
+				maxContent = math.Max(maxContent, childMax)
+				continue
+			}
+			if count > 0 {
+				maxContent += b.style.columnGap
+			}
+			maxContent += childMax
+			if b.style.flexWrap {
+				minContent = math.Max(minContent, childMin)
+			} else {
+				minContent += childMin
+			}
+			count++
+		}
+	case b.style.display == "grid" || b.style.display == "inline-grid":
+		columns := len(parseGridTracks(b.style.gridColumns, 0, b.style.columnGap, b.style.fontSize, e.ctx))
+		columns = max(1, columns)
+		for _, child := range b.children {
+			if child.isOutOfFlow() {
+				continue
+			}
+			childMin, childMax := e.outerIntrinsic(child)
+			minContent = math.Max(minContent, childMin)
+			maxContent = math.Max(maxContent, childMax)
+		}
+		maxContent = maxContent*float64(columns) + b.style.columnGap*float64(columns-1)
+	default:
+		lineWidth := 0.0
+		for _, child := range b.children {
+			if child.isOutOfFlow() {
+				continue
+			}
+			if child.isBlockLevel() {
+				maxContent = math.Max(maxContent, lineWidth)
+				lineWidth = 0
+				childMin, childMax := e.outerIntrinsic(child)
+				minContent = math.Max(minContent, childMin)
+				maxContent = math.Max(maxContent, childMax)
+				continue
+			}
+			lineWidth = e.measureInline(child, lineWidth, &minContent, &maxContent)
+		}
+		maxContent = math.Max(maxContent, lineWidth)
+	}
+	b.minContent, b.maxContent = minContent, math.Max(minContent, maxContent)
+	b.intrinsicComputed = true
+	return b.minContent, b.maxContent
+}
+
+// measureInline 累加行内内容的宽度，返回当前行宽；最长的不可断开片段计入最小内容宽度
+func (e *layoutEngine) measureInline(b *renderBox, lineWidth float64, minContent, maxContent *float64) float64 {
+	switch {
+	case b.isText():
+		for _, item := range e.splitText(b.text, b.style, "", nil) {
+			switch item.kind {
+			case itemBreak:
+				*maxContent = math.Max(*maxContent, lineWidth)
+				lineWidth = 0
+			default:
+				lineWidth += item.width
+				if item.kind == itemText || item.noWrap {
+					*minContent = math.Max(*minContent, item.width)
+				}
+			}
+		}
+		if b.style.whiteSpace == "nowrap" || b.style.whiteSpace == "pre" {
+			*minContent = math.Max(*minContent, lineWidth)
+		}
+	case b.node != nil && b.node.Data == "br":
+		*maxContent = math.Max(*maxContent, lineWidth)
+		lineWidth = 0
+	case b.style.display != "inline" || b.replaced:
+		childMin, childMax := e.outerIntrinsic(b)
+		*minContent = math.Max(*minContent, childMin)
+		lineWidth += childMax
+	default:
+		for _, child := range b.children {
+			if !child.isOutOfFlow() {
+				lineWidth = e.measureInline(child, lineWidth, minContent, maxContent)
+			}
+		}
+	}
+	return lineWidth
+}
@@ -0,0 +1,674 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// 缩略图渲染的绘制：背景、边框、圆角、渐变、图片和文字
+
+// renderHTML 以指定视口渲染HTML，返回视口范围内的图像
+func renderHTML(htmlContent string, width, height int, loader ResourceLoader) (img *image.RGBA, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			img, err = nil, fmt.Errorf("render failed: %v", r)
+		}
+	}()
+	if err := loadRenderFonts(); err != nil {
+		return nil, err
+	}
+
+	ctx := &styleContext{viewportWidth: float64(width), viewportHeight: float64(height), rootFontSize: 16}
+	root, err := buildDocument(htmlContent, ctx, loader)
+	if err != nil {
+		return nil, err
+	}
+
+	shaper := newTextShaper()
+	engine := &layoutEngine{shaper: shaper, ctx: ctx}
+	engine.layoutDocument(root)
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(canvas, canvas.Bounds(), canvasBackground(root))
+	painter := &painter{dst: canvas, shaper: shaper}
+	painter.paintBox(root, canvas.Bounds(), 1, 0, 0)
+	return canvas, nil
+}
+
+// canvasBackground 画布背景：根元素的背景色，没有时使用 body 的背景色，都没有时为白色
+func canvasBackground(root *renderBox) color.NRGBA {
+	if root.style.backgroundColor.A > 0 {
+		return opaqueOver(root.style.backgroundColor)
+	}
+	for _, child := range root.children {
+		if child.node != nil && child.node.Data == "body" && child.style.backgroundColor.A > 0 {
+			return opaqueOver(child.style.backgroundColor)
+		}
+	}
+	return color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+}
+
+// opaqueOver 将半透明颜色叠加到白色上
+func opaqueOver(c color.NRGBA) color.NRGBA {
+	a := float64(c.A) / 255
+	mix := func(v uint8) uint8 {
+		return uint8(math.Round(float64(v)*a + 255*(1-a)))
+	}
+	return color.NRGBA{R: mix(c.R), G: mix(c.G), B: mix(c.B), A: 255}
+}
+
+// painter 绘制器
+type painter struct {
+	dst    *image.RGBA
+	shaper *textShaper
+}
+
+// paintBox 绘制盒子：背景和边框、替换内容、普通流子元素和文字，最后按层级绘制绝对定位元素
+func (p *painter) paintBox(b *renderBox, clip image.Rectangle, opacity, dx, dy float64) {
+	if b.isText() {
+		return
+	}
+	style := b.style
+	opacity *= style.opacity
+	if opacity < 0.01 {
+		return
+	}
+	if style.position == "relative" || style.position == "sticky" {
+		switch {
+		case !style.inset[3].isAuto():
+			dx += style.inset[3].resolve(b.width, 0)
+		case !style.inset[1].isAuto():
+			dx -= style.inset[1].resolve(b.width, 0)
+		}
+		switch {
+		case !style.inset[0].isAuto():
+			dy += style.inset[0].resolve(b.height, 0)
+		case !style.inset[2].isAuto():
+			dy -= style.inset[2].resolve(b.height, 0)
+		}
+	}
+
+	x, y, w, h := b.x+dx, b.y+dy, b.width, b.height
+	radii := p.radii(b)
+	visible := style.visibility != "hidden" && style.visibility != "collapse"
+
+	if visible {
+		if style.backgroundColor.A > 0 {
+			fillRoundedRectRadii(p.dst, clip, x, y, w, h, radii, withOpacity(style.backgroundColor, opacity))
+		}
+		p.paintBackgroundImage(b, clip, x, y, w, h, radii, opacity)
+		p.paintBorders(b, clip, x, y, w, h, radii, opacity)
+		if b.replaced {
+			p.paintReplaced(b, clip, x, y, radii, opacity)
+		}
+		if b.marker != "" {
+			p.paintMarker(b, clip, dx, dy, opacity)
+		}
+	}
+
+	childClip := clip
+	if style.overflowHidden {
+		childClip = clip.Intersect(image.Rect(
+			int(math.Floor(x+b.border[3])), int(math.Floor(y+b.border[0])),
+			int(math.Ceil(x+w-b.border[1])), int(math.Ceil(y+h-b.border[2])),
+		))
+		if childClip.Empty() {
+			return
+		}
+	}
+
+	absolutes := append([]*renderBox(nil), b.absolutes...)
+	sort.SliceStable(absolutes, func(i, j int) bool {
+		return absolutes[i].style.zIndex < absolutes[j].style.zIndex
+	})
+	for _, abs := range absolutes {
+		if abs.style.zIndex < 0 {
+			p.paintBox(abs, childClip, opacity, dx, dy)
+		}
+	}
+
+	for _, child := range b.children {
+		if !child.isOutOfFlow() {
+			p.paintBox(child, childClip, opacity, dx, dy)
+		}
+	}
+	for _, fragment := range b.fragments {
+		if fragment.box == nil && fragment.style.visibility != "hidden" {
+			p.paintText(fragment, childClip, opacity, dx, dy)
+		}
+	}
+
+	for _, abs := range absolutes {
+		if abs.style.zIndex >= 0 {
+			p.paintBox(abs, childClip, opacity, dx, dy)
+		}
+	}
+}
+
+// radii 计算圆角半径，百分比相对于盒子尺寸，相邻圆角之和超过边长时等比缩小
+func (p *painter) radii(b *renderBox) [4]float64 {
+	var radii [4]float64
+	size := math.Min(b.width, b.height)
+	for i, r := range b.style.radius {
+		radii[i] = math.Max(0, r.resolve(size, 0))
+	}
+	scale := 1.0
+	for _, pair := range [][3]float64{
+		{radii[0], radii[1], b.width}, {radii[3], radii[2], b.width},
+		{radii[0], radii[3], b.height}, {radii[1], radii[2], b.height},
+	} {
+		if sum := pair[0] + pair[1]; sum > pair[2] && sum > 0 {
+			scale = math.Min(scale, pair[2]/sum)
+		}
+	}
+	for i := range radii {
+		radii[i] *= scale
+	}
+	return radii
+}
+
+// paintBorders 绘制边框：外圆角矩形减去内圆角矩形，像素颜色取最近一侧的边框颜色
+func (p *painter) paintBorders(b *renderBox, clip image.Rectangle, x, y, w, h float64, radii [4]float64, opacity float64) {
+	top, right, bottom, left := b.border[0], b.border[1], b.border[2], b.border[3]
+	if top+right+bottom+left == 0 {
+		return
+	}
+	colors := [4]color.NRGBA{}
+	for i, side := range b.style.border {
+		colors[i] = withOpacity(side.color, opacity)
+	}
+	inner := [4]float64{
+		math.Max(0, radii[0]-math.Max(left, top)), math.Max(0, radii[1]-math.Max(right, top)),
+		math.Max(0, radii[2]-math.Max(right, bottom)), math.Max(0, radii[3]-math.Max(left, bottom)),
+	}
+	ix, iy, iw, ih := x+left, y+top, w-left-right, h-top-bottom
+
+	bounds := clip.Intersect(image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x+w)), int(math.Ceil(y+h))))
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		fy := float64(py) + 0.5
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			fx := float64(px) + 0.5
+			coverage := roundedCoverage(fx, fy, x, y, w, h, radii)
+			if coverage <= 0 {
+				continue
+			}
+			if iw > 0 && ih > 0 {
+				coverage -= roundedCoverage(fx, fy, ix, iy, iw, ih, inner)
+			}
+			if coverage <= 0 {
+				continue
+			}
+			// 按到各边的相对距离选择颜色
+			side, best := 0, math.Inf(1)
+			for i, d := range [4]float64{(fy - y) / top, (x + w - fx) / right, (y + h - fy) / bottom, (fx - x) / left} {
+				if !math.IsNaN(d) && !math.IsInf(d, 0) && d < best {
+					side, best = i, d
+				}
+			}
+			blendPixel(p.dst, px, py, colors[side], coverage)
+		}
+	}
+}
+
+// paintBackgroundImage 绘制渐变或图片背景
+func (p *painter) paintBackgroundImage(b *renderBox, clip image.Rectangle, x, y, w, h float64, radii [4]float64, opacity float64) {
+	value := strings.TrimSpace(b.style.backgroundImage)
+	if value == "" || value == "none" {
+		return
+	}
+	if b.background != nil {
+		dstW, dstH := w, h
+		bounds := b.background.Bounds()
+		iw, ih := float64(bounds.Dx()), float64(bounds.Dy())
+		switch b.style.backgroundSize {
+		case "cover", "contain":
+			scale := math.Max(w/iw, h/ih)
+			if b.style.backgroundSize == "contain" {
+				scale = math.Min(w/iw, h/ih)
+			}
+			dstW, dstH = iw*scale, ih*scale
+			p.drawImage(b.background, clip, x+(w-dstW)/2, y+(h-dstH)/2, dstW, dstH, x, y, w, h, radii, opacity)
+		default:
+			// 按原始尺寸平铺，小图片平铺次数过多时拉伸填充
+			if math.Ceil(w/iw)*math.Ceil(h/ih) > 64 {
+				p.drawImage(b.background, clip, x, y, w, h, x, y, w, h, radii, opacity)
+				return
+			}
+			for ty := y; ty < y+h; ty += ih {
+				for tx := x; tx < x+w; tx += iw {
+					p.drawImage(b.background, clip, tx, ty, iw, ih, x, y, w, h, radii, opacity)
+				}
+			}
+		}
+		return
+	}
+	lower := strings.ToLower(value)
+	if idx := strings.Index(lower, "gradient("); idx >= 0 {
+		start := strings.LastIndex(lower[:idx], " ") + 1
+		end := matchingParen(value, idx+len("gradient"))
+		p.paintGradient(lower[start:end+1], clip, x, y, w, h, radii, opacity, b.style.color)
+	}
+}
+
+// gradientStop 渐变色标
+type gradientStop struct {
+	color    color.NRGBA
+	position float64
+}
+
+// paintGradient 绘制线性或径向渐变
+func (p *painter) paintGradient(value string, clip image.Rectangle, x, y, w, h float64, radii [4]float64, opacity float64, current color.NRGBA) {
+	open := strings.Index(value, "(")
+	if open < 0 || !strings.HasSuffix(value, ")") {
+		return
+	}
+	name := value[:open]
+	args := splitTopLevel(value[open+1:len(value)-1], ',')
+	if len(args) < 2 {
+		return
+	}
+	radial := strings.Contains(name, "radial")
+
+	// 线性渐变的方向，默认从上到下
+	angle := 180.0
+	first := strings.TrimSpace(args[0])
+	if _, ok := parseColor(strings.Fields(first + " x")[0], current); !ok {
+		args = args[1:]
+		if !radial {
+			angle = parseGradientAngle(first, w, h)
+		}
+	}
+
+	stops := make([]gradientStop, 0, len(args))
+	for _, arg := range args {
+		fields := splitTopLevel(strings.TrimSpace(arg), ' ')
+		c, ok := parseColor(fields[0], current)
+		if !ok {
+			continue
+		}
+		stop := gradientStop{color: c, position: -1}
+		if len(fields) > 1 {
+			if v, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64); err == nil && strings.HasSuffix(fields[1], "%") {
+				stop.position = v / 100
+			}
+		}
+		stops = append(stops, stop)
+	}
+	if len(stops) == 0 {
+		return
+	}
+	if len(stops) == 1 {
+		stops = append(stops, stops[0])
+	}
+	// 未指定位置的色标均匀分布
+	if stops[0].position < 0 {
+		stops[0].position = 0
+	}
+	if stops[len(stops)-1].position < 0 {
+		stops[len(stops)-1].position = 1
+	}
+	for i := 1; i < len(stops)-1; i++ {
+		if stops[i].position >= 0 {
+			continue
+		}
+		j := i
+		for stops[j].position < 0 {
+			j++
+		}
+		prev := stops[i-1].position
+		step := (stops[j].position - prev) / float64(j-i+1)
+		for k := i; k < j; k++ {
+			stops[k].position = prev + step*float64(k-i+1)
+		}
+	}
+
+	rad := angle * math.Pi / 180
+	dirX, dirY := math.Sin(rad), -math.Cos(rad)
+	length := math.Abs(w*dirX) + math.Abs(h*dirY)
+	cx, cy := x+w/2, y+h/2
+	maxRadius := math.Hypot(w/2, h/2)
+
+	bounds := clip.Intersect(image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x+w)), int(math.Ceil(y+h))))
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		fy := float64(py) + 0.5
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			fx := float64(px) + 0.5
+			coverage := roundedCoverage(fx, fy, x, y, w, h, radii)
+			if coverage <= 0 {
+				continue
+			}
+			var t float64
+			if radial {
+				t = math.Hypot(fx-cx, fy-cy) / maxRadius
+			} else if length > 0 {
+				t = ((fx-cx)*dirX+(fy-cy)*dirY)/length + 0.5
+			}
+			blendPixel(p.dst, px, py, withOpacity(gradientColor(stops, t), opacity), coverage)
+		}
+	}
+}
+
+// parseGradientAngle 解析线性渐变的角度或方向关键字
+func parseGradientAngle(value string, w, h float64) float64 {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasSuffix(value, "deg"):
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "deg"), 64); err == nil {
+			return v
+		}
+	case strings.HasSuffix(value, "turn"):
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "turn"), 64); err == nil {
+			return v * 360
+		}
+	case strings.HasPrefix(value, "to "):
+		diagonal := math.Atan2(w, h) * 180 / math.Pi
+		switch strings.Join(strings.Fields(value[3:]), " ") {
+		case "top":
+			return 0
+		case "right":
+			return 90
+		case "bottom":
+			return 180
+		case "left":
+			return 270
+		case "top right", "right top":
+			return 90 - diagonal
+		case "bottom right", "right bottom":
+			return 90 + diagonal
+		case "bottom left", "left bottom":
+			return 270 - diagonal
+		case "top left", "left top":
+			return 270 + diagonal
+		}
+	}
+	return 180
+}
+
+// gradientColor 计算渐变在 t 处的颜色
+func gradientColor(stops []gradientStop, t float64) color.NRGBA {
+	if t <= stops[0].position {
+		return stops[0].color
+	}
+	for i := 1; i < len(stops); i++ {
+		if t <= stops[i].position {
+			a, b := stops[i-1], stops[i]
+			span := b.position - a.position
+			if span <= 0 {
+				return b.color
+			}
+			f := (t - a.position) / span
+			lerp := func(u, v uint8) uint8 {
+				return uint8(math.Round(float64(u) + (float64(v)-float64(u))*f))
+			}
+			return color.NRGBA{R: lerp(a.color.R, b.color.R), G: lerp(a.color.G, b.color.G), B: lerp(a.color.B, b.color.B), A: lerp(a.color.A, b.color.A)}
+		}
+	}
+	return stops[len(stops)-1].color
+}
+
+// matchingParen 返回与 open 位置的左括号匹配的右括号位置
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s) - 1
+}
+
+// paintReplaced 绘制图片，无法读取的图片和其他替换元素绘制为占位块
+func (p *painter) paintReplaced(b *renderBox, clip image.Rectangle, x, y float64, radii [4]float64, opacity float64) {
+	cx := x + b.border[3] + b.padding[3]
+	cy := y + b.border[0] + b.padding[0]
+	cw := b.width - b.border[1] - b.border[3] - b.padding[1] - b.padding[3]
+	ch := b.height - b.border[0] - b.border[2] - b.padding[0] - b.padding[2]
+	if cw <= 0 || ch <= 0 {
+		return
+	}
+
+	if b.image != nil {
+		bounds := b.image.Bounds()
+		iw, ih := float64(bounds.Dx()), float64(bounds.Dy())
+		dw, dh := cw, ch
+		switch b.style.objectFit {
+		case "cover", "contain":
+			scale := math.Max(cw/iw, ch/ih)
+			if b.style.objectFit == "contain" {
+				scale = math.Min(cw/iw, ch/ih)
+			}
+			dw, dh = iw*scale, ih*scale
+		case "none":
+			dw, dh = iw, ih
+		}
+		p.drawImage(b.image, clip, cx+(cw-dw)/2, cy+(ch-dh)/2, dw, dh, cx, cy, cw, ch, radii, opacity)
+		return
+	}
+
+	var placeholder color.NRGBA
+	switch b.node.Data {
+	case "svg":
+		// 内联图标按文字颜色绘制为浅色块
+		placeholder = b.style.color
+		placeholder.A = uint8(float64(placeholder.A) * 0.3)
+		radii = [4]float64{3, 3, 3, 3}
+	case "input":
+		placeholder = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		if b.style.border[0].width == 0 {
+			p.strokeRect(clip, cx, cy, cw, ch, color.NRGBA{R: 118, G: 118, B: 118, A: 255}, opacity)
+		}
+	case "video", "iframe", "canvas", "object", "embed":
+		placeholder = color.NRGBA{R: 209, G: 213, B: 219, A: 255}
+	default:
+		placeholder = color.NRGBA{R: 229, G: 231, B: 235, A: 255}
+	}
+	fillRoundedRectRadii(p.dst, clip, cx, cy, cw, ch, radii, withOpacity(placeholder, opacity))
+}
+
+// strokeRect 绘制一像素宽的矩形边框
+func (p *painter) strokeRect(clip image.Rectangle, x, y, w, h float64, c color.NRGBA, opacity float64) {
+	c = withOpacity(c, opacity)
+	fillRoundedRect(p.dst, clip, x, y, w, 1, 0, c)
+	fillRoundedRect(p.dst, clip, x, y+h-1, w, 1, 0, c)
+	fillRoundedRect(p.dst, clip, x, y, 1, h, 0, c)
+	fillRoundedRect(p.dst, clip, x+w-1, y, 1, h, 0, c)
+}
+
+// drawImage 将图片缩放到 (x, y, w, h)，只绘制在 (bx, by, bw, bh) 圆角区域内
+func (p *painter) drawImage(img image.Image, clip image.Rectangle, x, y, w, h, bx, by, bw, bh float64, radii [4]float64, opacity float64) {
+	area := clip.Intersect(image.Rect(int(math.Floor(bx)), int(math.Floor(by)), int(math.Ceil(bx+bw)), int(math.Ceil(by+bh))))
+	target := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	if area.Empty() || target.Empty() || !target.Overlaps(area) {
+		return
+	}
+
+	var mask *image.Alpha
+	if opacity < 1 || radii != [4]float64{} {
+		mask = image.NewAlpha(area)
+		for py := area.Min.Y; py < area.Max.Y; py++ {
+			for px := area.Min.X; px < area.Max.X; px++ {
+				coverage := roundedCoverage(float64(px)+0.5, float64(py)+0.5, bx, by, bw, bh, radii) * opacity
+				mask.SetAlpha(px, py, color.Alpha{A: uint8(math.Round(coverage * 255))})
+			}
+		}
+	}
+	dst := p.dst.SubImage(area).(*image.RGBA)
+	options := &draw.Options{}
+	if mask != nil {
+		options.DstMask = mask
+	}
+	draw.ApproxBiLinear.Scale(dst, target, img, img.Bounds(), draw.Over, options)
+}
+
+// paintMarker 在列表项左侧绘制标记
+func (p *painter) paintMarker(b *renderBox, clip image.Rectangle, dx, dy, opacity float64) {
+	style := b.style
+	contentX := b.x + dx + b.border[3] + b.padding[3]
+	baseline := firstBaseline(b)
+	if baseline < 0 {
+		ascent, _ := p.shaper.metrics(style)
+		baseline = b.y + b.border[0] + b.padding[0] + (style.lineHeight-style.fontSize)/2 + ascent
+	}
+	baseline += dy
+	c := withOpacity(style.color, opacity)
+
+	switch b.marker {
+	case "disc", "circle", "square":
+		size := style.fontSize * 0.36
+		mx := contentX - style.fontSize*0.55 - size
+		my := baseline - style.fontSize*0.33 - size/2
+		radius := size / 2
+		if b.marker == "square" {
+			radius = 0
+		}
+		fillRoundedRect(p.dst, clip, mx, my, size, size, radius, c)
+		if b.marker == "circle" {
+			inner := size * 0.3
+			fillRoundedRect(p.dst, clip, mx+inner/2, my+inner/2, size-inner, size-inner, (size-inner)/2, canvasPixel(p.dst, mx, my))
+		}
+	default:
+		width := p.shaper.measure(b.marker, style)
+		p.shaper.draw(p.dst, b.marker, contentX-style.fontSize*0.4-width, baseline, style, c, clip)
+	}
+}
+
+// firstBaseline 查找盒子中第一行文字的基线，没有文字时返回 -1
+func firstBaseline(b *renderBox) float64 {
+	if len(b.fragments) > 0 {
+		return b.fragments[0].baseline
+	}
+	for _, child := range b.children {
+		if child.isBlockLevel() {
+			return firstBaseline(child)
+		}
+	}
+	return -1
+}
+
+// canvasPixel 读取画布上某点的颜色，用于绘制空心标记
+func canvasPixel(dst *image.RGBA, x, y float64) color.NRGBA {
+	point := image.Pt(int(x)-1, int(y))
+	if !point.In(dst.Bounds()) {
+		return color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return color.NRGBAModel.Convert(dst.At(point.X, point.Y)).(color.NRGBA)
+}
+
+// paintText 绘制文字片段及其下划线或删除线
+func (p *painter) paintText(fragment textFragment, clip image.Rectangle, opacity, dx, dy float64) {
+	x, baseline := fragment.x+dx, fragment.baseline+dy
+	style := fragment.style
+	bounds := image.Rect(int(x)-2, int(baseline-style.fontSize*1.5), int(x+fragment.width)+2, int(baseline+style.fontSize))
+	if !bounds.Overlaps(clip) {
+		return
+	}
+	c := withOpacity(style.color, opacity)
+	p.shaper.draw(p.dst, fragment.text, x, baseline, style, c, clip)
+
+	thickness := math.Max(1, style.fontSize/14)
+	switch fragment.decoration {
+	case "underline":
+		fillRoundedRect(p.dst, clip, x, baseline+style.fontSize*0.12, fragment.width, thickness, 0, c)
+	case "line-through":
+		fillRoundedRect(p.dst, clip, x, baseline-style.fontSize*0.3, fragment.width, thickness, 0, c)
+	case "overline":
+		fillRoundedRect(p.dst, clip, x, baseline-style.fontSize*0.85, fragment.width, thickness, 0, c)
+	}
+}
+
+// withOpacity 按不透明度调整颜色的透明度
+func withOpacity(c color.NRGBA, opacity float64) color.NRGBA {
+	if opacity < 1 {
+		c.A = uint8(math.Round(float64(c.A) * opacity))
+	}
+	return c
+}
+
+// fillRect 用不透明颜色填充矩形
+func fillRect(dst *image.RGBA, r image.Rectangle, c color.NRGBA) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// fillRoundedRect 填充四角半径相同的圆角矩形
+func fillRoundedRect(dst *image.RGBA, clip image.Rectangle, x, y, w, h, radius float64, c color.NRGBA) {
+	fillRoundedRectRadii(dst, clip, x, y, w, h, [4]float64{radius, radius, radius, radius}, c)
+}
+
+// fillRoundedRectRadii 填充圆角矩形，边缘按像素覆盖率抗锯齿
+func fillRoundedRectRadii(dst *image.RGBA, clip image.Rectangle, x, y, w, h float64, radii [4]float64, c color.NRGBA) {
+	if w <= 0 || h <= 0 || c.A == 0 {
+		return
+	}
+	bounds := clip.Intersect(dst.Bounds()).Intersect(image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x+w)), int(math.Ceil(y+h))))
+	if bounds.Empty() {
+		return
+	}
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		fy := float64(py) + 0.5
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			if coverage := roundedCoverage(float64(px)+0.5, fy, x, y, w, h, radii); coverage > 0 {
+				blendPixel(dst, px, py, c, coverage)
+			}
+		}
+	}
+}
+
+// roundedCoverage 计算像素中心 (fx, fy) 被圆角矩形覆盖的比例
+func roundedCoverage(fx, fy, x, y, w, h float64, radii [4]float64) float64 {
+	horizontal := clamp01(math.Min(fx-x, x+w-fx) + 0.5)
+	vertical := clamp01(math.Min(fy-y, y+h-fy) + 0.5)
+	coverage := math.Min(horizontal, vertical)
+	if coverage <= 0 {
+		return 0
+	}
+
+	corner := func(r, cx, cy float64) float64 {
+		return clamp01(r - math.Hypot(fx-cx, fy-cy) + 0.5)
+	}
+	switch {
+	case radii[0] > 0 && fx < x+radii[0] && fy < y+radii[0]:
+		coverage = math.Min(coverage, corner(radii[0], x+radii[0], y+radii[0]))
+	case radii[1] > 0 && fx > x+w-radii[1] && fy < y+radii[1]:
+		coverage = math.Min(coverage, corner(radii[1], x+w-radii[1], y+radii[1]))
+	case radii[2] > 0 && fx > x+w-radii[2] && fy > y+h-radii[2]:
+		coverage = math.Min(coverage, corner(radii[2], x+w-radii[2], y+h-radii[2]))
+	case radii[3] > 0 && fx < x+radii[3] && fy > y+h-radii[3]:
+		coverage = math.Min(coverage, corner(radii[3], x+radii[3], y+h-radii[3]))
+	}
+	return coverage
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// blendPixel 将颜色按覆盖率混合到画布像素上
+func blendPixel(dst *image.RGBA, x, y int, c color.NRGBA, coverage float64) {
+	if !(image.Point{X: x, Y: y}).In(dst.Rect) {
+		return
+	}
+	alpha := float64(c.A) / 255 * coverage
+	if alpha <= 0 {
+		return
+	}
+	i := dst.PixOffset(x, y)
+	pix := dst.Pix[i : i+4 : i+4]
+	inv := 1 - alpha
+	pix[0] = uint8(float64(c.R)*alpha + float64(pix[0])*inv + 0.5)
+	pix[1] = uint8(float64(c.G)*alpha + float64(pix[1])*inv + 0.5)
+	pix[2] = uint8(float64(c.B)*alpha + float64(pix[2])*inv + 0.5)
+	pix[3] = uint8(255*alpha + float64(pix[3])*inv + 0.5)
+}
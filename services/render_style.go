@@ -0,0 +1,1064 @@
+package services
+
+import (
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 缩略图渲染的计算样式：层叠后的声明展开为长属性，再解析为具体数值
+
+// lengthKind 长度类型
+type lengthKind uint8
+
+const (
+	lengthFixed lengthKind = iota
+	lengthAuto
+	lengthNone
+)
+
+// cssLength 长度值，固定部分和百分比部分分开保存以支持 calc(100% - 40px)
+type cssLength struct {
+	kind lengthKind
+	px   float64
+	pct  float64
+}
+
+var autoLength = cssLength{kind: lengthAuto}
+var noneLength = cssLength{kind: lengthNone}
+
+// resolve 按参考长度计算像素值，auto 和 none 返回 fallback
+func (l cssLength) resolve(base, fallback float64) float64 {
+	if l.kind != lengthFixed {
+		return fallback
+	}
+	return l.px + l.pct*base/100
+}
+
+// isAuto 是否为 auto
+func (l cssLength) isAuto() bool {
+	return l.kind == lengthAuto
+}
+
+// borderSide 一侧的边框
+type borderSide struct {
+	width float64
+	style string
+	color color.NRGBA
+}
+
+// computedStyle 元素的计算样式
+type computedStyle struct {
+	display  string
+	position string
+	float    string
+
+	color           color.NRGBA
+	backgroundColor color.NRGBA
+	backgroundImage string
+	backgroundSize  string
+	opacity         float64
+	visibility      string
+
+	border  [4]borderSide // 上右下左
+	radius  [4]cssLength  // 左上、右上、右下、左下
+	margin  [4]cssLength
+	padding [4]cssLength
+	inset   [4]cssLength
+
+	width, height        cssLength
+	minWidth, maxWidth   cssLength
+	minHeight, maxHeight cssLength
+	boxSizing            string
+	overflowHidden       bool
+
+	fontSize        float64
+	fontWeight      int
+	italic          bool
+	monospace       bool
+	fontFamily      string // 字体列表中的第一个字体，只用于内容分析
+	lineHeight      float64
+	lineHeightScale float64 // 无单位行高，子元素按自身字号重新计算
+	letterSpacing   float64
+	textAlign       string
+	textTransform   string
+	textDecoration  string
+	whiteSpace      string
+	listStyleType   string
+
+	flexDirection  string
+	flexWrap       bool
+	justifyContent string
+	alignItems     string
+	alignSelf      string
+	flexGrow       float64
+	flexShrink     float64
+	flexBasis      cssLength
+	rowGap         float64
+	columnGap      float64
+	gridColumns    string
+	gridSpan       int // 跨越的列数，-1 表示占满一行
+
+	zIndex    int
+	objectFit string
+
+	custom map[string]string
+}
+
+// styleContext 计算样式所需的视口信息
+type styleContext struct {
+	viewportWidth  float64
+	viewportHeight float64
+	rootFontSize   float64
+}
+
+// 可继承的属性在 newComputedStyle 中从父元素复制
+func newComputedStyle(parent *computedStyle) *computedStyle {
+	style := &computedStyle{
+		display:         "inline",
+		position:        "static",
+		float:           "none",
+		color:           color.NRGBA{A: 255},
+		opacity:         1,
+		visibility:      "visible",
+		width:           autoLength,
+		height:          autoLength,
+		maxWidth:        noneLength,
+		maxHeight:       noneLength,
+		inset:           [4]cssLength{autoLength, autoLength, autoLength, autoLength},
+		boxSizing:       "content-box",
+		fontSize:        16,
+		fontWeight:      400,
+		lineHeightScale: 1.2,
+		lineHeight:      16 * 1.2,
+		textAlign:       "left",
+		whiteSpace:      "normal",
+		listStyleType:   "disc",
+		flexDirection:   "row",
+		justifyContent:  "flex-start",
+		alignItems:      "stretch",
+		alignSelf:       "auto",
+		flexShrink:      1,
+		flexBasis:       autoLength,
+		objectFit:       "fill",
+	}
+	for i := range style.border {
+		style.border[i] = borderSide{style: "none", color: style.color}
+	}
+	if parent != nil {
+		style.color = parent.color
+		style.visibility = parent.visibility
+		style.fontSize = parent.fontSize
+		style.fontWeight = parent.fontWeight
+		style.italic = parent.italic
+		style.monospace = parent.monospace
+		style.fontFamily = parent.fontFamily
+		style.lineHeight = parent.lineHeight
+		style.lineHeightScale = parent.lineHeightScale
+		style.letterSpacing = parent.letterSpacing
+		style.textAlign = parent.textAlign
+		style.textTransform = parent.textTransform
+		style.whiteSpace = parent.whiteSpace
+		style.listStyleType = parent.listStyleType
+		style.custom = parent.custom
+	}
+	return style
+}
+
+var varRegex = regexp.MustCompile(`var\(\s*(--[A-Za-z0-9_-]+)\s*(?:,\s*([^()]*(?:\([^()]*\)[^()]*)*))?\)`)
+
+// computeStyle 根据层叠结果计算元素样式
+func computeStyle(node *html.Node, parent *computedStyle, declarations []matchedDeclaration, ctx *styleContext) *computedStyle {
+	style := newComputedStyle(parent)
+
+	// 自定义属性先于其他属性确定，供 var() 引用
+	copied := false
+	for _, d := range declarations {
+		if !strings.HasPrefix(d.property, "--") {
+			continue
+		}
+		if !copied {
+			custom := make(map[string]string, len(style.custom)+4)
+			for k, v := range style.custom {
+				custom[k] = v
+			}
+			style.custom = custom
+			copied = true
+		}
+		style.custom[d.property] = substituteVars(d.value, style.custom)
+	}
+
+	props := make(map[string]string)
+	for _, d := range declarations {
+		if strings.HasPrefix(d.property, "--") {
+			continue
+		}
+		expandShorthand(d.property, substituteVars(d.value, style.custom), props)
+	}
+
+	// 字号和行高先计算，em 单位依赖字号
+	if value, ok := props["font-size"]; ok {
+		style.fontSize = resolveFontSize(value, parent, ctx)
+	}
+	if node.Data == "html" {
+		ctx.rootFontSize = style.fontSize
+	}
+	if value, ok := props["line-height"]; ok {
+		style.applyLineHeight(value, parent, ctx)
+	} else if style.lineHeightScale > 0 {
+		style.lineHeight = style.lineHeightScale * style.fontSize
+	}
+	// 边框颜色默认为文字颜色，先确定文字颜色
+	if value, ok := props["color"]; ok {
+		if value == "inherit" && parent != nil {
+			style.color = parent.color
+		} else if c, ok := parseColor(value, style.color); ok {
+			style.color = c
+		}
+	}
+	for i := range style.border {
+		style.border[i].color = style.color
+	}
+
+	for property, value := range props {
+		style.apply(property, value, parent, ctx)
+	}
+
+	// 浮动和绝对定位元素按块级盒子处理
+	if style.display != "none" {
+		if style.float == "left" || style.float == "right" {
+			style.display = blockified(style.display, true)
+		}
+		if style.position == "absolute" || style.position == "fixed" {
+			style.display = blockified(style.display, false)
+		}
+	}
+	for i := range style.border {
+		if style.border[i].style == "none" || style.border[i].style == "hidden" {
+			style.border[i].width = 0
+		}
+	}
+	return style
+}
+
+// blockified 将行内显示类型转换为块级，浮动元素并排显示，按 inline-block 近似处理
+func blockified(display string, float bool) string {
+	switch display {
+	case "inline", "inline-block", "list-item", "block":
+		if float {
+			return "inline-block"
+		}
+		return "block"
+	case "inline-flex":
+		return "flex"
+	case "inline-grid":
+		return "grid"
+	}
+	return display
+}
+
+// substituteVars 替换 var() 引用，未定义且没有默认值的引用替换为空
+func substituteVars(value string, custom map[string]string) string {
+	for i := 0; i < 8 && strings.Contains(value, "var("); i++ {
+		value = varRegex.ReplaceAllStringFunc(value, func(match string) string {
+			m := varRegex.FindStringSubmatch(match)
+			if v, ok := custom[m[1]]; ok {
+				return v
+			}
+			return strings.TrimSpace(m[2])
+		})
+	}
+	return value
+}
+
+// apply 应用单个长属性
+func (s *computedStyle) apply(property, value string, parent *computedStyle, ctx *styleContext) {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	if lower == "initial" || lower == "unset" || lower == "revert" {
+		return
+	}
+	length := func() (cssLength, bool) {
+		return parseLength(lower, s.fontSize, ctx)
+	}
+	side := func(name string) int {
+		switch {
+		case strings.Contains(name, "top"):
+			return 0
+		case strings.Contains(name, "right"):
+			return 1
+		case strings.Contains(name, "bottom"):
+			return 2
+		}
+		return 3
+	}
+
+	switch property {
+	case "display":
+		if fields := strings.Fields(lower); len(fields) > 0 {
+			s.display = fields[0]
+			if len(fields) > 1 && fields[0] == "inline" {
+				s.display = "inline-" + fields[1]
+			}
+			if s.display == "contents" {
+				s.display = "inline"
+			}
+			if strings.HasPrefix(s.display, "table") {
+				s.display = tableDisplay(s.display)
+			}
+		}
+	case "position":
+		s.position = lower
+	case "float":
+		s.float = lower
+	case "background-color":
+		if c, ok := parseColor(lower, s.color); ok {
+			s.backgroundColor = c
+		}
+	case "background-image":
+		s.backgroundImage = value
+	case "background-size":
+		s.backgroundSize = lower
+	case "opacity":
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(lower, "%"), 64); err == nil {
+			if strings.HasSuffix(lower, "%") {
+				v /= 100
+			}
+			s.opacity = math.Max(0, math.Min(1, v))
+		}
+	case "visibility":
+		s.visibility = lower
+	case "border-top-width", "border-right-width", "border-bottom-width", "border-left-width":
+		s.border[side(property)].width = parseBorderWidth(lower, s.fontSize, ctx)
+	case "border-top-style", "border-right-style", "border-bottom-style", "border-left-style":
+		s.border[side(property)].style = lower
+	case "border-top-color", "border-right-color", "border-bottom-color", "border-left-color":
+		if c, ok := parseColor(lower, s.color); ok {
+			s.border[side(property)].color = c
+		}
+	case "border-top-left-radius", "border-top-right-radius", "border-bottom-right-radius", "border-bottom-left-radius":
+		if l, ok := parseLength(strings.Fields(lower + " 0")[0], s.fontSize, ctx); ok {
+			s.radius[map[string]int{
+				"border-top-left-radius":     0,
+				"border-top-right-radius":    1,
+				"border-bottom-right-radius": 2,
+				"border-bottom-left-radius":  3,
+			}[property]] = l
+		}
+	case "margin-top", "margin-right", "margin-bottom", "margin-left":
+		if l, ok := length(); ok {
+			s.margin[side(property)] = l
+		}
+	case "padding-top", "padding-right", "padding-bottom", "padding-left":
+		if l, ok := length(); ok && l.kind == lengthFixed {
+			s.padding[side(property)] = l
+		}
+	case "top", "right", "bottom", "left":
+		if l, ok := length(); ok {
+			s.inset[side(property)] = l
+		}
+	case "width":
+		if l, ok := length(); ok {
+			s.width = l
+		}
+	case "height":
+		if l, ok := length(); ok {
+			s.height = l
+		}
+	case "min-width":
+		if l, ok := length(); ok && l.kind == lengthFixed {
+			s.minWidth = l
+		}
+	case "max-width":
+		if l, ok := length(); ok {
+			s.maxWidth = l
+		}
+	case "min-height":
+		if l, ok := length(); ok && l.kind == lengthFixed {
+			s.minHeight = l
+		}
+	case "max-height":
+		if l, ok := length(); ok {
+			s.maxHeight = l
+		}
+	case "box-sizing":
+		s.boxSizing = lower
+	case "overflow-x", "overflow-y":
+		if lower != "visible" {
+			s.overflowHidden = true
+		}
+	case "font-weight":
+		s.fontWeight = parseFontWeight(lower, parent)
+	case "font-style":
+		s.italic = lower == "italic" || lower == "oblique"
+	case "font-family":
+		s.monospace = strings.Contains(lower, "mono") || strings.Contains(lower, "courier") ||
+			strings.Contains(lower, "consolas") || strings.Contains(lower, "menlo")
+		s.fontFamily = primaryFontFamily(value)
+	case "letter-spacing":
+		if l, ok := length(); ok {
+			s.letterSpacing = l.px
+		} else if lower == "normal" {
+			s.letterSpacing = 0
+		}
+	case "text-align":
+		switch lower {
+		case "center", "right", "left":
+			s.textAlign = lower
+		case "end":
+			s.textAlign = "right"
+		case "-webkit-center":
+			s.textAlign = "center"
+		default:
+			s.textAlign = "left"
+		}
+	case "text-transform":
+		s.textTransform = lower
+	case "text-decoration-line":
+		s.textDecoration = lower
+	case "white-space":
+		s.whiteSpace = lower
+	case "list-style-type":
+		s.listStyleType = lower
+	case "flex-direction":
+		s.flexDirection = lower
+	case "flex-wrap":
+		s.flexWrap = lower == "wrap" || lower == "wrap-reverse"
+	case "justify-content":
+		s.justifyContent = strings.TrimPrefix(strings.TrimPrefix(lower, "safe "), "unsafe ")
+	case "align-items":
+		s.alignItems = lower
+	case "align-self":
+		s.alignSelf = lower
+	case "flex-grow":
+		if v, err := strconv.ParseFloat(lower, 64); err == nil && v >= 0 {
+			s.flexGrow = v
+		}
+	case "flex-shrink":
+		if v, err := strconv.ParseFloat(lower, 64); err == nil && v >= 0 {
+			s.flexShrink = v
+		}
+	case "flex-basis":
+		if lower == "content" {
+			s.flexBasis = autoLength
+		} else if l, ok := length(); ok {
+			s.flexBasis = l
+		}
+	case "row-gap":
+		if l, ok := length(); ok {
+			s.rowGap = l.px
+		}
+	case "column-gap":
+		if l, ok := length(); ok {
+			s.columnGap = l.px
+		}
+	case "grid-template-columns":
+		s.gridColumns = lower
+	case "grid-column":
+		s.gridSpan = parseGridSpan(lower)
+	case "z-index":
+		if v, err := strconv.Atoi(lower); err == nil {
+			s.zIndex = v
+		}
+	case "object-fit":
+		s.objectFit = lower
+	}
+}
+
+// parseGridSpan 解析 grid-column 的跨列数，支持 span N 和 a / b 形式
+func parseGridSpan(value string) int {
+	start, end, found := strings.Cut(value, "/")
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if strings.HasPrefix(end, "span") {
+		start = end
+	}
+	if strings.HasPrefix(start, "span") {
+		if n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(start, "span"))); err == nil && n > 0 {
+			return n
+		}
+		return 1
+	}
+	if !found {
+		return 1
+	}
+	a, errA := strconv.Atoi(start)
+	b, errB := strconv.Atoi(end)
+	switch {
+	case errA != nil || errB != nil:
+		return 1
+	case b < 0:
+		return -1
+	case b > a:
+		return b - a
+	}
+	return 1
+}
+
+// tableDisplay 表格按弹性布局近似：行为水平弹性容器，单元格平分宽度
+func tableDisplay(display string) string {
+	switch display {
+	case "table-row":
+		return "flex"
+	case "table-cell":
+		return "block"
+	case "table-caption", "table-row-group", "table-header-group", "table-footer-group", "table":
+		return "block"
+	}
+	return "none"
+}
+
+// applyLineHeight 计算行高
+func (s *computedStyle) applyLineHeight(value string, parent *computedStyle, ctx *styleContext) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "normal":
+		s.lineHeightScale = 1.2
+		s.lineHeight = 1.2 * s.fontSize
+	case value == "inherit" && parent != nil:
+		s.lineHeightScale = parent.lineHeightScale
+		s.lineHeight = parent.lineHeight
+		if s.lineHeightScale > 0 {
+			s.lineHeight = s.lineHeightScale * s.fontSize
+		}
+	default:
+		if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 {
+			s.lineHeightScale = v
+			s.lineHeight = v * s.fontSize
+		} else if l, ok := parseLength(value, s.fontSize, ctx); ok && l.kind == lengthFixed {
+			s.lineHeightScale = 0
+			s.lineHeight = l.resolve(s.fontSize, s.fontSize*1.2)
+		}
+	}
+}
+
+// resolveFontSize 计算字号，em 和百分比相对于父元素字号
+func resolveFontSize(value string, parent *computedStyle, ctx *styleContext) float64 {
+	parentSize := 16.0
+	if parent != nil {
+		parentSize = parent.fontSize
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	keywords := map[string]float64{
+		"xx-small": 9, "x-small": 10, "small": 13, "medium": 16, "large": 18,
+		"x-large": 24, "xx-large": 32, "xxx-large": 48,
+	}
+	if size, ok := keywords[value]; ok {
+		return size
+	}
+	switch value {
+	case "smaller":
+		return parentSize / 1.2
+	case "larger":
+		return parentSize * 1.2
+	case "inherit":
+		return parentSize
+	}
+	if l, ok := parseLength(value, parentSize, ctx); ok && l.kind == lengthFixed {
+		if size := l.resolve(parentSize, parentSize); size >= 0 {
+			return math.Min(size, 400)
+		}
+	}
+	return parentSize
+}
+
+// parseFontWeight 解析字重
+func parseFontWeight(value string, parent *computedStyle) int {
+	parentWeight := 400
+	if parent != nil {
+		parentWeight = parent.fontWeight
+	}
+	switch value {
+	case "normal":
+		return 400
+	case "bold":
+		return 700
+	case "bolder":
+		if parentWeight >= 600 {
+			return 900
+		}
+		return 700
+	case "lighter":
+		return 300
+	}
+	if v, err := strconv.Atoi(value); err == nil {
+		return v
+	}
+	return parentWeight
+}
+
+// parseBorderWidth 解析边框宽度，支持 thin/medium/thick
+func parseBorderWidth(value string, fontSize float64, ctx *styleContext) float64 {
+	switch value {
+	case "thin":
+		return 1
+	case "medium":
+		return 3
+	case "thick":
+		return 5
+	}
+	if l, ok := parseLength(value, fontSize, ctx); ok && l.kind == lengthFixed {
+		return math.Max(0, l.px)
+	}
+	return 0
+}
+
+var lengthRegex = regexp.MustCompile(`^([+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:e[+-]?[0-9]+)?)([a-z%]*)$`)
+
+// parseLength 解析长度，支持 px、em、rem、%、vw、vh、pt 以及由加减组成的 calc()
+func parseLength(value string, fontSize float64, ctx *styleContext) (cssLength, bool) {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "auto", "fit-content", "max-content", "min-content":
+		return autoLength, true
+	case "none":
+		return noneLength, true
+	}
+	if strings.HasPrefix(value, "calc(") || strings.HasPrefix(value, "min(") || strings.HasPrefix(value, "max(") ||
+		strings.HasPrefix(value, "clamp(") {
+		return parseMathLength(value, fontSize, ctx)
+	}
+
+	m := lengthRegex.FindStringSubmatch(value)
+	if m == nil {
+		return cssLength{}, false
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return cssLength{}, false
+	}
+	rootFontSize := 16.0
+	if ctx != nil && ctx.rootFontSize > 0 {
+		rootFontSize = ctx.rootFontSize
+	}
+	switch m[2] {
+	case "px", "":
+		return cssLength{px: v}, true
+	case "%":
+		return cssLength{pct: v}, true
+	case "em":
+		return cssLength{px: v * fontSize}, true
+	case "rem":
+		return cssLength{px: v * rootFontSize}, true
+	case "ex", "ch":
+		return cssLength{px: v * fontSize / 2}, true
+	case "pt":
+		return cssLength{px: v * 4 / 3}, true
+	case "vw", "vh", "vmin", "vmax", "dvh", "svh", "lvh":
+		base := ctx.viewportWidth
+		switch m[2] {
+		case "vw":
+		case "vmin":
+			base = math.Min(ctx.viewportWidth, ctx.viewportHeight)
+		case "vmax":
+			base = math.Max(ctx.viewportWidth, ctx.viewportHeight)
+		default:
+			base = ctx.viewportHeight
+		}
+		return cssLength{px: v * base / 100}, true
+	}
+	return cssLength{}, false
+}
+
+// parseMathLength 解析 calc() 中的加减表达式，min()/max()/clamp() 取第一个能解析的参数作为近似
+func parseMathLength(value string, fontSize float64, ctx *styleContext) (cssLength, bool) {
+	open := strings.Index(value, "(")
+	if open < 0 || !strings.HasSuffix(value, ")") {
+		return cssLength{}, false
+	}
+	name, body := value[:open], value[open+1:len(value)-1]
+	if name != "calc" {
+		args := splitTopLevel(body, ',')
+		if name == "clamp" && len(args) == 3 {
+			args = []string{args[1], args[0], args[2]}
+		}
+		for _, arg := range args {
+			if l, ok := parseLength(strings.TrimSpace(arg), fontSize, ctx); ok {
+				return l, true
+			}
+		}
+		return cssLength{}, false
+	}
+
+	// calc 中只支持长度的加减和与数字的乘除
+	var result cssLength
+	sign := 1.0
+	for _, token := range strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(body)) {
+		switch token {
+		case "+":
+			sign = 1
+			continue
+		case "-":
+			sign = -1
+			continue
+		}
+		if strings.Contains(token, "*") || strings.Contains(token, "/") {
+			return cssLength{}, false
+		}
+		l, ok := parseLength(token, fontSize, ctx)
+		if !ok || l.kind != lengthFixed {
+			return cssLength{}, false
+		}
+		result.px += sign * l.px
+		result.pct += sign * l.pct
+	}
+	return result, true
+}
+
+// expandShorthand 将简写属性展开为长属性写入 props，后写入的覆盖先写入的
+func expandShorthand(property, value string, props map[string]string) {
+	sides := [4]string{"top", "right", "bottom", "left"}
+	fourValues := func(prefix, suffix string) {
+		values := boxValues(value)
+		for i, s := range sides {
+			props[prefix+s+suffix] = values[i]
+		}
+	}
+
+	switch property {
+	case "margin", "padding":
+		fourValues(property+"-", "")
+	case "inset":
+		values := boxValues(value)
+		for i, s := range sides {
+			props[s] = values[i]
+		}
+	case "border-width", "border-style", "border-color":
+		fourValues("border-", strings.TrimPrefix(property, "border"))
+	case "border":
+		for _, s := range sides {
+			expandBorder("border-"+s, value, props)
+		}
+	case "border-top", "border-right", "border-bottom", "border-left":
+		expandBorder(property, value, props)
+	case "border-radius":
+		values := boxValues(strings.Split(value, "/")[0])
+		for i, corner := range []string{"top-left", "top-right", "bottom-right", "bottom-left"} {
+			props["border-"+corner+"-radius"] = values[i]
+		}
+	case "background":
+		expandBackground(value, props)
+	case "overflow":
+		fields := strings.Fields(value)
+		if len(fields) > 0 {
+			props["overflow-x"] = fields[0]
+			props["overflow-y"] = fields[len(fields)-1]
+		}
+	case "flex":
+		expandFlex(value, props)
+	case "flex-flow":
+		for _, field := range strings.Fields(value) {
+			if strings.Contains(field, "wrap") {
+				props["flex-wrap"] = field
+			} else {
+				props["flex-direction"] = field
+			}
+		}
+	case "gap", "grid-gap":
+		fields := strings.Fields(value)
+		if len(fields) > 0 {
+			props["row-gap"] = fields[0]
+			props["column-gap"] = fields[len(fields)-1]
+		}
+	case "place-items":
+		if fields := strings.Fields(value); len(fields) > 0 {
+			props["align-items"] = fields[0]
+		}
+	case "font":
+		expandFont(value, props)
+	case "list-style":
+		for _, field := range strings.Fields(value) {
+			if !strings.HasPrefix(field, "url(") && field != "inside" && field != "outside" {
+				props["list-style-type"] = field
+			}
+		}
+	case "text-decoration":
+		props["text-decoration-line"] = "none"
+		for _, field := range strings.Fields(strings.ToLower(value)) {
+			if field == "underline" || field == "line-through" || field == "overline" {
+				props["text-decoration-line"] = field
+			}
+		}
+	case "background-clip", "-webkit-background-clip":
+		// 文字裁剪背景（渐变文字）无法绘制，文字按不透明颜色显示
+		if strings.Contains(value, "text") {
+			props["background-image"] = "none"
+			props["background-color"] = "transparent"
+		}
+	default:
+		props[property] = value
+	}
+}
+
+// boxValues 将 1 到 4 个值展开为上右下左
+func boxValues(value string) [4]string {
+	fields := strings.Fields(value)
+	switch len(fields) {
+	case 0:
+		return [4]string{"0", "0", "0", "0"}
+	case 1:
+		return [4]string{fields[0], fields[0], fields[0], fields[0]}
+	case 2:
+		return [4]string{fields[0], fields[1], fields[0], fields[1]}
+	case 3:
+		return [4]string{fields[0], fields[1], fields[2], fields[1]}
+	}
+	return [4]string{fields[0], fields[1], fields[2], fields[3]}
+}
+
+var borderStyles = map[string]bool{
+	"none": true, "hidden": true, "solid": true, "dashed": true, "dotted": true,
+	"double": true, "groove": true, "ridge": true, "inset": true, "outset": true,
+}
+
+// expandBorder 展开 border 简写：宽度、样式和颜色可以任意顺序出现
+func expandBorder(prefix, value string, props map[string]string) {
+	props[prefix+"-width"] = "medium"
+	props[prefix+"-style"] = "none"
+	props[prefix+"-color"] = "currentcolor"
+	for _, field := range splitTopLevel(strings.TrimSpace(value), ' ') {
+		lower := strings.ToLower(strings.TrimSpace(field))
+		switch {
+		case lower == "":
+		case borderStyles[lower]:
+			props[prefix+"-style"] = lower
+		case lower == "thin" || lower == "medium" || lower == "thick" || lengthRegex.MatchString(lower) || strings.HasPrefix(lower, "calc("):
+			props[prefix+"-width"] = lower
+		default:
+			props[prefix+"-color"] = lower
+		}
+	}
+}
+
+// expandBackground 展开 background 简写，只保留颜色、图片和尺寸
+func expandBackground(value string, props map[string]string) {
+	props["background-color"] = "transparent"
+	props["background-image"] = "none"
+	layers := splitTopLevel(value, ',')
+	if len(layers) > 1 {
+		// 多层背景只使用第一层图片和最后一层颜色
+		props["background-image"] = strings.TrimSpace(layers[0])
+	}
+	for _, field := range splitTopLevel(strings.TrimSpace(layers[len(layers)-1]), ' ') {
+		field = strings.TrimSpace(field)
+		lower := strings.ToLower(field)
+		switch {
+		case field == "":
+		case strings.HasPrefix(lower, "url(") || strings.Contains(lower, "gradient("):
+			if len(layers) == 1 {
+				props["background-image"] = field
+			}
+		case strings.Contains(lower, "cover"):
+			props["background-size"] = "cover"
+		case strings.Contains(lower, "contain"):
+			props["background-size"] = "contain"
+		default:
+			if _, ok := parseColor(lower, color.NRGBA{}); ok {
+				props["background-color"] = lower
+			}
+		}
+	}
+}
+
+// expandFlex 展开 flex 简写
+func expandFlex(value string, props map[string]string) {
+	fields := strings.Fields(strings.ToLower(value))
+	switch {
+	case len(fields) == 0:
+		return
+	case fields[0] == "none":
+		props["flex-grow"], props["flex-shrink"], props["flex-basis"] = "0", "0", "auto"
+		return
+	case fields[0] == "auto":
+		props["flex-grow"], props["flex-shrink"], props["flex-basis"] = "1", "1", "auto"
+		return
+	}
+
+	props["flex-grow"], props["flex-shrink"], props["flex-basis"] = "1", "1", "0"
+	numbers := 0
+	for _, field := range fields {
+		if _, err := strconv.ParseFloat(field, 64); err == nil && numbers < 2 {
+			if numbers == 0 {
+				props["flex-grow"] = field
+			} else {
+				props["flex-shrink"] = field
+			}
+			numbers++
+			continue
+		}
+		props["flex-basis"] = field
+	}
+}
+
+// expandFont 展开 font 简写：[style] [weight] size[/line-height] family
+func expandFont(value string, props map[string]string) {
+	fields := strings.Fields(value)
+	for i, field := range fields {
+		lower := strings.ToLower(field)
+		switch {
+		case lower == "italic" || lower == "oblique":
+			props["font-style"] = lower
+		case lower == "bold" || lower == "bolder" || lower == "lighter" || len(lower) == 3 && lower[1:] == "00":
+			props["font-weight"] = lower
+		case lower == "normal" || lower == "small-caps":
+		case lower != "" && (lower[0] >= '0' && lower[0] <= '9' || lower[0] == '.' || strings.HasSuffix(lower, "small") ||
+			strings.HasSuffix(lower, "large") || lower == "medium"):
+			size, lineHeight, found := strings.Cut(lower, "/")
+			props["font-size"] = size
+			if found {
+				props["line-height"] = lineHeight
+			} else {
+				props["line-height"] = "normal"
+			}
+			props["font-family"] = strings.Join(fields[i+1:], " ")
+			return
+		}
+	}
+}
+
+// primaryFontFamily 字体列表中的第一个字体名称
+func primaryFontFamily(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.Trim(strings.TrimSpace(first), `"'`)
+}
+
+// parseColor 解析颜色，支持关键字、十六进制、rgb()/rgba() 和 hsl()/hsla()
+func parseColor(value string, current color.NRGBA) (color.NRGBA, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "currentcolor" {
+		return current, true
+	}
+	if value == "transparent" {
+		return color.NRGBA{}, true
+	}
+	if c, ok := namedColors[value]; ok {
+		return color.NRGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: 255}, true
+	}
+	if strings.HasPrefix(value, "#") {
+		return parseHexColor(value[1:])
+	}
+
+	open := strings.Index(value, "(")
+	if open < 0 || !strings.HasSuffix(value, ")") {
+		return color.NRGBA{}, false
+	}
+	name := value[:open]
+	args := strings.FieldsFunc(value[open+1:len(value)-1], func(r rune) bool {
+		return r == ',' || r == ' ' || r == '/'
+	})
+	if len(args) < 3 {
+		return color.NRGBA{}, false
+	}
+	alpha := 1.0
+	if len(args) > 3 {
+		alpha = parseColorComponent(args[3], 1)
+	}
+
+	switch name {
+	case "rgb", "rgba":
+		return color.NRGBA{
+			R: uint8(math.Round(parseColorComponent(args[0], 255))),
+			G: uint8(math.Round(parseColorComponent(args[1], 255))),
+			B: uint8(math.Round(parseColorComponent(args[2], 255))),
+			A: uint8(math.Round(alpha * 255)),
+		}, true
+	case "hsl", "hsla":
+		hue, _ := strconv.ParseFloat(strings.TrimSuffix(args[0], "deg"), 64)
+		r, g, b := hslToRGB(hue, parseColorComponent(args[1], 1), parseColorComponent(args[2], 1))
+		return color.NRGBA{R: r, G: g, B: b, A: uint8(math.Round(alpha * 255))}, true
+	}
+	return color.NRGBA{}, false
+}
+
+// parseColorComponent 解析颜色分量，百分比按 scale 换算，结果限制在 0 到 scale 之间
+func parseColorComponent(s string, scale float64) float64 {
+	var v float64
+	if strings.HasSuffix(s, "%") {
+		p, _ := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		v = p / 100 * scale
+	} else {
+		v, _ = strconv.ParseFloat(s, 64)
+	}
+	return math.Max(0, math.Min(scale, v))
+}
+
+// parseHexColor 解析 3、4、6、8 位十六进制颜色
+func parseHexColor(hex string) (color.NRGBA, bool) {
+	if len(hex) == 3 || len(hex) == 4 {
+		expanded := make([]byte, 0, len(hex)*2)
+		for i := 0; i < len(hex); i++ {
+			expanded = append(expanded, hex[i], hex[i])
+		}
+		hex = string(expanded)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
+
+// hslToRGB HSL 转 RGB，s 和 l 取值 0 到 1
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 360
+	if s == 0 {
+		v := uint8(math.Round(l * 255))
+		return v, v, v
+	}
+	q := l * (1 + s)
+	if l >= 0.5 {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	channel := func(t float64) uint8 {
+		if t < 0 {
+			t++
+		}
+		if t > 1 {
+			t--
+		}
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 0.5:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(math.Round(v * 255))
+	}
+	return channel(h + 1.0/3), channel(h), channel(h - 1.0/3)
+}
+
+// namedColors 常用颜色关键字
+var namedColors = map[string]uint32{
+	"black": 0x000000, "white": 0xffffff, "red": 0xff0000, "green": 0x008000, "blue": 0x0000ff,
+	"yellow": 0xffff00, "orange": 0xffa500, "purple": 0x800080, "pink": 0xffc0cb, "gray": 0x808080,
+	"grey": 0x808080, "silver": 0xc0c0c0, "maroon": 0x800000, "navy": 0x000080, "teal": 0x008080,
+	"olive": 0x808000, "lime": 0x00ff00, "aqua": 0x00ffff, "cyan": 0x00ffff, "fuchsia": 0xff00ff,
+	"magenta": 0xff00ff, "brown": 0xa52a2a, "gold": 0xffd700, "indigo": 0x4b0082, "violet": 0xee82ee,
+	"coral": 0xff7f50, "crimson": 0xdc143c, "salmon": 0xfa8072, "tomato": 0xff6347, "khaki": 0xf0e68c,
+	"beige": 0xf5f5dc, "ivory": 0xfffff0, "lavender": 0xe6e6fa, "turquoise": 0x40e0d0, "tan": 0xd2b48c,
+	"skyblue": 0x87ceeb, "steelblue": 0x4682b4, "royalblue": 0x4169e1, "slategray": 0x708090,
+	"darkgray": 0xa9a9a9, "darkgrey": 0xa9a9a9, "lightgray": 0xd3d3d3, "lightgrey": 0xd3d3d3,
+	"gainsboro": 0xdcdcdc, "whitesmoke": 0xf5f5f5, "darkblue": 0x00008b, "darkgreen": 0x006400,
+	"darkred": 0x8b0000, "lightblue": 0xadd8e6, "lightgreen": 0x90ee90, "lightyellow": 0xffffe0,
+	"hotpink": 0xff69b4, "deeppink": 0xff1493, "dodgerblue": 0x1e90ff, "firebrick": 0xb22222,
+	"forestgreen": 0x228b22, "seagreen": 0x2e8b57, "orangered": 0xff4500, "chocolate": 0xd2691e,
+	"midnightblue": 0x191970, "aliceblue": 0xf0f8ff, "ghostwhite": 0xf8f8ff, "snow": 0xfffafa,
+	"mintcream": 0xf5fffa, "honeydew": 0xf0fff0, "azure": 0xf0ffff, "linen": 0xfaf0e6,
+	"wheat": 0xf5deb3, "plum": 0xdda0dd, "orchid": 0xda70d6, "darkslategray": 0x2f4f4f,
+	"dimgray": 0x696969, "dimgrey": 0x696969, "rebeccapurple": 0x663399, "goldenrod": 0xdaa520,
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// renderMustNotPanic 渲染HTML，渲染中的panic会被 renderHTML 转换为 "render failed" 错误，
+// 除复杂度超限外的错误都视为失败
+func renderMustNotPanic(t *testing.T, htmlContent string) {
+	t.Helper()
+	img, err := ThumbnailSvc.Render(htmlContent, nil)
+	if errors.Is(err, errRenderTooComplex) {
+		return
+	}
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if img.Bounds().Dx() != thumbnailViewportWidth || img.Bounds().Dy() != thumbnailViewportHeight {
+		t.Fatalf("rendered size = %v", img.Bounds())
+	}
+}
+
+func TestRenderPathologicalHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+	}{
+		{"empty", ""},
+		{"text only", "hello"},
+		{"unclosed tags", "<div><p><span><b><i>text"},
+		{"misnested tags", "<b><i>a</b>b</i><table><div>c</table>"},
+		{"deep nesting", strings.Repeat("<div>", 10000) + "x" + strings.Repeat("</div>", 10000)},
+		{"deep inline nesting", strings.Repeat("<span>", 3000) + "x"},
+		{"many siblings", strings.Repeat("<p>a</p>", 20000)},
+		{"long unbroken word", "<p>" + strings.Repeat("W", 200000) + "</p>"},
+		{"long text", "<p>" + strings.Repeat("lorem ipsum ", 50000) + "</p>"},
+		{"zero width container", `<div style="width:0"><p>some wrapped text that cannot fit</p></div>`},
+		{"negative sizes", `<div style="width:-100px;height:-50px;margin:-9999px;padding:-5px">x</div>`},
+		{"huge sizes", `<div style="width:1e308px;height:1e308px;margin-left:1e308px;font-size:1e308px;border:1e308px solid red">x</div>`},
+		{"huge percentages", `<div style="width:1e300%;padding:1e300%">x</div>`},
+		{"nan and infinity", `<div style="width:NaNpx;height:Infinitypx;font-size:-Infinitypx;line-height:NaN;opacity:NaN">x</div>`},
+		{"zero font size", `<p style="font-size:0;line-height:0">text</p>`},
+		{"tiny font size", `<p style="font-size:0.0001px">text</p>`},
+		{"broken css", `<style>div{color:red;;;:;{}}}} @media (max-width:{ p{ .a..b{} [x=" </style><div class="a">x</div>`},
+		{"unclosed css block", `<style>@media screen { div { color: red</style><div>x</div>`},
+		{"deeply nested media", "<style>" + strings.Repeat("@media screen{", 5000) + "div{color:red}" + "</style><div>x</div>"},
+		{"long descendant selector", "<style>section " + strings.Repeat("div ", 31) + "{color:red}</style>" + strings.Repeat("<div>", 2000) + "x"},
+		{"long sibling selector", "<style>section " + strings.Repeat("p ~ ", 31) + "p{color:red}</style>" + strings.Repeat("<p>x</p>", 2000)},
+		{"selector explosion", "<style>" + strings.Repeat("div ", 2000) + "{color:red}</style>" + strings.Repeat("<div>", 500) + "x"},
+		{"bad colors", `<div style="color:#zzz;background:rgb(999,-1,NaN);border-color:hsl(1e308,200%,-5%)">x</div>`},
+		{"bad calc", `<div style="width:calc(100% / 0);height:calc(((;margin:calc(1px*1e308)">x</div>`},
+		{"flex without room", `<div style="display:flex;width:0">` + strings.Repeat(`<div style="flex:1 1 0;min-width:100px">x</div>`, 200) + `</div>`},
+		{"flex negative grow", `<div style="display:flex"><div style="flex:-1 -1 -100px">a</div><div style="flex-grow:1e308">b</div></div>`},
+		{"grid explosion", `<div style="display:grid;grid-template-columns:repeat(100000,1fr);gap:1e308px"><div>a</div></div>`},
+		{"grid bad template", `<div style="display:grid;grid-template-columns:repeat(-1, ;grid-column:span -5">x</div>`},
+		{"table spans", `<table><tr><td colspan="1000000" rowspan="-1">a</td><td colspan="0">b</td></tr></table>`},
+		{"empty table", `<table><tr></tr><tbody></tbody></table>`},
+		{"positioned far away", `<div style="position:absolute;left:-1e308px;top:1e308px;width:10px">x</div><div style="position:fixed;inset:0">y</div>`},
+		{"float storm", strings.Repeat(`<div style="float:left;width:100%">x</div><div style="float:right;width:1e9px">y</div>`, 500)},
+		{"list start overflow", `<ol start="-9223372036854775808"><li>a</li></ol><ol start="99999999999999999999"><li>b</li></ol>`},
+		{"list roman huge", `<ol style="list-style-type:upper-roman" start="2147483647"><li>a</li><li>b</li></ol>`},
+		{"broken images", `<img src="data:image/png;base64,!!!"><img src="data:image/png;base64,iVBORw0KGgo="><img width="-5" height="1e9" src="x.png">`},
+		{"broken background", `<div style="background-image:url(data:,);background-size:0 0;height:10px"></div><div style="background:url(</div>`},
+		{"form controls", `<input value="` + strings.Repeat("x", 10000) + `"><textarea rows="-1" cols="1e9"></textarea><select><option></select>`},
+		{"control characters", "<p>\x00\x01‮�\U0001F600​\t\r\n</p>"},
+		{"white-space pre", `<pre style="white-space:pre">` + strings.Repeat("\n", 5000) + `</pre>`},
+		{"transform and overflow", `<div style="overflow:hidden;transform:scale(1e308) rotate(NaNdeg);width:10px;height:10px">x</div>`},
+		{"border radius", `<div style="border-radius:1e308px;border:5px solid;width:10px;height:10px"></div>`},
+		{"frameset", `<frameset><frame src="x"></frameset>`},
+		{"svg and template", `<svg><foreignObject><div>x</div></foreignObject></svg><template><div>y</div></template>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderMustNotPanic(t, tt.html)
+		})
+	}
+}
+
+func TestMatchSelector(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id="root" class="page">
+		<section><p id="a">a</p><p id="b" class="note">b</p><span id="c">c</span><p id="d">d</p></section>
+		<article><div><p id="e">e</p></div></article>
+	</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := func(id string) *html.Node {
+		var found *html.Node
+		var walk func(*html.Node)
+		walk = func(node *html.Node) {
+			if v, _ := getAttr(node, "id"); node.Type == html.ElementNode && v == id {
+				found = node
+			}
+			for child := node.FirstChild; child != nil && found == nil; child = child.NextSibling {
+				walk(child)
+			}
+		}
+		walk(doc)
+		return found
+	}
+
+	tests := []struct {
+		selector string
+		id       string
+		want     bool
+	}{
+		{"p", "a", true},
+		{".page p", "e", true},
+		{"section p", "e", false},
+		{"div > p", "e", true},
+		{"article > p", "e", false},
+		{"#root article div p", "e", true},
+		{"#root section div p", "e", false},
+		{"p + p", "b", true},
+		{"p + p", "d", false},
+		{"p + span", "c", true},
+		{".note ~ p", "d", true},
+		{".note ~ p", "a", false},
+		{"section .note ~ span + p", "d", true},
+		{"article .note ~ p", "d", false},
+	}
+	// 共用同一个缓存，与渲染时一样，失败查找的记录不能影响其他元素的匹配结果
+	cache := selectorCache{}
+	for _, tt := range tests {
+		selector, ok := parseSelector(tt.selector)
+		if !ok {
+			t.Fatalf("parseSelector(%q) failed", tt.selector)
+		}
+		for _, c := range []selectorCache{nil, cache} {
+			if got := matchSelector(selector, byID(tt.id), c); got != tt.want {
+				t.Errorf("matchSelector(%q, #%s) = %v, want %v", tt.selector, tt.id, got, tt.want)
+			}
+		}
+	}
+
+	if _, ok := parseSelector(strings.Repeat("div ", maxSelectorCompounds+1)); ok {
+		t.Error("selectors longer than the limit should be rejected")
+	}
+}
+
+// TestRenderMutatedHTML 随机删除和打乱片段后的HTML也不能导致渲染失败
+func TestRenderMutatedHTML(t *testing.T) {
+	fragments := []string{
+		"<div>", "</div>", "<span>", "</span>", "<p>", "<table>", "<tr>", "<td colspan=3>", "<ul>", "<li>",
+		`<div style="display:flex">`, `<div style="display:grid;grid-template-columns:1fr 2fr">`,
+		`<div style="float:left;width:50%">`, `<div style="position:absolute;top:10px">`,
+		`<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">`, "<br>", "<input>", "text ", "word ",
+		`<style>div{margin:-10px;padding:5%}</style>`, `<span style="font-size:200px">`,
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		var b strings.Builder
+		for j := rng.Intn(200); j >= 0; j-- {
+			b.WriteString(fragments[rng.Intn(len(fragments))])
+		}
+		htmlContent := b.String()
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			renderMustNotPanic(t, htmlContent)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// 缩略图渲染的文字排版。使用内置的 Go 字体，字体中没有的字符（如中文）绘制为灰色方块
+
+// 字体变体
+const (
+	fontRegular = iota
+	fontBold
+	fontItalic
+	fontBoldItalic
+	fontMono
+	fontMonoBold
+	fontVariantCount
+)
+
+var (
+	renderFontsOnce sync.Once
+	renderFonts     [fontVariantCount]*opentype.Font
+	renderFontsErr  error
+)
+
+// loadRenderFonts 解析内置字体，只在第一次渲染时执行
+func loadRenderFonts() error {
+	renderFontsOnce.Do(func() {
+		sources := [fontVariantCount][]byte{
+			goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF, gomono.TTF, gomonobold.TTF,
+		}
+		for i, src := range sources {
+			renderFonts[i], renderFontsErr = opentype.Parse(src)
+			if renderFontsErr != nil {
+				return
+			}
+		}
+	})
+	return renderFontsErr
+}
+
+// fontVariant 根据样式选择字体
+func fontVariant(style *computedStyle) int {
+	bold := style.fontWeight >= 600
+	switch {
+	case style.monospace && bold:
+		return fontMonoBold
+	case style.monospace:
+		return fontMono
+	case bold && style.italic:
+		return fontBoldItalic
+	case bold:
+		return fontBold
+	case style.italic:
+		return fontItalic
+	}
+	return fontRegular
+}
+
+type faceKey struct {
+	variant int
+	size    float64
+}
+
+// textShaper 文字测量和绘制，字体 Face 不能并发使用，每次渲染创建一个
+type textShaper struct {
+	faces  map[faceKey]font.Face
+	glyphs [fontVariantCount]map[rune]bool
+	buf    sfnt.Buffer
+}
+
+func newTextShaper() *textShaper {
+	return &textShaper{faces: make(map[faceKey]font.Face)}
+}
+
+// face 返回样式对应的字体 Face，字号按 0.5 像素取整以复用缓存
+func (ts *textShaper) face(style *computedStyle) font.Face {
+	key := faceKey{variant: fontVariant(style), size: math.Max(1, math.Round(style.fontSize*2)/2)}
+	if face, ok := ts.faces[key]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(renderFonts[key.variant], &opentype.FaceOptions{
+		Size:    key.size,
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+	if err != nil {
+		return nil
+	}
+	ts.faces[key] = face
+	return face
+}
+
+// hasGlyph 判断字体中是否有该字符
+func (ts *textShaper) hasGlyph(variant int, r rune) bool {
+	if ts.glyphs[variant] == nil {
+		ts.glyphs[variant] = make(map[rune]bool)
+	}
+	if ok, cached := ts.glyphs[variant][r]; cached {
+		return ok
+	}
+	index, err := renderFonts[variant].GlyphIndex(&ts.buf, r)
+	ok := err == nil && index != 0
+	ts.glyphs[variant][r] = ok
+	return ok
+}
+
+// metrics 返回字体的上升和下降高度
+func (ts *textShaper) metrics(style *computedStyle) (ascent, descent float64) {
+	face := ts.face(style)
+	if face == nil {
+		return style.fontSize * 0.8, style.fontSize * 0.2
+	}
+	m := face.Metrics()
+	return fixedToFloat(m.Ascent), fixedToFloat(m.Descent)
+}
+
+// advance 单个字符的宽度，缺失字符按全角或半角估算
+func (ts *textShaper) advance(face font.Face, variant int, r rune, style *computedStyle) float64 {
+	if r == '\t' {
+		r = ' '
+	}
+	if face != nil && ts.hasGlyph(variant, r) {
+		if adv, ok := face.GlyphAdvance(r); ok {
+			return fixedToFloat(adv) + style.letterSpacing
+		}
+	}
+	if isWideRune(r) {
+		return style.fontSize + style.letterSpacing
+	}
+	return style.fontSize*0.6 + style.letterSpacing
+}
+
+// measure 文字宽度
+func (ts *textShaper) measure(text string, style *computedStyle) float64 {
+	face := ts.face(style)
+	variant := fontVariant(style)
+	width := 0.0
+	for _, r := range text {
+		width += ts.advance(face, variant, r, style)
+	}
+	return width
+}
+
+// draw 以 (x, baseline) 为起点绘制文字，clip 之外的部分不绘制
+func (ts *textShaper) draw(dst *image.RGBA, text string, x, baseline float64, style *computedStyle, c color.NRGBA, clip image.Rectangle) {
+	face := ts.face(style)
+	variant := fontVariant(style)
+	src := image.NewUniform(c)
+	for _, r := range text {
+		adv := ts.advance(face, variant, r, style)
+		switch {
+		case unicode.IsSpace(r):
+		case face != nil && ts.hasGlyph(variant, r):
+			dot := fixed.Point26_6{X: floatToFixed(x), Y: floatToFixed(baseline)}
+			dr, mask, maskp, _, ok := face.Glyph(dot, r)
+			if ok {
+				target := dr.Intersect(clip)
+				if !target.Empty() {
+					draw.DrawMask(dst, target, src, image.Point{}, mask, maskp.Add(target.Min.Sub(dr.Min)), draw.Over)
+				}
+			}
+		default:
+			// 缺失字符绘制为半透明方块，保留文字的位置和大致密度
+			block := c
+			block.A = uint8(float64(c.A) * 0.45)
+			size := style.fontSize
+			fillRoundedRect(dst, clip, x+adv*0.08, baseline-size*0.78, adv*0.84-style.letterSpacing, size*0.86, size*0.12, block)
+		}
+		x += adv
+	}
+}
+
+// isWideRune 是否为全角字符（中日韩文字、全角符号和表情）
+func isWideRune(r rune) bool {
+	return r >= 0x1100 && r <= 0x115F ||
+		r >= 0x2E80 && r <= 0xA4CF ||
+		r >= 0xAC00 && r <= 0xD7A3 ||
+		r >= 0xF900 && r <= 0xFAFF ||
+		r >= 0xFE30 && r <= 0xFE4F ||
+		r >= 0xFF00 && r <= 0xFF60 ||
+		r >= 0xFFE0 && r <= 0xFFE6 ||
+		r >= 0x1F300 && r <= 0x1FAFF ||
+		r >= 0x20000 && r <= 0x3FFFD
+}
+
+// isBreakableRune 中日文字符之间不需要空格即可换行
+func isBreakableRune(r rune) bool {
+	return isWideRune(r) && !(r >= 0xAC00 && r <= 0xD7A3)
+}
+
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
+
+func floatToFixed(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(v * 64))
+}
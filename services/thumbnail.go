@@ -1,13 +1,51 @@
 package services
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"regexp"
+	"image"
+	"image/png"
+	"io"
+	"net/url"
 	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/oldweipro/design-ai/models"
+	"golang.org/x/image/draw"
+)
+
+// 缩略图渲染的视口尺寸，各尺寸的缩略图由同一次渲染结果缩放得到
+const (
+	thumbnailViewportWidth  = 1280
+	thumbnailViewportHeight = 800
+)
+
+// 缩略图格式
+const (
+	ThumbnailFormatPNG  = "png"
+	ThumbnailFormatWebP = "webp"
 )
 
+// ErrUnsupportedThumbnailFormat 不支持的缩略图格式
+var ErrUnsupportedThumbnailFormat = errors.New("unsupported thumbnail format")
+
+// ThumbnailSize 缩略图尺寸预设
+type ThumbnailSize struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// 支持的缩略图尺寸，宽高比与渲染视口一致
+var thumbnailSizes = map[string]ThumbnailSize{
+	"small":  {Name: "small", Width: 320, Height: 200},
+	"medium": {Name: "medium", Width: 640, Height: 400},
+	"large":  {Name: "large", Width: 1280, Height: 800},
+}
+
 // ThumbnailService 缩略图服务
 type ThumbnailService struct{}
 
@@ -16,134 +54,123 @@ func NewThumbnailService() *ThumbnailService {
 	return &ThumbnailService{}
 }
 
-// GenerateHTMLThumbnail 从HTML内容生成缩略图
-func (ts *ThumbnailService) GenerateHTMLThumbnail(htmlContent string) string {
+// SummaryThumbnail 根据HTML内容摘要生成SVG缩略图，用作渲染完成前的占位和渲染失败时的兜底
+func (ts *ThumbnailService) SummaryThumbnail(htmlContent string) string {
 	// 提取HTML内容的关键信息来生成缩略图
-	summary := ts.analyzeHTML(htmlContent)
+	summary := AnalyzeHTML(htmlContent)
 
 	// 生成基于内容的SVG缩略图
 	return ts.generateSVGThumbnail(summary)
 }
 
-// HTMLSummary HTML内容摘要
-type HTMLSummary struct {
-	HasImages    bool
-	HasText      bool
-	HasColors    bool
-	HasTables    bool
-	HasForms     bool
-	HasCanvas    bool
-	ElementCount int
-	TextLength   int
-	Colors       []string
-	Title        string
+// Size 查找缩略图尺寸预设
+func (ts *ThumbnailService) Size(name string) (ThumbnailSize, bool) {
+	size, ok := thumbnailSizes[strings.ToLower(name)]
+	return size, ok
 }
 
-// analyzeHTML 分析HTML内容
-func (ts *ThumbnailService) analyzeHTML(html string) HTMLSummary {
-	summary := HTMLSummary{}
-
-	// 清理HTML并转换为小写以便分析
-	cleanHTML := strings.ToLower(html)
-
-	// 计算元素数量
-	elementRegex := regexp.MustCompile(`<[a-zA-Z][^>]*>`)
-	elements := elementRegex.FindAllString(cleanHTML, -1)
-	summary.ElementCount = len(elements)
-
-	// 检查是否包含图片
-	summary.HasImages = strings.Contains(cleanHTML, "<img") || strings.Contains(cleanHTML, "background-image")
-
-	// 检查是否包含文本内容
-	textRegex := regexp.MustCompile(`>[^<]+<`)
-	textMatches := textRegex.FindAllString(cleanHTML, -1)
-	summary.HasText = len(textMatches) > 0
+// Render 以缩略图视口渲染HTML，loader 用于读取HTML引用的图片和样式表，为 nil 时只使用内联资源
+func (ts *ThumbnailService) Render(htmlContent string, loader ResourceLoader) (*image.RGBA, error) {
+	return renderHTML(htmlContent, thumbnailViewportWidth, thumbnailViewportHeight, loader)
+}
 
-	// 计算文本长度
-	for _, match := range textMatches {
-		content := strings.Trim(match, "><")
-		summary.TextLength += len(strings.TrimSpace(content))
+// RenderThumbnail 渲染HTML并编码为指定尺寸和格式的缩略图，返回图片数据和内容类型
+func (ts *ThumbnailService) RenderThumbnail(htmlContent string, size ThumbnailSize, format string, loader ResourceLoader) ([]byte, string, error) {
+	if format != ThumbnailFormatPNG && format != ThumbnailFormatWebP {
+		return nil, "", ErrUnsupportedThumbnailFormat
 	}
-
-	// 检查是否包含表格
-	summary.HasTables = strings.Contains(cleanHTML, "<table")
-
-	// 检查是否包含表单
-	summary.HasForms = strings.Contains(cleanHTML, "<form") || strings.Contains(cleanHTML, "<input")
-
-	// 检查是否包含Canvas
-	summary.HasCanvas = strings.Contains(cleanHTML, "<canvas")
-
-	// 提取颜色信息
-	colorRegex := regexp.MustCompile(`#[0-9a-fA-F]{3,6}|rgb\([^)]+\)|rgba\([^)]+\)`)
-	colors := colorRegex.FindAllString(html, -1)
-	summary.Colors = ts.uniqueColors(colors)
-	summary.HasColors = len(summary.Colors) > 0
-
-	// 尝试提取标题
-	titleRegex := regexp.MustCompile(`<title[^>]*>([^<]+)</title>`)
-	if matches := titleRegex.FindStringSubmatch(html); len(matches) > 1 {
-		summary.Title = strings.TrimSpace(matches[1])
+	rendered, err := ts.Render(htmlContent, loader)
+	if err != nil {
+		return nil, "", err
 	}
+	return ts.Encode(rendered, size, format)
+}
 
-	// 如果没有title标签，尝试提取h1标签
-	if summary.Title == "" {
-		h1Regex := regexp.MustCompile(`<h1[^>]*>([^<]+)</h1>`)
-		if matches := h1Regex.FindStringSubmatch(html); len(matches) > 1 {
-			summary.Title = strings.TrimSpace(matches[1])
+// AssetLoader 创建读取版本资源文件的加载器，HTML中的相对地址按版本根目录解析，外部地址不加载
+func (ts *ThumbnailService) AssetLoader(assets []models.VersionAsset) ResourceLoader {
+	fileIDs := make(map[string]string, len(assets))
+	for _, asset := range assets {
+		fileIDs[asset.Path] = asset.FileObjectID
+	}
+	return func(ref string) ([]byte, error) {
+		parsed, err := url.Parse(strings.TrimSpace(ref))
+		if err != nil || parsed.Scheme != "" || parsed.Host != "" {
+			return nil, fmt.Errorf("external resource %q is not loaded", ref)
+		}
+		assetPath, err := models.NormalizeAssetPath(strings.TrimPrefix(parsed.Path, "/"))
+		if err != nil {
+			return nil, err
 		}
+		fileID, ok := fileIDs[assetPath]
+		if !ok {
+			return nil, fmt.Errorf("asset %q not found", assetPath)
+		}
+		reader, _, err := NewMinIOService().OpenFile(fileID)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(io.LimitReader(reader, models.MaxVersionAssetSize))
 	}
-
-	return summary
 }
 
-// uniqueColors 去重颜色数组
-func (ts *ThumbnailService) uniqueColors(colors []string) []string {
-	seen := make(map[string]bool)
-	result := []string{}
+// Encode 将渲染结果缩放到指定尺寸并编码
+func (ts *ThumbnailService) Encode(rendered image.Image, size ThumbnailSize, format string) ([]byte, string, error) {
+	var scaled image.Image = rendered
+	if bounds := rendered.Bounds(); bounds.Dx() != size.Width || bounds.Dy() != size.Height {
+		dst := image.NewRGBA(image.Rect(0, 0, size.Width, size.Height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), rendered, bounds, draw.Src, nil)
+		scaled = dst
+	}
 
-	for _, color := range colors {
-		if !seen[color] && len(result) < 5 { // 最多保留5种颜色
-			seen[color] = true
-			result = append(result, color)
+	var buf bytes.Buffer
+	switch format {
+	case ThumbnailFormatPNG:
+		if err := png.Encode(&buf, scaled); err != nil {
+			return nil, "", err
 		}
+		return buf.Bytes(), "image/png", nil
+	case ThumbnailFormatWebP:
+		if err := nativewebp.Encode(&buf, scaled, nil); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/webp", nil
 	}
-
-	return result
+	return nil, "", ErrUnsupportedThumbnailFormat
 }
 
 // generateSVGThumbnail 生成SVG缩略图
-func (ts *ThumbnailService) generateSVGThumbnail(summary HTMLSummary) string {
+func (ts *ThumbnailService) generateSVGThumbnail(summary models.HTMLSummary) string {
 	width := 300
 	height := 200
 
 	// 根据内容类型选择背景色
 	backgroundColor := "#f8f9fa"
-	if summary.HasCanvas {
+	if summary.CanvasCount > 0 {
 		backgroundColor = "#2c3e50"
-	} else if summary.HasForms {
+	} else if summary.FormCount > 0 || summary.Interactive.Inputs > 0 {
 		backgroundColor = "#e3f2fd"
-	} else if summary.HasTables {
+	} else if summary.TableCount > 0 {
 		backgroundColor = "#fff3e0"
-	} else if summary.HasImages {
+	} else if summary.ImageCount > 0 {
 		backgroundColor = "#f3e5f5"
 	}
 
 	// 生成内容标识
 	var contentIcons []string
-	if summary.HasImages {
+	if summary.ImageCount > 0 {
 		contentIcons = append(contentIcons, "🖼️")
 	}
-	if summary.HasText {
+	if summary.TextLength > 0 {
 		contentIcons = append(contentIcons, "📝")
 	}
-	if summary.HasTables {
+	if summary.TableCount > 0 {
 		contentIcons = append(contentIcons, "📊")
 	}
-	if summary.HasForms {
+	if summary.FormCount > 0 || summary.Interactive.Inputs > 0 {
 		contentIcons = append(contentIcons, "📋")
 	}
-	if summary.HasCanvas {
+	if summary.CanvasCount > 0 {
 		contentIcons = append(contentIcons, "🎨")
 	}
 
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"gorm.io/gorm"
+)
+
+// 缩略图任务队列的默认参数
+const (
+	defaultThumbnailWorkers   = 2
+	thumbnailPollInterval     = 2 * time.Second
+	thumbnailRetryBaseBackoff = 10 * time.Second
+	thumbnailRetryMaxBackoff  = 10 * time.Minute
+)
+
+// errThumbnailJobObsolete 版本已删除或内容已变化，任务不需要再执行
+var errThumbnailJobObsolete = errors.New("thumbnail job is obsolete")
+
+// ThumbnailQueue 基于数据库的缩略图任务队列，保存版本后入队，由后台工作协程异步生成缩略图
+type ThumbnailQueue struct {
+	wake chan struct{}
+}
+
+// NewThumbnailQueue 创建缩略图任务队列
+func NewThumbnailQueue() *ThumbnailQueue {
+	return &ThumbnailQueue{wake: make(chan struct{}, 1)}
+}
+
+// Enqueue 为版本当前内容创建缩略图任务。版本已有等待中的任务时复用该任务，避免重复渲染。
+// 可以在保存版本的事务中调用，任务与版本一起提交
+func (q *ThumbnailQueue) Enqueue(tx *gorm.DB, versionID, contentHash, reason string) error {
+	if contentHash == "" {
+		return nil
+	}
+	now := time.Now()
+	result := tx.Model(&models.ThumbnailJob{}).
+		Where("version_id = ? AND status = ?", versionID, models.ThumbnailJobPending).
+		Updates(map[string]interface{}{
+			"content_hash": contentHash,
+			"reason":       reason,
+			"attempts":     0,
+			"last_error":   "",
+			"run_at":       now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	return tx.Create(&models.ThumbnailJob{
+		VersionID:   versionID,
+		ContentHash: contentHash,
+		Reason:      reason,
+		Status:      models.ThumbnailJobPending,
+		MaxAttempts: models.ThumbnailJobMaxAttempts,
+		RunAt:       now,
+	}).Error
+}
+
+// EnqueueAll 为所有版本创建重新生成缩略图的任务，返回入队的版本数量
+func (q *ThumbnailQueue) EnqueueAll() (int, error) {
+	return q.enqueueVersions(database.GetDB().Model(&models.PortfolioVersion{}), models.ThumbnailJobReasonRegenerate)
+}
+
+// EnqueueUnanalyzed 为尚未生成内容摘要且没有待执行任务的版本创建任务，用于补充升级前保存的版本
+func (q *ThumbnailQueue) EnqueueUnanalyzed() (int, error) {
+	query := database.GetDB().Model(&models.PortfolioVersion{}).
+		Where("content_summary IS NULL OR content_summary = ''").
+		Where("NOT EXISTS (SELECT 1 FROM thumbnail_jobs WHERE thumbnail_jobs.version_id = portfolio_versions.id AND thumbnail_jobs.status IN ?)",
+			[]string{models.ThumbnailJobPending, models.ThumbnailJobRunning})
+	return q.enqueueVersions(query, models.ThumbnailJobReasonBackfill)
+}
+
+// enqueueVersions 为查询到的版本创建任务，返回入队的版本数量
+func (q *ThumbnailQueue) enqueueVersions(query *gorm.DB, reason string) (int, error) {
+	var versions []struct {
+		ID          string
+		ContentHash string
+	}
+	if err := query.
+		Select("id, content_hash").
+		Where("content_hash IS NOT NULL AND content_hash <> ''").
+		Scan(&versions).Error; err != nil {
+		return 0, err
+	}
+
+	// 分批提交，避免长时间占用数据库写锁
+	const batchSize = 200
+	db := database.GetDB()
+	count := 0
+	for start := 0; start < len(versions); start += batchSize {
+		batch := versions[start:min(start+batchSize, len(versions))]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, version := range batch {
+				if err := q.Enqueue(tx, version.ID, version.ContentHash, reason); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+		count += len(batch)
+		q.Notify()
+	}
+	return count, nil
+}
+
+// Retry 重新执行已放弃的任务
+func (q *ThumbnailQueue) Retry(jobID string) error {
+	result := database.GetDB().Model(&models.ThumbnailJob{}).
+		Where("id = ? AND status = ?", jobID, models.ThumbnailJobDead).
+		Updates(map[string]interface{}{
+			"status":      models.ThumbnailJobPending,
+			"attempts":    0,
+			"run_at":      time.Now(),
+			"finished_at": nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	q.Notify()
+	return nil
+}
+
+// Stats 统计各状态的任务数量
+func (q *ThumbnailQueue) Stats() (models.ThumbnailJobStats, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := database.GetDB().Model(&models.ThumbnailJob{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	stats := models.ThumbnailJobStats{
+		models.ThumbnailJobPending:   0,
+		models.ThumbnailJobRunning:   0,
+		models.ThumbnailJobSucceeded: 0,
+		models.ThumbnailJobCanceled:  0,
+		models.ThumbnailJobDead:      0,
+	}
+	for _, row := range rows {
+		stats[row.Status] = row.Count
+	}
+	return stats, nil
+}
+
+// Notify 唤醒空闲的工作协程，在入队的事务提交后调用可以让任务立即执行
+func (q *ThumbnailQueue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start 启动后台工作协程，数量由 THUMBNAIL_WORKERS 环境变量配置
+func (q *ThumbnailQueue) Start() {
+	workers := thumbnailWorkerCount()
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	log.Printf("Thumbnail queue started with %d workers", workers)
+}
+
+// work 工作协程：依次领取并执行到期的任务，队列为空时等待唤醒或轮询
+func (q *ThumbnailQueue) work() {
+	ticker := time.NewTicker(thumbnailPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := q.claim()
+		if err != nil {
+			log.Printf("Failed to claim thumbnail job: %v", err)
+		}
+		if job != nil {
+			q.process(job)
+			continue
+		}
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim 领取一个到期的任务。先查询候选任务，再以条件更新抢占，多个工作协程不会领取同一任务。
+// 租约过期的执行中任务同样可以领取，重试次数已用尽时不再执行而是直接放弃
+func (q *ThumbnailQueue) claim() (*models.ThumbnailJob, error) {
+	db := database.GetDB()
+	now := time.Now()
+	due := db.Where("status = ? AND run_at <= ?", models.ThumbnailJobPending, now).
+		Or("status = ? AND started_at < ?", models.ThumbnailJobRunning, now.Add(-models.ThumbnailJobLease))
+
+	for {
+		var job models.ThumbnailJob
+		if err := db.Where(due).Order("run_at ASC").First(&job).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		// 最后一次执行没有在租约内结束（例如渲染导致进程退出），重新执行很可能再次失败
+		if job.Status == models.ThumbnailJobRunning && job.Attempts >= job.MaxAttempts {
+			if err := q.abandon(&job, now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		result := db.Model(&models.ThumbnailJob{}).
+			Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+			Updates(map[string]interface{}{
+				"status":     models.ThumbnailJobRunning,
+				"attempts":   job.Attempts + 1,
+				"started_at": now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 被其他工作协程抢先领取，下一轮继续
+			return nil, nil
+		}
+		job.Status = models.ThumbnailJobRunning
+		job.Attempts++
+		job.StartedAt = &now
+		return &job, nil
+	}
+}
+
+// abandon 将租约过期且重试次数已用尽的任务转为放弃状态并使用摘要缩略图
+func (q *ThumbnailQueue) abandon(job *models.ThumbnailJob, now time.Time) error {
+	result := database.GetDB().Model(&models.ThumbnailJob{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, models.ThumbnailJobRunning, job.Attempts).
+		Updates(map[string]interface{}{
+			"status":      models.ThumbnailJobDead,
+			"finished_at": now,
+			"last_error":  "lease expired on the final attempt",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Thumbnail job %s for version %s failed permanently: lease expired on attempt %d/%d", job.ID, job.VersionID, job.Attempts, job.MaxAttempts)
+		q.applyFallback(job)
+	}
+	return nil
+}
+
+// process 执行任务并记录结果：失败时按指数退避重试，重试次数用尽后转为放弃状态并使用摘要缩略图
+func (q *ThumbnailQueue) process(job *models.ThumbnailJob) {
+	db := database.GetDB()
+	err := q.run(job)
+	now := time.Now()
+
+	updates := map[string]interface{}{"finished_at": now, "last_error": ""}
+	switch {
+	case err == nil:
+		updates["status"] = models.ThumbnailJobSucceeded
+	case errors.Is(err, errThumbnailJobObsolete):
+		updates["status"] = models.ThumbnailJobCanceled
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("Thumbnail job %s for version %s failed permanently: %v", job.ID, job.VersionID, err)
+		updates["status"] = models.ThumbnailJobDead
+		updates["last_error"] = err.Error()
+		q.applyFallback(job)
+	default:
+		log.Printf("Thumbnail job %s for version %s failed (attempt %d/%d): %v", job.ID, job.VersionID, job.Attempts, job.MaxAttempts, err)
+		updates["status"] = models.ThumbnailJobPending
+		updates["last_error"] = err.Error()
+		updates["run_at"] = now.Add(thumbnailRetryBackoff(job.Attempts))
+		updates["finished_at"] = nil
+	}
+
+	// 执行超过租约时长的任务可能已被其他工作协程重新领取，此时以新的执行结果为准
+	if err := db.Model(&models.ThumbnailJob{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, models.ThumbnailJobRunning, job.Attempts).
+		Updates(updates).Error; err != nil {
+		log.Printf("Failed to update thumbnail job %s: %v", job.ID, err)
+	}
+}
+
+// run 分析并渲染版本内容，写入内容摘要和缩略图，只在版本内容仍与任务一致时写入
+func (q *ThumbnailQueue) run(job *models.ThumbnailJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("thumbnail job panicked: %v", r)
+		}
+	}()
+
+	db := database.GetDB()
+	var version models.PortfolioVersion
+	if err := db.Where("id = ?", job.VersionID).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errThumbnailJobObsolete
+		}
+		return err
+	}
+	if version.ContentHash != job.ContentHash {
+		return errThumbnailJobObsolete
+	}
+	if err := version.LoadContent(db); err != nil {
+		return err
+	}
+
+	// 内容分析不依赖渲染结果，先保存，渲染失败时搜索和元数据仍然可用
+	if err := SaveVersionSummary(version.ID, job.ContentHash, AnalyzeHTML(version.HTMLContent)); err != nil {
+		return err
+	}
+
+	var assets []models.VersionAsset
+	if err := db.Where("version_id = ?", version.ID).Find(&assets).Error; err != nil {
+		return err
+	}
+	var loader ResourceLoader
+	if len(assets) > 0 && NewMinIOService().GetActiveConfig() != nil {
+		loader = ThumbnailSvc.AssetLoader(assets)
+	}
+
+	data, contentType, err := ThumbnailSvc.RenderThumbnail(version.HTMLContent, thumbnailSizes["small"], ThumbnailFormatWebP, loader)
+	if err != nil {
+		return err
+	}
+	return ThumbnailSvc.SaveVersionThumbnail(version.ID, job.ContentHash, data, contentType)
+}
+
+// applyFallback 渲染彻底失败时以内容摘要作为版本缩略图，保存方式与渲染结果相同
+func (q *ThumbnailQueue) applyFallback(job *models.ThumbnailJob) {
+	var version models.PortfolioVersion
+	db := database.GetDB()
+	if err := db.Where("id = ?", job.VersionID).First(&version).Error; err != nil {
+		return
+	}
+	if err := version.LoadContent(db); err != nil {
+		log.Printf("Failed to load content for version %s: %v", job.VersionID, err)
+		return
+	}
+	_, data, err := parseThumbnailDataURL(ThumbnailSvc.SummaryThumbnail(version.HTMLContent))
+	if err == nil {
+		err = ThumbnailSvc.SaveVersionThumbnail(job.VersionID, job.ContentHash, data, "image/svg+xml")
+	}
+	if err != nil && !errors.Is(err, errThumbnailJobObsolete) {
+		log.Printf("Failed to store fallback thumbnail for version %s: %v", job.VersionID, err)
+	}
+}
+
+// thumbnailRetryBackoff 第 attempts 次失败后的重试间隔，按指数增长并设有上限
+func thumbnailRetryBackoff(attempts int) time.Duration {
+	backoff := thumbnailRetryBaseBackoff
+	for i := 1; i < attempts && backoff < thumbnailRetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > thumbnailRetryMaxBackoff {
+		backoff = thumbnailRetryMaxBackoff
+	}
+	return backoff
+}
+
+// thumbnailWorkerCount 读取工作协程数量配置
+func thumbnailWorkerCount() int {
+	if value := strings.TrimSpace(os.Getenv("THUMBNAIL_WORKERS")); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("Warning: invalid THUMBNAIL_WORKERS %q, using %d", value, defaultThumbnailWorkers)
+	}
+	return defaultThumbnailWorkers
+}
+
+// ThumbnailQueueSvc 全局缩略图任务队列
+var ThumbnailQueueSvc = NewThumbnailQueue()
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+)
+
+// thumbnailMigrationBatch 迁移内联缩略图时每批处理的版本数量
+const thumbnailMigrationBatch = 100
+
+// SaveVersionThumbnail 保存版本缩略图：配置了文件存储时写入对象并记录对象ID，否则以 data URL 保存在数据库中。
+// 只在版本内容仍为 contentHash 时写入，内容已变化时返回 errThumbnailJobObsolete
+func (ts *ThumbnailService) SaveVersionThumbnail(versionID, contentHash string, data []byte, contentType string) error {
+	if NewMinIOService().GetActiveConfig() == nil {
+		return ts.updateVersionThumbnail(versionID, contentHash, thumbnailDataURL(data, contentType), "")
+	}
+
+	fileObject, err := ts.uploadThumbnail(versionID, data, contentType)
+	if err != nil {
+		return err
+	}
+	if err := ts.updateVersionThumbnail(versionID, contentHash, "", fileObject.ID); err != nil {
+		ts.ReleaseThumbnailObjects(fileObject.ID)
+		return err
+	}
+	return nil
+}
+
+// uploadThumbnail 将缩略图写入文件存储，文件归属于版本所在作品的所有者和组织
+func (ts *ThumbnailService) uploadThumbnail(versionID string, data []byte, contentType string) (*models.FileObject, error) {
+	var owner struct {
+		UserID         string
+		OrganizationID string
+	}
+	if err := database.GetDB().Table("portfolios").
+		Select("portfolios.user_id, portfolios.organization_id").
+		Joins("JOIN portfolio_versions ON portfolio_versions.portfolio_id = portfolios.id").
+		Where("portfolio_versions.id = ?", versionID).
+		Scan(&owner).Error; err != nil {
+		return nil, err
+	}
+
+	filename := "thumbnail-" + versionID + thumbnailExtension(contentType)
+	return NewMinIOService().UploadReader(bytes.NewReader(data), int64(len(data)), filename, contentType,
+		owner.UserID, owner.OrganizationID, false, map[string]string{
+			"purpose":    "thumbnail",
+			"version-id": versionID,
+		})
+}
+
+// updateVersionThumbnail 条件更新版本缩略图，不更新版本的修改时间。替换下来的对象不再被引用时释放
+func (ts *ThumbnailService) updateVersionThumbnail(versionID, contentHash, inline, objectID string) error {
+	db := database.GetDB()
+
+	var previous string
+	db.Unscoped().Model(&models.PortfolioVersion{}).
+		Where("id = ?", versionID).
+		Pluck("thumbnail_object_id", &previous)
+
+	result := db.Model(&models.PortfolioVersion{}).
+		Where("id = ? AND content_hash = ?", versionID, contentHash).
+		UpdateColumns(map[string]interface{}{
+			"thumbnail":           inline,
+			"thumbnail_object_id": objectID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errThumbnailJobObsolete
+	}
+	if previous != "" && previous != objectID {
+		ts.ReleaseThumbnailObjects(previous)
+	}
+	return nil
+}
+
+// ReleaseThumbnailObjects 将不再被任何版本（包括回收站中的版本）引用的缩略图对象移入回收站
+func (ts *ThumbnailService) ReleaseThumbnailObjects(objectIDs ...string) {
+	db := database.GetDB()
+	minioService := NewMinIOService()
+	for _, id := range objectIDs {
+		if id == "" {
+			continue
+		}
+		var references int64
+		db.Unscoped().Model(&models.PortfolioVersion{}).Where("thumbnail_object_id = ?", id).Count(&references)
+		if references > 0 {
+			continue
+		}
+		if err := minioService.DeleteFile(id); err != nil {
+			log.Printf("Warning: failed to release thumbnail %s: %v", id, err)
+		}
+	}
+}
+
+// MigrateInlineThumbnails 将数据库中以 data URL 保存的缩略图迁移到文件存储，返回迁移的版本数量。
+// 未配置文件存储时不做任何操作，单个版本迁移失败时跳过，下次迁移时重试
+func (ts *ThumbnailService) MigrateInlineThumbnails() (int, error) {
+	if NewMinIOService().GetActiveConfig() == nil {
+		return 0, nil
+	}
+
+	db := database.GetDB()
+	migrated := 0
+	lastID := ""
+	for {
+		var versions []struct {
+			ID        string
+			Thumbnail string
+		}
+		if err := db.Unscoped().Model(&models.PortfolioVersion{}).
+			Select("id, thumbnail").
+			Where("id > ? AND thumbnail LIKE 'data:%' AND (thumbnail_object_id IS NULL OR thumbnail_object_id = '')", lastID).
+			Order("id ASC").
+			Limit(thumbnailMigrationBatch).
+			Scan(&versions).Error; err != nil {
+			return migrated, fmt.Errorf("failed to find inline thumbnails: %w", err)
+		}
+		if len(versions) == 0 {
+			return migrated, nil
+		}
+
+		for _, version := range versions {
+			lastID = version.ID
+			if err := ts.migrateInlineThumbnail(version.ID, version.Thumbnail); err != nil {
+				log.Printf("Warning: failed to migrate thumbnail of version %s: %v", version.ID, err)
+				continue
+			}
+			migrated++
+		}
+	}
+}
+
+// migrateInlineThumbnail 迁移单个版本的内联缩略图，迁移期间缩略图被重新生成时放弃本次迁移
+func (ts *ThumbnailService) migrateInlineThumbnail(versionID, dataURL string) error {
+	contentType, data, err := parseThumbnailDataURL(dataURL)
+	if err != nil {
+		return err
+	}
+	fileObject, err := ts.uploadThumbnail(versionID, data, contentType)
+	if err != nil {
+		return err
+	}
+
+	result := database.GetDB().Unscoped().Model(&models.PortfolioVersion{}).
+		Where("id = ? AND thumbnail = ?", versionID, dataURL).
+		UpdateColumns(map[string]interface{}{
+			"thumbnail":           "",
+			"thumbnail_object_id": fileObject.ID,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		ts.ReleaseThumbnailObjects(fileObject.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		return errThumbnailJobObsolete
+	}
+	return nil
+}
+
+// thumbnailDataURL 将缩略图编码为 data URL
+func thumbnailDataURL(data []byte, contentType string) string {
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// parseThumbnailDataURL 解析 data URL 中的内容类型和数据
+func parseThumbnailDataURL(dataURL string) (string, []byte, error) {
+	comma := strings.Index(dataURL, ",")
+	if !strings.HasPrefix(dataURL, "data:") || comma < 0 {
+		return "", nil, errors.New("invalid data URL")
+	}
+	contentType := strings.TrimSuffix(dataURL[len("data:"):comma], ";base64")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	data := decodeDataURI(dataURL)
+	if len(data) == 0 {
+		return "", nil, errors.New("empty or malformed data URL")
+	}
+	return contentType, data, nil
+}
+
+// thumbnailExtension 缩略图对象的文件扩展名
+func thumbnailExtension(contentType string) string {
+	switch contentType {
+	case "image/webp":
+		return ".webp"
+	case "image/png":
+		return ".png"
+	case "image/svg+xml":
+		return ".svg"
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"gorm.io/gorm"
+)
+
+// TrashRetention 读取管理员设置中的回收站保留时长
+func TrashRetention() time.Duration {
+	var settings models.AdminSettings
+	if err := database.GetDB().First(&settings).Error; err != nil {
+		return time.Duration(models.DefaultTrashRetentionDays) * 24 * time.Hour
+	}
+	return settings.TrashRetention()
+}
+
+// PurgeExpiredTrash 永久删除超过保留期的作品、版本和文件
+func PurgeExpiredTrash() (models.PurgeResult, error) {
+	var result models.PurgeResult
+	db := database.GetDB()
+	cutoff := time.Now().Add(-TrashRetention())
+
+	// 作品：连同其所有版本一起删除
+	var portfolioIDs []string
+	if err := db.Model(&models.Portfolio{}).
+		Where("status = ?", "deleted").
+		Where("trashed_at < ? OR (trashed_at IS NULL AND updated_at < ?)", cutoff, cutoff).
+		Pluck("id", &portfolioIDs).Error; err != nil {
+		return result, fmt.Errorf("failed to find expired portfolios: %w", err)
+	}
+
+	// 被删除版本的缩略图对象，版本删除后释放
+	var thumbnailIDs []string
+	if len(portfolioIDs) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			purged, released, err := PurgePortfolios(tx, portfolioIDs)
+			result.Portfolios = purged
+			thumbnailIDs = released
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to purge portfolios: %w", err)
+		}
+	}
+
+	// 单独删除的版本
+	var versionThumbnailIDs []string
+	db.Unscoped().Model(&models.PortfolioVersion{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND thumbnail_object_id <> ''", cutoff).
+		Pluck("thumbnail_object_id", &versionThumbnailIDs)
+	thumbnailIDs = append(thumbnailIDs, versionThumbnailIDs...)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.PortfolioVersion{}).
+			Select("id").Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+		if err := purgeVersionRecords(tx, expired); err != nil {
+			return err
+		}
+		versions := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&models.PortfolioVersion{})
+		result.Versions = versions.RowsAffected
+		return versions.Error
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to purge versions: %w", err)
+	}
+	ThumbnailSvc.ReleaseThumbnailObjects(thumbnailIDs...)
+
+	// 资源文件：清理早先遗留的、版本已不存在的资源记录，不再被引用的文件对象移入回收站，保留期结束后再删除
+	existingVersions := db.Unscoped().Model(&models.PortfolioVersion{}).Select("id")
+	var orphanFileIDs []string
+	if err := db.Model(&models.VersionAsset{}).
+		Where("version_id NOT IN (?)", existingVersions).
+		Distinct().Pluck("file_object_id", &orphanFileIDs).Error; err != nil {
+		return result, fmt.Errorf("failed to find orphaned assets: %w", err)
+	}
+	if len(orphanFileIDs) > 0 {
+		if err := db.Where("version_id NOT IN (?)", existingVersions).Delete(&models.VersionAsset{}).Error; err != nil {
+			return result, fmt.Errorf("failed to purge assets: %w", err)
+		}
+		if err := db.Where("id IN ? AND id NOT IN (?)", orphanFileIDs, db.Model(&models.VersionAsset{}).Select("file_object_id")).
+			Delete(&models.FileObject{}).Error; err != nil {
+			return result, fmt.Errorf("failed to release asset files: %w", err)
+		}
+	}
+
+	// 文件：需要同时删除MinIO对象
+	var fileIDs []string
+	if err := db.Unscoped().Model(&models.FileObject{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &fileIDs).Error; err != nil {
+		return result, fmt.Errorf("failed to find expired files: %w", err)
+	}
+	minioService := NewMinIOService()
+	for _, id := range fileIDs {
+		if err := minioService.PurgeFile(id); err != nil {
+			log.Printf("Warning: failed to purge file %s: %v", id, err)
+			continue
+		}
+		result.Files++
+	}
+
+	// 内容存储：删除不再被任何版本（包括回收站中的版本）引用的内容
+	blobs := db.Where("hash NOT IN (?)",
+		db.Unscoped().Model(&models.PortfolioVersion{}).Select("content_hash").Where("content_hash IS NOT NULL")).
+		Delete(&models.ContentBlob{})
+	if blobs.Error != nil {
+		return result, fmt.Errorf("failed to purge content blobs: %w", blobs.Error)
+	}
+	result.Blobs = blobs.RowsAffected
+
+	return result, nil
+}
+
+// PurgePortfolios 在事务中永久删除作品及其所有版本，同时删除协作者、转移请求、分享链接，
+// 以及版本的资源记录和缩略图任务。返回删除的作品数量和版本的缩略图对象，缩略图对象由调用方在事务提交后释放
+func PurgePortfolios(tx *gorm.DB, portfolioIDs []string) (int64, []string, error) {
+	if len(portfolioIDs) == 0 {
+		return 0, nil, nil
+	}
+
+	var thumbnailIDs []string
+	if err := tx.Unscoped().Model(&models.PortfolioVersion{}).
+		Where("portfolio_id IN ? AND thumbnail_object_id <> ''", portfolioIDs).
+		Pluck("thumbnail_object_id", &thumbnailIDs).Error; err != nil {
+		return 0, nil, err
+	}
+
+	versionIDs := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.PortfolioVersion{}).
+		Select("id").Where("portfolio_id IN ?", portfolioIDs)
+	if err := purgeVersionRecords(tx, versionIDs); err != nil {
+		return 0, nil, err
+	}
+	for _, model := range []interface{}{&models.PortfolioCollaborator{}, &models.PortfolioTransfer{}, &models.ShareLink{}} {
+		if err := tx.Where("portfolio_id IN ?", portfolioIDs).Delete(model).Error; err != nil {
+			return 0, nil, err
+		}
+	}
+	if err := tx.Unscoped().Where("portfolio_id IN ?", portfolioIDs).Delete(&models.PortfolioVersion{}).Error; err != nil {
+		return 0, nil, err
+	}
+
+	deleted := tx.Where("id IN ?", portfolioIDs).Delete(&models.Portfolio{})
+	return deleted.RowsAffected, thumbnailIDs, deleted.Error
+}
+
+// purgeVersionRecords 删除子查询选出的版本的资源记录和缩略图任务，需要在删除版本之前调用。
+// 不再被其他版本引用的资源文件移入回收站
+func purgeVersionRecords(tx *gorm.DB, versionIDs *gorm.DB) error {
+	var fileIDs []string
+	if err := tx.Model(&models.VersionAsset{}).
+		Where("version_id IN (?)", versionIDs).
+		Distinct().Pluck("file_object_id", &fileIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("version_id IN (?)", versionIDs).Delete(&models.VersionAsset{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("version_id IN (?)", versionIDs).Delete(&models.ThumbnailJob{}).Error; err != nil {
+		return err
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ? AND id NOT IN (?)", fileIDs,
+		tx.Session(&gorm.Session{NewDB: true}).Model(&models.VersionAsset{}).Select("file_object_id")).
+		Delete(&models.FileObject{}).Error
+}
+
+// StartTrashPurger 启动后台回收站清理任务
+func StartTrashPurger(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			result, err := PurgeExpiredTrash()
+			if err != nil {
+				log.Printf("Trash purge failed: %v", err)
+			} else if result.Portfolios+result.Versions+result.Files+result.Blobs > 0 {
+				log.Printf("Trash purged: %d portfolios, %d versions, %d files, %d blobs",
+					result.Portfolios, result.Versions, result.Files, result.Blobs)
+			}
+			<-ticker.C
+		}
+	}()
+}
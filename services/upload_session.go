@@ -0,0 +1,484 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分片上传的错误
+var (
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+	ErrUploadSessionClosed   = errors.New("upload session is no longer active")
+	ErrUploadSessionBusy     = errors.New("upload session is being completed")
+	ErrUploadStorageChanged  = errors.New("storage configuration changed since the upload started")
+	ErrUploadPartNumber      = errors.New("invalid part number")
+	ErrUploadPartSize        = errors.New("part size does not match the expected chunk size")
+	ErrUploadIncomplete      = errors.New("upload has missing parts")
+	ErrUploadTooManyParts    = errors.New("file is too large for resumable upload")
+	ErrUploadQuotaExceeded   = errors.New("organization storage quota exceeded")
+)
+
+// UploadSessionService 可断点续传的分片上传，分片直接转发到 MinIO 的分片上传，
+// 服务端只记录已上传的分片，连接中断后客户端查询缺失的分片继续上传
+type UploadSessionService struct{}
+
+// NewUploadSessionService 创建分片上传服务
+func NewUploadSessionService() *UploadSessionService {
+	return &UploadSessionService{}
+}
+
+// Create 创建上传会话并在 MinIO 中开始分片上传。未指定分片大小时使用默认值，
+// 分片数量超过上限时自动增大分片
+func (us *UploadSessionService) Create(req models.UploadSessionRequest, userID string) (*models.UploadSession, error) {
+	if minioClient == nil || activeConfig == nil {
+		return nil, errors.New("minio client not initialized")
+	}
+
+	chunkSize := req.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = models.DefaultUploadChunkSize
+	}
+	if parts := (req.Size + chunkSize - 1) / chunkSize; parts > models.MaxUploadParts {
+		// 按MB向上取整
+		chunkSize = ((req.Size+models.MaxUploadParts-1)/models.MaxUploadParts + 1<<20 - 1) / (1 << 20) * (1 << 20)
+		if chunkSize > models.MaxUploadChunkSize {
+			return nil, ErrUploadTooManyParts
+		}
+	}
+
+	contentType := normalizeMediaType(req.ContentType)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	fileName := NormalizeUploadName(req.FileName, contentType)
+	tags := make(map[string]string)
+	if req.Category != "" {
+		tags["category"] = req.Category
+	}
+	if req.Purpose != "" {
+		tags["purpose"] = req.Purpose
+	}
+
+	options := minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: make(map[string]string),
+	}
+	for k, v := range tags {
+		options.UserMetadata[k] = v
+	}
+	options.UserMetadata["uploaded-by"] = userID
+	options.UserMetadata["original-name"] = fileName
+
+	objectName := uuid.New().String() + filepath.Ext(fileName)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	core := minio.Core{Client: minioClient}
+	uploadID, err := core.NewMultipartUpload(ctx, activeConfig.BucketName, objectName, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	session := &models.UploadSession{
+		UploadID:       uploadID,
+		ObjectName:     objectName,
+		ConfigID:       activeConfig.ID,
+		FileName:       fileName,
+		ContentType:    contentType,
+		FileSize:       req.Size,
+		ChunkSize:      chunkSize,
+		IsPublic:       req.IsPublic,
+		Tags:           mapToJSON(tags),
+		UploadedBy:     userID,
+		OrganizationID: req.OrganizationID,
+		Status:         models.UploadSessionActive,
+		ExpiresAt:      time.Now().Add(models.UploadSessionTTL),
+	}
+	if err := database.GetDB().Create(session).Error; err != nil {
+		core.AbortMultipartUpload(context.Background(), activeConfig.BucketName, objectName, uploadID)
+		return nil, fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return session, nil
+}
+
+// Get 获取用户的上传会话和已上传的分片
+func (us *UploadSessionService) Get(sessionID, userID string) (*models.UploadSession, []models.UploadPart, error) {
+	db := database.GetDB()
+	var session models.UploadSession
+	if err := db.Where("id = ? AND uploaded_by = ?", sessionID, userID).First(&session).Error; err != nil {
+		return nil, nil, ErrUploadSessionNotFound
+	}
+	var parts []models.UploadPart
+	if err := db.Where("session_id = ?", session.ID).Order("part_number").Find(&parts).Error; err != nil {
+		return nil, nil, err
+	}
+	return &session, parts, nil
+}
+
+// UploadPart 上传一个分片，分片大小必须与会话的分片划分一致。同一分片可以重复上传，以最后一次为准。
+// 每次上传分片都会延长会话的有效期
+func (us *UploadSessionService) UploadPart(sessionID, userID string, partNumber int, reader io.Reader, size int64) (*models.UploadPart, error) {
+	session, _, err := us.Get(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := us.checkActive(session); err != nil {
+		return nil, err
+	}
+	expected := session.PartSize(partNumber)
+	if expected == 0 {
+		return nil, ErrUploadPartNumber
+	}
+	if size != expected {
+		return nil, ErrUploadPartSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	core := minio.Core{Client: minioClient}
+	uploaded, err := core.PutObjectPart(ctx, activeConfig.BucketName, session.ObjectName, session.UploadID,
+		partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload part: %w", err)
+	}
+
+	part := &models.UploadPart{
+		SessionID:  session.ID,
+		PartNumber: partNumber,
+		ETag:       uploaded.ETag,
+		Size:       uploaded.Size,
+	}
+	db := database.GetDB()
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}, {Name: "part_number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"e_tag", "size", "updated_at"}),
+	}).Create(part).Error; err != nil {
+		return nil, fmt.Errorf("failed to save upload part: %w", err)
+	}
+	db.Model(&models.UploadSession{}).Where("id = ? AND status = ?", session.ID, models.UploadSessionActive).
+		Update("expires_at", time.Now().Add(models.UploadSessionTTL))
+	return part, nil
+}
+
+// Complete 合并全部分片并创建文件对象。会话已完成时直接返回之前创建的文件对象，
+// 客户端在完成请求的响应丢失后可以安全重试。
+// 开始合并前以条件更新把会话从 active 转为 completing，多个请求（包括其他服务实例）中只有一个可以合并
+func (us *UploadSessionService) Complete(sessionID, userID string) (*models.FileObject, error) {
+	session, _, err := us.Get(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status == models.UploadSessionCompleted || session.Status == models.UploadSessionCompleting {
+		return us.completedFile(session)
+	}
+	if err := us.checkActive(session); err != nil {
+		return nil, err
+	}
+
+	// 合并期间会话不会过期，进程在合并中退出时由清理任务在有效期结束后取消
+	db := database.GetDB()
+	now := time.Now()
+	claim := db.Model(&models.UploadSession{}).
+		Where("id = ? AND status = ? AND expires_at > ?", session.ID, models.UploadSessionActive, now).
+		Updates(map[string]interface{}{
+			"status":     models.UploadSessionCompleting,
+			"expires_at": now.Add(models.UploadSessionTTL),
+		})
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		// 被其他请求抢先，按其当前状态返回
+		if session, _, err = us.Get(sessionID, userID); err != nil {
+			return nil, err
+		}
+		return us.completedFile(session)
+	}
+	session.Status = models.UploadSessionCompleting
+
+	// 领取后分片不再变化，重新读取分片列表
+	var parts []models.UploadPart
+	if err := db.Where("session_id = ?", session.ID).Order("part_number").Find(&parts).Error; err != nil {
+		us.release(session)
+		return nil, err
+	}
+	if len(parts) != session.TotalParts() {
+		us.release(session)
+		return nil, ErrUploadIncomplete
+	}
+
+	completeParts := make([]minio.CompletePart, len(parts))
+	for i, part := range parts {
+		completeParts[i] = minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	core := minio.Core{Client: minioClient}
+	if _, err := core.CompleteMultipartUpload(ctx, activeConfig.BucketName, session.ObjectName, session.UploadID,
+		completeParts, minio.PutObjectOptions{}); err != nil {
+		us.release(session)
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	// 分片可能乱序上传，合并后读取一次对象计算整个文件的摘要，并按内容检查文件类型
+	digests, err := objectDigestsOf(ctx, session.ObjectName)
+	var contentType, fileName string
+	if err == nil {
+		contentType, fileName, err = CheckUploadType(digests.head, session.FileName, session.ContentType, tagValue(session.Tags, "purpose"))
+	}
+	if err == nil {
+		err = CheckUploadSize(contentType, session.FileSize)
+	}
+	if err != nil {
+		// 合并后的对象无法读取、类型不符或超过该类型的大小上限时删除对象并取消会话
+		(&minioService{}).removeFromMinIO(session.ObjectName)
+		if abortErr := us.abort(session); abortErr != nil {
+			log.Printf("Failed to abort upload session %s: %v", session.ID, abortErr)
+		}
+		return nil, err
+	}
+
+	// 分片上传用于大文件，不做图片处理，按上传内容保存
+	fileObject := &models.FileObject{
+		ID:             uuid.New().String(),
+		OriginalName:   fileName,
+		StoragePath:    session.ObjectName,
+		ContentType:    contentType,
+		FileSize:       session.FileSize,
+		MD5Hash:        digests.md5,
+		SHA256Hash:     digests.sha256,
+		ConfigID:       session.ConfigID,
+		IsPublic:       session.IsPublic,
+		UploadedBy:     session.UploadedBy,
+		OrganizationID: session.OrganizationID,
+		Tags:           session.Tags,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkOrganizationQuota(tx, session.OrganizationID, session.FileSize); err != nil {
+			return err
+		}
+		if err := tx.Create(fileObject).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.UploadSession{}).
+			Where("id = ? AND status = ?", session.ID, models.UploadSessionCompleting).
+			Updates(map[string]interface{}{
+				"status":         models.UploadSessionCompleted,
+				"file_object_id": fileObject.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadSessionClosed
+		}
+		return tx.Where("session_id = ?", session.ID).Delete(&models.UploadPart{}).Error
+	})
+	if err != nil {
+		// 分片已合并，会话无法再次完成
+		(&minioService{}).removeFromMinIO(session.ObjectName)
+		if abortErr := us.abort(session); abortErr != nil {
+			log.Printf("Failed to abort upload session %s: %v", session.ID, abortErr)
+		}
+		return nil, fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	log.Printf("Resumable upload completed. ObjectID: %s, OriginalName: %s", fileObject.ID, session.FileName)
+	return fileObject, nil
+}
+
+// Abort 取消上传会话并释放 MinIO 中已上传的分片，正在合并的会话不能取消
+func (us *UploadSessionService) Abort(sessionID, userID string) error {
+	session, _, err := us.Get(sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if session.Status == models.UploadSessionCompleting {
+		return ErrUploadSessionBusy
+	}
+	if session.Status != models.UploadSessionActive {
+		return ErrUploadSessionClosed
+	}
+	return us.abort(session)
+}
+
+// CleanupExpired 取消超过有效期仍未完成的会话，并删除已结束超过保留时长的会话记录
+func (us *UploadSessionService) CleanupExpired() (int64, error) {
+	db := database.GetDB()
+	var expired []models.UploadSession
+	if err := db.Where("status IN ? AND expires_at < ?", []string{models.UploadSessionActive, models.UploadSessionCompleting}, time.Now()).
+		Find(&expired).Error; err != nil {
+		return 0, fmt.Errorf("failed to find expired upload sessions: %w", err)
+	}
+
+	var aborted int64
+	for i := range expired {
+		if err := us.abort(&expired[i]); err != nil {
+			if !errors.Is(err, ErrUploadSessionClosed) {
+				log.Printf("Failed to abort upload session %s: %v", expired[i].ID, err)
+			}
+		} else {
+			aborted++
+		}
+	}
+
+	cutoff := time.Now().Add(-models.UploadSessionTTL)
+	db.Where("status IN ? AND updated_at < ?", []string{models.UploadSessionCompleted, models.UploadSessionAborted}, cutoff).
+		Delete(&models.UploadSession{})
+	return aborted, nil
+}
+
+// StartUploadCleaner 启动后台任务，定期清理过期的上传会话和未确认的直传文件
+func StartUploadCleaner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			aborted, err := UploadSessionSvc.CleanupExpired()
+			if err != nil {
+				log.Printf("Upload session cleanup failed: %v", err)
+			} else if aborted > 0 {
+				log.Printf("Aborted %d expired upload sessions", aborted)
+			}
+			removed, err := DirectUploadSvc.CleanupPending()
+			if err != nil {
+				log.Printf("Pending upload cleanup failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d unconfirmed direct uploads", removed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// checkActive 检查会话是否可以继续上传
+func (us *UploadSessionService) checkActive(session *models.UploadSession) error {
+	if session.Status != models.UploadSessionActive || time.Now().After(session.ExpiresAt) {
+		return ErrUploadSessionClosed
+	}
+	if minioClient == nil || activeConfig == nil {
+		return errors.New("minio client not initialized")
+	}
+	if activeConfig.ID != session.ConfigID {
+		return ErrUploadStorageChanged
+	}
+	return nil
+}
+
+// completedFile 返回已完成会话生成的文件对象，会话正在合并时返回 ErrUploadSessionBusy
+func (us *UploadSessionService) completedFile(session *models.UploadSession) (*models.FileObject, error) {
+	switch session.Status {
+	case models.UploadSessionCompleting:
+		return nil, ErrUploadSessionBusy
+	case models.UploadSessionCompleted:
+		var fileObject models.FileObject
+		if err := database.GetDB().Where("id = ?", session.FileObjectID).First(&fileObject).Error; err != nil {
+			return nil, ErrUploadSessionClosed
+		}
+		return &fileObject, nil
+	}
+	return nil, ErrUploadSessionClosed
+}
+
+// release 合并未完成时把会话交还为 active，客户端可以补传分片或重试
+func (us *UploadSessionService) release(session *models.UploadSession) {
+	if err := database.GetDB().Model(&models.UploadSession{}).
+		Where("id = ? AND status = ?", session.ID, models.UploadSessionCompleting).
+		Update("status", models.UploadSessionActive).Error; err != nil {
+		log.Printf("Failed to release upload session %s: %v", session.ID, err)
+	}
+}
+
+// abort 以条件更新把会话标记为已取消，再取消 MinIO 中的分片上传。会话状态已被其他请求改变时返回
+// ErrUploadSessionClosed。存储配置已切换或取消分片上传失败时只标记会话，
+// 原存储中未完成的分片由存储自身的生命周期规则清理
+func (us *UploadSessionService) abort(session *models.UploadSession) error {
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.UploadSession{}).
+			Where("id = ? AND status = ?", session.ID, session.Status).
+			Update("status", models.UploadSessionAborted)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadSessionClosed
+		}
+		return tx.Where("session_id = ?", session.ID).Delete(&models.UploadPart{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	session.Status = models.UploadSessionAborted
+
+	if minioClient != nil && activeConfig != nil && activeConfig.ID == session.ConfigID {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		core := minio.Core{Client: minioClient}
+		if err := core.AbortMultipartUpload(ctx, activeConfig.BucketName, session.ObjectName, session.UploadID); err != nil &&
+			minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+			log.Printf("Failed to abort multipart upload for session %s: %v", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkOrganizationQuota 在保存文件记录的事务中检查组织存储配额，
+// 同时完成的多个上传不会都按完成前的用量通过检查
+func checkOrganizationQuota(tx *gorm.DB, organizationID string, size int64) error {
+	if organizationID == "" {
+		return nil
+	}
+	var org models.Organization
+	if err := tx.Select("id, storage_quota").Where("id = ?", organizationID).First(&org).Error; err != nil {
+		return err
+	}
+	if org.StorageQuota <= 0 {
+		return nil
+	}
+	used, _, err := OrganizationStorageUsage(tx, org.ID)
+	if err != nil {
+		return err
+	}
+	if used+size > org.StorageQuota {
+		return ErrUploadQuotaExceeded
+	}
+	return nil
+}
+
+// objectDigestsOf 读取 MinIO 中的对象计算MD5和SHA-256，并保存内容开头的字节
+func objectDigestsOf(ctx context.Context, objectName string) (*objectDigests, error) {
+	object, err := minioClient.GetObject(ctx, activeConfig.BucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	md5Hasher, sha256Hasher, head := md5.New(), sha256.New(), &sniffBuffer{}
+	if _, err := io.Copy(io.MultiWriter(md5Hasher, sha256Hasher, head), object); err != nil {
+		return nil, err
+	}
+	return &objectDigests{
+		md5:    hex.EncodeToString(md5Hasher.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hasher.Sum(nil)),
+		head:   head.data,
+	}, nil
+}
+
+// 全局实例
+var UploadSessionSvc = NewUploadSessionService()
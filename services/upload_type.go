@@ -0,0 +1,238 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/oldweipro/design-ai/database"
+	"github.com/oldweipro/design-ai/models"
+)
+
+// 上传类型校验的错误
+var (
+	ErrUploadTypeMismatch   = errors.New("file content does not match the declared type")
+	ErrUploadTypeNotAllowed = errors.New("file type is not allowed")
+	ErrUploadTooLarge       = errors.New("file exceeds the maximum size for its type")
+)
+
+// mediaTypeAliases 同一类型的不同写法，统一后再比较
+var mediaTypeAliases = map[string]string{
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"image/x-png":                  "image/png",
+	"image/x-icon":                 "image/vnd.microsoft.icon",
+	"application/javascript":       "text/javascript",
+	"application/x-javascript":     "text/javascript",
+	"text/ecmascript":              "text/javascript",
+	"text/xml":                     "application/xml",
+	"audio/wav":                    "audio/wave",
+	"audio/x-wav":                  "audio/wave",
+	"application/x-gzip":           "application/gzip",
+	"application/x-zip-compressed": "application/zip",
+}
+
+// sniffableTypes 能够根据文件内容识别的二进制类型，内容无法识别时不能按扩展名认定为这些类型
+var sniffableTypes = map[string]bool{
+	"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true, "image/bmp": true,
+	"image/vnd.microsoft.icon": true, "application/pdf": true, "application/zip": true, "application/gzip": true,
+	"application/x-rar-compressed": true, "application/wasm": true, "application/ogg": true,
+	"audio/mpeg": true, "audio/wave": true, "audio/aiff": true, "audio/midi": true, "audio/ogg": true,
+	"video/mp4": true, "video/webm": true, "video/avi": true, "font/woff": true, "font/woff2": true,
+	"font/ttf": true, "font/otf": true,
+}
+
+// canonicalExtensions 规范化文件名时各类型使用的扩展名
+var canonicalExtensions = map[string]string{
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+	"image/svg+xml":    ".svg",
+	"text/html":        ".html",
+	"text/css":         ".css",
+	"text/javascript":  ".js",
+	"text/plain":       ".txt",
+	"application/json": ".json",
+	"application/pdf":  ".pdf",
+	"application/zip":  ".zip",
+	"application/xml":  ".xml",
+	"video/mp4":        ".mp4",
+	"audio/mpeg":       ".mp3",
+}
+
+// normalizeMediaType 去掉参数并统一类型的写法，无法解析时返回空字符串
+func normalizeMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+// extensionType 按扩展名推断的类型
+func extensionType(filename string) string {
+	return normalizeMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))))
+}
+
+// isTextType 是否为文本格式的类型
+func isTextType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || contentType == "application/json" ||
+		contentType == "application/xml" || contentType == "image/svg+xml" || strings.HasSuffix(contentType, "+json") ||
+		strings.HasSuffix(contentType, "+xml")
+}
+
+// isZipContainer 以 ZIP 为容器的文档格式，如 docx、xlsx、epub
+func isZipContainer(contentType string) bool {
+	return strings.Contains(contentType, "openxmlformats") || strings.HasPrefix(contentType, "application/vnd.oasis.opendocument") ||
+		strings.HasSuffix(contentType, "+zip") || contentType == "application/java-archive"
+}
+
+// DetectUploadType 根据文件开头的内容判断实际类型。文本内容和无法识别的二进制内容参考扩展名细分，
+// 但扩展名不能把内容认定为可以识别却没有识别出的格式，如内容是文本、扩展名是 .png 时结果为 text/plain
+func DetectUploadType(head []byte, filename string) string {
+	head = head[:min(len(head), sniffLength)]
+	detected := normalizeMediaType(http.DetectContentType(head))
+	byExtension := extensionType(filename)
+	isSVG := bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+
+	switch {
+	case detected == "text/html":
+		// SVG 常以注释开头，会被识别为 HTML
+		if isSVG && byExtension == "image/svg+xml" {
+			return byExtension
+		}
+	case detected == "text/plain" || detected == "application/xml":
+		if isSVG {
+			return "image/svg+xml"
+		}
+		if byExtension != "" && isTextType(byExtension) {
+			return byExtension
+		}
+	case detected == "application/zip":
+		if isZipContainer(byExtension) {
+			return byExtension
+		}
+	case detected == "application/octet-stream":
+		if byExtension != "" && !sniffableTypes[byExtension] && !isTextType(byExtension) {
+			return byExtension
+		}
+	}
+	return detected
+}
+
+// declaredTypeMatches 声明的类型与检测到的类型是否一致。未声明或声明为通用二进制时不比较；
+// 检测结果是通用类型时，只要声明的不是可识别的格式或可执行脚本的类型，就按检测结果保存
+func declaredTypeMatches(declared, detected string) bool {
+	if declared == "" || declared == "application/octet-stream" || declared == detected {
+		return true
+	}
+	if detected == "text/plain" || detected == "application/octet-stream" {
+		active := declared == "text/html" || declared == "image/svg+xml" || declared == "text/javascript" || declared == "application/xml"
+		return !active && !sniffableTypes[declared] && isTextType(declared) == (detected == "text/plain")
+	}
+	return false
+}
+
+// NormalizeUploadName 扩展名与内容类型不一致时替换为该类型的扩展名，扩展名统一为小写
+func NormalizeUploadName(filename, contentType string) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	if ext != "" && extensionType(ext) == contentType {
+		return base + strings.ToLower(ext)
+	}
+	if canonical, ok := canonicalExtensions[contentType]; ok {
+		return base + canonical
+	}
+	if extensions, err := mime.ExtensionsByType(contentType); err == nil && len(extensions) > 0 {
+		return base + extensions[0]
+	}
+	// 无法识别的二进制内容保留未知的扩展名，去掉会被误认为其他类型的扩展名
+	if contentType == "application/octet-stream" && (ext == "" || extensionType(ext) == "") {
+		return base + strings.ToLower(ext)
+	}
+	return base + ".bin"
+}
+
+// AllowedUploadTypes 读取管理员设置中用途允许上传的内容类型，返回 nil 表示不限制
+func AllowedUploadTypes(purpose string) []string {
+	allowed := models.DefaultUploadAllowedTypes
+	var settings models.AdminSettings
+	if err := database.GetDB().First(&settings).Error; err == nil {
+		allowed = settings.AllowedUploadTypes()
+	}
+	if purpose == "" {
+		purpose = "*"
+	}
+	return models.UploadTypesForPurpose(allowed, purpose)
+}
+
+// UploadSizeLimits 读取管理员设置的各内容类型的上传大小上限
+func UploadSizeLimits() map[string]int64 {
+	var settings models.AdminSettings
+	if err := database.GetDB().First(&settings).Error; err != nil {
+		return models.DefaultUploadSizeLimits
+	}
+	return settings.UploadLimits()
+}
+
+// CheckUploadSize 按检测到的实际类型检查大小上限。上传前按声明的类型检查过一次，
+// 声明的类型可能与内容不符，检测出类型后需要再次检查
+func CheckUploadSize(contentType string, size int64) error {
+	if limit := models.UploadSizeLimit(UploadSizeLimits(), contentType); limit > 0 && size > limit {
+		return fmt.Errorf("%w: %s is limited to %d bytes", ErrUploadTooLarge, contentType, limit)
+	}
+	return nil
+}
+
+// CheckDeclaredUploadType 在内容上传之前按声明的类型检查白名单，用于分片上传和直传
+func CheckDeclaredUploadType(declared, purpose string) error {
+	contentType := normalizeMediaType(declared)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if !models.UploadTypeAllowed(AllowedUploadTypes(purpose), contentType) {
+		return fmt.Errorf("%w: %s is not allowed for %s uploads", ErrUploadTypeNotAllowed, contentType, uploadPurposeName(purpose))
+	}
+	return nil
+}
+
+// CheckUploadType 根据文件开头的内容检测实际类型，检查与声明的类型是否一致、是否在用途的白名单中，
+// 返回检测到的类型和规范化扩展名后的文件名
+func CheckUploadType(head []byte, filename, declared, purpose string) (string, string, error) {
+	contentType := DetectUploadType(head, filename)
+	if declaredType := normalizeMediaType(declared); !declaredTypeMatches(declaredType, contentType) {
+		return "", "", fmt.Errorf("%w: content is %s but was declared as %s", ErrUploadTypeMismatch, contentType, declaredType)
+	}
+	if !models.UploadTypeAllowed(AllowedUploadTypes(purpose), contentType) {
+		return "", "", fmt.Errorf("%w: %s is not allowed for %s uploads", ErrUploadTypeNotAllowed, contentType, uploadPurposeName(purpose))
+	}
+	return contentType, NormalizeUploadName(filename, contentType), nil
+}
+
+// uploadPurposeName 错误信息中的用途名称
+func uploadPurposeName(purpose string) string {
+	if purpose == "" || purpose == "*" {
+		return "general"
+	}
+	return purpose
+}
+
+// sniffBuffer 保存写入内容的前 sniffLength 个字节，用于边读取边检测类型
+type sniffBuffer struct {
+	data []byte
+}
+
+func (b *sniffBuffer) Write(p []byte) (int, error) {
+	if n := sniffLength - len(b.data); n > 0 {
+		b.data = append(b.data, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
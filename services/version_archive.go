@@ -0,0 +1,236 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/oldweipro/design-ai/models"
+)
+
+// 压缩包导入错误
+var (
+	ErrArchiveTooLarge     = errors.New("archive exceeds the version size limits")
+	ErrArchiveInvalid      = errors.New("invalid archive")
+	ErrArchiveMissingEntry = errors.New("archive must contain " + models.VersionEntryFile)
+)
+
+// ArchiveFile 压缩包中的资源文件
+type ArchiveFile struct {
+	Path        string
+	ContentType string
+	Data        []byte
+}
+
+// VersionArchive 解析后的版本压缩包
+type VersionArchive struct {
+	HTMLContent string
+	Manifest    *models.VersionManifest // 压缩包中没有元数据文件时为 nil
+	Files       []ArchiveFile
+}
+
+// VersionArchiveService 版本压缩包导入导出服务
+type VersionArchiveService struct{}
+
+// NewVersionArchiveService 创建版本压缩包服务实例
+func NewVersionArchiveService() *VersionArchiveService {
+	return &VersionArchiveService{}
+}
+
+// Read 解析版本压缩包。压缩包中的路径必须在根目录内，文件数和解压后的大小受版本限制约束，
+// 大小按实际解压的字节数计算，不信任压缩包中声明的大小。
+// 所有文件都在同一个顶层目录下时（直接压缩文件夹的常见结果）去掉该目录
+func (as *VersionArchiveService) Read(reader io.ReaderAt, size int64) (*VersionArchive, error) {
+	zr, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrArchiveInvalid, err)
+	}
+
+	// 入口、元数据和资源文件
+	if len(zr.File) > models.MaxVersionAssetCount+2 {
+		return nil, fmt.Errorf("%w: more than %d files", ErrArchiveTooLarge, models.MaxVersionAssetCount)
+	}
+
+	type entry struct {
+		path string
+		file *zip.File
+	}
+	entries := make([]entry, 0, len(zr.File))
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || isArchiveJunk(file.Name) {
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrArchiveInvalid, file.Name)
+		}
+		entryPath, err := models.NormalizeAssetPath(file.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unsafe path %q", ErrArchiveInvalid, file.Name)
+		}
+		entries = append(entries, entry{path: entryPath, file: file})
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, e := range entries {
+		paths = append(paths, e.path)
+	}
+	if prefix := commonArchiveRoot(paths); prefix != "" {
+		for i := range entries {
+			entries[i].path = strings.TrimPrefix(entries[i].path, prefix)
+		}
+	}
+
+	archive := &VersionArchive{}
+	seen := make(map[string]bool, len(entries))
+	var total int64
+	for _, e := range entries {
+		if seen[e.path] {
+			return nil, fmt.Errorf("%w: duplicate path %q", ErrArchiveInvalid, e.path)
+		}
+		seen[e.path] = true
+
+		data, err := readArchiveFile(e.file)
+		if err != nil {
+			return nil, err
+		}
+		total += int64(len(data))
+		if total > models.MaxVersionTotalSize {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, models.MaxVersionTotalSize)
+		}
+
+		switch e.path {
+		case models.VersionEntryFile:
+			archive.HTMLContent = string(data)
+		case models.VersionManifestFile:
+			var manifest models.VersionManifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("%w: malformed %s", ErrArchiveInvalid, models.VersionManifestFile)
+			}
+			archive.Manifest = &manifest
+		default:
+			archive.Files = append(archive.Files, ArchiveFile{
+				Path:        e.path,
+				ContentType: AssetContentType(e.path, ""),
+				Data:        data,
+			})
+		}
+	}
+
+	if strings.TrimSpace(archive.HTMLContent) == "" {
+		return nil, ErrArchiveMissingEntry
+	}
+	return archive, nil
+}
+
+// Write 将版本写入压缩包：入口HTML、元数据和资源文件，资源文件按需通过 open 读取
+func (as *VersionArchiveService) Write(w io.Writer, manifest *models.VersionManifest, htmlContent string, open func(asset models.ManifestAsset) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+
+	writeFile := func(name string, content io.Reader) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: manifest.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, content)
+		return err
+	}
+
+	if err := writeFile(models.VersionEntryFile, strings.NewReader(htmlContent)); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(models.VersionManifestFile, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	for _, asset := range manifest.Assets {
+		reader, err := open(asset)
+		if err != nil {
+			return fmt.Errorf("failed to read asset %s: %w", asset.Path, err)
+		}
+		err = writeFile(asset.Path, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// AssetContentType 优先按扩展名确定资源文件类型，声明的类型作为备选
+func AssetContentType(assetPath, declared string) string {
+	if contentType := mime.TypeByExtension(path.Ext(assetPath)); contentType != "" {
+		return contentType
+	}
+	if declared != "" {
+		return declared
+	}
+	return "application/octet-stream"
+}
+
+// readArchiveFile 读取压缩包中的文件，实际解压大小超过单个资源文件限制时返回错误
+func readArchiveFile(file *zip.File) ([]byte, error) {
+	if file.UncompressedSize64 > models.MaxVersionAssetSize {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrArchiveTooLarge, file.Name, models.MaxVersionAssetSize)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrArchiveInvalid, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, models.MaxVersionAssetSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrArchiveInvalid, err)
+	}
+	if len(data) > models.MaxVersionAssetSize {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrArchiveTooLarge, file.Name, models.MaxVersionAssetSize)
+	}
+	return data, nil
+}
+
+// isArchiveJunk 系统生成的无关文件，如 macOS 的 __MACOSX 目录和 .DS_Store
+func isArchiveJunk(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "__MACOSX" || segment == ".DS_Store" || segment == "Thumbs.db" {
+			return true
+		}
+	}
+	return false
+}
+
+// commonArchiveRoot 所有文件都在同一个顶层目录下时返回该目录前缀
+func commonArchiveRoot(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	root := ""
+	for _, p := range paths {
+		i := strings.Index(p, "/")
+		if i < 0 {
+			return ""
+		}
+		if root == "" {
+			root = p[:i+1]
+		} else if p[:i+1] != root {
+			return ""
+		}
+	}
+	return root
+}
+
+// 全局版本压缩包服务实例
+var VersionArchiveSvc = NewVersionArchiveService()
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PreviewTokenTTL 预览令牌的有效期。令牌会写入预览页面的资源地址中，页面脚本可以读取，只授予短时间的只读访问
+const PreviewTokenTTL = 15 * time.Minute
+
+// 生成预览令牌：作品ID、版本ID和过期时间 + HMAC签名，只能用于预览指定版本
+func SignPreviewToken(portfolioID, versionID string, expiresAt time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(portfolioID + ":" + versionID + ":" + strconv.FormatInt(expiresAt.Unix(), 10)))
+	return payload + "." + previewSignature(payload)
+}
+
+// 验证预览令牌是否签发给指定作品版本且未过期
+func VerifyPreviewToken(token, portfolioID, versionID string) error {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return errors.New("invalid preview token")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(previewSignature(payload))) {
+		return errors.New("invalid preview token signature")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return errors.New("invalid preview token")
+	}
+	parts := strings.Split(string(decoded), ":")
+	if len(parts) != 3 || parts[0] != portfolioID || parts[1] != versionID {
+		return errors.New("preview token does not match the version")
+	}
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() >= expiresAt {
+		return errors.New("preview token has expired")
+	}
+	return nil
+}
+
+func previewSignature(payload string) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("preview:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
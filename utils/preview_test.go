@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyPreviewToken(t *testing.T) {
+	valid := SignPreviewToken("p1", "v1", time.Now().Add(time.Minute))
+	tests := []struct {
+		name        string
+		token       string
+		portfolioID string
+		versionID   string
+		ok          bool
+	}{
+		{"valid", valid, "p1", "v1", true},
+		{"other version", valid, "p1", "v2", false},
+		{"other portfolio", valid, "p2", "v1", false},
+		{"expired", SignPreviewToken("p1", "v1", time.Now().Add(-time.Second)), "p1", "v1", false},
+		{"tampered signature", valid + "x", "p1", "v1", false},
+		{"share token", SignShareToken("p1"), "p1", "v1", false},
+		{"no signature", "cDE6djE6OTk5OTk5OTk5OQ", "p1", "v1", false},
+		{"empty", "", "p1", "v1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyPreviewToken(tt.token, tt.portfolioID, tt.versionID)
+			if (err == nil) != tt.ok {
+				t.Errorf("VerifyPreviewToken() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 版本号递增方式
+const (
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+)
+
+// DefaultBranch 默认分支名
+const DefaultBranch = "main"
+
+var branchNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+
+// ValidBranchName 分支名只能包含小写字母、数字和连字符，且不能以连字符开头
+func ValidBranchName(branch string) bool {
+	return branchNameRegex.MatchString(branch)
+}
+
+// ParseSemver 解析版本号，支持 v1、v1.2、1.2.3、v1.2.3-branch 等形式，
+// 返回主版本号、次版本号和修订号
+func ParseSemver(version string) (int, int, int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if core, _, found := strings.Cut(version, "-"); found {
+		version = core
+	}
+	if version == "" {
+		return 0, 0, 0, false
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return 0, 0, 0, false
+	}
+	numbers := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, 0, 0, false
+		}
+		numbers[i] = n
+	}
+	return numbers[0], numbers[1], numbers[2], true
+}
+
+// FormatVersion 生成版本号，默认分支为 vX.Y.Z，其他分支为 vX.Y.Z-branch
+func FormatVersion(major, minor, patch int, branch string) string {
+	version := fmt.Sprintf("v%d.%d.%d", major, minor, patch)
+	if branch != "" && branch != DefaultBranch {
+		version += "-" + branch
+	}
+	return version
+}
+
+// BumpVersion 按指定方式递增版本号，未知方式按次版本号递增
+func BumpVersion(major, minor, patch int, bump string) (int, int, int) {
+	switch bump {
+	case BumpMajor:
+		return major + 1, 0, 0
+	case BumpPatch:
+		return major, minor, patch + 1
+	default:
+		return major, minor + 1, 0
+	}
+}
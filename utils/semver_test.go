@@ -0,0 +1,100 @@
+package utils
+
+import "testing"
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		input               string
+		major, minor, patch int
+		ok                  bool
+	}{
+		{"v1", 1, 0, 0, true},
+		{"v1.2", 1, 2, 0, true},
+		{"1.2.3", 1, 2, 3, true},
+		{"v1.2.3", 1, 2, 3, true},
+		{" v2.0.1 ", 2, 0, 1, true},
+		{"v1.2.3-dark-mode", 1, 2, 3, true},
+		{"v10.20.30", 10, 20, 30, true},
+		{"", 0, 0, 0, false},
+		{"v", 0, 0, 0, false},
+		{"-main", 0, 0, 0, false},
+		{"v1.2.3.4", 0, 0, 0, false},
+		{"v1..2", 0, 0, 0, false},
+		{"v1.x", 0, 0, 0, false},
+		{"v-1.0", 0, 0, 0, false},
+		{"draft", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			major, minor, patch, ok := ParseSemver(tt.input)
+			if ok != tt.ok || major != tt.major || minor != tt.minor || patch != tt.patch {
+				t.Errorf("ParseSemver(%q) = %d, %d, %d, %v, want %d, %d, %d, %v",
+					tt.input, major, minor, patch, ok, tt.major, tt.minor, tt.patch, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	tests := []struct {
+		major, minor, patch int
+		branch              string
+		want                string
+	}{
+		{1, 2, 3, "", "v1.2.3"},
+		{1, 2, 3, DefaultBranch, "v1.2.3"},
+		{1, 2, 3, "dark-mode", "v1.2.3-dark-mode"},
+	}
+	for _, tt := range tests {
+		got := FormatVersion(tt.major, tt.minor, tt.patch, tt.branch)
+		if got != tt.want {
+			t.Errorf("FormatVersion(%d, %d, %d, %q) = %q, want %q", tt.major, tt.minor, tt.patch, tt.branch, got, tt.want)
+		}
+		// 格式化结果应能解析回相同的版本号
+		if major, minor, patch, ok := ParseSemver(got); !ok || major != tt.major || minor != tt.minor || patch != tt.patch {
+			t.Errorf("ParseSemver(%q) did not round-trip", got)
+		}
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		bump                string
+		major, minor, patch int
+	}{
+		{BumpMajor, 2, 0, 0},
+		{BumpMinor, 1, 3, 0},
+		{BumpPatch, 1, 2, 4},
+		{"", 1, 3, 0},
+		{"unknown", 1, 3, 0},
+	}
+	for _, tt := range tests {
+		major, minor, patch := BumpVersion(1, 2, 3, tt.bump)
+		if major != tt.major || minor != tt.minor || patch != tt.patch {
+			t.Errorf("BumpVersion(1, 2, 3, %q) = %d.%d.%d, want %d.%d.%d", tt.bump, major, minor, patch, tt.major, tt.minor, tt.patch)
+		}
+	}
+}
+
+func TestValidBranchName(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   bool
+	}{
+		{"main", true},
+		{"dark-mode", true},
+		{"v2", true},
+		{"", false},
+		{"-dark", false},
+		{"Dark", false},
+		{"dark_mode", false},
+		{"dark/mode", false},
+		{"a123456789a123456789a123456789a123456789a123456789", true},
+		{"a123456789a123456789a123456789a123456789a123456789x", false},
+	}
+	for _, tt := range tests {
+		if got := ValidBranchName(tt.branch); got != tt.want {
+			t.Errorf("ValidBranchName(%q) = %v, want %v", tt.branch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// 生成分享链接令牌：链接ID + HMAC签名，防止伪造和枚举
+func SignShareToken(linkID string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(linkID))
+	return payload + "." + shareSignature(payload)
+}
+
+// 验证分享链接令牌并返回链接ID
+func VerifyShareToken(token string) (string, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", errors.New("invalid share token")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(shareSignature(payload))) {
+		return "", errors.New("invalid share token signature")
+	}
+
+	linkID, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.New("invalid share token")
+	}
+	return string(linkID), nil
+}
+
+func shareSignature(payload string) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("share:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}